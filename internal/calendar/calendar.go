@@ -0,0 +1,297 @@
+// Package calendar renders the current month as a wallpaper: a grid of its
+// days with today highlighted, the days with events marked and the events
+// still to come listed, over a background image. The render is cached per
+// day, so it changes when the date does.
+package calendar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/overlay"
+	"github.com/lucasassuncao/gopaper/internal/process"
+)
+
+// MaxAgenda is the number of upcoming events listed under the month.
+const MaxAgenda = 5
+
+// Style is how the calendar is drawn.
+type Style struct {
+	WeekStart time.Weekday
+	Color     color.NRGBA // text
+	Accent    color.NRGBA // today's circle and the event markers
+}
+
+// String returns the style in a canonical form, used in cache keys.
+func (s Style) String() string {
+	return fmt.Sprintf("%d/%02x%02x%02x%02x/%02x%02x%02x%02x", s.WeekStart, s.Color.R, s.Color.G, s.Color.B, s.Color.A, s.Accent.R, s.Accent.G, s.Accent.B, s.Accent.A)
+}
+
+// FromConfig returns the style a calendar configures: the defaults are
+// weeks starting on Monday, white text and an orange accent.
+func FromConfig(c models.Calendar) (Style, error) {
+	st := Style{WeekStart: time.Monday, Color: color.NRGBA{255, 255, 255, 255}, Accent: color.NRGBA{231, 111, 81, 255}}
+	switch c.WeekStart {
+	case "", "monday":
+	case "sunday":
+		st.WeekStart = time.Sunday
+	default:
+		return Style{}, fmt.Errorf("unknown week-start %q: use monday or sunday", c.WeekStart)
+	}
+	for _, f := range []struct {
+		value string
+		dst   *color.NRGBA
+	}{{c.Color, &st.Color}, {c.Accent, &st.Accent}} {
+		if f.value == "" {
+			continue
+		}
+		col, err := overlay.ParseColor(f.value)
+		if err != nil {
+			return Style{}, err
+		}
+		*f.dst = col
+	}
+	return st, nil
+}
+
+// Month is what a calendar shows for a given day: that day's month, the
+// days of it with an event, and the events from that day on.
+type Month struct {
+	Today  time.Time // midnight
+	Marked []int     // days of the month with an event, ascending
+	Agenda []string  // up to MaxAgenda upcoming events, one line each
+}
+
+// MonthOf returns the month around now, with events placed on it.
+func MonthOf(now time.Time, events []Event) Month {
+	today := midnight(now)
+	first := today.AddDate(0, 0, 1-today.Day())
+	next := first.AddDate(0, 1, 0)
+	m := Month{Today: today}
+
+	type upcoming struct {
+		start time.Time
+		line  string
+	}
+	var (
+		agenda []upcoming
+		marked = map[int]bool{}
+	)
+	for _, e := range events {
+		length := e.End.Sub(e.Start)
+		for _, start := range e.Occurrences(first, next) {
+			end, day := start.Add(length), midnight(start)
+			for d := day; d.Before(next) && (d.Equal(day) || d.Before(end)); d = d.AddDate(0, 0, 1) {
+				if !d.Before(first) {
+					marked[d.Day()] = true
+				}
+			}
+			if end.After(today) || (length == 0 && !start.Before(today)) {
+				shown := start
+				if shown.Before(today) {
+					shown = today
+				}
+				line := shown.Format("Mon 2")
+				if !e.AllDay {
+					line += "  " + start.Format("15:04")
+				}
+				agenda = append(agenda, upcoming{start, line + "  " + e.Summary})
+			}
+		}
+	}
+	slices.SortStableFunc(agenda, func(a, b upcoming) int { return a.start.Compare(b.start) })
+	for _, a := range agenda[:min(len(agenda), MaxAgenda)] {
+		m.Agenda = append(m.Agenda, a.line)
+	}
+	for d := range marked {
+		m.Marked = append(m.Marked, d)
+	}
+	slices.Sort(m.Marked)
+	return m
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// String returns the month in a canonical form, used in cache keys.
+func (m Month) String() string {
+	days := make([]string, len(m.Marked))
+	for i, d := range m.Marked {
+		days[i] = strconv.Itoa(d)
+	}
+	return m.Today.Format(time.DateOnly) + "\x00" + strings.Join(days, ",") + "\x00" + strings.Join(m.Agenda, "\x00")
+}
+
+// Apply renders m over the image at background (a plain dark background
+// when empty) at size into cacheDir and returns the render's path. Renders
+// are keyed by the background's path, size and modification time, the
+// month, the style and the size, so a new one is drawn when the date or the
+// events change.
+func Apply(background string, m Month, st Style, size image.Point, cacheDir string) (string, error) {
+	if size.X <= 0 || size.Y <= 0 {
+		return "", fmt.Errorf("invalid size %v", size)
+	}
+	parts := []string{background, m.String(), st.String(), size.String()}
+	if background != "" {
+		info, err := os.Stat(background)
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %w", background, err)
+		}
+		parts = append(parts, strconv.FormatInt(info.Size(), 10), strconv.FormatInt(info.ModTime().UnixNano(), 10))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	key := hex.EncodeToString(sum[:8])
+	if cached, ok := imagetype.Cached(cacheDir, key); ok {
+		return cached, nil
+	}
+
+	var bg image.Image
+	if background != "" {
+		img, err := imagetype.Decode(background)
+		if err != nil {
+			return "", err
+		}
+		bg = img
+	}
+	img, err := Render(bg, m, st, size)
+	if err != nil {
+		return "", err
+	}
+	return imagetype.Store(img, cacheDir, key)
+}
+
+// Render draws m on a dimmed panel in the middle of bg, scaled and cropped
+// to cover size (a plain dark background when bg is nil).
+func Render(bg image.Image, m Month, st Style, size image.Point) (*image.RGBA, error) {
+	canvas := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	if bg != nil {
+		draw.Draw(canvas, canvas.Bounds(), process.Cover(bg, size), image.Point{}, draw.Src)
+	} else {
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.NRGBA{20, 24, 31, 255}), image.Point{}, draw.Src)
+	}
+
+	first := m.Today.AddDate(0, 0, 1-m.Today.Day())
+	days := first.AddDate(0, 1, -1).Day()
+	offset := (int(first.Weekday()) - int(st.WeekStart) + 7) % 7
+	weeks := (offset + days + 6) / 7
+
+	// Everything is laid out in cells: the title and the weekday header
+	// take one cell's height each, every week a cell, every agenda line
+	// half a cell.
+	cell := min(float64(size.X)*0.45/7, float64(size.Y)*0.7/(float64(weeks)+2+float64(len(m.Agenda))/2+1))
+	pad := cell / 2
+	height := 2*pad + cell*float64(2+weeks)
+	if len(m.Agenda) > 0 {
+		height += cell/4 + cell/2*float64(len(m.Agenda))
+	}
+	width := 7*cell + 2*pad
+	panel := image.Rect(0, 0, int(width), int(height)).Add(image.Pt((size.X-int(width))/2, (size.Y-int(height))/2))
+	draw.Draw(canvas, panel, image.NewUniform(color.NRGBA{0, 0, 0, 150}), image.Point{}, draw.Over)
+
+	left, top := float64(panel.Min.X)+pad, float64(panel.Min.Y)+pad
+	dim := st.Color
+	dim.A = uint8(int(dim.A) * 170 / 255)
+
+	title, err := overlay.Face(cell * 0.5)
+	if err != nil {
+		return nil, err
+	}
+	defer title.Close()
+	small, err := overlay.Face(cell * 0.3)
+	if err != nil {
+		return nil, err
+	}
+	defer small.Close()
+	number, err := overlay.Face(cell * 0.38)
+	if err != nil {
+		return nil, err
+	}
+	defer number.Close()
+
+	centered(canvas, title, m.Today.Format("January 2006"), left+3.5*cell, top+cell/2, st.Color)
+	for i := range 7 {
+		name := time.Weekday((int(st.WeekStart) + i) % 7).String()[:2]
+		centered(canvas, small, name, left+(float64(i)+0.5)*cell, top+1.5*cell, dim)
+	}
+	for day := 1; day <= days; day++ {
+		slot := offset + day - 1
+		cx := left + (float64(slot%7)+0.5)*cell
+		cy := top + (float64(2+slot/7)+0.5)*cell
+		marker := st.Accent
+		if day == m.Today.Day() {
+			disc(canvas, cx, cy, cell*0.4, st.Accent)
+			marker = st.Color
+		}
+		centered(canvas, number, strconv.Itoa(day), cx, cy, st.Color)
+		if _, ok := slices.BinarySearch(m.Marked, day); ok {
+			disc(canvas, cx, cy+cell*0.3, cell*0.05, marker)
+		}
+	}
+	y := top + cell*float64(2+weeks) + cell/4
+	for _, line := range m.Agenda {
+		d := font.Drawer{Dst: canvas, Src: image.NewUniform(st.Color), Face: small}
+		line = fit(small, line, 7*cell)
+		d.Dot = fixed.P(int(left), int(y+cell/4)+small.Metrics().Ascent.Ceil()/2)
+		d.DrawString(line)
+		y += cell / 2
+	}
+	return canvas, nil
+}
+
+// centered draws s with its middle at x, y.
+func centered(img draw.Image, face font.Face, s string, x, y float64, c color.NRGBA) {
+	w := font.MeasureString(face, s).Ceil()
+	m := face.Metrics()
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face}
+	d.Dot = fixed.P(int(x)-w/2, int(y)+(m.Ascent.Ceil()-m.Descent.Ceil())/2)
+	d.DrawString(s)
+}
+
+// fit shortens s with an ellipsis until it is at most width pixels wide.
+func fit(face font.Face, s string, width float64) string {
+	if font.MeasureString(face, s).Ceil() <= int(width) {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && font.MeasureString(face, string(r)+"…").Ceil() > int(width) {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
+
+// disc fills a circle of radius r around x, y.
+func disc(img draw.Image, x, y, r float64, c color.NRGBA) {
+	bounds := image.Rect(int(x-r), int(y-r), int(x+r)+1, int(y+r)+1)
+	draw.DrawMask(img, bounds, image.NewUniform(c), image.Point{}, circle{x, y, r}, bounds.Min, draw.Over)
+}
+
+// circle is the mask of a disc, antialiased over one pixel.
+type circle struct{ x, y, r float64 }
+
+func (c circle) ColorModel() color.Model { return color.AlphaModel }
+
+func (c circle) Bounds() image.Rectangle {
+	return image.Rect(int(c.x-c.r)-1, int(c.y-c.r)-1, int(c.x+c.r)+2, int(c.y+c.r)+2)
+}
+
+func (c circle) At(x, y int) color.Color {
+	dx, dy := float64(x)+0.5-c.x, float64(y)+0.5-c.y
+	d := c.r - math.Hypot(dx, dy)
+	return color.Alpha{uint8(min(max(d+0.5, 0), 1) * 255)}
+}
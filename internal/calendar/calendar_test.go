@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/models"
+)
+
+func TestFromConfig(t *testing.T) {
+	st, err := FromConfig(models.Calendar{WeekStart: "sunday", Accent: "#112233"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.WeekStart != time.Sunday || st.Accent != (color.NRGBA{0x11, 0x22, 0x33, 255}) || st.Color != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("FromConfig() = %+v", st)
+	}
+	for _, bad := range []models.Calendar{{WeekStart: "friday"}, {Color: "white"}} {
+		if _, err := FromConfig(bad); err == nil {
+			t.Errorf("FromConfig(%+v): expected an error", bad)
+		}
+	}
+}
+
+func TestMonthOf(t *testing.T) {
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	at := func(m, d, h int) time.Time { return time.Date(2026, time.Month(m), d, h, 0, 0, 0, time.UTC) }
+	events := []Event{
+		{Summary: "Past", Start: at(10, 2, 10), End: at(10, 2, 11)},
+		{Summary: "Trip", Start: at(10, 18, 0), End: at(10, 21, 0), AllDay: true},
+		{Summary: "Dentist", Start: at(10, 22, 9), End: at(10, 22, 10)},
+		{Summary: "Next month", Start: at(11, 3, 9), End: at(11, 3, 10)},
+	}
+	m := MonthOf(now, events)
+	if !m.Today.Equal(at(10, 19, 0)) {
+		t.Errorf("Today = %v", m.Today)
+	}
+	if want := []int{2, 18, 19, 20, 22}; !slices.Equal(m.Marked, want) {
+		t.Errorf("Marked = %v, want %v", m.Marked, want)
+	}
+	if want := []string{"Mon 19  Trip", "Thu 22  09:00  Dentist"}; !slices.Equal(m.Agenda, want) {
+		t.Errorf("Agenda = %q, want %q", m.Agenda, want)
+	}
+}
+
+func TestRenderHighlightsToday(t *testing.T) {
+	st := Style{WeekStart: time.Monday, Color: color.NRGBA{255, 255, 255, 255}, Accent: color.NRGBA{255, 0, 0, 255}}
+	size := image.Pt(640, 360)
+	accented := func(img *image.RGBA) int {
+		n := 0
+		for i := 0; i < len(img.Pix); i += 4 {
+			if img.Pix[i] == 255 && img.Pix[i+1] == 0 && img.Pix[i+2] == 0 {
+				n++
+			}
+		}
+		return n
+	}
+	plain, err := Render(nil, Month{Today: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)}, st, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accented(plain) == 0 {
+		t.Error("today is not highlighted")
+	}
+	marked, err := Render(nil, Month{Today: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), Marked: []int{3, 25}}, st, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accented(marked) <= accented(plain) {
+		t.Error("days with events are not marked")
+	}
+}
+
+func TestApplyRendersOncePerDay(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "calendar")
+	st, _ := FromConfig(models.Calendar{})
+	size := image.Pt(160, 90)
+	today := MonthOf(time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC), nil)
+
+	first, err := Apply("", today, st, size, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Apply("", MonthOf(time.Date(2026, 10, 19, 22, 0, 0, 0, time.UTC), nil), st, size, cache); again != first {
+		t.Errorf("the same day rendered to %q, then %q", first, again)
+	}
+	if tomorrow, _ := Apply("", MonthOf(time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC), nil), st, size, cache); tomorrow == first {
+		t.Error("the next day should render another calendar")
+	}
+	if _, err := Apply(filepath.Join(t.TempDir(), "missing.png"), today, st, size, cache); err == nil {
+		t.Error("expected an error for a missing background")
+	}
+}
@@ -0,0 +1,248 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is one calendar event read from an .ics file.
+type Event struct {
+	Summary string
+	Start   time.Time
+	End     time.Time // exclusive; the day after Start for a one-day all-day event
+	AllDay  bool
+	Repeat  *Rule // nil for a one-off event
+}
+
+// Rule is the subset of an RRULE gopaper understands: a frequency, an
+// interval and an end. BY* parts are ignored, so occurrences fall on the
+// start date's day, weekday or time.
+type Rule struct {
+	Freq     string // DAILY, WEEKLY, MONTHLY or YEARLY
+	Interval int
+	Count    int       // 0 for no limit
+	Until    time.Time // zero for no limit
+}
+
+// LoadICS reads the events of the .ics file at path; floating times (with
+// neither a UTC marker nor a TZID) are read in loc.
+func LoadICS(path string, loc *time.Location) ([]Event, error) {
+	f, err := os.Open(path) // #nosec G304 -- configured events file
+	if err != nil {
+		return nil, fmt.Errorf("could not read events file: %w", err)
+	}
+	defer f.Close()
+	events, err := ParseICS(f, loc)
+	if err != nil {
+		return nil, fmt.Errorf("could not read events file %s: %w", path, err)
+	}
+	return events, nil
+}
+
+// ParseICS reads the VEVENTs of an iCalendar stream. Events without a
+// DTSTART, and recurrence rules it doesn't understand, are skipped (the
+// latter leaving the event's first occurrence).
+func ParseICS(r io.Reader, loc *time.Location) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		events  []Event
+		current *Event
+		hasEnd  bool
+	)
+	for _, line := range lines {
+		name, params, value, ok := property(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			current, hasEnd = &Event{}, false
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if current != nil && !current.Start.IsZero() {
+				if !hasEnd || !current.End.After(current.Start) {
+					current.End = current.Start
+					if current.AllDay {
+						current.End = current.Start.AddDate(0, 0, 1)
+					}
+				}
+				events = append(events, *current)
+			}
+			current = nil
+		case current == nil:
+		case name == "SUMMARY":
+			current.Summary = unescape(value)
+		case name == "DTSTART":
+			t, allDay, err := parseTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("DTSTART: %w", err)
+			}
+			current.Start, current.AllDay = t, allDay
+		case name == "DTEND":
+			t, _, err := parseTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("DTEND: %w", err)
+			}
+			current.End, hasEnd = t, true
+		case name == "RRULE":
+			current.Repeat = parseRule(value, loc)
+		}
+	}
+	return events, nil
+}
+
+// unfold joins continuation lines (starting with a space or a tab) onto the
+// line before them.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, sc.Err()
+}
+
+// property splits a content line into its upper-cased name, its parameters
+// and its value.
+func property(line string) (name string, params map[string]string, value string, ok bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, "", false
+	}
+	fields := strings.Split(head, ";")
+	params = map[string]string{}
+	for _, p := range fields[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(fields[0]), params, value, true
+}
+
+// parseTime reads a DATE or DATE-TIME value: a date is an all-day time at
+// midnight in loc, a time ending in Z is UTC, a TZID names its zone (loc
+// when unknown) and anything else is floating, in loc.
+func parseTime(value string, params map[string]string, loc *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if v, ok := strings.CutSuffix(value, "Z"); ok {
+		t, err := time.ParseInLocation("20060102T150405", v, time.UTC)
+		return t.In(loc), false, err
+	}
+	zone := loc
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			zone = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, zone)
+	return t.In(loc), false, err
+}
+
+// parseRule reads an RRULE, or returns nil for a frequency it doesn't
+// support.
+func parseRule(value string, loc *time.Location) *Rule {
+	r := &Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(v)
+		case "INTERVAL":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				r.Interval = n
+			}
+		case "COUNT":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				r.Count = n
+			}
+		case "UNTIL":
+			if t, _, err := parseTime(v, nil, loc); err == nil {
+				r.Until = t
+			}
+		}
+	}
+	switch r.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+		return r
+	}
+	return nil
+}
+
+// unescape undoes iCalendar text escaping.
+func unescape(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// Occurrences returns the start of every occurrence of e that overlaps
+// [from, to), in order.
+func (e Event) Occurrences(from, to time.Time) []time.Time {
+	length := e.End.Sub(e.Start)
+	overlaps := func(start time.Time) bool {
+		end := start.Add(length)
+		if length == 0 {
+			return !start.Before(from) && start.Before(to)
+		}
+		return start.Before(to) && end.After(from)
+	}
+	if e.Repeat == nil {
+		if overlaps(e.Start) {
+			return []time.Time{e.Start}
+		}
+		return nil
+	}
+	var out []time.Time
+	// n counts the occurrences, i the candidates: a candidate falling on a
+	// day its month doesn't have is skipped and, as RFC 5545 requires, not
+	// counted against COUNT.
+	for i, n := 0, 0; e.Repeat.Count == 0 || n < e.Repeat.Count; i++ {
+		start, ok := e.Repeat.nth(e.Start, i)
+		if !start.Before(to) || (!e.Repeat.Until.IsZero() && start.After(e.Repeat.Until)) {
+			break
+		}
+		if !ok {
+			continue
+		}
+		n++
+		if overlaps(start) {
+			out = append(out, start)
+		}
+	}
+	return out
+}
+
+// nth returns the start of the nth candidate (0 is start itself), and false
+// when it doesn't exist: monthly and yearly repeats step from the start's
+// day, and a month without that day (the 31st, February 29th) has no
+// occurrence. The start returned then is where time.AddDate rolled it over
+// to, which still bounds the repeat.
+func (r *Rule) nth(start time.Time, n int) (time.Time, bool) {
+	k := n * r.Interval
+	switch r.Freq {
+	case "DAILY":
+		return start.AddDate(0, 0, k), true
+	case "WEEKLY":
+		return start.AddDate(0, 0, 7*k), true
+	case "MONTHLY":
+		t := start.AddDate(0, k, 0)
+		return t, t.Day() == start.Day()
+	default:
+		t := start.AddDate(k, 0, 0)
+		return t, t.Day() == start.Day()
+	}
+}
@@ -0,0 +1,107 @@
+package calendar
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sample = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20261031\r\n" +
+	"DTEND;VALUE=DATE:20261102\r\n" +
+	"SUMMARY:Long\r\n  weekend\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20261021T073000Z\r\n" +
+	"DTEND:20261021T083000Z\r\n" +
+	"SUMMARY:Dentist\\, downtown\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;TZID=America/New_York:20261005T090000\r\n" +
+	"SUMMARY:Standup\r\n" +
+	"RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:No start\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	events, err := ParseICS(strings.NewReader(sample), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(events), events)
+	}
+	long, dentist, standup := events[0], events[1], events[2]
+	if long.Summary != "Long weekend" || !long.AllDay || long.End.Sub(long.Start) != 48*time.Hour {
+		t.Errorf("all-day event = %+v", long)
+	}
+	if dentist.Summary != "Dentist, downtown" || dentist.AllDay || dentist.Start != time.Date(2026, 10, 21, 7, 30, 0, 0, time.UTC) {
+		t.Errorf("timed event = %+v", dentist)
+	}
+	if standup.Start != time.Date(2026, 10, 5, 13, 0, 0, 0, time.UTC) || standup.Repeat == nil || standup.Repeat.Interval != 2 {
+		t.Errorf("recurring event = %+v", standup)
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	start := time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC)
+	from, to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	weekly := Event{Start: start, End: start, Repeat: &Rule{Freq: "WEEKLY", Interval: 2, Count: 3}}
+	if got := weekly.Occurrences(from, to); len(got) != 2 || got[1].Day() != 19 {
+		t.Errorf("every other week, 3 times = %v", got)
+	}
+
+	birthday := Event{Start: time.Date(1990, 10, 12, 0, 0, 0, 0, time.UTC), AllDay: true, Repeat: &Rule{Freq: "YEARLY", Interval: 1}}
+	birthday.End = birthday.Start.AddDate(0, 0, 1)
+	if got := birthday.Occurrences(from, to); len(got) != 1 || got[0].Year() != 2026 || got[0].Day() != 12 {
+		t.Errorf("yearly = %v", got)
+	}
+
+	// Months without a 31st are skipped, not rolled over into the next.
+	endOfMonth := Event{Start: time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC), Repeat: &Rule{Freq: "MONTHLY", Interval: 1}}
+	endOfMonth.End = endOfMonth.Start.Add(time.Hour)
+	got := endOfMonth.Occurrences(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	var months []time.Month
+	for _, o := range got {
+		if o.Day() != 31 {
+			t.Errorf("monthly on the 31st fell on %v", o)
+		}
+		months = append(months, o.Month())
+	}
+	if want := []time.Month{1, 3, 5, 7, 8, 10, 12}; !slices.Equal(months, want) {
+		t.Errorf("monthly on the 31st = %v, want %v", months, want)
+	}
+
+	// Skipped months don't count against COUNT.
+	endOfMonth.Repeat.Count = 3
+	got = endOfMonth.Occurrences(time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC))
+	if len(got) != 1 || got[0].Day() != 31 {
+		t.Errorf("the third of 3 monthly occurrences on the 31st = %v, want May 31st", got)
+	}
+
+	leapDay := Event{Start: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), AllDay: true, Repeat: &Rule{Freq: "YEARLY", Interval: 1}}
+	leapDay.End = leapDay.Start.AddDate(0, 0, 1)
+	for year, want := range map[int]int{2027: 0, 2028: 1} {
+		march := time.Date(year, 3, 1, 0, 0, 0, 0, time.UTC)
+		got := leapDay.Occurrences(march.AddDate(0, -1, 0), march.AddDate(0, 1, 0))
+		if len(got) != want || (want == 1 && got[0].Day() != 29) {
+			t.Errorf("yearly on February 29th in February-March %d = %v, want %d", year, got, want)
+		}
+	}
+
+	until := Event{Start: start, End: start, Repeat: &Rule{Freq: "DAILY", Interval: 1, Until: start.AddDate(0, 0, 2)}}
+	if got := until.Occurrences(from, to); len(got) != 3 {
+		t.Errorf("daily until the 7th = %v", got)
+	}
+
+	spanning := Event{Start: time.Date(2026, 9, 29, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC), AllDay: true}
+	if got := spanning.Occurrences(from, to); len(got) != 1 {
+		t.Errorf("an event running into the month should overlap it, got %v", got)
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/calendar"
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/weather"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// pickCalendar picks a background from the category cat's calendar names
+// and draws the month around now over it, at the resolution of the 1-based
+// monitor (0 for the largest). It returns the calendar's path and the
+// background, if any. A background that can't be picked is logged and the
+// calendar drawn without one.
+func pickCalendar(g *models.Gopaper, cat *models.Categories, monitor int, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDirs map[*models.Categories]string, opts helper.PickOptions) (string, []string, error) {
+	var parts []string
+	if name := cat.Calendar.Background; name != "" {
+		c := findCategory(g.Categories, name)
+		switch {
+		case c == nil || c.Collage != nil || c.Calendar != nil:
+			g.Logger.Warn("skipping calendar background: not a category with images", g.Logger.Args("category", cat.Name, "background", name))
+		default:
+			bg, _, err := pickWallpaper(g, c, monitor, now, ws, conditions, map[*models.Categories]string{c: collageWallhavenDir(g.Viper, c, wallhavenDirs)}, opts)
+			if err != nil {
+				g.Logger.Warn("could not pick a calendar background, drawing without one", g.Logger.Args("category", cat.Name, "background", name, "error", err))
+			} else {
+				parts = []string{bg}
+			}
+		}
+	}
+	path, err := renderCalendar(g.Viper, g.Logger, cat.Calendar, parts, monitor, now)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, parts, nil
+}
+
+// renderCalendar draws the month around now, with c's events, over the
+// background in parts (none when empty) at the resolution of the 1-based
+// monitor, in the calendar cache, and returns the calendar's path. An
+// events file that can't be read is logged and left out.
+func renderCalendar(v *viper.Viper, log *pterm.Logger, c *models.Calendar, parts []string, monitor int, now time.Time) (string, error) {
+	st, err := calendar.FromConfig(*c)
+	if err != nil {
+		return "", fmt.Errorf("invalid calendar: %w", err)
+	}
+	var events []calendar.Event
+	if c.Events != "" {
+		if events, err = calendar.LoadICS(config.ExpandTilde(c.Events), now.Location()); err != nil {
+			log.Warn("could not read calendar events, drawing without them", log.Args("error", err))
+		}
+	}
+	size, err := monitorSize(monitor)
+	if err != nil {
+		log.Debug("could not determine the monitor size, using the default size", log.Args("size", defaultScreenSize.String(), "error", err))
+		size = defaultScreenSize
+	}
+	dir, err := config.CalendarCacheDir(v)
+	if err != nil {
+		return "", err
+	}
+	background := ""
+	if len(parts) > 0 {
+		background = displayPath(v, log, parts[0])
+	}
+	out, err := calendar.Apply(background, calendar.MonthOf(now, events), st, size, dir)
+	if err != nil {
+		return "", fmt.Errorf("could not draw the calendar: %w", err)
+	}
+	return out, nil
+}
+
+// historyRenderPath returns the image at path recorded in history for the
+// 1-based monitor (0 for every monitor), drawn again where it has to be: a
+// calendar for today, over the same background; a collage when its render
+// has been pruned (see historyCollagePath).
+func historyRenderPath(v *viper.Viper, category, path string, parts []string, monitor int) string {
+	cat := categoryByName(v, category)
+	if cat == nil || cat.Calendar == nil {
+		return historyCollagePath(v, category, path, parts)
+	}
+	if len(parts) > 0 {
+		if _, err := os.Stat(parts[0]); err != nil {
+			logger.Warn("calendar background no longer exists, drawing without it", logger.Args("category", category, "background", parts[0]))
+			parts = nil
+		}
+	}
+	out, err := renderCalendar(v, logger, cat.Calendar, parts, monitor, time.Now())
+	if err != nil {
+		logger.Warn("could not draw the calendar again", logger.Args("category", category, "error", err))
+		return path
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/collage"
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/weather"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// defaultCollageCount is the number of images in a collage without a count.
+const defaultCollageCount = 4
+
+// pickCollage picks up to cat's collage count of distinct images, each from
+// a random one of the categories the collage names (those with a source
+// active now), and renders them into a collage. It returns the collage's
+// path and the picked images, in layout order.
+func pickCollage(g *models.Gopaper, cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDirs map[*models.Categories]string, opts helper.PickOptions) (string, []string, error) {
+	var pool []*models.Categories
+	for _, name := range cat.Collage.From {
+		c := findCategory(g.Categories, name)
+		if c == nil || c.Collage != nil || c.Calendar != nil {
+			g.Logger.Warn("skipping collage source: not a category with images", g.Logger.Args("category", cat.Name, "from", name))
+			continue
+		}
+		if _, ok := helper.ResolveSources(c, now, ws, conditions, collageWallhavenDir(g.Viper, c, wallhavenDirs)); ok {
+			pool = append(pool, c)
+		}
+	}
+	if len(pool) == 0 {
+		return "", nil, fmt.Errorf("none of the collage's categories has a source active now")
+	}
+
+	count := cat.Collage.Count
+	if count <= 0 {
+		count = defaultCollageCount
+	}
+	var (
+		parts []string
+		seen  = map[string]bool{}
+	)
+	// Picks are random, so allow a few repeats before settling for fewer
+	// images than asked.
+	for attempt := 0; len(parts) < count && attempt < count*4; attempt++ {
+		c := helper.GetRandomCategory(pool)
+		path, _, err := pickWallpaper(g, c, 0, now, ws, conditions, map[*models.Categories]string{c: collageWallhavenDir(g.Viper, c, wallhavenDirs)}, opts)
+		if err != nil {
+			g.Logger.Debug("could not pick a collage image", g.Logger.Args("category", c.Name, "error", err))
+			continue
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		parts = append(parts, path)
+	}
+	if len(parts) == 0 {
+		return "", nil, errors.New("no image could be picked for the collage")
+	}
+	if len(parts) < count {
+		g.Logger.Info("fewer images available than the collage asks for", g.Logger.Args("category", cat.Name, "count", count, "picked", len(parts)))
+	}
+
+	path, err := renderCollage(g.Viper, g.Logger, cat.Collage, parts)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, parts, nil
+}
+
+// findCategory returns the category named name among categories, or nil.
+func findCategory(categories []*models.Categories, name string) *models.Categories {
+	for _, c := range categories {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// collageWallhavenDir returns the wallhaven cache directory of c: the one
+// refreshed for this run, or for a category not drawn from directly (e.g.
+// a disabled one only used by collages) its cache as-is.
+func collageWallhavenDir(v *viper.Viper, c *models.Categories, wallhavenDirs map[*models.Categories]string) string {
+	if c.Wallhaven == nil {
+		return ""
+	}
+	if dir, ok := wallhavenDirs[c]; ok {
+		return dir
+	}
+	dir, err := config.WallhavenCacheDir(v, c.Name, c.Wallhaven.Cache)
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// renderCollage lays parts out as c asks on a canvas the size of the
+// primary monitor, in the collage cache, and returns the collage's path.
+func renderCollage(v *viper.Viper, log *pterm.Logger, c *models.Collage, parts []string) (string, error) {
+	size, err := primaryMonitorSize()
+	if err != nil {
+		log.Debug("could not determine the primary monitor's size, using the default size", log.Args("size", defaultScreenSize.String(), "error", err))
+		size = defaultScreenSize
+	}
+	dir, err := config.CollageCacheDir(v)
+	if err != nil {
+		return "", err
+	}
+	display := make([]string, len(parts))
+	for i, p := range parts {
+		display[i] = displayPath(v, log, p)
+	}
+	out, err := collage.Apply(display, c.Layout, size, c.Gap, dir)
+	if err != nil {
+		return "", fmt.Errorf("could not render the collage: %w", err)
+	}
+	return out, nil
+}
+
+// historyCollagePath returns the collage at path recorded in history, or
+// renders parts again with the named category's current collage settings
+// when the cached render has since been pruned. Anything else is returned
+// unchanged.
+func historyCollagePath(v *viper.Viper, category, path string, parts []string) string {
+	if len(parts) == 0 {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	c := &models.Collage{}
+	if cat := categoryByName(v, category); cat != nil && cat.Collage != nil {
+		c = cat.Collage
+	}
+	out, err := renderCollage(v, logger, c, parts)
+	if err != nil {
+		logger.Warn("could not render the collage again", logger.Args("category", category, "error", err))
+		return path
+	}
+	return out
+}
@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/ignore"
+	"github.com/lucasassuncao/gopaper/internal/imghash"
+	"github.com/lucasassuncao/gopaper/internal/metacache"
+	"github.com/lucasassuncao/gopaper/internal/models"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Dedupe actions.
+const (
+	dedupeReport     = "report"
+	dedupeQuarantine = "quarantine"
+	dedupeIgnore     = "ignore"
+)
+
+// DedupeCmd finds near-duplicate images across the categories' sources.
+func DedupeCmd() *cobra.Command {
+	var (
+		configPath   string
+		categoryFlag string
+		algorithm    string
+		threshold    int
+		action       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Find near-duplicate images across categories",
+		Long: `Hash every image in the source directories of every category (or the ones
+named with --category) and report groups of near-duplicates: the same
+picture in several folders, at other resolutions or recompressed.
+
+In each group the image with the most pixels (then the largest file) is
+kept and the others are duplicates. --action decides what happens to them:
+"report" only lists them, "quarantine" moves them to the quarantine
+directory (configuration.dedupe.quarantine), and "ignore" adds them to the
+.gopaperignore file of their directory so no category picks them.
+
+Hashes are cached in the metadata cache, so only new or changed images
+are decoded on later runs.`,
+		Example: `  # List near-duplicates with the configured algorithm and threshold
+  gopaper dedupe
+
+  # Stricter matching, then move duplicates out of the library
+  gopaper dedupe --threshold 4 --action quarantine`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g := &models.Gopaper{Viper: viper.New()}
+			if err := preRunHandler(g, configPath); err != nil {
+				return err
+			}
+			opts, err := config.DedupeOptions(g.Viper)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("algorithm") {
+				if opts.Algorithm, err = imghash.ParseAlgorithm(algorithm); err != nil {
+					return err
+				}
+			}
+			if cmd.Flags().Changed("threshold") {
+				if threshold < 0 || threshold > 64 {
+					return fmt.Errorf("invalid --threshold %d: must be between 0 and 64", threshold)
+				}
+				opts.Threshold = threshold
+			}
+			switch action {
+			case dedupeReport, dedupeQuarantine, dedupeIgnore:
+			default:
+				return fmt.Errorf("invalid --action %q: use report, quarantine or ignore", action)
+			}
+			return runDedupe(g, categoryFlag, opts, action)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	cmd.Flags().StringVar(&categoryFlag, "category", "", "Comma-separated category names to scan (default: all categories)")
+	cmd.Flags().StringVar(&algorithm, "algorithm", "", "Hash to compare, phash or dhash (default: configuration.dedupe.algorithm)")
+	cmd.Flags().IntVar(&threshold, "threshold", 0, "Largest number of differing hash bits, 0-64 (default: configuration.dedupe.threshold)")
+	cmd.Flags().StringVar(&action, "action", dedupeReport, "What to do with duplicates: report, quarantine or ignore")
+	return cmd
+}
+
+// hashedImage is one scanned image.
+type hashedImage struct {
+	path   string
+	hashes imghash.Hashes
+	pixels int
+	size   int64
+	width  int
+	height int
+}
+
+// runDedupe scans the selected categories, groups near-duplicates, applies
+// action to the duplicates and prints a table of every group. Unreadable
+// directories and images that can't be decoded are reported and skipped.
+func runDedupe(g *models.Gopaper, categoryFlag string, opts imghash.Options, action string) error {
+	all, err := config.UnmarshalConfig(g)
+	if err != nil {
+		return err
+	}
+	categories, err := FilterCategories(all, ParseCategoryNames(categoryFlag), true, g.Logger)
+	if err != nil {
+		return err
+	}
+
+	paths := dedupeCandidates(g, categories)
+	if len(paths) == 0 {
+		return fmt.Errorf("no images to compare")
+	}
+
+	var cache *metacache.Cache
+	if p, err := config.MetadataCachePath(g.Viper); err == nil {
+		cache = metacache.Open(p)
+	}
+	images := hashImages(g, paths, cache)
+	if err := cache.Save(); err != nil {
+		g.Logger.Warn("could not save the metadata cache", g.Logger.Args("error", err))
+	}
+
+	hashes := make([]imghash.Hashes, len(images))
+	for i, img := range images {
+		hashes[i] = img.hashes
+	}
+	groups := imghash.Group(hashes, opts)
+	if len(groups) == 0 {
+		pterm.Success.Printfln("No near-duplicates among %d image(s)", len(images))
+		return nil
+	}
+
+	var quarantine string
+	if action == dedupeQuarantine {
+		if quarantine, err = config.QuarantineDir(g.Viper); err != nil {
+			return err
+		}
+	}
+
+	table := pterm.TableData{{"Group", "File", "Resolution", "Size", "Distance", "Result"}}
+	duplicates, failed := 0, 0
+	for n, group := range groups {
+		keep := group[0]
+		for _, i := range group[1:] {
+			a, b := images[i], images[keep]
+			if a.pixels > b.pixels || (a.pixels == b.pixels && a.size > b.size) {
+				keep = i
+			}
+		}
+		for _, i := range group {
+			img := images[i]
+			result, distance := "keep", "-"
+			if i != keep {
+				duplicates++
+				distance = strconv.Itoa(opts.Distance(img.hashes, images[keep].hashes))
+				result, err = applyDedupeAction(img.path, action, quarantine)
+				if err != nil {
+					g.Logger.Warn("could not handle duplicate", g.Logger.Args("file", img.path, "action", action, "error", err))
+					result = "failed: " + err.Error()
+					failed++
+				}
+			}
+			table = append(table, []string{
+				strconv.Itoa(n + 1), img.path, fmt.Sprintf("%dx%d", img.width, img.height),
+				formatBytes(img.size), distance, result,
+			})
+		}
+	}
+
+	if err := pterm.DefaultTable.WithHasHeader().WithData(table).Render(); err != nil {
+		return err
+	}
+	pterm.Info.Printfln("%d group(s), %d duplicate(s) among %d image(s) (%s, threshold %d)",
+		len(groups), duplicates, len(images), opts.Algorithm, opts.Threshold)
+	if failed > 0 {
+		return fmt.Errorf("could not %s %d duplicate(s)", action, failed)
+	}
+	return nil
+}
+
+// dedupeCandidates returns the absolute paths of every image in the
+// categories' source directories (every variant's, and wallhaven caches),
+// each once even when several categories share a directory.
+func dedupeCandidates(g *models.Gopaper, categories []*models.Categories) []string {
+	opts := helper.PickOptions{Index: indexStore(g), Detection: config.Detection(g.Viper)}
+	seen := map[string]bool{}
+	var paths []string
+	for _, c := range categories {
+		dirs := helper.AllSourceDirs(c)
+		if c.Wallhaven != nil {
+			dir, err := config.WallhavenCacheDir(g.Viper, c.Name, c.Wallhaven.Cache)
+			if err != nil {
+				g.Logger.Warn("could not resolve wallhaven cache directory, skipping category", g.Logger.Args("category", c.Name, "error", err))
+				continue
+			}
+			dirs = []models.SourceDir{{Path: dir}}
+		}
+		for _, d := range dirs {
+			files, err := helper.EligibleFiles(c, config.ExpandTilde(d.Path), opts)
+			if err != nil {
+				g.Logger.Warn("could not read directory", g.Logger.Args("category", c.Name, "error", err))
+				continue
+			}
+			for _, f := range files {
+				if abs, err := filepath.Abs(f); err == nil {
+					f = abs
+				}
+				if !seen[f] {
+					seen[f] = true
+					paths = append(paths, f)
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// hashImages hashes paths in parallel through cache, in paths' order,
+// leaving out the images that can't be read or decoded.
+func hashImages(g *models.Gopaper, paths []string, cache *metacache.Cache) []hashedImage {
+	results := make([]*hashedImage, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				path := paths[i]
+				info, err := os.Stat(path)
+				if err != nil {
+					g.Logger.Warn("could not read image", g.Logger.Args("file", path, "error", err))
+					continue
+				}
+				hashes, err := cache.Hashes(path, info)
+				if err != nil {
+					g.Logger.Warn("could not hash image, skipping it", g.Logger.Args("file", path, "error", err))
+					continue
+				}
+				w, h, _ := cache.Dimensions(path, info)
+				results[i] = &hashedImage{path: path, hashes: hashes, pixels: w * h, size: info.Size(), width: w, height: h}
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	images := make([]hashedImage, 0, len(results))
+	for _, r := range results {
+		if r != nil {
+			images = append(images, *r)
+		}
+	}
+	return images
+}
+
+// applyDedupeAction handles one duplicate and returns the table's result
+// column for it.
+func applyDedupeAction(path, action, quarantine string) (string, error) {
+	switch action {
+	case dedupeQuarantine:
+		dst, err := moveToDir(path, quarantine)
+		if err != nil {
+			return "", err
+		}
+		return "moved to " + dst, nil
+	case dedupeIgnore:
+		if err := appendIgnore(path); err != nil {
+			return "", err
+		}
+		return "ignored", nil
+	}
+	return "duplicate", nil
+}
+
+// appendIgnore adds a rule matching exactly path's file name to the
+// .gopaperignore file of its directory.
+func appendIgnore(path string) error {
+	ignoreFile := filepath.Join(filepath.Dir(path), ignore.FileName)
+	existing, err := os.ReadFile(ignoreFile) // #nosec G304 -- inside a configured category source
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	line := "/" + ignore.Escape(filepath.Base(path)) + "\n"
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		line = "\n" + line
+	}
+	f, err := os.OpenFile(ignoreFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// moveToDir moves the file at path into dir, numbering its name when dir
+// already holds one by that name, and returns the new path. A move across
+// file systems is a copy followed by removing the original.
+func moveToDir(path, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("could not create quarantine directory: %w", err)
+	}
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	dst := filepath.Join(dir, base)
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(dst); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		dst = filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(base, ext), n, ext))
+	}
+	if err := os.Rename(path, dst); err == nil {
+		return dst, nil
+	}
+
+	src, err := os.Open(path) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) // #nosec G304 -- inside the quarantine directory
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	src.Close()
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("copied to %s but could not remove the original: %w", dst, err)
+	}
+	return dst, nil
+}
+
+// formatBytes renders a file size with a binary unit, e.g. "2.4 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -89,21 +89,22 @@ produce a new config from an existing template).`,
 			}
 
 			res, err := editor.Run(editor.Config{
-				Path:             loadPath,
-				SavePath:         output,
-				Schema:           &models.Config{},
-				Title:            "gopaper",
-				BlockPresets:     GopaperBlockPresets,
-				DocPresets:       GopaperDocPresets,
-				EnableHints:      true,
-				Metadata:         gopaperHints,
-				Theme:            selectedTheme,
-				NoSaveConfirm:    noSaveConfirm,
-				NoDeleteConfirm:  noDeleteConfirm,
-				NoValidateOnSave: noValidateOnSave,
-				Validators:       GopaperValidators,
-				Dump:             dump || dumpPath != "",
-				DumpPath:         dumpPath,
+				Path:                 loadPath,
+				SavePath:             output,
+				Schema:               &models.Config{},
+				SchemaRecursionDepth: filterSchemaDepth,
+				Title:                "gopaper",
+				BlockPresets:         GopaperBlockPresets,
+				DocPresets:           GopaperDocPresets,
+				EnableHints:          true,
+				Metadata:             gopaperHints,
+				Theme:                selectedTheme,
+				NoSaveConfirm:        noSaveConfirm,
+				NoDeleteConfirm:      noDeleteConfirm,
+				NoValidateOnSave:     noValidateOnSave,
+				Validators:           GopaperValidators,
+				Dump:                 dump || dumpPath != "",
+				DumpPath:             dumpPath,
 			})
 			if err != nil {
 				return err
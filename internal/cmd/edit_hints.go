@@ -6,6 +6,11 @@ import (
 	"github.com/lucasassuncao/yedit/metadata"
 )
 
+// filterSchemaDepth is how many levels of filters nested under any-of,
+// all-of and not the editor lays out, and the metadata-declared rules
+// (allowed values, required fields) check, beyond the category's own filter.
+const filterSchemaDepth = 4
+
 func buildGopaperHints() (editor.MetadataSource, error) {
 	return metadata.New(models.Config{})
 }
@@ -257,6 +257,21 @@ func categoriesPresetsMap() map[string][]models.Categories {
 				Filter:  &models.Filter{Size: &models.SizeFilter{Min: "2MB"}},
 			},
 		},
+		// filter.any-of/not: files matching either pattern, minus drafts
+		"with-filter-combinators": {
+			{
+				Name:    "Cities",
+				Source:  filepath.Join(walls, "Cities"),
+				Enabled: true,
+				Filter: &models.Filter{
+					AnyOf: []models.Filter{
+						{Match: &models.MatchFilter{Globs: []string{"city_*", "skyline_*"}}},
+						{Match: &models.MatchFilter{Regex: `^night-\d+`}},
+					},
+					Not: &models.Filter{Match: &models.MatchFilter{Glob: "*_draft*"}},
+				},
+			},
+		},
 	}
 }
 
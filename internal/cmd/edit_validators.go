@@ -1,17 +1,30 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/lucasassuncao/gopaper/internal/calendar"
+	"github.com/lucasassuncao/gopaper/internal/config"
 	"github.com/lucasassuncao/gopaper/internal/filters"
+	"github.com/lucasassuncao/gopaper/internal/generate"
+	"github.com/lucasassuncao/gopaper/internal/hooks"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/overlay"
+	"github.com/lucasassuncao/gopaper/internal/palette"
+	"github.com/lucasassuncao/gopaper/internal/process"
 	"github.com/lucasassuncao/gopaper/internal/schedule"
+	"github.com/lucasassuncao/gopaper/internal/tags"
+	"github.com/lucasassuncao/gopaper/internal/theme"
 	"github.com/lucasassuncao/gopaper/internal/weather"
 	"github.com/lucasassuncao/yedit/editor"
 )
@@ -36,29 +49,27 @@ var GopaperValidators = []editor.Validator{
 	// hints; NoDuplicates skips unnamed entries).
 	editor.NoDuplicates("categories", "name"),
 
-	// within a filter.match block, literal/regex/glob are mutually exclusive.
-	editor.MutuallyExclusiveNested("categories.filter.match", "literal", "regex", "glob"),
+	// The file backend's monitors are told apart by the file they are
+	// published at.
+	editor.NoDuplicates("configuration.output.monitors", "path"),
 
-	// age and size min/max pairs must be ordered.
+	// within a filter.match block, literal/regex/regexes/glob/globs are
+	// mutually exclusive. These nested validators (like the ordering ones
+	// below) also reach the filters nested under any-of, all-of and not.
+	editor.MutuallyExclusiveNested("categories.filter.match", "literal", "regex", "regexes", "glob", "globs"),
+
+	// age, size and brightness min/max pairs must be ordered.
 	editor.CrossFieldOrderedNested("categories.filter.age", "min", "max"),
 	editor.CrossFieldOrderedNested("categories.filter.size", "min", "max"),
+	editor.CrossFieldOrderedNested("categories.filter.brightness", "min", "max"),
 
-	// Validate that filter.match.regex/glob compile and filter.size.min/max
-	// parse, so a malformed filter is caught here instead of at wallpaper-
-	// change time.
+	// Validate every filter, including those nested under any-of, all-of
+	// and not, so a malformed one is caught here instead of at
+	// wallpaper-change time (see validateFilter).
 	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
 		var doc struct {
 			Categories []struct {
-				Filter *struct {
-					Match *struct {
-						Regex string `yaml:"regex"`
-						Glob  string `yaml:"glob"`
-					} `yaml:"match"`
-					Size *struct {
-						Min string `yaml:"min"`
-						Max string `yaml:"max"`
-					} `yaml:"size"`
-				} `yaml:"filter"`
+				Filter *filterDoc `yaml:"filter"`
 			} `yaml:"categories"`
 		}
 		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
@@ -66,56 +77,24 @@ var GopaperValidators = []editor.Validator{
 		}
 		var errs []editor.Violation
 		for i, c := range doc.Categories {
-			if c.Filter == nil {
-				continue
-			}
-			if m := c.Filter.Match; m != nil {
-				if m.Regex != "" {
-					if _, err := regexp.Compile(m.Regex); err != nil {
-						errs = append(errs, editor.Violation{
-							Path:    fmt.Sprintf("categories[%d].filter.match.regex", i),
-							Message: err.Error(),
-						})
-					}
-				}
-				if m.Glob != "" {
-					if _, err := filepath.Match(m.Glob, ""); err != nil {
-						errs = append(errs, editor.Violation{
-							Path:    fmt.Sprintf("categories[%d].filter.match.glob", i),
-							Message: err.Error(),
-						})
-					}
-				}
-			}
-			if s := c.Filter.Size; s != nil {
-				if s.Min != "" {
-					if _, err := filters.ParseSize(s.Min); err != nil {
-						errs = append(errs, editor.Violation{
-							Path:    fmt.Sprintf("categories[%d].filter.size.min", i),
-							Message: err.Error(),
-						})
-					}
-				}
-				if s.Max != "" {
-					if _, err := filters.ParseSize(s.Max); err != nil {
-						errs = append(errs, editor.Violation{
-							Path:    fmt.Sprintf("categories[%d].filter.size.max", i),
-							Message: err.Error(),
-						})
-					}
-				}
+			if c.Filter != nil {
+				errs = append(errs, validateFilter(c.Filter, fmt.Sprintf("categories[%d].filter", i))...)
 			}
 		}
 		return errs
 	}),
 
-	// Category source/variants/wallhaven shape, and per-variant
-	// hours/condition rules. A category has exactly one of: a plain source,
-	// variants (source optional there, but required as the base directory
-	// for any variant with a relative source), or a wallhaven block (which
-	// requires a query, and an API key for sketchy/nsfw purity). Each
-	// variant defines exactly one of hours/condition; a condition name must
-	// exist in configuration.conditions.
+	// Category source/variants/wallhaven/tags/collage/calendar/generate
+	// shape, and per-variant hours/condition rules. A category has exactly
+	// one of: a plain source, variants (source optional there, but required
+	// as the base directory for any variant with a relative source), a
+	// wallhaven block (which requires a query, and an API key for
+	// sketchy/nsfw purity), a tags query on its own, a collage of other
+	// categories or a calendar over another's image (those must exist and
+	// not be collages or calendars themselves), or a generator.
+	// tags can also narrow a source or variants. Each variant has a source,
+	// sources or a generator, and defines exactly one of hours/condition; a
+	// condition name must exist in configuration.conditions.
 	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
 		var doc struct {
 			Configuration struct {
@@ -125,34 +104,139 @@ var GopaperValidators = []editor.Validator{
 				Conditions map[string]struct {
 					Hours        string   `yaml:"hours"`
 					Weather      []string `yaml:"weather"`
-					WindSpeedMin *float64 `yaml:"wind-speed-min"`
-					WindSpeedMax *float64 `yaml:"wind-speed-max"`
+					WindSpeedMin *string  `yaml:"wind-speed-min"`
+					WindSpeedMax *string  `yaml:"wind-speed-max"`
 				} `yaml:"conditions"`
 			} `yaml:"configuration"`
 			Categories []struct {
-				Source   string `yaml:"source"`
-				Variants []struct {
-					Source    string `yaml:"source"`
-					Hours     string `yaml:"hours"`
-					Condition string `yaml:"condition"`
+				Name      string             `yaml:"name"`
+				Source    string             `yaml:"source"`
+				Sources   []models.SourceDir `yaml:"sources"`
+				Recursive bool               `yaml:"recursive"`
+				MaxDepth  int                `yaml:"max-depth"`
+				Variants  []struct {
+					Source    string             `yaml:"source"`
+					Sources   []models.SourceDir `yaml:"sources"`
+					Generate  *generateDoc       `yaml:"generate"`
+					Hours     string             `yaml:"hours"`
+					Condition string             `yaml:"condition"`
 				} `yaml:"variants"`
 				Wallhaven *struct {
 					Query  string `yaml:"query"`
 					Purity string `yaml:"purity"`
 				} `yaml:"wallhaven"`
+				Tags    string `yaml:"tags"`
+				Collage *struct {
+					From []string `yaml:"from"`
+				} `yaml:"collage"`
+				Generate *generateDoc     `yaml:"generate"`
+				Calendar *models.Calendar `yaml:"calendar"`
 			} `yaml:"categories"`
 		}
 		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
 			return nil
 		}
 		hasAPIKey := doc.Configuration.Wallhaven != nil && doc.Configuration.Wallhaven.APIKey != ""
+		// kinds maps each category name to "collage" or "calendar" for
+		// those, "" for categories with images of their own.
+		kinds := map[string]string{}
+		for _, c := range doc.Categories {
+			switch {
+			case c.Collage != nil:
+				kinds[c.Name] = "collage"
+			case c.Calendar != nil:
+				kinds[c.Name] = "calendar"
+			default:
+				kinds[c.Name] = ""
+			}
+		}
 		var errs []editor.Violation
 		for i, c := range doc.Categories {
+			if c.MaxDepth != 0 && !c.Recursive {
+				errs = append(errs, editor.Violation{
+					Path:    fmt.Sprintf("categories[%d].max-depth", i),
+					Message: "only applies with recursive: true",
+				})
+			}
+			if c.Source != "" && len(c.Sources) > 0 {
+				errs = append(errs, editor.Violation{
+					Path:    fmt.Sprintf("categories[%d].sources", i),
+					Message: "source and sources are mutually exclusive - define one or the other",
+				})
+			}
+			errs = append(errs, sourcesViolations(fmt.Sprintf("categories[%d].sources", i), c.Sources)...)
+			if c.Tags != "" {
+				if _, err := tags.ParseQuery(c.Tags); err != nil {
+					errs = append(errs, editor.Violation{
+						Path:    fmt.Sprintf("categories[%d].tags", i),
+						Message: err.Error(),
+					})
+				}
+			}
+			hasBase := c.Source != "" || len(c.Sources) > 0
+			if c.Generate != nil {
+				if hasBase || len(c.Variants) > 0 || c.Wallhaven != nil || c.Tags != "" || c.Collage != nil || c.Calendar != nil {
+					errs = append(errs, editor.Violation{
+						Path:    fmt.Sprintf("categories[%d].generate", i),
+						Message: "generate is mutually exclusive with source/sources/variants/wallhaven/tags/collage/calendar - for a palette that follows the time of day, put generate in each variant",
+					})
+				}
+				errs = append(errs, generateViolations(fmt.Sprintf("categories[%d].generate", i), *c.Generate)...)
+				continue
+			}
+			if c.Calendar != nil {
+				if hasBase || len(c.Variants) > 0 || c.Wallhaven != nil || c.Tags != "" || c.Collage != nil {
+					errs = append(errs, editor.Violation{
+						Path:    fmt.Sprintf("categories[%d].calendar", i),
+						Message: "calendar is mutually exclusive with source/sources/variants/wallhaven/tags/collage - pick the background from another category with calendar.background",
+					})
+				}
+				if _, err := calendar.FromConfig(*c.Calendar); err != nil {
+					errs = append(errs, editor.Violation{
+						Path:    fmt.Sprintf("categories[%d].calendar", i),
+						Message: err.Error(),
+					})
+				}
+				if name := c.Calendar.Background; name != "" {
+					if msg := imageCategoryProblem(kinds, name, "a calendar's background is picked"); msg != "" {
+						errs = append(errs, editor.Violation{
+							Path:    fmt.Sprintf("categories[%d].calendar.background", i),
+							Message: msg,
+						})
+					}
+				}
+				continue
+			}
+			if c.Collage != nil {
+				if hasBase || len(c.Variants) > 0 || c.Wallhaven != nil || c.Tags != "" {
+					errs = append(errs, editor.Violation{
+						Path:    fmt.Sprintf("categories[%d].collage", i),
+						Message: "collage is mutually exclusive with source/sources/variants/wallhaven/tags - define one or the other",
+					})
+				}
+				if len(c.Collage.From) == 0 {
+					errs = append(errs, editor.Violation{
+						Path:    fmt.Sprintf("categories[%d].collage.from", i),
+						Message: "required - name at least one category to pick images from",
+					})
+				}
+				for j, name := range c.Collage.From {
+					msg := imageCategoryProblem(kinds, name, "collages pick")
+					if msg == "" {
+						continue
+					}
+					errs = append(errs, editor.Violation{
+						Path:    fmt.Sprintf("categories[%d].collage.from[%d]", i, j),
+						Message: msg,
+					})
+				}
+				continue
+			}
 			if c.Wallhaven != nil {
-				if c.Source != "" || len(c.Variants) > 0 {
+				if hasBase || len(c.Variants) > 0 || c.Tags != "" {
 					errs = append(errs, editor.Violation{
 						Path:    fmt.Sprintf("categories[%d].wallhaven", i),
-						Message: "wallhaven is mutually exclusive with source/variants - define one or the other",
+						Message: "wallhaven is mutually exclusive with source/sources/variants/tags - define one or the other",
 					})
 				}
 				if (c.Wallhaven.Purity == "sketchy" || c.Wallhaven.Purity == "nsfw") && !hasAPIKey {
@@ -164,25 +248,49 @@ var GopaperValidators = []editor.Validator{
 				continue
 			}
 			if len(c.Variants) == 0 {
-				if c.Source == "" {
+				if !hasBase && c.Tags == "" {
 					errs = append(errs, editor.Violation{
 						Path:    fmt.Sprintf("categories[%d].source", i),
-						Message: "define one of source, variants, or wallhaven",
+						Message: "define one of source, sources, variants, wallhaven, tags, collage, calendar, or generate",
 					})
 				}
 				continue
 			}
 			for j, v := range c.Variants {
-				if v.Source == "" {
+				varPath := fmt.Sprintf("categories[%d].variants[%d]", i, j)
+				switch {
+				case v.Generate != nil && (v.Source != "" || len(v.Sources) > 0):
 					errs = append(errs, editor.Violation{
-						Path:    fmt.Sprintf("categories[%d].variants[%d].source", i, j),
-						Message: "required",
+						Path:    varPath + ".generate",
+						Message: "generate is mutually exclusive with source/sources - define one or the other",
 					})
-				} else if !filepath.IsAbs(v.Source) && c.Source == "" {
+				case v.Source != "" && len(v.Sources) > 0:
 					errs = append(errs, editor.Violation{
-						Path:    fmt.Sprintf("categories[%d].variants[%d].source", i, j),
-						Message: "relative source requires the category to define source (used as the base directory)",
+						Path:    varPath + ".sources",
+						Message: "source and sources are mutually exclusive - define one or the other",
 					})
+				case v.Source == "" && len(v.Sources) == 0 && v.Generate == nil:
+					errs = append(errs, editor.Violation{
+						Path:    varPath + ".source",
+						Message: "required - either source, sources or generate",
+					})
+				case v.Source != "" && !filepath.IsAbs(v.Source) && !hasBase:
+					errs = append(errs, editor.Violation{
+						Path:    varPath + ".source",
+						Message: "relative source requires the category to define source or sources (used as the base directory)",
+					})
+				}
+				errs = append(errs, sourcesViolations(varPath+".sources", v.Sources)...)
+				if v.Generate != nil {
+					errs = append(errs, generateViolations(varPath+".generate", *v.Generate)...)
+				}
+				for k, sd := range v.Sources {
+					if sd.Path != "" && !filepath.IsAbs(sd.Path) && !hasBase {
+						errs = append(errs, editor.Violation{
+							Path:    fmt.Sprintf("%s.sources[%d]", varPath, k),
+							Message: "relative source requires the category to define source or sources (used as the base directory)",
+						})
+					}
 				}
 
 				switch {
@@ -218,16 +326,20 @@ var GopaperValidators = []editor.Validator{
 
 	// configuration.conditions shape: exactly one of hours / date-range /
 	// weather-bucket (weather, wind-speed-*, temperature-*, which combine
-	// with AND) per condition, known sky names, valid date-range, and
+	// with AND) per condition, known sky names (built-in or declared in
+	// configuration.weather.buckets), thresholds that parse with a unit
+	// suffix matching the field, valid date-range, and
 	// configuration.weather requiredness/validity.
 	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
 		var doc struct {
 			Configuration struct {
 				Weather *struct {
-					Provider  string   `yaml:"provider"`
-					Latitude  *float64 `yaml:"latitude"`
-					Longitude *float64 `yaml:"longitude"`
-					CacheTTL  string   `yaml:"cache-ttl"`
+					Provider  string           `yaml:"provider"`
+					Latitude  *float64         `yaml:"latitude"`
+					Longitude *float64         `yaml:"longitude"`
+					CacheTTL  string           `yaml:"cache-ttl"`
+					Units     string           `yaml:"units"`
+					Buckets   map[string][]int `yaml:"buckets"`
 				} `yaml:"weather"`
 				Conditions map[string]struct {
 					Hours     string `yaml:"hours"`
@@ -236,10 +348,10 @@ var GopaperValidators = []editor.Validator{
 						End   string `yaml:"end"`
 					} `yaml:"date-range"`
 					Weather        []string `yaml:"weather"`
-					WindSpeedMin   *float64 `yaml:"wind-speed-min"`
-					WindSpeedMax   *float64 `yaml:"wind-speed-max"`
-					TemperatureMin *float64 `yaml:"temperature-min"`
-					TemperatureMax *float64 `yaml:"temperature-max"`
+					WindSpeedMin   *string  `yaml:"wind-speed-min"`
+					WindSpeedMax   *string  `yaml:"wind-speed-max"`
+					TemperatureMin *string  `yaml:"temperature-min"`
+					TemperatureMax *string  `yaml:"temperature-max"`
 				} `yaml:"conditions"`
 			} `yaml:"configuration"`
 		}
@@ -253,6 +365,12 @@ var GopaperValidators = []editor.Validator{
 		}
 		sort.Strings(conditionNames)
 
+		var custom map[string][]int
+		if doc.Configuration.Weather != nil {
+			custom = doc.Configuration.Weather.Buckets
+		}
+		buckets := weather.NewBuckets(custom)
+
 		var errs []editor.Violation
 		needsWeatherConfig := false
 		for _, name := range conditionNames {
@@ -298,11 +416,15 @@ var GopaperValidators = []editor.Validator{
 				}
 			case hasWeatherFields:
 				needsWeatherConfig = true
+				errs = append(errs, thresholdViolations(name, "temperature-min", cond.TemperatureMin, weather.KindTemperature)...)
+				errs = append(errs, thresholdViolations(name, "temperature-max", cond.TemperatureMax, weather.KindTemperature)...)
+				errs = append(errs, thresholdViolations(name, "wind-speed-min", cond.WindSpeedMin, weather.KindSpeed)...)
+				errs = append(errs, thresholdViolations(name, "wind-speed-max", cond.WindSpeedMax, weather.KindSpeed)...)
 				for _, sky := range cond.Weather {
-					if !weather.IsValidSky(sky) {
+					if !buckets.Has(sky) {
 						errs = append(errs, editor.Violation{
 							Path:    fmt.Sprintf("configuration.conditions.%s.weather", name),
-							Message: fmt.Sprintf("unknown weather category %q - use one of: %s", sky, strings.Join(weather.SkyNames(), ", ")),
+							Message: fmt.Sprintf("unknown weather category %q - use one of: %s", sky, strings.Join(buckets.Names(), ", ")),
 						})
 					}
 				}
@@ -346,6 +468,278 @@ var GopaperValidators = []editor.Validator{
 				})
 			}
 		}
+		return append(errs, bucketViolations(w.Buckets)...)
+	}),
+
+	// categories[].process: each step is a known bare name or a mapping of
+	// exactly one operation with an amount in range; a step's condition must
+	// exist in configuration.conditions.
+	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
+		var doc struct {
+			Configuration struct {
+				Conditions map[string]yaml.Node `yaml:"conditions"`
+			} `yaml:"configuration"`
+			Categories []struct {
+				Process []yaml.Node `yaml:"process"`
+			} `yaml:"categories"`
+		}
+		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
+			return nil
+		}
+		var errs []editor.Violation
+		for i, c := range doc.Categories {
+			for j, node := range c.Process {
+				path := fmt.Sprintf("categories[%d].process[%d]", i, j)
+				var step models.ProcessStep
+				if err := node.Decode(&step); err != nil {
+					errs = append(errs, editor.Violation{Path: path, Message: err.Error()})
+					continue
+				}
+				if _, err := process.FromConfig(step); err != nil {
+					errs = append(errs, editor.Violation{Path: path, Message: err.Error()})
+				}
+				if _, ok := doc.Configuration.Conditions[step.Condition]; step.Condition != "" && !ok {
+					errs = append(errs, editor.Violation{
+						Path:    path + ".condition",
+						Message: fmt.Sprintf("unknown condition %q - not defined in configuration.conditions", step.Condition),
+					})
+				}
+			}
+		}
+		return errs
+	}),
+
+	// behavior.backend is how the whole run sets wallpapers: a category
+	// can't pick its own. The file backend publishes to configuration.output.
+	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
+		var doc struct {
+			Configuration struct {
+				Behavior *struct {
+					Backend string `yaml:"backend"`
+				} `yaml:"behavior"`
+				Output *struct {
+					Path string `yaml:"path"`
+				} `yaml:"output"`
+			} `yaml:"configuration"`
+			Categories []struct {
+				Behavior *struct {
+					Backend string `yaml:"backend"`
+				} `yaml:"behavior"`
+			} `yaml:"categories"`
+		}
+		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
+			return nil
+		}
+		var errs []editor.Violation
+		if b := doc.Configuration.Behavior; b != nil && b.Backend == "file" && doc.Configuration.Output == nil {
+			errs = append(errs, editor.Violation{
+				Path:    "configuration.output",
+				Message: "required because behavior.backend is file",
+			})
+		}
+		for i, c := range doc.Categories {
+			if c.Behavior != nil && c.Behavior.Backend != "" {
+				errs = append(errs, editor.Violation{
+					Path:    fmt.Sprintf("categories[%d].behavior.backend", i),
+					Message: "only allowed in configuration.behavior: every category is set through the same backend",
+				})
+			}
+		}
+		return errs
+	}),
+
+	// behavior.overlay, at configuration level and on each category: known
+	// show items, a positive font-size, and a quote-file wherever quote is
+	// shown (the category's own or the inherited one).
+	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
+		type overlayDoc struct {
+			Show      []string `yaml:"show"`
+			FontSize  float64  `yaml:"font-size"`
+			QuoteFile string   `yaml:"quote-file"`
+		}
+		var doc struct {
+			Configuration struct {
+				Behavior *struct {
+					Overlay *overlayDoc `yaml:"overlay"`
+				} `yaml:"behavior"`
+			} `yaml:"configuration"`
+			Categories []struct {
+				Behavior *struct {
+					Overlay *overlayDoc `yaml:"overlay"`
+				} `yaml:"behavior"`
+			} `yaml:"categories"`
+		}
+		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
+			return nil
+		}
+		var global overlayDoc
+		if b := doc.Configuration.Behavior; b != nil && b.Overlay != nil {
+			global = *b.Overlay
+		}
+		check := func(path string, o, inherited overlayDoc) []editor.Violation {
+			var errs []editor.Violation
+			for k, item := range o.Show {
+				if !slices.Contains(overlay.Items(), item) {
+					errs = append(errs, editor.Violation{
+						Path:    fmt.Sprintf("%s.show[%d]", path, k),
+						Message: fmt.Sprintf("unknown overlay item %q - use one of %s", item, strings.Join(overlay.Items(), ", ")),
+					})
+				}
+			}
+			if o.FontSize < 0 {
+				errs = append(errs, editor.Violation{Path: path + ".font-size", Message: "must be positive"})
+			}
+			if slices.Contains(o.Show, overlay.Quote) && o.QuoteFile == "" && inherited.QuoteFile == "" {
+				errs = append(errs, editor.Violation{Path: path + ".quote-file", Message: "required to show quote"})
+			}
+			return errs
+		}
+
+		var errs []editor.Violation
+		if b := doc.Configuration.Behavior; b != nil && b.Overlay != nil {
+			errs = append(errs, check("configuration.behavior.overlay", global, overlayDoc{})...)
+		}
+		for i, c := range doc.Categories {
+			if c.Behavior == nil || c.Behavior.Overlay == nil {
+				continue
+			}
+			errs = append(errs, check(fmt.Sprintf("categories[%d].behavior.overlay", i), *c.Behavior.Overlay, global)...)
+		}
+		return errs
+	}),
+
+	// configuration.index.ttl must parse as a Go duration.
+	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
+		var doc struct {
+			Configuration struct {
+				Index *struct {
+					TTL string `yaml:"ttl"`
+				} `yaml:"index"`
+			} `yaml:"configuration"`
+		}
+		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
+			return nil
+		}
+		idx := doc.Configuration.Index
+		if idx == nil || idx.TTL == "" {
+			return nil
+		}
+		if _, err := time.ParseDuration(idx.TTL); err != nil {
+			return []editor.Violation{{
+				Path:    "configuration.index.ttl",
+				Message: err.Error(),
+			}}
+		}
+		return nil
+	}),
+
+	// configuration.dedupe.threshold is a number of hash bits, 0-64.
+	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
+		var doc struct {
+			Configuration struct {
+				Dedupe *struct {
+					Threshold *int `yaml:"threshold"`
+				} `yaml:"dedupe"`
+			} `yaml:"configuration"`
+		}
+		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
+			return nil
+		}
+		d := doc.Configuration.Dedupe
+		if d == nil || d.Threshold == nil || (*d.Threshold >= 0 && *d.Threshold <= 64) {
+			return nil
+		}
+		return []editor.Violation{{
+			Path:    "configuration.dedupe.threshold",
+			Message: "must be between 0 and 64 (differing bits of a 64-bit hash)",
+		}}
+	}),
+
+	// configuration.theme: colors within 8-16, and every template with an
+	// in and an out; an in that is neither built in nor a readable file is
+	// left for the run to report (the file may be written later), but one
+	// that doesn't parse is reported here.
+	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
+		var doc struct {
+			Configuration struct {
+				Theme *struct {
+					Colors    *int                   `yaml:"colors"`
+					Templates []models.ThemeTemplate `yaml:"templates"`
+				} `yaml:"theme"`
+			} `yaml:"configuration"`
+		}
+		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
+			return nil
+		}
+		th := doc.Configuration.Theme
+		if th == nil {
+			return nil
+		}
+		var errs []editor.Violation
+		if th.Colors != nil && (*th.Colors < palette.MinColors || *th.Colors > palette.MaxColors) {
+			errs = append(errs, editor.Violation{
+				Path:    "configuration.theme.colors",
+				Message: fmt.Sprintf("must be between %d and %d", palette.MinColors, palette.MaxColors),
+			})
+		}
+		for i, t := range th.Templates {
+			path := fmt.Sprintf("configuration.theme.templates[%d]", i)
+			if t.In == "" {
+				errs = append(errs, editor.Violation{Path: path + ".in", Message: "required - a template file or one of " + strings.Join(theme.Builtins(), ", ")})
+			} else if src := config.ExpandTilde(t.In); slices.Contains(theme.Builtins(), src) || isFile(src) {
+				if _, err := theme.Parse(src); err != nil {
+					errs = append(errs, editor.Violation{Path: path + ".in", Message: err.Error()})
+				}
+			}
+			if t.Out == "" {
+				errs = append(errs, editor.Violation{Path: path + ".out", Message: "required - the file the theme is written to"})
+			}
+		}
+		return errs
+	}),
+
+	// configuration.hooks: every hook has a command, and every timeout is a
+	// positive duration.
+	editor.ValidatorFunc(func(in editor.ValidationInput) []editor.Violation {
+		var doc struct {
+			Configuration struct {
+				Hooks *struct {
+					Timeout    string        `yaml:"timeout"`
+					PreChange  []models.Hook `yaml:"pre-change"`
+					PostChange []models.Hook `yaml:"post-change"`
+				} `yaml:"hooks"`
+			} `yaml:"configuration"`
+		}
+		if err := yaml.Unmarshal(in.Raw, &doc); err != nil {
+			return nil
+		}
+		h := doc.Configuration.Hooks
+		if h == nil {
+			return nil
+		}
+		var errs []editor.Violation
+		timeout := func(path, value string) {
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				errs = append(errs, editor.Violation{Path: path, Message: fmt.Sprintf("%q is not a positive duration (e.g. \"10s\")", value)})
+			}
+		}
+		if h.Timeout != "" {
+			timeout("configuration.hooks.timeout", h.Timeout)
+		}
+		for _, list := range []struct {
+			event string
+			hooks []models.Hook
+		}{{hooks.PreChange, h.PreChange}, {hooks.PostChange, h.PostChange}} {
+			for i, hook := range list.hooks {
+				path := fmt.Sprintf("configuration.hooks.%s[%d]", list.event, i)
+				if strings.TrimSpace(hook.Command) == "" {
+					errs = append(errs, editor.Violation{Path: path + ".command", Message: "required - the shell command to run"})
+				}
+				if hook.Timeout != "" {
+					timeout(path+".timeout", hook.Timeout)
+				}
+			}
+		}
 		return errs
 	}),
 
@@ -376,3 +770,245 @@ var GopaperValidators = []editor.Validator{
 		}}
 	}),
 }
+
+// sourcesViolations checks the entries of a sources list at path: each needs
+// a path, and an explicit weight must be positive.
+func sourcesViolations(path string, sources []models.SourceDir) []editor.Violation {
+	var errs []editor.Violation
+	for k, sd := range sources {
+		if sd.Path == "" {
+			errs = append(errs, editor.Violation{
+				Path:    fmt.Sprintf("%s[%d]", path, k),
+				Message: "directory path required",
+			})
+		}
+		if sd.Weight < 0 {
+			errs = append(errs, editor.Violation{
+				Path:    fmt.Sprintf("%s[%d].weight", path, k),
+				Message: "weight must be a positive number",
+			})
+		}
+	}
+	return errs
+}
+
+// imageCategoryProblem returns why the category named name can't provide
+// images (to what, e.g. "collages pick", which picks them from categories
+// with images), or "" when it can. kinds is as built by the category
+// validator.
+func imageCategoryProblem(kinds map[string]string, name, what string) string {
+	kind, ok := kinds[name]
+	switch {
+	case !ok:
+		return fmt.Sprintf("no category named %q", name)
+	case kind != "":
+		return fmt.Sprintf("%q is a %s itself - %s from categories with images", name, kind, what)
+	}
+	return ""
+}
+
+// generateDoc is the part of a generate block generateViolations checks.
+type generateDoc struct {
+	Type   string   `yaml:"type"`
+	Colors []string `yaml:"colors"`
+	Width  int      `yaml:"width"`
+}
+
+// generateViolations checks a generate block the way it is rendered: a
+// known type, the right number of valid colors.
+func generateViolations(path string, g generateDoc) []editor.Violation {
+	if _, err := generate.FromConfig(models.Generate{Type: g.Type, Colors: g.Colors, Width: g.Width}); err != nil {
+		return []editor.Violation{{Path: path, Message: err.Error()}}
+	}
+	return nil
+}
+
+// filterDoc is the part of a category filter validateFilter checks, with
+// the filters nested under any-of, all-of and not.
+type filterDoc struct {
+	Match *struct {
+		Regex   string   `yaml:"regex"`
+		Regexes []string `yaml:"regexes"`
+		Glob    string   `yaml:"glob"`
+		Globs   []string `yaml:"globs"`
+	} `yaml:"match"`
+	Size *struct {
+		Min string `yaml:"min"`
+		Max string `yaml:"max"`
+	} `yaml:"size"`
+	Dimensions *struct {
+		Aspect string `yaml:"aspect"`
+	} `yaml:"dimensions"`
+	Brightness *struct {
+		Min float64 `yaml:"min"`
+		Max float64 `yaml:"max"`
+	} `yaml:"brightness"`
+	Color *struct {
+		Dominant  string  `yaml:"dominant"`
+		Tolerance float64 `yaml:"tolerance"`
+	} `yaml:"color"`
+	Exif *struct {
+		Months []string        `yaml:"months"`
+		Camera string          `yaml:"camera"`
+		Area   *models.GeoArea `yaml:"area"`
+	} `yaml:"exif"`
+	AnyOf []filterDoc `yaml:"any-of"`
+	AllOf []filterDoc `yaml:"all-of"`
+	Not   *filterDoc  `yaml:"not"`
+}
+
+// validateFilter checks the filter at path and, recursively, the filters
+// nested in it: regexes, globs and exif.camera must compile, size bounds,
+// dimensions.aspect, color.dominant and exif.months must parse, and
+// brightness, color tolerance and the exif area must be in range.
+func validateFilter(f *filterDoc, path string) []editor.Violation {
+	var errs []editor.Violation
+	add := func(field string, err error) {
+		errs = append(errs, editor.Violation{Path: path + "." + field, Message: err.Error()})
+	}
+
+	if m := f.Match; m != nil {
+		if m.Regex != "" {
+			if _, err := regexp.Compile(m.Regex); err != nil {
+				add("match.regex", err)
+			}
+		}
+		for j, expr := range m.Regexes {
+			if _, err := regexp.Compile(expr); err != nil {
+				add(fmt.Sprintf("match.regexes[%d]", j), err)
+			}
+		}
+		if m.Glob != "" {
+			if _, err := filepath.Match(m.Glob, ""); err != nil {
+				add("match.glob", err)
+			}
+		}
+		for j, glob := range m.Globs {
+			if _, err := filepath.Match(glob, ""); err != nil {
+				add(fmt.Sprintf("match.globs[%d]", j), err)
+			}
+		}
+	}
+	if s := f.Size; s != nil {
+		if s.Min != "" {
+			if _, err := filters.ParseSize(s.Min); err != nil {
+				add("size.min", err)
+			}
+		}
+		if s.Max != "" {
+			if _, err := filters.ParseSize(s.Max); err != nil {
+				add("size.max", err)
+			}
+		}
+	}
+	if d := f.Dimensions; d != nil && d.Aspect != "" {
+		if _, _, err := filters.ParseAspect(d.Aspect); err != nil {
+			add("dimensions.aspect", err)
+		}
+	}
+	if b := f.Brightness; b != nil {
+		if b.Min < 0 || b.Min > 1 {
+			add("brightness.min", errors.New("must be between 0 and 1"))
+		}
+		if b.Max < 0 || b.Max > 1 {
+			add("brightness.max", errors.New("must be between 0 and 1"))
+		}
+	}
+	if col := f.Color; col != nil {
+		if col.Dominant != "" {
+			if _, err := filters.ParseColor(col.Dominant); err != nil {
+				add("color.dominant", err)
+			}
+		}
+		if col.Tolerance < 0 || col.Tolerance > 441 {
+			add("color.tolerance", errors.New("must be between 0 and 441"))
+		}
+	}
+	if e := f.Exif; e != nil {
+		for j, m := range e.Months {
+			if _, err := filters.ParseMonth(m, time.Now()); err != nil {
+				add(fmt.Sprintf("exif.months[%d]", j), err)
+			}
+		}
+		if e.Camera != "" {
+			if _, err := filepath.Match(e.Camera, ""); err != nil {
+				add("exif.camera", err)
+			}
+		}
+		if e.Area != nil {
+			if err := filters.ValidateArea(*e.Area); err != nil {
+				add("exif.area", err)
+			}
+		}
+	}
+
+	for j := range f.AnyOf {
+		errs = append(errs, validateFilter(&f.AnyOf[j], fmt.Sprintf("%s.any-of[%d]", path, j))...)
+	}
+	for j := range f.AllOf {
+		errs = append(errs, validateFilter(&f.AllOf[j], fmt.Sprintf("%s.all-of[%d]", path, j))...)
+	}
+	if f.Not != nil {
+		errs = append(errs, validateFilter(f.Not, path+".not")...)
+	}
+	return errs
+}
+
+// bucketViolations checks configuration.weather.buckets: every bucket needs
+// at least one code, and codes must be WMO weather codes (0-99).
+func bucketViolations(buckets map[string][]int) []editor.Violation {
+	names := make([]string, 0, len(buckets))
+	for name := range buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []editor.Violation
+	for _, name := range names {
+		codes := buckets[name]
+		if len(codes) == 0 {
+			errs = append(errs, editor.Violation{
+				Path:    fmt.Sprintf("configuration.weather.buckets.%s", name),
+				Message: "list at least one WMO weather code",
+			})
+			continue
+		}
+		for _, code := range codes {
+			if code < 0 || code > 99 {
+				errs = append(errs, editor.Violation{
+					Path:    fmt.Sprintf("configuration.weather.buckets.%s", name),
+					Message: fmt.Sprintf("%d is not a WMO weather code (0-99)", code),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// thresholdViolations checks one temperature-*/wind-speed-* value of a
+// named condition: it must be a number, optionally suffixed with a unit of
+// the field's own kind (a temperature can't be given in mph).
+func thresholdViolations(condition, field string, raw *string, want weather.QuantityKind) []editor.Violation {
+	if raw == nil {
+		return nil
+	}
+	path := fmt.Sprintf("configuration.conditions.%s.%s", condition, field)
+	_, unit, kind, err := weather.ParseQuantity(*raw)
+	if err != nil {
+		return []editor.Violation{{Path: path, Message: err.Error()}}
+	}
+	if kind != weather.KindNone && kind != want {
+		accepted := "C or F"
+		if want == weather.KindSpeed {
+			accepted = "km/h, mph, m/s or kn"
+		}
+		return []editor.Violation{{Path: path, Message: fmt.Sprintf("unit %q does not apply here - use %s", unit, accepted)}}
+	}
+	return nil
+}
+
+// isFile reports whether path names an existing regular file.
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
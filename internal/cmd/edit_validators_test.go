@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -16,7 +18,7 @@ func runValidators(t *testing.T, raw string) []editor.Violation {
 	if err != nil {
 		t.Fatalf("building hints: %v", err)
 	}
-	wired := editor.Wire(GopaperValidators, editor.Config{Schema: &models.Config{}, Metadata: hints})
+	wired := editor.Wire(GopaperValidators, editor.Config{Schema: &models.Config{}, SchemaRecursionDepth: filterSchemaDepth, Metadata: hints})
 	return editor.RunAll(wired, []byte(raw), nil)
 }
 
@@ -124,7 +126,767 @@ func TestValidateCategoryWithNoSourceAtAll(t *testing.T) {
     enabled: true
 `
 	vs := runValidators(t, raw)
-	if !hasViolation(vs, "categories[0].source", "define one of source, variants, or wallhaven") {
-		t.Errorf("expected the three-way shape violation, got: %+v", vs)
+	if !hasViolation(vs, "categories[0].source", "define one of source, sources, variants, wallhaven, tags, collage, calendar, or generate") {
+		t.Errorf("expected the source-shape violation, got: %+v", vs)
+	}
+}
+
+func TestValidateMaxDepthRequiresRecursive(t *testing.T) {
+	raw := validBase + `
+  - name: "Photos"
+    source: "/walls/photos"
+    max-depth: 2
+    enabled: true
+  - name: "Archive"
+    source: "/walls/archive"
+    recursive: true
+    max-depth: 2
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "categories[0].max-depth", "recursive") {
+		t.Errorf("expected a max-depth violation for the non-recursive category, got: %+v", vs)
+	}
+	if hasViolation(vs, "categories[1]", "") {
+		t.Errorf("expected no violation for the recursive category, got: %+v", vs)
+	}
+}
+
+const weatherBase = `
+configuration:
+  logging:
+    output: console
+    level: info
+  weather:
+    provider: open-meteo
+    latitude: -23.55
+    longitude: -46.63
+`
+
+func TestValidateCustomWeatherBucketIsKnownSky(t *testing.T) {
+	raw := weatherBase + `
+    buckets:
+      heavy-rain: [65, 67, 82]
+  conditions:
+    downpour: { weather: [heavy-rain] }
+categories:
+  - name: "A"
+    source: "/walls"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if hasViolation(vs, "conditions.downpour.weather", "unknown weather category") {
+		t.Errorf("custom bucket should be accepted as a sky name, got: %+v", vs)
+	}
+}
+
+func TestValidateUndeclaredWeatherBucket(t *testing.T) {
+	raw := weatherBase + `
+  conditions:
+    downpour: { weather: [heavy-rain] }
+categories:
+  - name: "A"
+    source: "/walls"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "conditions.downpour.weather", `unknown weather category "heavy-rain"`) {
+		t.Errorf("expected an unknown-sky violation, got: %+v", vs)
+	}
+}
+
+func TestValidateWeatherBucketCodes(t *testing.T) {
+	raw := weatherBase + `
+    buckets:
+      empty: []
+      bogus: [65, 150]
+  conditions:
+    downpour: { weather: [bogus] }
+categories:
+  - name: "A"
+    source: "/walls"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "weather.buckets.empty", "at least one") {
+		t.Errorf("expected an empty-bucket violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "weather.buckets.bogus", "150 is not a WMO weather code") {
+		t.Errorf("expected an out-of-range code violation, got: %+v", vs)
+	}
+}
+
+func TestValidateThresholdUnitSuffixes(t *testing.T) {
+	raw := weatherBase + `
+    units: imperial
+  conditions:
+    cold:  { temperature-max: "50F", wind-speed-min: "20mph" }
+    wrong: { temperature-min: "20mph" }
+    junk:  { wind-speed-max: "breezy" }
+categories:
+  - name: "A"
+    source: "/walls"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if hasViolation(vs, "conditions.cold", "") {
+		t.Errorf("suffixed thresholds of the right kind should be accepted, got: %+v", vs)
+	}
+	if !hasViolation(vs, "conditions.wrong.temperature-min", `unit "mph" does not apply here`) {
+		t.Errorf("expected a unit-kind violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "conditions.junk.wind-speed-max", "could not parse") {
+		t.Errorf("expected a parse violation, got: %+v", vs)
+	}
+}
+
+func TestValidateWeatherUnitsOneOf(t *testing.T) {
+	raw := weatherBase + `
+    units: kelvin
+categories:
+  - name: "A"
+    source: "/walls"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "weather.units", "") {
+		t.Errorf("expected a units violation, got: %+v", vs)
+	}
+}
+
+func TestValidateSourcesMutuallyExclusiveWithSource(t *testing.T) {
+	raw := validBase + `
+  - name: "Both"
+    source: "/walls/a"
+    sources: ["/walls/b"]
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "categories[0].sources", "mutually exclusive") {
+		t.Errorf("expected a source/sources violation, got: %+v", vs)
+	}
+}
+
+func TestValidateSourcesAloneIsValidSourceShape(t *testing.T) {
+	raw := validBase + `
+  - name: "NAS plus local"
+    sources:
+      - /mnt/nas/walls
+      - path: /home/me/walls
+        weight: 2
+    variants:
+      - sources: [./day, /walls/shared-day]
+        hours: "06:00-17:59"
+      - source: ./night
+        hours: "18:00-05:59"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if len(vs) != 0 {
+		t.Errorf("expected no violations, got: %+v", vs)
+	}
+}
+
+func TestValidateSourcesEntries(t *testing.T) {
+	raw := validBase + `
+  - name: "Bad entries"
+    sources:
+      - path: ""
+      - path: /walls/b
+        weight: -1
+    enabled: true
+  - name: "Relative without base"
+    variants:
+      - sources: [./day]
+        hours: "06:00-17:59"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "categories[0].sources[0]", "path required") {
+		t.Errorf("expected a missing-path violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "categories[0].sources[1].weight", "positive") {
+		t.Errorf("expected a weight violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "categories[1].variants[0].sources[0]", "base directory") {
+		t.Errorf("expected a relative-source violation, got: %+v", vs)
+	}
+}
+
+func TestValidateIndexTTL(t *testing.T) {
+	raw := `
+configuration:
+  logging:
+    output: console
+    level: info
+  index:
+    enabled: true
+    ttl: "a day"
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "configuration.index.ttl", "invalid duration") {
+		t.Errorf("expected an index ttl violation, got: %+v", vs)
+	}
+}
+
+func TestValidateFilterDimensions(t *testing.T) {
+	raw := validBase + `
+  - name: "Wide"
+    source: "/walls"
+    filter:
+      dimensions:
+        aspect: "wide"
+        orientation: diagonal
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "filter.dimensions.aspect", "could not parse ratio") {
+		t.Errorf("expected aspect violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.dimensions.orientation", "") {
+		t.Errorf("expected orientation violation, got %+v", vs)
+	}
+
+	ok := validBase + `
+  - name: "Wide"
+    source: "/walls"
+    filter:
+      dimensions:
+        min-width: 3440
+        aspect: "21:9±0.06"
+        orientation: landscape
+`
+	if vs := runValidators(t, ok); hasViolation(vs, "filter.dimensions", "") {
+		t.Errorf("expected no dimensions violations, got %+v", vs)
+	}
+}
+
+func TestValidateFilterBrightnessAndColor(t *testing.T) {
+	raw := validBase + `
+  - name: "Night"
+    source: "/walls"
+    filter:
+      brightness:
+        max: 1.5
+      color:
+        dominant: "navy"
+        tolerance: 500
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "filter.brightness.max", "between 0 and 1") {
+		t.Errorf("expected brightness violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.color.dominant", "could not parse color") {
+		t.Errorf("expected dominant violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.color.tolerance", "between 0 and 441") {
+		t.Errorf("expected tolerance violation, got %+v", vs)
+	}
+
+	missing := validBase + `
+  - name: "Blue"
+    source: "/walls"
+    filter:
+      color:
+        tolerance: 20
+`
+	if vs := runValidators(t, missing); !hasViolation(vs, "filter.color.dominant", "") {
+		t.Errorf("expected a required dominant violation, got %+v", vs)
+	}
+}
+
+func TestValidateFilterExif(t *testing.T) {
+	raw := validBase + `
+  - name: "Summer"
+    source: "/walls"
+    filter:
+      age:
+        source: ctime
+      exif:
+        months: [6, 13]
+        camera: "["
+        area:
+          min-lat: 42.2
+          max-lat: 36.9
+          min-lon: -9.6
+          max-lon: -6.1
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "filter.age.source", "") {
+		t.Errorf("expected age source violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.exif.months[1]", "not a month number") {
+		t.Errorf("expected months violation, got %+v", vs)
+	}
+	if hasViolation(vs, "filter.exif.months[0]", "") {
+		t.Errorf("expected month 6 to be accepted, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.exif.camera", "syntax error") {
+		t.Errorf("expected camera violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.exif.area", "north of max-lat") {
+		t.Errorf("expected area violation, got %+v", vs)
+	}
+}
+
+func TestValidateNestedFilters(t *testing.T) {
+	raw := validBase + `
+  - name: "City"
+    source: "/walls"
+    filter:
+      any-of:
+        - match:
+            globs: ["city_*", "["]
+        - match:
+            regex: "^night"
+            glob: "night*"
+      all-of:
+        - size:
+            min: "20MB"
+            max: "1MB"
+        - not:
+            age:
+              source: ctime
+            exif:
+              months: [13]
+      not:
+        match:
+          regexes: ["_draft", "("]
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "categories[0].filter.any-of[0].match.globs[1]", "syntax error") {
+		t.Errorf("expected nested globs violation, got %+v", vs)
+	}
+	if hasViolation(vs, "filter.any-of[0].match.globs[0]", "") {
+		t.Errorf("expected city_* to be accepted, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.any-of[1].match", "") {
+		t.Errorf("expected nested regex/glob exclusivity violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.all-of[0].size", "") {
+		t.Errorf("expected nested size ordering violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.all-of[1].not.age.source", "") {
+		t.Errorf("expected nested age source violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.all-of[1].not.exif.months[0]", "not a month number") {
+		t.Errorf("expected nested months violation, got %+v", vs)
+	}
+	if !hasViolation(vs, "filter.not.match.regexes[1]", "missing closing )") {
+		t.Errorf("expected nested regexes violation, got %+v", vs)
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	raw := validBase + `
+  - name: "Cozy"
+    tags: "autumn and not people"
+    enabled: true
+  - name: "Narrowed"
+    source: "/walls/photos"
+    tags: "cozy or (autumn"
+    enabled: true
+  - name: "Both"
+    tags: "cozy"
+    wallhaven:
+      query: "landscape"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if hasViolation(vs, "categories[0]", "") {
+		t.Errorf("a tags query alone should satisfy the source shape, got: %+v", vs)
+	}
+	if !hasViolation(vs, "categories[1].tags", "missing ')'") {
+		t.Errorf("expected a tags query violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "categories[2].wallhaven", "mutually exclusive") {
+		t.Errorf("expected a wallhaven/tags violation, got: %+v", vs)
+	}
+}
+
+func TestValidateDedupe(t *testing.T) {
+	raw := `
+configuration:
+  logging:
+    output: console
+    level: info
+  dedupe:
+    algorithm: ahash
+    threshold: 80
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "configuration.dedupe.threshold", "between 0 and 64") {
+		t.Errorf("expected a threshold violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "configuration.dedupe.algorithm", "") {
+		t.Errorf("expected an algorithm violation, got: %+v", vs)
+	}
+}
+
+func TestValidateTheme(t *testing.T) {
+	broken := filepath.Join(t.TempDir(), "broken.tmpl")
+	if err := os.WriteFile(broken, []byte("{{ .Background "), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	raw := `
+configuration:
+  logging:
+    output: console
+    level: info
+  theme:
+    colors: 20
+    templates:
+      - {in: kitty, out: /tmp/colors.conf}
+      - {in: "` + filepath.ToSlash(broken) + `", out: /tmp/broken}
+      - {in: css}
+      - {out: /tmp/missing}
+      - {in: /does/not/exist/yet.tmpl, out: /tmp/later}
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "configuration.theme.colors", "between 8 and 16") {
+		t.Errorf("expected a colors violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "configuration.theme.templates[1].in", "invalid template") {
+		t.Errorf("expected a parse violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "configuration.theme.templates[2].out", "required") {
+		t.Errorf("expected a missing out violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "configuration.theme.templates[3].in", "required") {
+		t.Errorf("expected a missing in violation, got: %+v", vs)
+	}
+	for _, path := range []string{"templates[0]", "templates[4]"} {
+		if hasViolation(vs, "configuration.theme."+path, "") {
+			t.Errorf("unexpected violation for %s: %+v", path, vs)
+		}
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	raw := `
+configuration:
+  logging:
+    output: console
+    level: info
+  hooks:
+    timeout: soon
+    pre-change:
+      - command: "! pgrep -x obs"
+      - command: ""
+    post-change:
+      - {command: "notify-send gopaper", timeout: 0s}
+      - {command: "sync-lockscreen", timeout: 1m}
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "configuration.hooks.timeout", "positive duration") {
+		t.Errorf("expected a timeout violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "configuration.hooks.pre-change[1].command", "required") {
+		t.Errorf("expected a missing command violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "configuration.hooks.post-change[0].timeout", "positive duration") {
+		t.Errorf("expected a zero timeout violation, got: %+v", vs)
+	}
+	for _, path := range []string{"pre-change[0]", "post-change[1]"} {
+		if hasViolation(vs, "configuration.hooks."+path, "") {
+			t.Errorf("unexpected violation for %s: %+v", path, vs)
+		}
+	}
+}
+
+func TestValidateBackend(t *testing.T) {
+	raw := `
+configuration:
+  logging:
+    output: console
+    level: info
+  behavior:
+    backend: sway
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+    behavior:
+      backend: feh
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "categories[0].behavior.backend", "only allowed in configuration.behavior") {
+		t.Errorf("expected a category backend violation, got: %+v", vs)
+	}
+	if hasViolation(vs, "configuration.behavior.backend", "") {
+		t.Errorf("unexpected violation for the configuration backend: %+v", vs)
+	}
+
+	vs = runValidators(t, strings.Replace(raw, "backend: sway", "backend: wayland", 1))
+	if !hasViolation(vs, "configuration.behavior.backend", "") {
+		t.Errorf("expected an unknown backend violation, got: %+v", vs)
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	raw := `
+configuration:
+  logging:
+    output: console
+    level: info
+  behavior:
+    backend: file
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "configuration.output", "required because behavior.backend is file") {
+		t.Errorf("expected a missing output violation, got: %+v", vs)
+	}
+
+	withOutput := strings.Replace(raw, "    backend: file\n", `    backend: file
+  output:
+    method: hardlink
+    monitors:
+      - path: /srv/kiosk/left.jpg
+      - path: /srv/kiosk/left.jpg
+`, 1)
+	vs = runValidators(t, withOutput)
+	if hasViolation(vs, "configuration.output", "required because") {
+		t.Errorf("unexpected missing output violation: %+v", vs)
+	}
+	for _, path := range []string{"configuration.output.path", "configuration.output.method", "configuration.output.monitors[1].path"} {
+		if !hasViolation(vs, path, "") {
+			t.Errorf("expected a violation at %s, got: %+v", path, vs)
+		}
+	}
+}
+
+func TestValidateProcess(t *testing.T) {
+	raw := `
+configuration:
+  logging:
+    output: console
+    level: info
+  conditions:
+    night:
+      hours: "20:00-06:00"
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+    process:
+      - resize-to-monitor
+      - blur: 8
+      - {dim: 0.3, condition: night}
+      - grayscale
+  - name: "Broken"
+    source: "/walls/broken"
+    enabled: true
+    process:
+      - sepia
+      - {blur: 4, grayscale: true}
+      - dim: 2
+      - {grayscale: true, condition: rain}
+`
+	vs := runValidators(t, raw)
+	for _, want := range []struct{ path, msg string }{
+		{"categories[1].process[0]", "unknown process step"},
+		{"categories[1].process[1]", "one operation per step"},
+		{"categories[1].process[2]", "between 0 and 1"},
+		{"categories[1].process[3].condition", "unknown condition"},
+	} {
+		if !hasViolation(vs, want.path, want.msg) {
+			t.Errorf("expected %q at %s, got: %+v", want.msg, want.path, vs)
+		}
+	}
+	if hasViolation(vs, "categories[0]", "") {
+		t.Errorf("the valid pipeline should pass, got: %+v", vs)
+	}
+}
+
+func TestValidateOverlay(t *testing.T) {
+	raw := `
+configuration:
+  logging:
+    output: console
+    level: info
+  behavior:
+    overlay:
+      show: [date, weather]
+      quote-file: ~/quotes.txt
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+    behavior:
+      overlay:
+        show: [quote, moon]
+        font-size: -4
+`
+	vs := runValidators(t, raw)
+	if !hasViolation(vs, "categories[0].behavior.overlay.show[1]", "unknown overlay item") {
+		t.Errorf("expected an unknown item violation, got: %+v", vs)
+	}
+	if !hasViolation(vs, "categories[0].behavior.overlay.font-size", "positive") {
+		t.Errorf("expected a font-size violation, got: %+v", vs)
+	}
+	if hasViolation(vs, "quote-file", "") {
+		t.Errorf("the inherited quote-file should satisfy quote, got: %+v", vs)
+	}
+
+	vs = runValidators(t, `
+configuration:
+  logging:
+    output: console
+    level: info
+  behavior:
+    overlay:
+      show: [quote]
+categories:
+  - name: "Photos"
+    source: "/walls/photos"
+    enabled: true
+`)
+	if !hasViolation(vs, "configuration.behavior.overlay.quote-file", "required") {
+		t.Errorf("expected a quote-file violation, got: %+v", vs)
+	}
+}
+
+func TestValidateCollage(t *testing.T) {
+	raw := validBase + `
+  - name: "Nature"
+    source: "/walls/nature"
+    enabled: false
+  - name: "Wall"
+    enabled: true
+    collage:
+      count: 6
+      layout: mosaic
+      from: [Nature]
+  - name: "Broken"
+    source: "/walls/broken"
+    enabled: true
+    collage:
+      from: [Nature, Wall, Missing]
+  - name: "Empty"
+    enabled: true
+    collage:
+      layout: grid
+`
+	vs := runValidators(t, raw)
+	for _, want := range []struct{ path, msg string }{
+		{"categories[2].collage", "mutually exclusive"},
+		{"categories[2].collage.from[1]", "is a collage itself"},
+		{"categories[2].collage.from[2]", `no category named "Missing"`},
+		{"categories[3].collage.from", "required"},
+	} {
+		if !hasViolation(vs, want.path, want.msg) {
+			t.Errorf("expected %q at %s, got: %+v", want.msg, want.path, vs)
+		}
+	}
+	if hasViolation(vs, "categories[1]", "") {
+		t.Errorf("the valid collage should pass, got: %+v", vs)
+	}
+}
+
+func TestValidateGenerate(t *testing.T) {
+	raw := validBase + `
+  - name: "Sky"
+    enabled: true
+    generate:
+      type: linear-gradient
+      colors: ["#0b1d3a", "#f4a261"]
+      angle: 90
+  - name: "Mixed"
+    source: "/walls/mixed"
+    enabled: true
+    generate:
+      type: solid
+      colors: ["#000000"]
+  - name: "Plasma"
+    enabled: true
+    generate:
+      type: plasma
+      colors: ["#000000", "#ffffff"]
+  - name: "Day"
+    source: "/walls"
+    enabled: true
+    variants:
+      - hours: "06:00-18:00"
+        generate:
+          type: solid
+          colors: ["#87ceeb"]
+      - hours: "18:00-06:00"
+        source: "night"
+        generate:
+          type: solid
+          colors: ["#000000", "#111111"]
+`
+	vs := runValidators(t, raw)
+	for _, want := range []struct{ path, msg string }{
+		{"categories[1].generate", "mutually exclusive"},
+		{"categories[2].generate", `unknown generator type "plasma"`},
+		{"categories[3].variants[1].generate", "mutually exclusive with source/sources"},
+		{"categories[3].variants[1].generate", "solid takes exactly one color"},
+	} {
+		if !hasViolation(vs, want.path, want.msg) {
+			t.Errorf("expected %q at %s, got: %+v", want.msg, want.path, vs)
+		}
+	}
+	for _, ok := range []string{"categories[0]", "categories[3].variants[0]"} {
+		if hasViolation(vs, ok, "") {
+			t.Errorf("%s should pass, got: %+v", ok, vs)
+		}
+	}
+}
+
+func TestValidateCalendar(t *testing.T) {
+	raw := validBase + `
+  - name: "Nature"
+    source: "/walls/nature"
+    enabled: false
+  - name: "Month"
+    enabled: true
+    calendar:
+      background: Nature
+      events: ~/calendar.ics
+      week-start: sunday
+  - name: "Broken"
+    source: "/walls/broken"
+    enabled: true
+    calendar:
+      background: Month
+      accent: orange
+  - name: "Wall"
+    enabled: true
+    collage:
+      from: [Nature, Month]
+`
+	vs := runValidators(t, raw)
+	for _, want := range []struct{ path, msg string }{
+		{"categories[2].calendar", "mutually exclusive"},
+		{"categories[2].calendar", `invalid color "orange"`},
+		{"categories[2].calendar.background", `"Month" is a calendar itself`},
+		{"categories[3].collage.from[1]", `"Month" is a calendar itself`},
+	} {
+		if !hasViolation(vs, want.path, want.msg) {
+			t.Errorf("expected %q at %s, got: %+v", want.msg, want.path, vs)
+		}
+	}
+	if hasViolation(vs, "categories[1]", "") {
+		t.Errorf("the valid calendar should pass, got: %+v", vs)
 	}
 }
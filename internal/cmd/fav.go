@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/tags"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// markKind describes one of the two marks stored as reserved tags in the
+// tag database: favorite and banned.
+type markKind struct {
+	name     string // command name: fav or ban
+	tag      string // tags.Favorite or tags.Banned
+	opposite string // the mark a file loses when given this one
+	noun     string // "favorite", "banned image"
+	short    string
+	long     string
+	example  string
+	done     string // message printed after marking, with the path
+}
+
+var (
+	favMark = markKind{
+		name:     "fav",
+		tag:      tags.Favorite,
+		opposite: tags.Banned,
+		noun:     "favorite",
+		short:    "Mark the current wallpaper as a favorite",
+		long: `Mark the current wallpaper (or the given file) as a favorite.
+
+Favorites are drawn configuration.favorites.weight times as often as the
+other images of their category, and a category with tags: favorite picks
+only among them. Favoriting a banned image lifts the ban.`,
+		example: `  # The wallpaper on the desktop now (the primary monitor's, after a
+  # per-monitor change)
+  gopaper fav
+
+  # Monitor 2's wallpaper
+  gopaper fav --monitor 2
+
+  # Every favorite, as JSON
+  gopaper fav list --json`,
+		done: "Added %s to favorites",
+	}
+	banMark = markKind{
+		name:     "ban",
+		tag:      tags.Banned,
+		opposite: tags.Favorite,
+		noun:     "banned image",
+		short:    "Ban the current wallpaper so it is never picked again",
+		long: `Ban the current wallpaper (or the given file): no category picks it again.
+It stays on the desktop until the next change. Banning a favorite removes
+it from the favorites.`,
+		example: `  # Never show the current wallpaper again, then change it
+  gopaper ban && gopaper
+
+  # Lift a ban
+  gopaper ban rm ~/Pictures/Walls/blurry.jpg`,
+		done: "Banned %s",
+	}
+)
+
+// FavCmd marks images as favorites.
+func FavCmd() *cobra.Command { return markCmd(favMark) }
+
+// BanCmd bans images from every category.
+func BanCmd() *cobra.Command { return markCmd(banMark) }
+
+// markCmd builds the fav or ban command: marking the current wallpaper or
+// a given file, plus its list and rm subcommands.
+func markCmd(k markKind) *cobra.Command {
+	var (
+		configPath string
+		monitor    int
+	)
+
+	cmd := &cobra.Command{
+		Use:     k.name + " [file]",
+		Short:   k.short,
+		Long:    k.long,
+		Example: k.example,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g, db, err := openTagDB(configPath)
+			if err != nil {
+				return err
+			}
+			path, err := markTarget(g, args, monitor)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("could not mark %s: %w", path, err)
+			}
+			if err := mark(db, path, k); err != nil {
+				return err
+			}
+			if err := db.Save(); err != nil {
+				return err
+			}
+			pterm.Success.Printfln(k.done, path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	cmd.Flags().IntVarP(&monitor, "monitor", "m", 0, "After a per-monitor change, act on this monitor's wallpaper (1-based)")
+	cmd.AddCommand(markListCmd(k), markRmCmd(k))
+	return cmd
+}
+
+// markRmCmd removes the mark from the current wallpaper or a given file.
+func markRmCmd(k markKind) *cobra.Command {
+	var (
+		configPath string
+		monitor    int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rm [file]",
+		Short: fmt.Sprintf("Unmark the current wallpaper (or a file) as a %s", k.noun),
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g, db, err := openTagDB(configPath)
+			if err != nil {
+				return err
+			}
+			path, err := markTarget(g, args, monitor)
+			if err != nil {
+				return err
+			}
+			if db.Remove(path, k.tag) == 0 {
+				pterm.Info.Printfln("%s is not a %s", path, k.noun)
+				return nil
+			}
+			if err := db.Save(); err != nil {
+				return err
+			}
+			pterm.Success.Printfln("%s is no longer a %s", path, k.noun)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	cmd.Flags().IntVarP(&monitor, "monitor", "m", 0, "After a per-monitor change, act on this monitor's wallpaper (1-based)")
+	return cmd
+}
+
+// markedFile is one entry of fav list --json / ban list --json.
+type markedFile struct {
+	Path   string   `json:"path"`
+	Tags   []string `json:"tags"`
+	Exists bool     `json:"exists"`
+}
+
+// markListCmd lists the files carrying the mark.
+func markListCmd(k markKind) *cobra.Command {
+	var (
+		configPath string
+		asJSON     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: fmt.Sprintf("List every %s", k.noun),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, db, err := openTagDB(configPath)
+			if err != nil {
+				return err
+			}
+			files := []markedFile{}
+			for _, f := range db.Files() {
+				if !db.Has(f, k.tag) {
+					continue
+				}
+				_, err := os.Stat(f)
+				files = append(files, markedFile{Path: f, Tags: db.Tags(f), Exists: err == nil})
+			}
+
+			if asJSON {
+				data, err := json.MarshalIndent(files, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+			if len(files) == 0 {
+				pterm.Info.Printfln("No %ss yet", k.noun)
+				return nil
+			}
+			table := pterm.TableData{{"File", "Tags"}}
+			for _, f := range files {
+				path := f.Path
+				if !f.Exists {
+					path += " (missing)"
+				}
+				table = append(table, []string{path, strings.Join(f.Tags, ", ")})
+			}
+			return pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the list as JSON")
+	return cmd
+}
+
+// mark gives the file at path the mark k, dropping the opposite one.
+func mark(db *tags.DB, path string, k markKind) error {
+	if _, err := db.Add(path, k.tag); err != nil {
+		return err
+	}
+	db.Remove(path, k.opposite)
+	return nil
+}
+
+// markTarget returns the file fav/ban act on: the one named in args, else
+// the current wallpaper according to history (monitor's, when given), else
+// the one the desktop reports.
+func markTarget(g *models.Gopaper, args []string, monitor int) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+
+	entry, ok, err := currentEntry(g.Viper)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		if monitor == 0 {
+			return entry.Path, nil
+		}
+		for _, m := range entry.Monitors {
+			if m.Monitor == monitor {
+				return m.Path, nil
+			}
+		}
+		return "", fmt.Errorf("the current wallpaper has no monitor %d: it was not a per-monitor change covering it", monitor)
+	}
+
+	if monitor != 0 {
+		return "", errors.New("--monitor needs a per-monitor change in history")
+	}
+	path, err := helper.GetPreviousWallpaper()
+	if err != nil {
+		return "", fmt.Errorf("could not determine the current wallpaper: %w", err)
+	}
+	if path == "" {
+		return "", fmt.Errorf("could not determine the current wallpaper: %w", history.ErrHistoryEmpty)
+	}
+	return path, nil
+}
+
+// currentEntry returns the history entry of the current wallpaper; ok is
+// false when history has none.
+func currentEntry(v *viper.Viper) (entry history.Entry, ok bool, err error) {
+	histPath, err := config.HistoryPath(v)
+	if err != nil {
+		return entry, false, fmt.Errorf("could not determine history path: %w", err)
+	}
+	h, err := history.Load(histPath, config.HistoryLimit(v))
+	if err != nil {
+		return entry, false, fmt.Errorf("could not load history: %w", err)
+	}
+	if h.CurrentIndex < 0 || h.CurrentIndex >= len(h.Entries) {
+		return entry, false, nil
+	}
+	return h.Entries[h.CurrentIndex], true, nil
+}
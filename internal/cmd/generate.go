@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/generate"
+	"github.com/lucasassuncao/gopaper/internal/models"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// generatedWallpaper renders gen at the resolution of the 1-based monitor
+// (0 for the largest, covering every monitor) into the generate cache and
+// returns the image's path.
+func generatedWallpaper(v *viper.Viper, log *pterm.Logger, gen *models.Generate, monitor int) (string, error) {
+	spec, err := generate.FromConfig(*gen)
+	if err != nil {
+		return "", fmt.Errorf("invalid generator: %w", err)
+	}
+	size, err := monitorSize(monitor)
+	if err != nil {
+		log.Debug("could not determine the monitor size, using the default size", log.Args("size", defaultScreenSize.String(), "error", err))
+		size = defaultScreenSize
+	}
+	dir, err := config.GenerateCacheDir(v)
+	if err != nil {
+		return "", err
+	}
+	out, err := generate.Apply(spec, size, dir)
+	if err != nil {
+		return "", fmt.Errorf("could not generate the wallpaper: %w", err)
+	}
+	log.Debug("using generated image", log.Args("generator", spec.String(), "path", out))
+	return out, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/lucasassuncao/gopaper/internal/config"
 	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/tags"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
@@ -25,8 +26,9 @@ func HistoryCmd() *cobra.Command {
 		Long: `Open an interactive list of every wallpaper recorded in history.
 
 Arrow keys (or j/k) navigate, "/" filters by name or category, Enter
-reapplies the selected wallpaper (with the configured transition), and
-q quits without changing anything.`,
+reapplies the selected wallpaper (with the configured transition), f and b
+toggle the selected wallpaper's favorite and banned marks (see gopaper fav
+and gopaper ban), and q quits without changing anything else.`,
 		Example: `  # Browse the full history
   gopaper history
 
@@ -52,6 +54,9 @@ func runHistoryTUI(categoryFilter string) error {
 		}
 		// No config file found: fall back to the built-in history defaults.
 	}
+	if err := useBackend(v); err != nil {
+		return err
+	}
 
 	histPath, err := config.HistoryPath(v)
 	if err != nil {
@@ -77,20 +82,32 @@ func runHistoryTUI(categoryFilter string) error {
 		return nil
 	}
 
+	var db *tags.DB
+	if p, err := config.TagDBPath(v); err == nil {
+		if db, err = tags.Open(p); err != nil {
+			logger.Warn("could not open the tag database, f and b are disabled", logger.Args("error", err))
+		}
+	}
+
 	items := make([]list.Item, len(entries))
 	for i, e := range entries {
-		items[i] = historyItem{entry: e}
+		items[i] = historyItem{entry: e, db: db}
 	}
 
 	l := list.New(items, list.NewDefaultDelegate(), 0, 0)
-	l.Title = "gopaper history (newest first) — Enter applies, q quits"
+	l.Title = "gopaper history (newest first) — Enter applies, f favorite, b ban, q quits"
 	l.SetShowStatusBar(false)
 
-	m := historyModel{list: l}
+	m := historyModel{list: l, db: db}
 	res, err := tea.NewProgram(&m, tea.WithAltScreen()).Run()
 	if err != nil {
 		return fmt.Errorf("could not run history TUI: %w", err)
 	}
+	if db != nil {
+		if err := db.Save(); err != nil {
+			logger.Warn("could not save favorites and bans", logger.Args("error", err))
+		}
+	}
 
 	final, ok := res.(*historyModel)
 	if !ok || final.chosen == nil {
@@ -99,6 +116,9 @@ func runHistoryTUI(categoryFilter string) error {
 	entry := *final.chosen
 
 	if err := applyHistoryEntry(v, entry); err != nil {
+		if vetoed(err) {
+			return nil
+		}
 		return err
 	}
 
@@ -121,8 +141,10 @@ func runHistoryTUI(categoryFilter string) error {
 }
 
 // historyItem adapts a history.Entry to the bubbles list item interface.
+// db supplies the favorite/banned marks shown in the description.
 type historyItem struct {
 	entry history.Entry
+	db    *tags.DB
 }
 
 func (i historyItem) Title() string { return filepath.Base(i.entry.Path) }
@@ -132,6 +154,20 @@ func (i historyItem) Description() string {
 	if n := len(i.entry.Monitors); n > 0 {
 		desc += fmt.Sprintf(" · %d monitors", n)
 	}
+	if i.entry.Panorama {
+		desc += " · panorama"
+	}
+	if i.entry.Calendar {
+		desc += " · calendar"
+	} else if n := len(i.entry.Parts); n > 0 {
+		desc += fmt.Sprintf(" · collage of %d", n)
+	}
+	if i.db.Has(i.entry.Path, tags.Favorite) {
+		desc += " · ★ favorite"
+	}
+	if i.db.Has(i.entry.Path, tags.Banned) {
+		desc += " · banned"
+	}
 	return desc
 }
 
@@ -140,9 +176,11 @@ func (i historyItem) FilterValue() string { return i.entry.Category + " " + i.en
 // historyModel is the Bubble Tea model for the history browser: a plain
 // list where Enter records the selection and quits; the applying happens
 // after the program exits (runHistoryTUI), keeping the TUI side-effect-free.
+// f and b toggle marks in db, which is likewise only saved after exit.
 type historyModel struct {
 	list   list.Model
 	chosen *history.Entry
+	db     *tags.DB
 }
 
 func (m *historyModel) Init() tea.Cmd { return nil }
@@ -164,6 +202,10 @@ func (m *historyModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.chosen = &item.entry
 			}
 			return m, tea.Quit
+		case "f":
+			return m, m.toggleMark(favMark)
+		case "b":
+			return m, m.toggleMark(banMark)
 		}
 	}
 
@@ -172,4 +214,23 @@ func (m *historyModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// toggleMark removes k's mark from the selected wallpaper, or gives it the
+// mark (dropping the opposite one), and reports the change in the status
+// line.
+func (m *historyModel) toggleMark(k markKind) tea.Cmd {
+	item, ok := m.list.SelectedItem().(historyItem)
+	if !ok || m.db == nil {
+		return nil
+	}
+	path := item.entry.Path
+	if m.db.Has(path, k.tag) {
+		m.db.Remove(path, k.tag)
+		return m.list.NewStatusMessage(fmt.Sprintf("%s is no longer a %s", filepath.Base(path), k.noun))
+	}
+	if err := mark(m.db, path, k); err != nil {
+		return m.list.NewStatusMessage(err.Error())
+	}
+	return m.list.NewStatusMessage(fmt.Sprintf(k.done, filepath.Base(path)))
+}
+
 func (m *historyModel) View() string { return m.list.View() }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/hooks"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// preChange runs the pre-change hooks for the change entry is about to
+// record. A non-nil error, wrapping hooks.ErrVetoed, means a hook vetoed the
+// change (already logged): it must not be applied. Hooks that can't be read
+// from the configuration are logged and skipped.
+func preChange(v *viper.Viper, log *pterm.Logger, entry history.Entry) error {
+	list, err := config.Hooks(v, hooks.PreChange)
+	if err != nil {
+		log.Warn("invalid hooks configuration, skipping the pre-change hooks", log.Args("error", err))
+		return nil
+	}
+	if err := hooks.Run(context.Background(), hooks.PreChange, list, entry, hooks.Applied{}); err != nil {
+		log.Info("wallpaper change vetoed", log.Args("category", entry.Category, "wallpaper", entry.Path, "reason", err))
+		return err
+	}
+	return nil
+}
+
+// postChange has the backend record the change entry recorded (the file
+// backend's manifest), then runs the post-change hooks, which may read it,
+// with applied, what the change handed to the desktop. Failures are logged,
+// never failing the change itself.
+func postChange(v *viper.Viper, log *pterm.Logger, entry history.Entry, applied hooks.Applied) {
+	if err := helper.RecordChange(entry); err != nil {
+		log.Warn("could not record the change for the backend", log.Args("error", err))
+	}
+	list, err := config.Hooks(v, hooks.PostChange)
+	if err != nil {
+		log.Warn("invalid hooks configuration, skipping the post-change hooks", log.Args("error", err))
+		return
+	}
+	if err := hooks.Run(context.Background(), hooks.PostChange, list, entry, applied); err != nil {
+		log.Warn("post-change hook failed", log.Args("error", err))
+	}
+}
+
+// vetoed reports whether err is a pre-change hook's veto, which ends a
+// command without failing it.
+func vetoed(err error) bool {
+	return errors.Is(err, hooks.ErrVetoed)
+}
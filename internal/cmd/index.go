@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/models"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// IndexCmd groups the file-index maintenance subcommands.
+func IndexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Manage the persistent file index of the source directories",
+		Long: `Manage the on-disk file index enabled by configuration.index.
+
+With the index enabled, each run reads file names, sizes and times from the
+index instead of listing and stat'ing every source directory, re-reading a
+directory only when its modification time changed. Indexes are also rebuilt
+from scratch once older than configuration.index.ttl.`,
+	}
+	cmd.AddCommand(indexRebuildCmd())
+	return cmd
+}
+
+// indexRebuildCmd rescans every source directory from scratch.
+func indexRebuildCmd() *cobra.Command {
+	var (
+		configPath   string
+		categoryFlag string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Rescan every source directory and rewrite its index",
+		Long: `Rescan the source directories of every category (or the ones named with
+--category) from scratch and rewrite their indexes, including every
+variant's directories regardless of which one is active right now.
+
+Useful after changes the incremental refresh can't see, such as images
+edited in place on a network share.`,
+		Example: `  # Rebuild every category's index
+  gopaper index rebuild
+
+  # Only two categories
+  gopaper index rebuild --category "Nature,Photos"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g := &models.Gopaper{Viper: viper.New()}
+			if err := preRunHandler(g, configPath); err != nil {
+				return err
+			}
+			return runIndexRebuild(g, categoryFlag)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	cmd.Flags().StringVar(&categoryFlag, "category", "", "Comma-separated category names to rebuild (default: all categories)")
+	return cmd
+}
+
+// runIndexRebuild rebuilds the index of each selected category's
+// directories and prints a summary table. A directory that can't be read is
+// reported and skipped; the command fails only when none could be indexed.
+func runIndexRebuild(g *models.Gopaper, categoryFlag string) error {
+	store, err := config.IndexSettings(g.Viper)
+	if err != nil {
+		return err
+	}
+	if !g.Viper.GetBool("configuration.index.enabled") {
+		g.Logger.Warn("configuration.index.enabled is false: indexes are written but not used until it is enabled")
+	}
+
+	all, err := config.UnmarshalConfig(g)
+	if err != nil {
+		return err
+	}
+	categories, err := FilterCategories(all, ParseCategoryNames(categoryFlag), true, g.Logger)
+	if err != nil {
+		return err
+	}
+
+	table := pterm.TableData{{"Category", "Directory", "Files"}}
+	indexed, failed := 0, 0
+	for _, c := range categories {
+		dirs := helper.AllSourceDirs(c)
+		if c.Wallhaven != nil {
+			dir, err := config.WallhavenCacheDir(g.Viper, c.Name, c.Wallhaven.Cache)
+			if err != nil {
+				g.Logger.Warn("could not resolve wallhaven cache directory, skipping category", g.Logger.Args("category", c.Name, "error", err))
+				continue
+			}
+			dirs = []models.SourceDir{{Path: dir}}
+		}
+		for _, d := range dirs {
+			path := config.ExpandTilde(d.Path)
+			idx, err := store.Rebuild(path, helper.IndexDepth(c))
+			if idx == nil {
+				g.Logger.Warn("could not index directory", g.Logger.Args("category", c.Name, "directory", path, "error", err))
+				failed++
+				continue
+			}
+			if err != nil {
+				g.Logger.Warn("could not save index", g.Logger.Args("category", c.Name, "directory", path, "error", err))
+				failed++
+				continue
+			}
+			indexed++
+			table = append(table, []string{c.Name, path, strconv.Itoa(idx.FileCount())})
+		}
+	}
+
+	if indexed == 0 {
+		if failed == 0 {
+			return fmt.Errorf("no source directories to index")
+		}
+		return fmt.Errorf("could not index any source directory")
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+}
@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/lucasassuncao/gopaper/internal/config"
 	"github.com/lucasassuncao/gopaper/internal/helper"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // MonitorsCmd lists the connected monitors with the 1-based index gopaper
@@ -14,22 +17,45 @@ import (
 // categories[].monitor, so users can tell which physical monitor a given
 // index refers to.
 func MonitorsCmd() *cobra.Command {
-	return &cobra.Command{
+	var configPath string
+
+	cmd := &cobra.Command{
 		Use:   "monitors",
 		Short: "List connected monitors and the index gopaper uses for each",
 		Long: `List every monitor gopaper can target, with the 1-based index used by
 configuration.behavior.monitor ("monitor1", "monitor2", ...) and
 categories[].monitor.
 
+On Windows monitors are enumerated by IDesktopWallpaper; elsewhere by
+swaymsg, wlr-randr or xrandr (the first whose session is running), and the
+device path is the output's name (e.g. "DP-1").
+
 Name is the monitor's EDID-reported name (e.g. "ASUS VG32VQ1B"), read via
-WMI; falls back to the 3-letter manufacturer code (e.g. "BOE") when a
+WMI or xrandr --verbose, or the compositor's make and model on Wayland;
+falls back to the 3-letter manufacturer code (e.g. "BOE") when a
 monitor's EDID doesn't set a friendly name, which is common for laptop
 panels. Position is each monitor's desktop rectangle, in the same
-arrangement as Windows Display Settings: the monitor at 0,0 is the
+arrangement as the display settings: the monitor at 0,0 is the
 reference point, and others are offset from it (e.g. a monitor at
-"1920,0" sits to its right).`,
+"1920,0" sits to its right). On Wayland, positions and sizes are in
+logical pixels, the resolution divided by the output's scale.
+
+With the file backend and configuration.output.monitors, the configured
+monitors are listed instead.`,
 		Example: `  gopaper monitors`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.New()
+			if err := loadConfig(v, configPath); err != nil {
+				var notFound config.ConfigFileNotFoundError
+				if configPath != "" || !errors.As(err, &notFound) {
+					return fmt.Errorf("could not load configuration: %w", err)
+				}
+				// No config file found: list the desktop's monitors.
+			}
+			if err := useBackend(v); err != nil {
+				return err
+			}
+
 			details, err := helper.ListMonitorDetails()
 			if err != nil {
 				return fmt.Errorf("could not list monitors: %w", err)
@@ -50,7 +76,10 @@ reference point, and others are offset from it (e.g. a monitor at
 				})
 			}
 
-			return pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+			return pterm.DefaultTable.WithHasHeader().WithData(table).WithWriter(cmd.OutOrStdout()).Render()
 		},
 	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	return cmd
 }
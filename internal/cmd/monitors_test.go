@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasassuncao/gopaper/internal/helper"
+)
+
+func TestMonitorsCmd_FileBackend(t *testing.T) {
+	for _, env := range []string{"DISPLAY", "WAYLAND_DISPLAY", "SWAYSOCK"} {
+		t.Setenv(env, "")
+	}
+	t.Cleanup(func() {
+		if err := helper.UseBackend(helper.DefaultBackend); err != nil {
+			t.Error(err)
+		}
+	})
+	dir := t.TempDir()
+	config := fileBackendConfig(t, dir, "", "nature")
+
+	cmd := MonitorsCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--config", config})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"left.png", "right.png"} {
+		path := filepath.Join(dir, "out", name)
+		if !strings.Contains(out.String(), path) {
+			t.Errorf("monitor%d (%s) missing from:\n%s", i+1, path, out.String())
+		}
+	}
+}
@@ -2,16 +2,17 @@ package cmd
 
 import (
 	"fmt"
-	"path/filepath"
 	"time"
 
+	"github.com/lucasassuncao/gopaper/internal/compose"
 	"github.com/lucasassuncao/gopaper/internal/config"
-	"github.com/lucasassuncao/gopaper/internal/filters"
 	"github.com/lucasassuncao/gopaper/internal/helper"
 	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/hooks"
 	"github.com/lucasassuncao/gopaper/internal/models"
 	"github.com/lucasassuncao/gopaper/internal/weather"
 
+	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
 )
 
@@ -35,11 +36,14 @@ func runPerMonitor(g *models.Gopaper, active []*models.Categories, now time.Time
 		return false, nil
 	}
 
+	opts := pickOptions(g, "")
 	var (
 		targets        []helper.MonitorTarget
+		indices        []int
 		monitorEntries []history.MonitorEntry
 		primary        *models.Categories
 		primaryPath    string
+		primaryParts   []string
 	)
 	for i, devicePath := range monitors {
 		candidates := categoriesForMonitor(active, i+1)
@@ -49,18 +53,20 @@ func runPerMonitor(g *models.Gopaper, active []*models.Categories, now time.Time
 			continue
 		}
 
-		fullPath, err := pickWallpaperFile(cat, now, ws, conditions, wallhavenDirs[cat])
+		fullPath, parts, err := pickWallpaper(g, cat, i+1, now, ws, conditions, wallhavenDirs, opts)
 		if err != nil {
 			g.Logger.Warn("could not pick a wallpaper for monitor, leaving it unchanged",
 				g.Logger.Args("monitor", i+1, "category", cat.Name, "error", err))
 			continue
 		}
 
-		targets = append(targets, helper.MonitorTarget{DevicePath: devicePath, Path: fullPath})
-		monitorEntries = append(monitorEntries, history.MonitorEntry{Monitor: i + 1, Path: fullPath, Category: cat.Name})
+		targets = append(targets, helper.MonitorTarget{DevicePath: devicePath, Path: wallpaperPath(g.Viper, g.Logger, cat, fullPath, i+1, now, ws, conditions)})
+		indices = append(indices, i+1)
+		monitorEntries = append(monitorEntries, history.MonitorEntry{Monitor: i + 1, Path: fullPath, Category: cat.Name, Parts: parts})
 		if primary == nil {
 			primary = cat
 			primaryPath = fullPath
+			primaryParts = parts
 		}
 	}
 
@@ -69,29 +75,41 @@ func runPerMonitor(g *models.Gopaper, active []*models.Categories, now time.Time
 		return true, fmt.Errorf("enabled categories not found")
 	}
 
-	if err := helper.SetWallpapersPerMonitor(targets); err != nil {
-		g.Logger.Error("Error setting per-monitor wallpapers", g.Logger.Args("error", err))
-		return true, fmt.Errorf("error setting the wallpaper: %w", err)
-	}
-
 	// SetPosition is global in IDesktopWallpaper — there is no per-monitor
-	// position, so the primary monitor's category mode wins.
+	// position, so the primary monitor's category mode wins. A composed
+	// canvas must span the desktop to line up with the monitors.
 	mode := config.ModeForCategory(g.Viper, primary.ModeOverride())
-	if err := helper.SetWallpaperMode(mode); err != nil {
-		g.Logger.Error("Error setting wallpaper mode", g.Logger.Args("error", err))
-		return true, fmt.Errorf("error setting wallpaper mode: %w", err)
+	if !helper.PerMonitorSupported() {
+		mode = "span"
 	}
-
 	entry := history.Entry{
 		Path:      primaryPath,
 		Category:  primary.Name,
 		Mode:      mode,
 		Timestamp: time.Now(),
 		Monitors:  monitorEntries,
+		Calendar:  primary.Calendar != nil,
+		Parts:     primaryParts,
+	}
+	if preChange(g.Viper, g.Logger, entry) != nil {
+		return true, nil // vetoed, already logged
+	}
+
+	canvas, err := setMonitorWallpapers(g.Viper, g.Logger, targets, mode)
+	if err != nil {
+		g.Logger.Error("Error setting per-monitor wallpapers", g.Logger.Args("error", err))
+		return true, fmt.Errorf("error setting the wallpaper: %w", err)
+	}
+	if err := helper.SetWallpaperMode(mode); err != nil {
+		g.Logger.Error("Error setting wallpaper mode", g.Logger.Args("error", err))
+		return true, fmt.Errorf("error setting wallpaper mode: %w", err)
 	}
+
 	if err := recordHistoryEntry(g, entry); err != nil {
 		g.Logger.Warn("Could not record history", g.Logger.Args("error", err))
 	}
+	exportTheme(g.Viper, g.Logger, targets[0].Path)
+	postChange(g.Viper, g.Logger, entry, monitorsApplied(targets, indices, canvas))
 
 	for _, m := range monitorEntries {
 		g.Logger.Info("Wallpaper changed successfully.",
@@ -110,6 +128,12 @@ func runPerMonitor(g *models.Gopaper, active []*models.Categories, now time.Time
 // index isn't connected — pinning to a monitor is best-effort and must
 // never break a working setup).
 func runSingleMonitor(g *models.Gopaper, cat *models.Categories, monitor int, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDirs map[*models.Categories]string) (handled bool, err error) {
+	if !helper.PerMonitorSupported() {
+		// A composed canvas would blank every other monitor, the opposite of
+		// leaving them untouched.
+		g.Logger.Warn("per-monitor wallpapers are not supported here, falling back to a single wallpaper", g.Logger.Args("category", cat.Name, "monitor", monitor))
+		return false, nil
+	}
 	monitors, err := helper.ListMonitors()
 	if err != nil {
 		g.Logger.Warn("could not enumerate monitors, falling back to a single wallpaper", g.Logger.Args("error", err))
@@ -121,34 +145,41 @@ func runSingleMonitor(g *models.Gopaper, cat *models.Categories, monitor int, no
 		return false, nil
 	}
 
-	fullPath, err := pickWallpaperFile(cat, now, ws, conditions, wallhavenDirs[cat])
+	fullPath, parts, err := pickWallpaper(g, cat, monitor, now, ws, conditions, wallhavenDirs, pickOptions(g, ""))
 	if err != nil {
 		g.Logger.Error("could not pick a wallpaper", g.Logger.Args("category", cat.Name, "error", err))
 		return true, fmt.Errorf("error getting random file: %w", err)
 	}
 
-	target := helper.MonitorTarget{DevicePath: monitors[monitor-1], Path: fullPath}
-	if err := helper.SetWallpapersPerMonitor([]helper.MonitorTarget{target}); err != nil {
+	target := helper.MonitorTarget{DevicePath: monitors[monitor-1], Path: wallpaperPath(g.Viper, g.Logger, cat, fullPath, monitor, now, ws, conditions)}
+	mode := config.ModeForCategory(g.Viper, cat.ModeOverride())
+	entry := history.Entry{
+		Path:      fullPath,
+		Category:  cat.Name,
+		Mode:      mode,
+		Timestamp: time.Now(),
+		Monitors:  []history.MonitorEntry{{Monitor: monitor, Path: fullPath, Category: cat.Name, Parts: parts}},
+		Calendar:  cat.Calendar != nil,
+		Parts:     parts,
+	}
+	if preChange(g.Viper, g.Logger, entry) != nil {
+		return true, nil // vetoed, already logged
+	}
+
+	if err := helper.SetWallpapersPerMonitor([]helper.MonitorTarget{target}, mode); err != nil {
 		g.Logger.Error("Error setting the wallpaper", g.Logger.Args("error", err))
 		return true, fmt.Errorf("error setting the wallpaper: %w", err)
 	}
-
-	mode := config.ModeForCategory(g.Viper, cat.ModeOverride())
 	if err := helper.SetWallpaperMode(mode); err != nil {
 		g.Logger.Error("Error setting wallpaper mode", g.Logger.Args("error", err))
 		return true, fmt.Errorf("error setting wallpaper mode: %w", err)
 	}
 
-	entry := history.Entry{
-		Path:      fullPath,
-		Category:  cat.Name,
-		Mode:      mode,
-		Timestamp: time.Now(),
-		Monitors:  []history.MonitorEntry{{Monitor: monitor, Path: fullPath, Category: cat.Name}},
-	}
 	if err := recordHistoryEntry(g, entry); err != nil {
 		g.Logger.Warn("Could not record history", g.Logger.Args("error", err))
 	}
+	exportTheme(g.Viper, g.Logger, target.Path)
+	postChange(g.Viper, g.Logger, entry, monitorsApplied([]helper.MonitorTarget{target}, []int{monitor}, ""))
 
 	g.Logger.Info("Wallpaper changed successfully.",
 		g.Logger.Args("monitor", monitor),
@@ -158,6 +189,54 @@ func runSingleMonitor(g *models.Gopaper, cat *models.Categories, monitor int, no
 	return true, nil
 }
 
+// setMonitorWallpapers puts each target's image on its monitor, in mode.
+// Where the desktop can't set a wallpaper per monitor, the images are
+// composed into one canvas laid out like the monitors (those without a
+// target are left black) and set as the single wallpaper; canvas is then
+// its path, and the wallpaper mode must be span for it to line up.
+func setMonitorWallpapers(v *viper.Viper, log *pterm.Logger, targets []helper.MonitorTarget, mode string) (canvas string, err error) {
+	if helper.PerMonitorSupported() {
+		return "", helper.SetWallpapersPerMonitor(targets, mode)
+	}
+
+	details, err := helper.ListMonitorDetails()
+	if err != nil {
+		return "", fmt.Errorf("could not lay out the monitors: %w", err)
+	}
+	paths := make(map[string]string, len(targets))
+	for _, t := range targets {
+		paths[t.DevicePath] = t.Path
+	}
+	parts := make([]compose.Part, len(details))
+	for i, d := range details {
+		parts[i] = compose.Part{Path: paths[d.DevicePath], Rect: d.Rect()}
+	}
+
+	dir, err := config.ComposeCacheDir(v)
+	if err != nil {
+		return "", err
+	}
+	canvas, err = compose.Apply(parts, dir)
+	if err != nil {
+		return "", fmt.Errorf("could not compose the monitors' wallpapers: %w", err)
+	}
+	log.Debug("composed per-monitor wallpapers into one spanned image", log.Args("path", canvas, "monitors", len(parts)))
+	return canvas, helper.SetWallpaperFromPath(canvas, "span", false)
+}
+
+// monitorsApplied returns what setMonitorWallpapers handed to the desktop
+// for targets, the images of the 1-based monitors, and canvas.
+func monitorsApplied(targets []helper.MonitorTarget, monitors []int, canvas string) hooks.Applied {
+	applied := hooks.Applied{Path: canvas, Monitors: make(map[int]string, len(targets))}
+	for i, t := range targets {
+		applied.Monitors[monitors[i]] = t.Path
+	}
+	if applied.Path == "" && len(targets) > 0 {
+		applied.Path = targets[0].Path
+	}
+	return applied
+}
+
 // perMonitorEligible filters active down to the categories whose effective
 // monitor mode is per-monitor — an "all" or "monitorN" category never takes
 // part in individual per-monitor draws.
@@ -183,28 +262,41 @@ func categoriesForMonitor(active []*models.Categories, monitor int) []*models.Ca
 	return out
 }
 
-// pickWallpaperFile resolves a category's source directory and picks a
-// random image from it, returning the image's full path.
-func pickWallpaperFile(cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDir string) (string, error) {
-	resolvedSource, ok := helper.ResolveSource(cat, now, ws, conditions, wallhavenDir)
-	if !ok {
-		return "", fmt.Errorf("no active variant for category %q", cat.Name)
+// pickWallpaper picks cat's next wallpaper under opts, for the 1-based
+// monitor (0 for every monitor): a file from its sources, a rendered
+// collage along with the images laid out on it, a calendar along with its
+// background, or an image drawn by its generator in effect now.
+func pickWallpaper(g *models.Gopaper, cat *models.Categories, monitor int, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDirs map[*models.Categories]string, opts helper.PickOptions) (path string, parts []string, err error) {
+	if cat.Collage != nil {
+		return pickCollage(g, cat, now, ws, conditions, wallhavenDirs, opts)
+	}
+	if cat.Calendar != nil {
+		return pickCalendar(g, cat, monitor, now, ws, conditions, wallhavenDirs, opts)
 	}
-	sourcePath := config.ExpandTilde(resolvedSource)
+	if gen, ok := helper.ResolveGenerator(cat, now, ws, conditions); ok {
+		path, err = generatedWallpaper(g.Viper, g.Logger, gen, monitor)
+		return path, nil, err
+	}
+	path, err = pickWallpaperFile(cat, now, ws, conditions, wallhavenDirs[cat], opts)
+	return path, nil, err
+}
 
-	files, err := helper.ReadDirectory(sourcePath)
-	if err != nil {
-		return "", fmt.Errorf("error reading directory: %w", err)
+// pickWallpaperFile resolves a category's source directories and picks a
+// random image from them under opts (narrowed to the category's filter and
+// tag query), returning the image's full path.
+func pickWallpaperFile(cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDir string, opts helper.PickOptions) (string, error) {
+	resolved, ok := helper.ResolveSources(cat, now, ws, conditions, wallhavenDir)
+	if !ok {
+		return "", fmt.Errorf("no active variant for category %q", cat.Name)
 	}
 
-	filter, err := filters.Compile(cat.Filter)
+	opts, err := forCategory(opts, cat)
 	if err != nil {
-		return "", fmt.Errorf("invalid filter for category %q: %w", cat.Name, err)
+		return "", err
 	}
-
-	file, err := helper.GetRandomFile(files, filter, "")
+	file, err := helper.GetRandomFileFromSources(cat, expandSources(resolved), opts)
 	if err != nil {
 		return "", fmt.Errorf("error getting random file: %w", err)
 	}
-	return filepath.Join(sourcePath, file), nil
+	return file, nil
 }
@@ -3,10 +3,13 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/lucasassuncao/gopaper/internal/config"
 	"github.com/lucasassuncao/gopaper/internal/helper"
 	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/hooks"
 	"github.com/lucasassuncao/gopaper/internal/models"
 
 	"github.com/pterm/pterm"
@@ -27,6 +30,9 @@ func navigateHistory(navigate func(*history.History) (history.Entry, error), bou
 		}
 		// No config file found: fall back to the built-in history defaults.
 	}
+	if err := useBackend(v); err != nil {
+		return err
+	}
 
 	histPath, err := config.HistoryPath(v)
 	if err != nil {
@@ -48,6 +54,9 @@ func navigateHistory(navigate func(*history.History) (history.Entry, error), bou
 	}
 
 	if err := applyHistoryEntry(v, entry); err != nil {
+		if vetoed(err) {
+			return nil
+		}
 		return err
 	}
 
@@ -66,62 +75,123 @@ func navigateHistory(navigate func(*history.History) (history.Entry, error), bou
 // path honoring the entry's category transition override (looked up by name
 // in the current config — the category may have been edited or removed
 // since the entry was recorded, in which case the global setting applies).
+// The hooks run around it as around any change; a veto is returned as an
+// error wrapping hooks.ErrVetoed.
 func applyHistoryEntry(v *viper.Viper, entry history.Entry) error {
-	if err := applyEntryWallpaper(v, entry); err != nil {
+	if err := preChange(v, logger, entry); err != nil {
+		return err
+	}
+	applied, spanned, err := applyEntryWallpaper(v, entry)
+	if err != nil {
 		return fmt.Errorf("could not set wallpaper: %w", err)
 	}
+	if spanned {
+		entry.Mode = "span"
+	}
 	if err := helper.SetWallpaperMode(entry.Mode); err != nil {
 		return fmt.Errorf("could not set wallpaper mode: %w", err)
 	}
+	exportTheme(v, logger, firstApplied(applied))
+	postChange(v, logger, entry, applied)
 	return nil
 }
 
 // applyEntryWallpaper puts the entry's image(s) on the desktop: per-monitor
-// when recorded that way, otherwise the single-wallpaper path.
-func applyEntryWallpaper(v *viper.Viper, entry history.Entry) error {
+// or as a panorama when recorded that way, otherwise the single-wallpaper
+// path. applied is what was handed to the desktop; spanned reports that the
+// monitors' images were composed into one canvas, which needs the span
+// mode.
+func applyEntryWallpaper(v *viper.Viper, entry history.Entry) (applied hooks.Applied, spanned bool, err error) {
+	now := &historyNow{v: v}
 	if len(entry.Monitors) > 0 {
-		return applyMonitorsEntry(entry)
+		return applyMonitorsEntry(v, now, entry)
+	}
+	if entry.Panorama {
+		return applyPanoramaEntry(v, now, entry)
 	}
-	return helper.SetWallpaperFromPath(entry.Path, config.TransitionEnabledForCategory(v, categoryTransition(v, entry.Category)))
+	applied.Path = historyWallpaperPath(v, now, entry.Category, historyRenderPath(v, entry.Category, entry.Path, entry.Parts, 0), 0)
+	return applied, false, helper.SetWallpaperFromPath(applied.Path, entry.Mode, config.TransitionEnabledForCategory(v, categoryTransition(v, entry.Category)))
+}
+
+// firstApplied returns the image of applied the theme colors are taken
+// from: the first monitor's when each got its own.
+func firstApplied(applied hooks.Applied) string {
+	if len(applied.Monitors) == 0 {
+		return applied.Path
+	}
+	return applied.Monitors[slices.Min(slices.Collect(maps.Keys(applied.Monitors)))]
 }
 
 // categoryTransition returns the transition override of the named category
 // in the current config, or "" when the category no longer exists (the
 // global setting then applies).
 func categoryTransition(v *viper.Viper, name string) string {
-	var categories []*models.Categories
-	if err := v.UnmarshalKey("categories", &categories); err != nil {
-		return ""
-	}
-	for _, c := range categories {
-		if c.Name == name {
-			return c.TransitionOverride()
-		}
+	return categoryByName(v, name).TransitionOverride()
+}
+
+// categoryByName returns the named category of the current config, or nil
+// when it no longer exists.
+func categoryByName(v *viper.Viper, name string) *models.Categories {
+	categories, err := config.UnmarshalConfig(&models.Gopaper{Viper: v})
+	if err != nil {
+		return nil
 	}
-	return ""
+	return findCategory(categories, name)
 }
 
 // applyMonitorsEntry re-applies a per-monitor history entry by matching each
 // recorded 1-based monitor index against the monitors present now (device
 // paths are not persisted). Entries whose monitor is gone are skipped with a
 // warning; it errors only when none can be applied.
-func applyMonitorsEntry(entry history.Entry) error {
+func applyMonitorsEntry(v *viper.Viper, now *historyNow, entry history.Entry) (applied hooks.Applied, spanned bool, err error) {
 	monitors, err := helper.ListMonitors()
 	if err != nil {
-		return err
+		return applied, false, err
 	}
 
-	var targets []helper.MonitorTarget
+	var (
+		targets []helper.MonitorTarget
+		indices []int
+	)
 	for _, m := range entry.Monitors {
 		idx := m.Monitor - 1
 		if idx < 0 || idx >= len(monitors) {
 			logger.Warn("skipping monitor from history entry: not connected now", logger.Args("monitor", m.Monitor))
 			continue
 		}
-		targets = append(targets, helper.MonitorTarget{DevicePath: monitors[idx], Path: m.Path})
+		targets = append(targets, helper.MonitorTarget{DevicePath: monitors[idx], Path: historyWallpaperPath(v, now, m.Category, historyRenderPath(v, m.Category, m.Path, m.Parts, m.Monitor), m.Monitor)})
+		indices = append(indices, m.Monitor)
 	}
 	if len(targets) == 0 {
-		return fmt.Errorf("none of the entry's monitors are connected")
+		return applied, false, fmt.Errorf("none of the entry's monitors are connected")
+	}
+	canvas, err := setMonitorWallpapers(v, logger, targets, entry.Mode)
+	return monitorsApplied(targets, indices, canvas), canvas != "", err
+}
+
+// applyPanoramaEntry re-slices a panorama history entry's image for the
+// monitors connected now, with the bezel its category has now.
+func applyPanoramaEntry(v *viper.Viper, now *historyNow, entry history.Entry) (applied hooks.Applied, spanned bool, err error) {
+	targets, err := historyPanoramaTargets(v, now, entry)
+	if err != nil {
+		return applied, false, err
+	}
+	canvas, err := setMonitorWallpapers(v, logger, targets, entry.Mode)
+	return monitorsApplied(targets, panoramaMonitors(targets), canvas), canvas != "", err
+}
+
+// historyPanoramaTargets slices a panorama history entry's image for the
+// monitors connected now.
+func historyPanoramaTargets(v *viper.Viper, now *historyNow, entry history.Entry) ([]helper.MonitorTarget, error) {
+	details, err := helper.ListMonitorDetails()
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 {
+		return nil, errors.New("no monitors connected")
 	}
-	return helper.SetWallpapersPerMonitor(targets)
+	path := historyRenderPath(v, entry.Category, entry.Path, entry.Parts, 0)
+	return panoramaTargets(v, logger, categoryByName(v, entry.Category), path, details, func(slice string, monitor int) string {
+		return historyWallpaperPath(v, now, entry.Category, slice, monitor)
+	})
 }
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/compose"
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/weather"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// runPanorama picks one image from cat and spreads it across every
+// monitor, each showing its slice of it as laid out in the display
+// settings. handled reports whether the run was taken over: false means the
+// caller should fall back to the single-wallpaper flow (monitor enumeration
+// failed or only one monitor is connected).
+//
+// Like per-monitor changes, a panorama is always instant.
+func runPanorama(g *models.Gopaper, cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDirs map[*models.Categories]string, previous string) (handled bool, err error) {
+	details, err := helper.ListMonitorDetails()
+	if err != nil {
+		g.Logger.Warn("could not lay out the monitors, falling back to a single wallpaper", g.Logger.Args("error", err))
+		return false, nil
+	}
+	if len(details) <= 1 {
+		g.Logger.Info("only one monitor connected, using the single-wallpaper flow")
+		return false, nil
+	}
+
+	fullPath, parts, err := pickWallpaper(g, cat, 0, now, ws, conditions, wallhavenDirs, pickOptions(g, previous))
+	if err != nil {
+		g.Logger.Error("could not pick a wallpaper", g.Logger.Args("category", cat.Name, "error", err))
+		return true, err
+	}
+
+	targets, err := panoramaTargets(g.Viper, g.Logger, cat, fullPath, details, func(slice string, monitor int) string {
+		return wallpaperPath(g.Viper, g.Logger, cat, slice, monitor, now, ws, conditions)
+	})
+	if err != nil {
+		g.Logger.Error("could not slice the panorama", g.Logger.Args("path", fullPath, "error", err))
+		return true, err
+	}
+	// The slices already fit their monitors exactly; composed back into one
+	// canvas they must span the desktop.
+	mode := "crop"
+	if !helper.PerMonitorSupported() {
+		mode = "span"
+	}
+	entry := history.Entry{
+		Path:      fullPath,
+		Category:  cat.Name,
+		Mode:      mode,
+		Timestamp: time.Now(),
+		Panorama:  true,
+		Calendar:  cat.Calendar != nil,
+		Parts:     parts,
+	}
+	if preChange(g.Viper, g.Logger, entry) != nil {
+		return true, nil // vetoed, already logged
+	}
+
+	canvas, err := setMonitorWallpapers(g.Viper, g.Logger, targets, mode)
+	if err != nil {
+		g.Logger.Error("Error setting the panorama", g.Logger.Args("error", err))
+		return true, fmt.Errorf("error setting the wallpaper: %w", err)
+	}
+	if err := helper.SetWallpaperMode(mode); err != nil {
+		g.Logger.Error("Error setting wallpaper mode", g.Logger.Args("error", err))
+		return true, fmt.Errorf("error setting wallpaper mode: %w", err)
+	}
+
+	if err := recordHistoryEntry(g, entry); err != nil {
+		g.Logger.Warn("Could not record history", g.Logger.Args("error", err))
+	}
+	exportTheme(g.Viper, g.Logger, targets[0].Path)
+	postChange(g.Viper, g.Logger, entry, monitorsApplied(targets, panoramaMonitors(targets), canvas))
+
+	g.Logger.Info("Wallpaper changed successfully.",
+		g.Logger.Args("category", cat.Name),
+		g.Logger.Args("new wallpaper", fullPath),
+		g.Logger.Args("monitors", len(details)),
+	)
+	return true, nil
+}
+
+// panoramaMonitors returns the 1-based monitors of panoramaTargets' targets.
+func panoramaMonitors(targets []helper.MonitorTarget) []int {
+	monitors := make([]int, len(targets))
+	for i := range targets {
+		monitors[i] = i + 1
+	}
+	return monitors
+}
+
+// panoramaTargets cuts the image at path into one slice per monitor of
+// details, spread apart by cat's bezel, and pairs each with its monitor.
+// finish turns a slice into the file handed to the desktop for the 1-based
+// monitor (process steps and overlay).
+func panoramaTargets(v *viper.Viper, log *pterm.Logger, cat *models.Categories, path string, details []helper.MonitorDetail, finish func(slice string, monitor int) string) ([]helper.MonitorTarget, error) {
+	dir, err := config.ComposeCacheDir(v)
+	if err != nil {
+		return nil, err
+	}
+	rects := make([]image.Rectangle, len(details))
+	for i, d := range details {
+		rects[i] = d.Rect()
+	}
+	slices, err := compose.SliceFile(displayPath(v, log, path), rects, config.BezelForCategory(v, cat.BezelOverride()), dir)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]helper.MonitorTarget, len(details))
+	for i, d := range details {
+		targets[i] = helper.MonitorTarget{DevicePath: d.DevicePath, Path: finish(slices[i], i+1)}
+	}
+	return targets, nil
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/overlay"
+	"github.com/lucasassuncao/gopaper/internal/process"
+	"github.com/lucasassuncao/gopaper/internal/weather"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// wallpaperPath returns the file handed to the desktop for the image at
+// path, picked from cat to be shown on the 1-based monitor (0 for a
+// wallpaper mirrored on every monitor): the image rendered through cat's
+// process steps that apply now, then with its overlay text drawn on. A step
+// that fails is logged and skipped, so the image still shows.
+func wallpaperPath(v *viper.Viper, log *pterm.Logger, cat *models.Categories, path string, monitor int, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition) string {
+	out := processedPath(v, log, cat, path, monitor, now, ws, conditions)
+	return overlaidPath(v, log, cat, out, now, ws)
+}
+
+// processedPath returns a copy of the image at path rendered through cat's
+// process steps that apply now, or else displayPath's. A pipeline that
+// can't be rendered is logged and skipped.
+func processedPath(v *viper.Viper, log *pterm.Logger, cat *models.Categories, path string, monitor int, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition) string {
+	if cat == nil || len(cat.Process) == 0 {
+		return displayPath(v, log, path)
+	}
+	steps, err := process.Active(cat.Process, func(name string) bool {
+		return helper.NamedConditionHolds(name, now, ws, conditions)
+	})
+	if err != nil {
+		log.Warn("invalid process steps, using the image unprocessed", log.Args("category", cat.Name, "error", err))
+		return displayPath(v, log, path)
+	}
+	if len(steps) == 0 {
+		return displayPath(v, log, path)
+	}
+
+	var screen image.Point
+	if process.NeedsScreen(steps) {
+		if screen, err = monitorSize(monitor); err != nil {
+			log.Warn("could not determine the monitor size, skipping resize-to-monitor", log.Args("category", cat.Name, "error", err))
+		}
+	}
+	dir, err := config.ProcessCacheDir(v)
+	if err != nil {
+		log.Warn("could not resolve the process cache directory, using the image unprocessed", log.Args("path", path, "error", err))
+		return displayPath(v, log, path)
+	}
+	out, err := process.Apply(path, steps, screen, dir)
+	if err != nil {
+		log.Warn("could not process image, using it unprocessed", log.Args("path", path, "error", err))
+		return displayPath(v, log, path)
+	}
+	log.Debug("using processed image", log.Args("path", path, "processed", out, "steps", fmt.Sprint(steps)))
+	return out
+}
+
+// overlaidPath returns a copy of the image at path with cat's overlay text
+// drawn on, or path itself when cat has no overlay. An overlay that can't
+// be rendered is logged and skipped; an item that can't (e.g. an unreadable
+// quote file) is logged and left out.
+func overlaidPath(v *viper.Viper, log *pterm.Logger, cat *models.Categories, path string, now time.Time, ws *weather.Snapshot) string {
+	ov, err := config.OverlayForCategory(v, cat.OverlayOverride())
+	if err != nil {
+		log.Warn("invalid overlay configuration, skipping the overlay", log.Args("error", err))
+		return path
+	}
+	if ov == nil {
+		return path
+	}
+	st, err := overlay.FromConfig(*ov)
+	if err != nil {
+		log.Warn("invalid overlay configuration, skipping the overlay", log.Args("error", err))
+		return path
+	}
+	units, _ := config.WeatherUnits(v)
+	hostname, _ := os.Hostname()
+	lines, err := overlay.Lines(ov.Show, overlay.Inputs{
+		Now:        now,
+		DateFormat: ov.DateFormat,
+		Weather:    ws,
+		Units:      units,
+		QuoteFile:  ov.QuoteFile,
+		Hostname:   hostname,
+	})
+	if err != nil {
+		log.Warn("could not render every overlay item", log.Args("error", err))
+	}
+
+	dir, err := config.OverlayCacheDir(v)
+	if err != nil {
+		log.Warn("could not resolve the overlay cache directory, skipping the overlay", log.Args("error", err))
+		return path
+	}
+	out, err := overlay.Apply(path, lines, st, dir)
+	if err != nil {
+		log.Warn("could not draw the overlay, skipping it", log.Args("path", path, "error", err))
+		return path
+	}
+	return out
+}
+
+// defaultScreenSize stands in for the monitor size when monitors can't be
+// enumerated, for images rendered from scratch.
+var defaultScreenSize = image.Pt(1920, 1080)
+
+// monitorSize returns the size of the 1-based monitor, or with 0 that of
+// the largest connected monitor, the one a mirrored wallpaper must cover.
+func monitorSize(monitor int) (image.Point, error) {
+	details, err := helper.ListMonitorDetails()
+	if err != nil {
+		return image.Point{}, err
+	}
+	if len(details) == 0 {
+		return image.Point{}, errors.New("no monitor connected")
+	}
+	if monitor > 0 {
+		if monitor > len(details) {
+			return image.Point{}, fmt.Errorf("monitor %d is not connected", monitor)
+		}
+		d := details[monitor-1]
+		return image.Pt(d.Right-d.Left, d.Bottom-d.Top), nil
+	}
+	var largest image.Point
+	for _, d := range details {
+		if w, h := d.Right-d.Left, d.Bottom-d.Top; w*h > largest.X*largest.Y {
+			largest = image.Pt(w, h)
+		}
+	}
+	return largest, nil
+}
+
+// primaryMonitorSize returns the size of the primary monitor, the one at
+// the desktop's origin (the first one when none is).
+func primaryMonitorSize() (image.Point, error) {
+	details, err := helper.ListMonitorDetails()
+	if err != nil {
+		return image.Point{}, err
+	}
+	if len(details) == 0 {
+		return image.Point{}, errors.New("no monitor connected")
+	}
+	for _, d := range details {
+		if d.Left == 0 && d.Top == 0 {
+			return d.Rect().Size(), nil
+		}
+	}
+	return details[0].Rect().Size(), nil
+}
+
+// historyNow is what images re-applied from history are processed and
+// overlaid under: the conditions and the weather now. It is loaded once per
+// navigation, when the first image that needs it is re-applied.
+type historyNow struct {
+	v          *viper.Viper
+	loaded     bool
+	conditions map[string]models.Condition
+	ws         *weather.Snapshot
+}
+
+func (h *historyNow) load() (map[string]models.Condition, *weather.Snapshot) {
+	if !h.loaded {
+		conditions, err := config.LoadConditions(h.v)
+		if err != nil {
+			logger.Warn("invalid conditions, conditioned process steps are skipped", logger.Args("error", err))
+		}
+		h.conditions, h.ws = conditions, fetchWeatherSnapshot(&models.Gopaper{Viper: h.v, Logger: logger})
+		h.loaded = true
+	}
+	return h.conditions, h.ws
+}
+
+// historyWallpaperPath is wallpaperPath for re-applying a history entry's
+// image: its category is looked up by name in the current configuration
+// (only the configuration-level overlay applies when it no longer exists),
+// its process steps run under the conditions holding now, and its overlay
+// shows today's values. An image with neither is shown as is, without
+// loading conditions or weather.
+func historyWallpaperPath(v *viper.Viper, now *historyNow, category, path string, monitor int) string {
+	cat := categoryByName(v, category)
+	if ov, err := config.OverlayForCategory(v, cat.OverlayOverride()); err == nil && ov == nil && (cat == nil || len(cat.Process) == 0) {
+		return displayPath(v, logger, path)
+	}
+	conditions, ws := now.load()
+	return wallpaperPath(v, logger, cat, path, monitor, time.Now(), ws, conditions)
+}
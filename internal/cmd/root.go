@@ -9,11 +9,18 @@ import (
 	"github.com/lucasassuncao/gopaper/internal/filters"
 	"github.com/lucasassuncao/gopaper/internal/helper"
 	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/hooks"
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/index"
+	"github.com/lucasassuncao/gopaper/internal/metacache"
 	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/tags"
 	"github.com/lucasassuncao/gopaper/internal/wallhaven"
 	"github.com/lucasassuncao/gopaper/internal/weather"
 
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // RootCmd represents the base command when called without subcommands
@@ -50,6 +57,12 @@ func RootCmd(g *models.Gopaper, version string) *cobra.Command {
 	cmd.AddCommand(MonitorsCmd())
 	cmd.AddCommand(ValidateCmd())
 	cmd.AddCommand(ShowCmd())
+	cmd.AddCommand(IndexCmd())
+	cmd.AddCommand(TagCmd())
+	cmd.AddCommand(FavCmd())
+	cmd.AddCommand(BanCmd())
+	cmd.AddCommand(DedupeCmd())
+	cmd.AddCommand(ThemeCmd())
 	cmd.AddCommand(selfUpdateCmd(version))
 
 	return cmd
@@ -57,22 +70,7 @@ func RootCmd(g *models.Gopaper, version string) *cobra.Command {
 
 // preRunHandler handle the pre-run configuration loading
 func preRunHandler(g *models.Gopaper, configPath string) error {
-	var err error
-	if configPath != "" {
-		dir := filepath.Dir(configPath)
-		filename := filepath.Base(configPath)
-		ext := filepath.Ext(filename)
-		nameWithoutExt := filename[:len(filename)-len(ext)]
-
-		err = config.InitConfig(g.Viper,
-			config.WithConfigName(nameWithoutExt),
-			config.WithConfigType(ext[1:]),
-			config.WithConfigPath(dir),
-		)
-	} else {
-		err = config.LoadDefault(g.Viper)
-	}
-	if err != nil {
+	if err := loadConfig(g.Viper, configPath); err != nil {
 		if configPath != "" {
 			return fmt.Errorf("configuration file not found at '%s'", configPath)
 		}
@@ -86,6 +84,45 @@ func preRunHandler(g *models.Gopaper, configPath string) error {
 
 	g.Logger = logger
 
+	if err := useBackend(g.Viper); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// loadConfig reads the configuration file at configPath into v, or the one
+// found by the standard lookup when configPath is empty.
+func loadConfig(v *viper.Viper, configPath string) error {
+	if configPath == "" {
+		return config.LoadDefault(v)
+	}
+	dir := filepath.Dir(configPath)
+	filename := filepath.Base(configPath)
+	ext := filepath.Ext(filename)
+	nameWithoutExt := filename[:len(filename)-len(ext)]
+
+	return config.InitConfig(v,
+		config.WithConfigName(nameWithoutExt),
+		config.WithConfigType(ext[1:]),
+		config.WithConfigPath(dir),
+	)
+}
+
+// useBackend selects configuration.behavior.backend for every later change,
+// with configuration.output for the file backend.
+func useBackend(v *viper.Viper) error {
+	name := v.GetString("configuration.behavior.backend")
+	if name == "file" {
+		out, err := config.FileOutput(v)
+		if err != nil {
+			return err
+		}
+		helper.SetFileOutput(out)
+	}
+	if err := helper.UseBackend(name); err != nil {
+		return fmt.Errorf("invalid configuration.behavior.backend: %w", err)
+	}
 	return nil
 }
 
@@ -130,15 +167,20 @@ func runOnce(g *models.Gopaper, categoryFlag string, includeDisabled bool) error
 
 	previous, err := helper.GetPreviousWallpaper()
 	if err != nil {
-		g.Logger.Warn("Could not get previous wallpaper", g.Logger.Args("error", err))
+		// Some backends can't tell; the history knows what was set last.
+		if previous, err = markTarget(g, nil, 0); err != nil {
+			g.Logger.Warn("Could not get previous wallpaper", g.Logger.Args("error", err))
+		}
 	}
+	previous = unconvertedPath(g.Viper, previous)
 	selectedCategory = avoidRepeatCategory(selectedCategory, active, now, ws, conditions, wallhavenDirs, previous)
 
 	// The drawn category decides the run's monitor mode: an "all"
 	// category takes every monitor with one mirrored image (fade
 	// allowed); a "per-monitor" one hands each monitor its own draw
-	// among the per-monitor-eligible categories; a "monitorN" one is
-	// pinned to that single monitor, leaving the others untouched.
+	// among the per-monitor-eligible categories; a "panorama" one
+	// spreads one of its images across every monitor; a "monitorN" one
+	// is pinned to that single monitor, leaving the others untouched.
 	switch mmMode := config.MonitorModeForCategory(g.Viper, selectedCategory.MonitorOverride()); mmMode {
 	case "per-monitor":
 		handled, err := runPerMonitor(g, perMonitorEligible(g.Viper, active), now, ws, conditions, wallhavenDirs)
@@ -146,6 +188,12 @@ func runOnce(g *models.Gopaper, categoryFlag string, includeDisabled bool) error
 			return err
 		}
 		// Fall through to the single-wallpaper flow.
+	case "panorama":
+		handled, err := runPanorama(g, selectedCategory, now, ws, conditions, wallhavenDirs, previous)
+		if handled {
+			return err
+		}
+		// Fall through to the single-wallpaper flow.
 	default:
 		if idx, ok := config.ParseMonitorMode(mmMode); ok {
 			handled, err := runSingleMonitor(g, selectedCategory, idx, now, ws, conditions, wallhavenDirs)
@@ -175,14 +223,14 @@ func activeCategories(g *models.Gopaper, candidates []*models.Categories, now ti
 }
 
 // avoidRepeatCategory swaps selectedCategory for a different active category
-// when it would draw from the same directory as the current wallpaper and
+// when it would draw from the same source as the current wallpaper and
 // another active category could take its place instead.
 func avoidRepeatCategory(selectedCategory *models.Categories, active []*models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDirs map[*models.Categories]string, previous string) *models.Categories {
 	if len(active) <= 1 {
 		return selectedCategory
 	}
-	resolved, ok := helper.ResolveSource(selectedCategory, now, ws, conditions, wallhavenDirs[selectedCategory])
-	if !ok || config.ExpandTilde(resolved) != filepath.Dir(previous) {
+	resolved, ok := helper.ResolveSources(selectedCategory, now, ws, conditions, wallhavenDirs[selectedCategory])
+	if !ok || !helper.FromAnySource(expandSources(resolved), previous, selectedCategory.Recursive) {
 		return selectedCategory
 	}
 	if c := helper.GetRandomCategory(excludeCategory(active, selectedCategory)); c != nil {
@@ -191,52 +239,48 @@ func avoidRepeatCategory(selectedCategory *models.Categories, active []*models.C
 	return selectedCategory
 }
 
-// applySingleWallpaper resolves selectedCategory's current source, picks a
-// random image from it (excluding the current wallpaper when possible), and
-// applies it as the single/mirrored wallpaper.
+// applySingleWallpaper picks selectedCategory's next wallpaper (a random
+// image from its current sources, excluding the current wallpaper when
+// possible, a collage or a generated image) and applies it as the
+// single/mirrored wallpaper, between the pre-change hooks (which may veto
+// it) and the post-change ones.
 func applySingleWallpaper(g *models.Gopaper, selectedCategory *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDirs map[*models.Categories]string, previous string) error {
-	resolvedSource, _ := helper.ResolveSource(selectedCategory, now, ws, conditions, wallhavenDirs[selectedCategory])
-	sourcePath := config.ExpandTilde(resolvedSource)
-
-	files, err := helper.ReadDirectory(sourcePath)
-	if err != nil {
-		g.Logger.Error("error reading source directory.", g.Logger.Args("source", sourcePath, "error", err))
-		return fmt.Errorf("error reading directory: %w", err)
-	}
-
-	filter, err := filters.Compile(selectedCategory.Filter)
+	newWallpaper, parts, err := pickWallpaper(g, selectedCategory, 0, now, ws, conditions, wallhavenDirs, pickOptions(g, previous))
 	if err != nil {
-		g.Logger.Error("invalid filter", g.Logger.Args("category", selectedCategory.Name, "error", err))
-		return fmt.Errorf("invalid filter for category %q: %w", selectedCategory.Name, err)
+		g.Logger.Error("could not pick a wallpaper", g.Logger.Args("category", selectedCategory.Name, "error", err))
+		return err
 	}
 
-	exclude := ""
-	if sourcePath == filepath.Dir(previous) {
-		exclude = filepath.Base(previous)
+	applied := wallpaperPath(g.Viper, g.Logger, selectedCategory, newWallpaper, 0, now, ws, conditions)
+	mode := config.ModeForCategory(g.Viper, selectedCategory.ModeOverride())
+	entry := history.Entry{
+		Path:      newWallpaper,
+		Category:  selectedCategory.Name,
+		Mode:      mode,
+		Timestamp: time.Now(),
+		Calendar:  selectedCategory.Calendar != nil,
+		Parts:     parts,
 	}
-	selectedFile, err := helper.GetRandomFile(files, filter, exclude)
-	if err != nil {
-		g.Logger.Error("Error getting random file", g.Logger.Args("error", err))
-		return fmt.Errorf("error getting random file: %w", err)
+	if preChange(g.Viper, g.Logger, entry) != nil {
+		return nil // vetoed, already logged
 	}
 
-	err = helper.SetWallpaperFromFile(sourcePath, selectedFile, config.TransitionEnabledForCategory(g.Viper, selectedCategory.TransitionOverride()))
+	err = helper.SetWallpaperFromPath(applied, mode, config.TransitionEnabledForCategory(g.Viper, selectedCategory.TransitionOverride()))
 	if err != nil {
 		g.Logger.Error("Error setting the wallpaper", g.Logger.Args("error", err))
 		return fmt.Errorf("error setting the wallpaper: %w", err)
 	}
 
-	mode := config.ModeForCategory(g.Viper, selectedCategory.ModeOverride())
 	if err = helper.SetWallpaperMode(mode); err != nil {
 		g.Logger.Error("Error setting wallpaper mode", g.Logger.Args("error", err))
 		return fmt.Errorf("error setting wallpaper mode: %w", err)
 	}
 
-	newWallpaper := filepath.Join(sourcePath, selectedFile)
-
-	if err := recordHistory(g, newWallpaper, selectedCategory, mode); err != nil {
+	if err := recordHistoryEntry(g, entry); err != nil {
 		g.Logger.Warn("Could not record history", g.Logger.Args("error", err))
 	}
+	exportTheme(g.Viper, g.Logger, applied)
+	postChange(g.Viper, g.Logger, entry, hooks.Applied{Path: applied})
 
 	g.Logger.Info("Wallpaper changed successfully.",
 		g.Logger.Args("category", selectedCategory.Name),
@@ -247,6 +291,129 @@ func applySingleWallpaper(g *models.Gopaper, selectedCategory *models.Categories
 	return nil
 }
 
+// indexStore returns the configured file-index store, or nil (read the
+// source directories directly) when the index is disabled or its settings
+// are invalid — the index only speeds things up, so it never aborts a run.
+func indexStore(g *models.Gopaper) *index.Store {
+	store, err := config.IndexStore(g.Viper)
+	if err != nil {
+		g.Logger.Warn("invalid index configuration, reading source directories directly", g.Logger.Args("error", err))
+		return nil
+	}
+	return store
+}
+
+// pickOptions returns the configured file-picking options (index store,
+// image-type detection, metadata cache, tag database, favorites boost and
+// near-duplicate check) with previous as the file to avoid. A tag database
+// that can't be read is logged and left out: tag-query categories then
+// have nothing to match and bans aren't applied.
+func pickOptions(g *models.Gopaper, previous string) helper.PickOptions {
+	opts := helper.PickOptions{
+		Previous:  previous,
+		Index:     indexStore(g),
+		Detection: config.Detection(g.Viper),
+		FavWeight: config.FavoriteWeight(g.Viper),
+	}
+	if p, err := config.MetadataCachePath(g.Viper); err == nil {
+		opts.Meta = metacache.Open(p)
+	}
+	if g.Viper.GetBool("configuration.dedupe.exclude-similar") {
+		similar, err := config.DedupeOptions(g.Viper)
+		if err != nil {
+			g.Logger.Warn("invalid dedupe configuration, near-duplicates of the current wallpaper are not skipped", g.Logger.Args("error", err))
+		} else {
+			opts.Similar = &similar
+		}
+	}
+	if p, err := config.TagDBPath(g.Viper); err == nil {
+		db, err := tags.Open(p)
+		if err != nil {
+			g.Logger.Warn("could not open the tag database", g.Logger.Args("error", err))
+		}
+		opts.TagDB = db
+	}
+	return opts
+}
+
+// forCategory returns opts narrowed to cat's filter and tag query.
+func forCategory(opts helper.PickOptions, cat *models.Categories) (helper.PickOptions, error) {
+	filter, err := filters.Compile(cat.Filter)
+	if err != nil {
+		return opts, fmt.Errorf("invalid filter for category %q: %w", cat.Name, err)
+	}
+	opts.Filter = filter
+	if cat.Tags != "" {
+		q, err := tags.ParseQuery(cat.Tags)
+		if err != nil {
+			return opts, fmt.Errorf("invalid tags query for category %q: %w", cat.Name, err)
+		}
+		opts.Tags = q
+	}
+	return opts, nil
+}
+
+// displayPath returns a path the desktop can display for the picked image
+// at path, converting it into the conversion cache when its format needs
+// it. A failed conversion is logged and path returned unchanged: the
+// desktop may still cope with the original.
+func displayPath(v *viper.Viper, log *pterm.Logger, path string) string {
+	dir, err := config.ConvertCacheDir(v)
+	if err != nil {
+		log.Warn("could not resolve the conversion cache directory, using the image as-is", log.Args("path", path, "error", err))
+		return path
+	}
+	out, err := imagetype.Displayable(path, dir)
+	if err != nil {
+		log.Warn("could not convert image, using it as-is", log.Args("path", path, "error", err))
+		return path
+	}
+	if out != path {
+		log.Debug("using converted image", log.Args("path", path, "converted", out))
+	}
+	return out
+}
+
+// unconvertedPath maps a desktop wallpaper path that lives in the conversion,
+// process or overlay cache back to the source image it was rendered from (the
+// current history entry), so repeat avoidance compares against source
+// paths. Any other path is returned unchanged, as is a cached one the
+// history can't resolve.
+func unconvertedPath(v *viper.Viper, current string) string {
+	if current == "" || !inCacheDir(v, current) {
+		return current
+	}
+	histPath, err := config.HistoryPath(v)
+	if err != nil {
+		return current
+	}
+	h, err := history.Load(histPath, 0)
+	if err != nil || h.CurrentIndex < 0 || h.CurrentIndex >= len(h.Entries) {
+		return current
+	}
+	return h.Entries[h.CurrentIndex].Path
+}
+
+// inCacheDir reports whether path sits directly in the conversion, process,
+// overlay, compose, collage, generate or calendar cache directory.
+func inCacheDir(v *viper.Viper, path string) bool {
+	for _, cacheDir := range []func(*viper.Viper) (string, error){config.ConvertCacheDir, config.ProcessCacheDir, config.OverlayCacheDir, config.ComposeCacheDir, config.CollageCacheDir, config.GenerateCacheDir, config.CalendarCacheDir} {
+		if dir, err := cacheDir(v); err == nil && filepath.Dir(path) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandSources returns dirs with a leading ~ expanded in every path.
+func expandSources(dirs []models.SourceDir) []models.SourceDir {
+	out := make([]models.SourceDir, len(dirs))
+	for i, d := range dirs {
+		out[i] = models.SourceDir{Path: config.ExpandTilde(d.Path), Weight: d.Weight}
+	}
+	return out
+}
+
 // excludeCategory returns cats without exclude, preserving order.
 func excludeCategory(cats []*models.Categories, exclude *models.Categories) []*models.Categories {
 	out := make([]*models.Categories, 0, len(cats)-1)
@@ -258,17 +425,6 @@ func excludeCategory(cats []*models.Categories, exclude *models.Categories) []*m
 	return out
 }
 
-// recordHistory appends the current wallpaper to the persistent history file,
-// unless configuration.history.enabled is explicitly set to false.
-func recordHistory(g *models.Gopaper, wallpaper string, cat *models.Categories, mode string) error {
-	return recordHistoryEntry(g, history.Entry{
-		Path:      wallpaper,
-		Category:  cat.Name,
-		Mode:      mode,
-		Timestamp: time.Now(),
-	})
-}
-
 // recordHistoryEntry appends a pre-built entry (single or per-monitor) to
 // the persistent history file, unless configuration.history.enabled is
 // explicitly set to false.
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/models"
+
+	"github.com/spf13/viper"
+)
+
+// fileBackendConfig writes a configuration publishing through the file
+// backend into dir, with one category per source directory holding one
+// image, and returns its path.
+func fileBackendConfig(t *testing.T, dir, behavior string, categories ...string) string {
+	t.Helper()
+	var cfg strings.Builder
+	cfg.WriteString(`configuration:
+  logging:
+    output: none
+    level: info
+  history:
+    file: ` + filepath.Join(dir, "history", "gopaper.json") + `
+  behavior:
+    backend: file
+    transition: none
+` + behavior + `
+  output:
+    path: ` + filepath.Join(dir, "out", "wallpaper.png") + `
+    monitors:
+      - path: ` + filepath.Join(dir, "out", "left.png") + `
+      - path: ` + filepath.Join(dir, "out", "right.png") + `
+categories:
+`)
+	for i, name := range categories {
+		src := filepath.Join(dir, "walls", name)
+		writePNG(t, filepath.Join(src, name+".png"), color.Gray{Y: uint8(40 * (i + 1))})
+		cfg.WriteString("  - name: " + name + "\n    source: " + src + "\n    enabled: true\n")
+	}
+	path := filepath.Join(dir, "gopaper.yaml")
+	if err := os.WriteFile(path, []byte(cfg.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writePNG(t *testing.T, path string, c color.Color) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 32, 18))
+	for y := range 18 {
+		for x := range 32 {
+			img.Set(x, y, c)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runOnceWithFileBackend loads config and runs one change through the file
+// backend, returning the manifest it wrote.
+func runOnceWithFileBackend(t *testing.T, config string) helper.Manifest {
+	t.Helper()
+	t.Cleanup(func() {
+		if err := helper.UseBackend(helper.DefaultBackend); err != nil {
+			t.Error(err)
+		}
+	})
+	g := &models.Gopaper{Viper: viper.New()}
+	if err := preRunHandler(g, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := runOnce(g, "", false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(config), "out", "current.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m helper.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func assertSameFile(t *testing.T, got, want string) {
+	t.Helper()
+	a, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("%s is not a copy of %s", got, want)
+	}
+}
+
+func TestRunOnce_FileBackend(t *testing.T) {
+	dir := t.TempDir()
+	config := fileBackendConfig(t, dir, "    mode: fit", "nature")
+
+	m := runOnceWithFileBackend(t, config)
+	src := filepath.Join(dir, "walls", "nature", "nature.png")
+	out := filepath.Join(dir, "out", "wallpaper.png")
+	if m.Path != src || m.Category != "nature" || m.Mode != "fit" || m.Output != out || len(m.Monitors) != 0 {
+		t.Errorf("manifest = %+v", m)
+	}
+	assertSameFile(t, out, src)
+
+	h, err := history.Load(filepath.Join(dir, "history", "gopaper.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Entries) != 1 || h.Entries[0].Path != src {
+		t.Errorf("history = %+v", h.Entries)
+	}
+}
+
+func TestRunOnce_FileBackendPerMonitor(t *testing.T) {
+	dir := t.TempDir()
+	config := fileBackendConfig(t, dir, "    monitor: per-monitor", "nature")
+
+	m := runOnceWithFileBackend(t, config)
+	src := filepath.Join(dir, "walls", "nature", "nature.png")
+	if m.Path != src || m.Category != "nature" || m.Mode != "crop" || len(m.Monitors) != 2 {
+		t.Fatalf("manifest = %+v", m)
+	}
+	for i, name := range []string{"left.png", "right.png"} {
+		got := m.Monitors[i]
+		out := filepath.Join(dir, "out", name)
+		if got.Monitor != i+1 || got.Path != src || got.Category != "nature" || got.Output != out {
+			t.Errorf("monitors[%d] = %+v", i, got)
+		}
+		assertSameFile(t, out, src)
+	}
+}
+
+// processedConfig is fileBackendConfig with one category, nature, whose
+// red image a process step turns grey, and section added to the
+// configuration.
+func processedConfig(t *testing.T, dir, section string) string {
+	t.Helper()
+	config := fileBackendConfig(t, dir, section, "nature")
+	writePNG(t, filepath.Join(dir, "walls", "nature", "nature.png"), color.RGBA{R: 200, G: 30, B: 30, A: 255})
+	f, err := os.OpenFile(config, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("    process:\n      - grayscale\n"); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// processedThemeConfig is processedConfig with a theme template writing the
+// palette to the returned file.
+func processedThemeConfig(t *testing.T, dir string) (config, palette string) {
+	t.Helper()
+	tmpl := filepath.Join(dir, "palette.tmpl")
+	if err := os.WriteFile(tmpl, []byte("{{range .Palette}}{{.}} {{end}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	palette = filepath.Join(dir, "palette")
+	return processedConfig(t, dir, "  theme:\n    templates:\n      - { in: "+tmpl+", out: "+palette+" }"), palette
+}
+
+func readPalette(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestThemeFollowsProcessedWallpaper(t *testing.T) {
+	dir := t.TempDir()
+	config, palette := processedThemeConfig(t, dir)
+
+	runOnceWithFileBackend(t, config)
+	want := readPalette(t, palette)
+	for _, c := range strings.Fields(want) {
+		if c[1:3] != c[3:5] || c[3:5] != c[5:7] {
+			t.Fatalf("palette %q has colors the grayscale step removed", want)
+		}
+	}
+
+	g := &models.Gopaper{Viper: viper.New()}
+	if err := preRunHandler(g, config); err != nil {
+		t.Fatal(err)
+	}
+	entry, ok, err := currentEntry(g.Viper)
+	if err != nil || !ok {
+		t.Fatalf("currentEntry() = (%+v, %v, %v)", entry, ok, err)
+	}
+	if err := applyHistoryEntry(g.Viper, entry); err != nil {
+		t.Fatal(err)
+	}
+	if got := readPalette(t, palette); got != want {
+		t.Errorf("re-applied from history, palette = %q, want %q", got, want)
+	}
+
+	cmd := ThemeCmd()
+	cmd.SetArgs([]string{"--config", config})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readPalette(t, palette); got != want {
+		t.Errorf("gopaper theme, palette = %q, want %q", got, want)
+	}
+}
+
+func TestRunOnce_HookSeesAppliedPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are PowerShell on Windows")
+	}
+	dir := t.TempDir()
+	got := filepath.Join(dir, "applied")
+	config := processedConfig(t, dir, "  hooks:\n    post-change:\n      - command: 'printf %s \"$GOPAPER_APPLIED_PATH\" > "+got+"'")
+
+	m := runOnceWithFileBackend(t, config)
+	data, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := string(data)
+	if filepath.Dir(applied) != filepath.Join(dir, "history", "processed") {
+		t.Errorf("GOPAPER_APPLIED_PATH = %q, want the processed copy", applied)
+	}
+	if applied == m.Path {
+		t.Errorf("GOPAPER_APPLIED_PATH is the picked image %s", m.Path)
+	}
+}
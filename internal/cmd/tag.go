@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/tags"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// TagCmd groups the tag database subcommands.
+func TagCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tag",
+		Short: "Tag images and manage the tag database",
+		Long: `Assign tags to image files and manage the tag database (tags.json, next to
+the history file).
+
+A category with a tags query, such as tags: "autumn and not people", picks
+among the tagged files matching it; combined with source, sources or
+variants, the query narrows those directories' files instead.`,
+	}
+	cmd.AddCommand(tagAddCmd(), tagRmCmd(), tagListCmd(), tagImportCmd())
+	return cmd
+}
+
+// openTagDB loads the configuration at configPath and opens the tag
+// database next to its history file.
+func openTagDB(configPath string) (*models.Gopaper, *tags.DB, error) {
+	g := &models.Gopaper{Viper: viper.New()}
+	if err := preRunHandler(g, configPath); err != nil {
+		return nil, nil, err
+	}
+	path, err := config.TagDBPath(g.Viper)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := tags.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, db, nil
+}
+
+// tagAddCmd tags one file.
+func tagAddCmd() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "add <file> <tag>...",
+		Short: "Add tags to an image file",
+		Long: `Add tags to an image file. Tags are case-insensitive and made of letters,
+digits and - _ . : /; and, or and not are reserved for queries.`,
+		Example: `  gopaper tag add ~/Pictures/Walls/leaves.jpg cozy autumn`,
+		Args:    cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Stat(args[0]); err != nil {
+				return fmt.Errorf("could not tag %s: %w", args[0], err)
+			}
+			_, db, err := openTagDB(configPath)
+			if err != nil {
+				return err
+			}
+			added, err := db.Add(args[0], args[1:]...)
+			if err != nil {
+				return err
+			}
+			if err := db.Save(); err != nil {
+				return err
+			}
+			pterm.Success.Printfln("Added %d tag(s) to %s: %s", added, args[0], strings.Join(db.Tags(args[0]), ", "))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	return cmd
+}
+
+// tagRmCmd removes some or all of a file's tags.
+func tagRmCmd() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "rm <file> [tag...]",
+		Short: "Remove tags from an image file",
+		Long:  `Remove the given tags from an image file, or all of its tags when none are given.`,
+		Example: `  # Remove one tag
+  gopaper tag rm ~/Pictures/Walls/leaves.jpg cozy
+
+  # Forget the file entirely, e.g. after deleting it
+  gopaper tag rm ~/Pictures/Walls/leaves.jpg`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, db, err := openTagDB(configPath)
+			if err != nil {
+				return err
+			}
+			removed := db.Remove(args[0], args[1:]...)
+			if err := db.Save(); err != nil {
+				return err
+			}
+			pterm.Success.Printfln("Removed %d tag(s) from %s", removed, args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	return cmd
+}
+
+// tagListCmd lists tags, a file's tags, or the files matching a query.
+func tagListCmd() *cobra.Command {
+	var (
+		configPath string
+		query      string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list [file]",
+		Short: "List tags, a file's tags, or the files matching a query",
+		Example: `  # Every tag and how many files carry it
+  gopaper tag list
+
+  # One file's tags
+  gopaper tag list ~/Pictures/Walls/leaves.jpg
+
+  # The files a category with this tags query would pick from
+  gopaper tag list --query "autumn and not people"`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, db, err := openTagDB(configPath)
+			if err != nil {
+				return err
+			}
+			switch {
+			case len(args) == 1:
+				for _, t := range db.Tags(args[0]) {
+					fmt.Println(t)
+				}
+				return nil
+			case query != "":
+				q, err := tags.ParseQuery(query)
+				if err != nil {
+					return fmt.Errorf("invalid tags query: %w", err)
+				}
+				for _, f := range db.Files() {
+					if q.Matches(db.Tags(f)) {
+						fmt.Println(f)
+					}
+				}
+				return nil
+			}
+
+			counts := db.Counts()
+			if len(counts) == 0 {
+				pterm.Info.Println("No tags yet: add some with gopaper tag add or gopaper tag import")
+				return nil
+			}
+			names := make([]string, 0, len(counts))
+			for t := range counts {
+				names = append(names, t)
+			}
+			sort.Strings(names)
+			table := pterm.TableData{{"Tag", "Files"}}
+			for _, t := range names {
+				table = append(table, []string{t, strconv.Itoa(counts[t])})
+			}
+			return pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	cmd.Flags().StringVarP(&query, "query", "q", "", "List the files matching this tags query")
+	return cmd
+}
+
+// tagImportCmd tags images with their XMP dc:subject keywords.
+func tagImportCmd() *cobra.Command {
+	var (
+		configPath string
+		recursive  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <path>...",
+		Short: "Tag images with their XMP keywords",
+		Long: `Tag image files with the XMP dc:subject keywords embedded in them or stored
+in a sidecar file ("photo.jpg.xmp" or "photo.xmp"), as written by photo
+managers such as darktable, digiKam or Lightroom.
+
+Keywords are turned into tags by lower-casing them and joining words with
+"-" ("Autumn Leaves" becomes autumn-leaves). Directories are imported one
+level deep unless --recursive is given.`,
+		Example: `  gopaper tag import ~/Pictures/Walls --recursive`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g, db, err := openTagDB(configPath)
+			if err != nil {
+				return err
+			}
+			return runTagImport(g, db, args, recursive)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Descend into subdirectories")
+	return cmd
+}
+
+// runTagImport imports the XMP keywords of every image under paths and
+// prints a summary. An unreadable file is reported and skipped.
+func runTagImport(g *models.Gopaper, db *tags.DB, paths []string, recursive bool) error {
+	detection := config.Detection(g.Viper)
+	scanned, tagged, added := 0, 0, 0
+	importFile := func(path string) {
+		scanned++
+		keywords, err := tags.ReadXMPSubjects(path)
+		if err != nil {
+			g.Logger.Warn("could not read XMP keywords", g.Logger.Args("file", path, "error", err))
+			return
+		}
+		var fileTags []string
+		for _, k := range keywords {
+			if t := tags.Sanitize(k); t != "" {
+				fileTags = append(fileTags, t)
+			}
+		}
+		if len(fileTags) == 0 {
+			return
+		}
+		n, err := db.Add(path, fileTags...)
+		if err != nil {
+			g.Logger.Warn("could not tag file", g.Logger.Args("file", path, "error", err))
+			return
+		}
+		tagged++
+		added += n
+	}
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return fmt.Errorf("could not import %s: %w", root, err)
+		}
+		if !info.IsDir() {
+			importFile(root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				g.Logger.Warn("could not read directory", g.Logger.Args("path", path, "error", err))
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && !recursive {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() && imagetype.Eligible(path, detection) {
+				importFile(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := db.Save(); err != nil {
+		return err
+	}
+	pterm.Success.Printfln("Scanned %d image(s): %d tagged, %d new tag(s)", scanned, tagged, added)
+	return nil
+}
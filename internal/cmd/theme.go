@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lucasassuncao/gopaper/internal/config"
+	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/theme"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ThemeCmd writes the theme templates from the current wallpaper's colors.
+func ThemeCmd() *cobra.Command {
+	var (
+		configPath string
+		monitor    int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "theme [image]",
+		Short: "Regenerate the theme files from the current wallpaper's colors",
+		Long: `Extract the current wallpaper's palette (or the given image's) and render
+every configuration.theme.templates entry with it, as happens after each
+wallpaper change. Without templates configured, the colors are printed.`,
+		Example: `  # After editing a template
+  gopaper theme
+
+  # Take the colors from another image
+  gopaper theme ~/Pictures/Walls/leaves.jpg`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g := &models.Gopaper{Viper: viper.New()}
+			if err := preRunHandler(g, configPath); err != nil {
+				return err
+			}
+			path, image, err := themeTarget(g, args, monitor)
+			if err != nil {
+				return err
+			}
+			templates, colors, err := config.ThemeTemplates(g.Viper)
+			if err != nil {
+				return err
+			}
+			th, err := theme.FromImage(image, colors)
+			if err != nil {
+				return fmt.Errorf("could not extract the colors: %w", err)
+			}
+			th.Wallpaper = path
+
+			if len(templates) == 0 {
+				for i, c := range th.Colors {
+					fmt.Printf("color%-2d %s\n", i, c)
+				}
+				return nil
+			}
+			if err := theme.Write(templates, th); err != nil {
+				return err
+			}
+			pterm.Success.Printfln("Wrote %d theme file(s) from %s", len(templates), path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to configuration file (default: standard lookup)")
+	cmd.Flags().IntVarP(&monitor, "monitor", "m", 0, "After a per-monitor change, use this monitor's wallpaper (1-based)")
+	return cmd
+}
+
+// themeTarget returns the wallpaper gopaper theme takes its colors from, as
+// markTarget finds it, and the image they are extracted from: the current
+// wallpaper as handed to the desktop, drawn again as prev/next would
+// re-apply it, so the theme matches the one written by the change itself.
+func themeTarget(g *models.Gopaper, args []string, monitor int) (path, image string, err error) {
+	path, err = markTarget(g, args, monitor)
+	if err != nil {
+		return "", "", err
+	}
+	if len(args) == 0 {
+		entry, ok, err := currentEntry(g.Viper)
+		if err != nil {
+			return "", "", err
+		}
+		if ok {
+			image, err := historyAppliedPath(g.Viper, entry, monitor)
+			return path, image, err
+		}
+	}
+	return path, displayPath(g.Viper, g.Logger, path), nil
+}
+
+// historyAppliedPath returns the file re-applying entry would hand to the
+// desktop for the 1-based monitor (the first monitor's when 0 and each got
+// its own).
+func historyAppliedPath(v *viper.Viper, entry history.Entry, monitor int) (string, error) {
+	now := &historyNow{v: v}
+	switch {
+	case len(entry.Monitors) > 0:
+		for _, m := range entry.Monitors {
+			if monitor == 0 || m.Monitor == monitor {
+				return historyWallpaperPath(v, now, m.Category, historyRenderPath(v, m.Category, m.Path, m.Parts, m.Monitor), m.Monitor), nil
+			}
+		}
+		return "", fmt.Errorf("the current wallpaper has no monitor %d", monitor)
+	case entry.Panorama:
+		targets, err := historyPanoramaTargets(v, now, entry)
+		if err != nil {
+			return "", err
+		}
+		i := max(monitor, 1) - 1
+		if i >= len(targets) {
+			return "", fmt.Errorf("monitor %d is not connected", monitor)
+		}
+		return targets[i].Path, nil
+	default:
+		return historyWallpaperPath(v, now, entry.Category, historyRenderPath(v, entry.Category, entry.Path, entry.Parts, 0), 0), nil
+	}
+}
+
+// exportTheme renders the configured theme templates from the colors of
+// the image at path, the wallpaper just applied. Nothing is done without
+// templates; failures are logged, never failing the change itself.
+func exportTheme(v *viper.Viper, log *pterm.Logger, path string) {
+	templates, colors, err := config.ThemeTemplates(v)
+	if err != nil {
+		log.Warn("invalid theme configuration, skipping the theme", log.Args("error", err))
+		return
+	}
+	if len(templates) == 0 {
+		return
+	}
+	th, err := theme.FromImage(path, colors)
+	if err != nil {
+		log.Warn("could not extract the wallpaper's colors, skipping the theme", log.Args("path", path, "error", err))
+		return
+	}
+	if err := theme.Write(templates, th); err != nil {
+		log.Warn("could not write every theme file", log.Args("error", err))
+		return
+	}
+	log.Debug("theme written", log.Args("from", path, "files", len(templates)))
+}
@@ -63,7 +63,7 @@ uniqueness, cross-field rules) and reports every violation found.`,
 	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to the configuration file to validate (default: standard lookup)")
 	cmd.Flags().StringVarP(&format, "format", "f", "pretty", fmt.Sprintf("Output format: %s", strings.Join(validFormats, ", ")))
 	cmd.Flags().BoolVar(&summary, "summary", false, "Show only the error count, not individual violations")
-	cmd.Flags().BoolVar(&strict, "strict", false, "Also verify that every category's source directory exists on disk")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Also verify that every category's source directories exist on disk")
 	return cmd
 }
 
@@ -100,7 +100,7 @@ func runValidate(configPath string, format validateFormat, summaryOnly, strict b
 		return fmt.Errorf("building hint source: %w", err)
 	}
 
-	wired := editor.Wire(GopaperValidators, editor.Config{Schema: &models.Config{}, Metadata: hints})
+	wired := editor.Wire(GopaperValidators, editor.Config{Schema: &models.Config{}, SchemaRecursionDepth: filterSchemaDepth, Metadata: hints})
 	violations := editor.RunAll(wired, raw, nil)
 
 	if strict {
@@ -122,15 +122,17 @@ func runValidate(configPath string, format validateFormat, summaryOnly, strict b
 	return nil
 }
 
-// strictDirViolations checks whether each category's source directory (and
-// each variant's) exists on disk, returning a violation for each one that
-// doesn't.
+// strictDirViolations checks whether each category's source directory (or
+// each of its sources, and each variant's) exists on disk, returning a
+// violation for each one that doesn't.
 func strictDirViolations(raw []byte) []editor.Violation {
 	var doc struct {
 		Categories []struct {
-			Source   string `yaml:"source"`
+			Source   string             `yaml:"source"`
+			Sources  []models.SourceDir `yaml:"sources"`
 			Variants []struct {
-				Source string `yaml:"source"`
+				Source  string             `yaml:"source"`
+				Sources []models.SourceDir `yaml:"sources"`
 			} `yaml:"variants"`
 		} `yaml:"categories"`
 	}
@@ -139,39 +141,60 @@ func strictDirViolations(raw []byte) []editor.Violation {
 	}
 
 	var out []editor.Violation
+	missing := func(path, dir string) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			out = append(out, editor.Violation{
+				Path:    path,
+				Message: fmt.Sprintf("directory does not exist: %s", dir),
+			})
+		}
+	}
 	for i, c := range doc.Categories {
+		var bases []string
 		if c.Source != "" {
-			if _, err := os.Stat(config.ExpandTilde(c.Source)); os.IsNotExist(err) {
-				out = append(out, editor.Violation{
-					Path:    fmt.Sprintf("categories[%d].source", i),
-					Message: fmt.Sprintf("directory does not exist: %s", c.Source),
-				})
-			}
+			bases = append(bases, config.ExpandTilde(c.Source))
+			missing(fmt.Sprintf("categories[%d].source", i), config.ExpandTilde(c.Source))
 		}
-		for j, v := range c.Variants {
-			if v.Source == "" {
+		for k, sd := range c.Sources {
+			if sd.Path == "" {
 				continue
 			}
-			varPath := v.Source
-			switch {
-			case filepath.IsAbs(varPath):
-				varPath = config.ExpandTilde(varPath)
-			case c.Source != "":
-				varPath = filepath.Join(config.ExpandTilde(c.Source), varPath)
-			default:
-				continue // no base to resolve against; the shape validator already flags this
+			bases = append(bases, config.ExpandTilde(sd.Path))
+			missing(fmt.Sprintf("categories[%d].sources[%d]", i, k), config.ExpandTilde(sd.Path))
+		}
+		for j, v := range c.Variants {
+			if v.Source != "" {
+				for _, dir := range variantDirs(v.Source, bases) {
+					missing(fmt.Sprintf("categories[%d].variants[%d].source", i, j), dir)
+				}
 			}
-			if _, err := os.Stat(varPath); os.IsNotExist(err) {
-				out = append(out, editor.Violation{
-					Path:    fmt.Sprintf("categories[%d].variants[%d].source", i, j),
-					Message: fmt.Sprintf("directory does not exist: %s", varPath),
-				})
+			for k, sd := range v.Sources {
+				if sd.Path == "" {
+					continue
+				}
+				for _, dir := range variantDirs(sd.Path, bases) {
+					missing(fmt.Sprintf("categories[%d].variants[%d].sources[%d]", i, j, k), dir)
+				}
 			}
 		}
 	}
 	return out
 }
 
+// variantDirs resolves a variant source entry: as-is when absolute,
+// otherwise against each of the category's base directories (none when
+// the category has no base; the shape validator already flags this).
+func variantDirs(src string, bases []string) []string {
+	if filepath.IsAbs(src) {
+		return []string{config.ExpandTilde(src)}
+	}
+	dirs := make([]string, 0, len(bases))
+	for _, b := range bases {
+		dirs = append(dirs, filepath.Join(b, src))
+	}
+	return dirs
+}
+
 var topSectionRe = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_-]*)`)
 
 // sectionOf extracts the top-level section name from a violation path, or
@@ -43,5 +43,16 @@ func fetchWeatherSnapshot(g *models.Gopaper) *weather.Snapshot {
 		g.Logger.Warn("could not fetch weather, weather-based variants will be skipped", g.Logger.Args("error", err))
 		return nil
 	}
+	snap.Buckets = weather.NewBuckets(weatherCfg.Buckets)
+
+	units, err := weather.ParseUnits(weatherCfg.Units)
+	if err != nil {
+		units = weather.UnitsMetric
+	}
+	g.Logger.Debug("Current weather",
+		g.Logger.Args("code", snap.Code),
+		g.Logger.Args("temperature", units.FormatTemperature(snap.Temperature)),
+		g.Logger.Args("wind speed", units.FormatSpeed(snap.WindSpeed)),
+	)
 	return &snap
 }
@@ -0,0 +1,241 @@
+// Package collage lays several images out on one wallpaper, either in an
+// even grid or in a mosaic of rows that keeps each image close to its own
+// shape, and caches the result.
+package collage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/process"
+)
+
+// Layouts.
+const (
+	Grid   = "grid"
+	Mosaic = "mosaic"
+)
+
+// Layouts returns every supported layout, for validation messages.
+func Layouts() []string {
+	return []string{Grid, Mosaic}
+}
+
+// Cells returns where each of n images goes on a canvas of size, with gap
+// pixels between the cells and around the edges. aspects holds each image's
+// width/height, used by the mosaic layout; the grid ignores it.
+func Cells(layout string, aspects []float64, size image.Point, gap int) ([]image.Rectangle, error) {
+	n := len(aspects)
+	if n == 0 {
+		return nil, nil
+	}
+	var rows [][]int
+	switch layout {
+	case Grid, "":
+		rows = gridRows(n, size)
+	case Mosaic:
+		rows = mosaicRows(aspects, size, gap)
+	default:
+		return nil, fmt.Errorf("unknown layout %q", layout)
+	}
+	return place(rows, aspects, layout == Mosaic, size, gap), nil
+}
+
+// gridRows splits n images into rows of equal length (the last one may be
+// shorter), choosing the column count whose cells come closest to the
+// canvas's own shape (the more columns on a tie, as wallpapers are wide).
+func gridRows(n int, size image.Point) [][]int {
+	cols, best := 1, math.Inf(1)
+	for c := 1; c <= n; c++ {
+		r := (n + c - 1) / c
+		cellAspect := (float64(size.X) / float64(c)) / (float64(size.Y) / float64(r))
+		canvasAspect := float64(size.X) / float64(size.Y)
+		if d := math.Abs(math.Log(cellAspect / canvasAspect)); d <= best+1e-9 {
+			cols, best = c, d
+		}
+	}
+	var rows [][]int
+	for i := 0; i < n; i += cols {
+		row := make([]int, 0, cols)
+		for j := i; j < min(i+cols, n); j++ {
+			row = append(row, j)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// mosaicRows splits the images, in order, into the number of rows whose
+// images, scaled to fill the width at their own aspect ratio, come closest
+// to filling the height too.
+func mosaicRows(aspects []float64, size image.Point, gap int) [][]int {
+	n := len(aspects)
+	var (
+		best     [][]int
+		bestDiff = math.Inf(1)
+	)
+	for r := 1; r <= n; r++ {
+		rows := balanced(n, r)
+		height := float64(gap * (r + 1))
+		for _, row := range rows {
+			height += float64(size.X-gap*(len(row)+1)) / aspectSum(row, aspects)
+		}
+		if d := math.Abs(math.Log(height / float64(size.Y))); d < bestDiff {
+			best, bestDiff = rows, d
+		}
+	}
+	return best
+}
+
+// balanced splits indices 0..n-1 into r consecutive rows whose lengths
+// differ by at most one.
+func balanced(n, r int) [][]int {
+	rows := make([][]int, r)
+	next := 0
+	for i := range rows {
+		count := n / r
+		if i < n%r {
+			count++
+		}
+		for range count {
+			rows[i] = append(rows[i], next)
+			next++
+		}
+	}
+	return rows
+}
+
+func aspectSum(row []int, aspects []float64) float64 {
+	sum := 0.0
+	for _, i := range row {
+		sum += aspects[i]
+	}
+	return sum
+}
+
+// place turns rows into cell rectangles filling size. Rows share the height
+// evenly, and cells within a row share its width evenly; with weighted,
+// rows are as tall, and cells as wide, as their images' aspect ratios ask.
+func place(rows [][]int, aspects []float64, weighted bool, size image.Point, gap int) []image.Rectangle {
+	cells := make([]image.Rectangle, len(aspects))
+	rowWeights := make([]float64, len(rows))
+	for i, row := range rows {
+		rowWeights[i] = 1
+		if weighted {
+			rowWeights[i] = 1 / aspectSum(row, aspects)
+		}
+	}
+	heights := split(size.Y-gap*(len(rows)+1), rowWeights)
+	y := gap
+	for i, row := range rows {
+		weights := make([]float64, len(row))
+		for j, idx := range row {
+			weights[j] = 1
+			if weighted {
+				weights[j] = aspects[idx]
+			}
+		}
+		x := gap
+		for j, w := range split(size.X-gap*(len(row)+1), weights) {
+			cells[row[j]] = image.Rect(x, y, x+w, y+heights[i])
+			x += w + gap
+		}
+		y += heights[i] + gap
+	}
+	return cells
+}
+
+// split divides total into parts proportional to weights, rounding so the
+// parts add up to total exactly.
+func split(total int, weights []float64) []int {
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+	parts := make([]int, len(weights))
+	acc, prev := 0.0, 0
+	for i, w := range weights {
+		acc += w
+		end := int(math.Round(float64(total) * acc / sum))
+		parts[i] = max(end-prev, 0)
+		prev = end
+	}
+	return parts
+}
+
+// Render lays imgs out on a black canvas of size and draws each one scaled
+// and cropped to cover its cell.
+func Render(imgs []image.Image, layout string, size image.Point, gap int) (*image.RGBA, error) {
+	aspects := make([]float64, len(imgs))
+	for i, img := range imgs {
+		b := img.Bounds()
+		aspects[i] = 1
+		if b.Dy() > 0 {
+			aspects[i] = float64(b.Dx()) / float64(b.Dy())
+		}
+	}
+	cells, err := Cells(layout, aspects, size, gap)
+	if err != nil {
+		return nil, err
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	draw.Draw(canvas, canvas.Bounds(), image.Black, image.Point{}, draw.Src)
+	for i, img := range imgs {
+		if cells[i].Empty() {
+			continue
+		}
+		draw.Draw(canvas, cells[i], process.Cover(img, cells[i].Size()), image.Point{}, draw.Src)
+	}
+	return canvas, nil
+}
+
+// Apply renders the images at paths into a collage in cacheDir and returns
+// its path. The collage is keyed by every image's path, size and
+// modification time, the layout, size and gap, so the same picks are laid
+// out only once.
+func Apply(paths []string, layout string, size image.Point, gap int, cacheDir string) (string, error) {
+	if len(paths) == 0 {
+		return "", errors.New("no images to lay out")
+	}
+	if size.X <= 0 || size.Y <= 0 {
+		return "", fmt.Errorf("invalid collage size %v", size)
+	}
+	var (
+		first os.FileInfo
+		extra = []string{layout, size.String(), strconv.Itoa(gap)}
+	)
+	for i, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %w", p, err)
+		}
+		if i == 0 {
+			first = info
+		}
+		extra = append(extra, p, strconv.FormatInt(info.Size(), 10), strconv.FormatInt(info.ModTime().UnixNano(), 10))
+	}
+	key := imagetype.Key(paths[0], first, extra...)
+	if cached, ok := imagetype.Cached(cacheDir, key); ok {
+		return cached, nil
+	}
+
+	imgs := make([]image.Image, len(paths))
+	for i, p := range paths {
+		img, err := imagetype.Decode(p)
+		if err != nil {
+			return "", err
+		}
+		imgs[i] = img
+	}
+	canvas, err := Render(imgs, layout, size, gap)
+	if err != nil {
+		return "", err
+	}
+	return imagetype.Store(canvas, cacheDir, key)
+}
@@ -0,0 +1,101 @@
+package collage
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tiles checks that cells stay inside size, keep gap apart from each other
+// and from the edges, and don't overlap.
+func tiles(t *testing.T, cells []image.Rectangle, size image.Point, gap int) {
+	t.Helper()
+	inner := image.Rect(gap, gap, size.X-gap, size.Y-gap)
+	for i, c := range cells {
+		if c.Empty() || !c.In(inner) {
+			t.Errorf("cell %d %v is empty or outside %v", i, c, inner)
+		}
+		for j, o := range cells[:i] {
+			if c.Inset(-gap + 1).Overlaps(o) {
+				t.Errorf("cells %d %v and %d %v are closer than the %dpx gap", i, c, j, o, gap)
+			}
+		}
+	}
+}
+
+func TestGridCells(t *testing.T) {
+	size := image.Pt(1920, 1080)
+	cells, err := Cells(Grid, make([]float64, 6), size, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles(t, cells, size, 8)
+	// Six cells on a 16:9 canvas: three columns of two rows.
+	if cells[0].Dx() != cells[5].Dx() || cells[2].Min.Y != cells[0].Min.Y || cells[3].Min.Y == cells[0].Min.Y {
+		t.Errorf("want a 3x2 grid of equal cells, got %v", cells)
+	}
+	if got := cells[2].Max.X; got != size.X-8 {
+		t.Errorf("the last column ends at %d, want %d", got, size.X-8)
+	}
+}
+
+func TestMosaicCellsFollowTheImagesShapes(t *testing.T) {
+	size := image.Pt(1600, 900)
+	aspects := []float64{16.0 / 9, 2.0 / 3, 1, 3, 4.0 / 3}
+	cells, err := Cells(Mosaic, aspects, size, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles(t, cells, size, 4)
+	// Within a row, the panoramic image is wider than the portrait one.
+	for i := range cells {
+		for j := range cells {
+			if cells[i].Min.Y == cells[j].Min.Y && aspects[i] > aspects[j] && cells[i].Dx() <= cells[j].Dx() {
+				t.Errorf("cell %d (aspect %.2f) is not wider than cell %d (aspect %.2f) in the same row", i, aspects[i], j, aspects[j])
+			}
+		}
+	}
+	if _, err := Cells("spiral", aspects, size, 0); err == nil {
+		t.Error("expected an error for an unknown layout")
+	}
+}
+
+func TestApplyCachesTheCollage(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, c := range []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}} {
+		img := image.NewRGBA(image.Rect(0, 0, 30, 20))
+		for p := 0; p < len(img.Pix); p += 4 {
+			img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3] = c.R, c.G, c.B, c.A
+		}
+		p := filepath.Join(dir, string(rune('a'+i))+".png")
+		f, err := os.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		paths = append(paths, p)
+	}
+	cache := filepath.Join(dir, "collage")
+	size := image.Pt(160, 90)
+
+	first, err := Apply(paths, Grid, size, 2, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Apply(paths, Grid, size, 2, cache); again != first {
+		t.Errorf("same images laid out to %q, then %q", first, again)
+	}
+	if other, _ := Apply(paths, Mosaic, size, 2, cache); other == first {
+		t.Error("another layout should render another collage")
+	}
+	if _, err := Apply(nil, Grid, size, 2, cache); err == nil {
+		t.Error("expected an error without images")
+	}
+}
@@ -0,0 +1,101 @@
+// Package compose stitches one image per monitor into a single canvas
+// covering the whole desktop, for desktops that can only set one wallpaper:
+// applied in span mode, the canvas shows each monitor its own image. It
+// also does the reverse for panoramas, cutting one image into a slice per
+// monitor.
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"strconv"
+
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/process"
+)
+
+// Part is one monitor's image and where the monitor sits on the desktop. A
+// part without a path is a monitor left black, still counted in the
+// canvas's bounds so the others line up.
+type Part struct {
+	Path string
+	Rect image.Rectangle // desktop coordinates, as arranged in the display settings
+}
+
+// Bounds returns the bounding rectangle of rects: the area a spanned
+// wallpaper is stretched over.
+func Bounds(rects []image.Rectangle) image.Rectangle {
+	var b image.Rectangle
+	for _, r := range rects {
+		b = b.Union(r)
+	}
+	return b
+}
+
+// Render draws each of imgs scaled and cropped to cover its rect, on a
+// canvas the size of the rects' bounding rectangle with its top-left corner
+// at 0,0. Areas no monitor covers (with monitors of different heights) and
+// rects whose image is nil stay black.
+func Render(imgs []image.Image, rects []image.Rectangle) *image.RGBA {
+	b := Bounds(rects)
+	canvas := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), image.Black, image.Point{}, draw.Src)
+	for i, img := range imgs {
+		if img == nil {
+			continue
+		}
+		r := rects[i].Sub(b.Min)
+		draw.Draw(canvas, r, process.Cover(img, r.Size()), image.Point{}, draw.Src)
+	}
+	return canvas
+}
+
+// Apply renders parts into a canvas in cacheDir and returns its path. The
+// canvas is keyed by every part's path, size, modification time and
+// rectangle, so the same picks on the same layout are stitched only once.
+func Apply(parts []Part, cacheDir string) (string, error) {
+	var (
+		first     = -1
+		firstInfo os.FileInfo
+		extra     []string
+	)
+	for i, p := range parts {
+		if p.Path == "" {
+			extra = append(extra, "", p.Rect.String())
+			continue
+		}
+		info, err := os.Stat(p.Path)
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %w", p.Path, err)
+		}
+		if first < 0 {
+			first, firstInfo = i, info
+		}
+		extra = append(extra, p.Path, strconv.FormatInt(info.Size(), 10), strconv.FormatInt(info.ModTime().UnixNano(), 10), p.Rect.String())
+	}
+	if first < 0 {
+		return "", errors.New("no monitor images to compose")
+	}
+	key := imagetype.Key(parts[first].Path, firstInfo, extra...)
+	if cached, ok := imagetype.Cached(cacheDir, key); ok {
+		return cached, nil
+	}
+
+	imgs := make([]image.Image, len(parts))
+	rects := make([]image.Rectangle, len(parts))
+	for i, p := range parts {
+		rects[i] = p.Rect
+		if p.Path == "" {
+			continue
+		}
+		img, err := imagetype.Decode(p.Path)
+		if err != nil {
+			return "", err
+		}
+		imgs[i] = img
+	}
+	return imagetype.Store(Render(imgs, rects), cacheDir, key)
+}
@@ -0,0 +1,97 @@
+package compose
+
+import (
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func solid(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+var (
+	red  = color.RGBA{255, 0, 0, 255}
+	blue = color.RGBA{0, 0, 255, 255}
+)
+
+func TestRenderPlacesEachImageOnItsMonitor(t *testing.T) {
+	// A 1920x1080 primary at 0,0 and a 1080x1920 portrait monitor to its
+	// left, 400px higher, scaled down tenfold.
+	rects := []image.Rectangle{image.Rect(0, 0, 192, 108), image.Rect(-108, -40, 0, 152)}
+	canvas := Render([]image.Image{solid(40, 30, red), solid(50, 50, blue)}, rects)
+
+	if got := canvas.Bounds(); got != image.Rect(0, 0, 300, 192) {
+		t.Fatalf("canvas bounds = %v, want 300x192", got)
+	}
+	for _, c := range []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{108 + 5, 40 + 5, red},              // primary's top-left corner
+		{299, 40 + 107, red},                // primary's bottom-right corner
+		{0, 0, blue},                        // portrait's top-left corner
+		{107, 191, blue},                    // portrait's bottom-right corner
+		{200, 10, color.RGBA{0, 0, 0, 255}}, // above the primary: no monitor
+	} {
+		if got := canvas.RGBAAt(c.x, c.y); got != c.want {
+			t.Errorf("pixel (%d,%d) = %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+}
+
+func TestApplyCachesTheCanvas(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, img image.Image) string {
+		p := filepath.Join(dir, name)
+		f, err := os.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	a, b := write("a.png", solid(16, 9, red)), write("b.png", solid(16, 9, blue))
+	cache := filepath.Join(dir, "composed")
+	parts := []Part{{Path: a, Rect: image.Rect(0, 0, 32, 18)}, {Path: b, Rect: image.Rect(32, 0, 64, 18)}}
+
+	first, err := Apply(parts, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Apply(parts, cache); again != first {
+		t.Errorf("same parts composed to %q, then %q", first, again)
+	}
+	swapped := []Part{{Path: b, Rect: parts[0].Rect}, {Path: a, Rect: parts[1].Rect}}
+	if other, _ := Apply(swapped, cache); other == first {
+		t.Error("swapped images should compose a different canvas")
+	}
+	if _, err := Apply([]Part{{Rect: parts[0].Rect}}, cache); err == nil {
+		t.Error("expected an error without any image")
+	}
+
+	blank, err := Apply([]Part{parts[0], {Rect: parts[1].Rect}}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(blank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil || cfg.Width != 64 {
+		t.Errorf("a monitor without an image should still widen the canvas, got %dpx (%v)", cfg.Width, err)
+	}
+}
@@ -0,0 +1,87 @@
+package compose
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"strconv"
+
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/process"
+)
+
+// Layout returns rects pushed apart by bezel at every seam, the way the
+// screens sit physically: each rect moves right by bezel.X for every seam
+// to its left (a distinct right edge of the rects wholly left of it) and
+// down by bezel.Y for every seam above it.
+func Layout(rects []image.Rectangle, bezel image.Point) []image.Rectangle {
+	out := make([]image.Rectangle, len(rects))
+	for i, r := range rects {
+		left, above := map[int]bool{}, map[int]bool{}
+		for _, o := range rects {
+			if o.Max.X <= r.Min.X {
+				left[o.Max.X] = true
+			}
+			if o.Max.Y <= r.Min.Y {
+				above[o.Max.Y] = true
+			}
+		}
+		out[i] = r.Add(image.Pt(len(left)*bezel.X, len(above)*bezel.Y))
+	}
+	return out
+}
+
+// Slice scales img to cover the bezel-spread layout of rects and cuts it
+// into one image per rect, each the size of its monitor, so the monitors
+// side by side show img as one continuous picture.
+func Slice(img image.Image, rects []image.Rectangle, bezel image.Point) []*image.RGBA {
+	layout := Layout(rects, bezel)
+	b := Bounds(layout)
+	full := process.Cover(img, b.Size())
+	slices := make([]*image.RGBA, len(layout))
+	for i, r := range layout {
+		slices[i] = image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+		draw.Draw(slices[i], slices[i].Bounds(), full, r.Min.Sub(b.Min), draw.Src)
+	}
+	return slices
+}
+
+// SliceFile slices the image at path for rects into cacheDir and returns
+// the slices' paths in the order of rects. Each slice is keyed by path,
+// size, modification time, the whole layout, bezel and its position, so an
+// image is cut only once per layout.
+func SliceFile(path string, rects []image.Rectangle, bezel image.Point, cacheDir string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	extra := []string{"panorama", bezel.String()}
+	for _, r := range rects {
+		extra = append(extra, r.String())
+	}
+	keys := make([]string, len(rects))
+	paths := make([]string, len(rects))
+	cached := true
+	for i := range rects {
+		keys[i] = imagetype.Key(path, info, append(extra, "slice", strconv.Itoa(i))...)
+		var ok bool
+		if paths[i], ok = imagetype.Cached(cacheDir, keys[i]); !ok {
+			cached = false
+		}
+	}
+	if cached {
+		return paths, nil
+	}
+
+	img, err := imagetype.Decode(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range Slice(img, rects, bezel) {
+		if paths[i], err = imagetype.Store(s, cacheDir, keys[i]); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
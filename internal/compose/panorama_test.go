@@ -0,0 +1,95 @@
+package compose
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLayoutSpreadsMonitorsByTheBezel(t *testing.T) {
+	// Three side-by-side monitors, the middle one the primary, and a fourth
+	// above the primary.
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 100, 50),
+		image.Rect(-100, 0, 0, 50),
+		image.Rect(100, 0, 200, 50),
+		image.Rect(0, -50, 100, 0),
+	}
+	got := Layout(rects, image.Pt(10, 4))
+	want := []image.Rectangle{
+		image.Rect(10, 4, 110, 54),
+		image.Rect(-100, 4, 0, 54),
+		image.Rect(120, 4, 220, 54),
+		image.Rect(10, -50, 110, 0),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Layout() = %v, want %v", got, want)
+	}
+	if got := Layout(rects, image.Point{}); !reflect.DeepEqual(got, rects) {
+		t.Errorf("without a bezel, Layout() = %v, want the rects unchanged", got)
+	}
+}
+
+// stripes returns a w-pixel-wide image whose column x has red x, so a
+// slice's first column tells which part of it the slice shows.
+func stripes(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetRGBA(x, y, color.RGBA{uint8(x), 0, 0, 255})
+		}
+	}
+	return img
+}
+
+func TestSliceSkipsTheBezel(t *testing.T) {
+	rects := []image.Rectangle{image.Rect(0, 0, 100, 50), image.Rect(100, 0, 200, 50)}
+	slices := Slice(stripes(220, 50), rects, image.Pt(20, 0))
+	if len(slices) != 2 {
+		t.Fatalf("got %d slices, want 2", len(slices))
+	}
+	for i, s := range slices {
+		if s.Bounds() != image.Rect(0, 0, 100, 50) {
+			t.Errorf("slice %d bounds = %v, want the monitor's size", i, s.Bounds())
+		}
+	}
+	if r := slices[0].RGBAAt(99, 25).R; r != 99 {
+		t.Errorf("left slice ends at column %d, want 99", r)
+	}
+	if r := slices[1].RGBAAt(0, 25).R; r != 120 {
+		t.Errorf("right slice starts at column %d, want 120 (past the 20px bezel)", r)
+	}
+}
+
+func TestSliceFileCachesTheSlices(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "wide.png")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, stripes(200, 40)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	cache := filepath.Join(dir, "composed")
+	rects := []image.Rectangle{image.Rect(0, 0, 50, 20), image.Rect(50, 0, 100, 20)}
+
+	first, err := SliceFile(src, rects, image.Point{}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || first[0] == first[1] {
+		t.Fatalf("SliceFile() = %q, want two distinct slices", first)
+	}
+	if again, _ := SliceFile(src, rects, image.Point{}, cache); !reflect.DeepEqual(again, first) {
+		t.Errorf("same layout sliced to %q, then %q", first, again)
+	}
+	if other, _ := SliceFile(src, rects, image.Pt(8, 0), cache); reflect.DeepEqual(other, first) {
+		t.Error("a different bezel should cut different slices")
+	}
+}
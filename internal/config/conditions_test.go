@@ -1,6 +1,8 @@
 package config
 
 import (
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -66,3 +68,79 @@ func TestLoadWeatherConfigParsesEntries(t *testing.T) {
 		t.Errorf("got %+v, want provider=open-meteo latitude=-23.55 longitude=-46.63", wc)
 	}
 }
+
+func TestLoadWeatherConfigParsesBuckets(t *testing.T) {
+	v := viper.New()
+	v.Set("configuration.weather.provider", "open-meteo")
+	v.Set("configuration.weather.buckets", map[string]any{"heavy-rain": []any{65, 67, 82}})
+
+	wc, err := LoadWeatherConfig(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := wc.Buckets["heavy-rain"]; len(got) != 3 || got[0] != 65 {
+		t.Errorf("buckets[heavy-rain] = %v, want [65 67 82]", got)
+	}
+}
+
+func TestLoadConditionsImperialUnitsConvertToMetric(t *testing.T) {
+	v := viper.New()
+	v.Set("configuration.weather.units", "imperial")
+	v.Set("configuration.conditions.cold.temperature-max", 50)
+	v.Set("configuration.conditions.windy.wind-speed-min", 10)
+
+	conditions, err := LoadConditions(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := *conditions["cold"].TemperatureMax; math.Abs(got-10) > 0.01 {
+		t.Errorf("cold.temperature-max = %v C, want 10 (50F)", got)
+	}
+	if got := *conditions["windy"].WindSpeedMin; math.Abs(got-16.09) > 0.01 {
+		t.Errorf("windy.wind-speed-min = %v km/h, want 16.09 (10mph)", got)
+	}
+}
+
+func TestLoadConditionsSuffixOverridesUnits(t *testing.T) {
+	v := viper.New()
+	v.Set("configuration.conditions.cold.temperature-max", "50F")
+	v.Set("configuration.conditions.windy.wind-speed-min", "20mph")
+	v.Set("configuration.conditions.mild.temperature-min", 18)
+
+	conditions, err := LoadConditions(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := *conditions["cold"].TemperatureMax; math.Abs(got-10) > 0.01 {
+		t.Errorf("cold.temperature-max = %v C, want 10 (50F)", got)
+	}
+	if got := *conditions["windy"].WindSpeedMin; math.Abs(got-32.19) > 0.01 {
+		t.Errorf("windy.wind-speed-min = %v km/h, want 32.19 (20mph)", got)
+	}
+	if got := *conditions["mild"].TemperatureMin; got != 18 {
+		t.Errorf("mild.temperature-min = %v, want 18 (bare metric number)", got)
+	}
+}
+
+func TestLoadConditionsMismatchedUnit(t *testing.T) {
+	for field, value := range map[string]string{"wind-speed-min": "50F", "temperature-max": "20mph"} {
+		v := viper.New()
+		v.Set("configuration.conditions.odd."+field, value)
+		_, err := LoadConditions(v)
+		if err == nil {
+			t.Errorf("%s: %q: expected an error", field, value)
+			continue
+		}
+		if msg := err.Error(); !strings.Contains(msg, `"odd"`) || !strings.Contains(msg, field) {
+			t.Errorf("%s: error %q should name the condition and the field", field, msg)
+		}
+	}
+}
+
+func TestLoadConditionsInvalidUnits(t *testing.T) {
+	v := viper.New()
+	v.Set("configuration.weather.units", "kelvin")
+	if _, err := LoadConditions(v); err == nil {
+		t.Error("expected error for unknown configuration.weather.units")
+	}
+}
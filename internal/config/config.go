@@ -1,15 +1,28 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"image"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/lucasassuncao/gopaper/internal/helper"
 	"github.com/lucasassuncao/gopaper/internal/history"
+	"github.com/lucasassuncao/gopaper/internal/hooks"
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/imghash"
+	"github.com/lucasassuncao/gopaper/internal/index"
 	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/palette"
+	"github.com/lucasassuncao/gopaper/internal/theme"
+	"github.com/lucasassuncao/gopaper/internal/weather"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
@@ -70,13 +83,37 @@ func WithConfigPath(path string) ViperOptions {
 // UnmarshalConfig unmarshals the config file into a struct
 func UnmarshalConfig(m *models.Gopaper) ([]*models.Categories, error) {
 	var categories []*models.Categories
-	if err := m.Viper.UnmarshalKey("categories", &categories); err != nil {
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		sourceDirHook,
+		processStepHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+	if err := m.Viper.UnmarshalKey("categories", &categories, hook); err != nil {
 		return nil, fmt.Errorf("unable to decode config into struct: %w", err)
 	}
 
 	return categories, nil
 }
 
+// sourceDirHook decodes the plain-path shorthand of a sources entry into a
+// models.SourceDir.
+func sourceDirHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeFor[models.SourceDir]() {
+		return data, nil
+	}
+	return models.SourceDir{Path: data.(string)}, nil
+}
+
+// processStepHook decodes the bare-name shorthand of a process step into a
+// models.ProcessStep.
+func processStepHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeFor[models.ProcessStep]() {
+		return data, nil
+	}
+	return models.ParseProcessStep(data.(string))
+}
+
 // LoadDefault loads gopaper.yaml from the standard search locations: next to
 // the executable, then its conf subdirectory. Returns
 // ConfigFileNotFoundError if none exists.
@@ -107,8 +144,9 @@ func TransitionEnabledForCategory(v *viper.Viper, categoryTransition string) boo
 }
 
 // MonitorMode returns the configured default monitor behavior: "per-monitor",
-// "monitorN" (pinned to a single 1-based monitor index), or "all" (the
-// default, one image mirrored on every monitor).
+// "panorama" (one image spread across every monitor), "monitorN" (pinned to
+// a single 1-based monitor index), or "all" (the default, one image
+// mirrored on every monitor).
 func MonitorMode(v *viper.Viper) string {
 	if m := v.GetString("configuration.behavior.monitor"); isMonitorMode(m) {
 		return m
@@ -127,9 +165,9 @@ func MonitorModeForCategory(v *viper.Viper, categoryMode string) string {
 }
 
 // isMonitorMode reports whether m is a recognized monitor mode: "all",
-// "per-monitor", or a "monitorN" pin.
+// "per-monitor", "panorama", or a "monitorN" pin.
 func isMonitorMode(m string) bool {
-	if m == "all" || m == "per-monitor" {
+	if m == "all" || m == "per-monitor" || m == "panorama" {
 		return true
 	}
 	_, ok := ParseMonitorMode(m)
@@ -164,6 +202,67 @@ func ModeForCategory(v *viper.Viper, categoryMode string) string {
 	return "crop"
 }
 
+// BezelForCategory resolves the effective bezel gaps for a category, as
+// horizontal and vertical pixels: configuration.behavior.bezel with every
+// field set in categoryBezel (categories[].behavior.bezel) overriding it.
+// Negative widths count as 0.
+func BezelForCategory(v *viper.Viper, categoryBezel *models.Bezel) image.Point {
+	gap := image.Pt(v.GetInt("configuration.behavior.bezel.horizontal"), v.GetInt("configuration.behavior.bezel.vertical"))
+	if categoryBezel != nil {
+		if categoryBezel.Horizontal != 0 {
+			gap.X = categoryBezel.Horizontal
+		}
+		if categoryBezel.Vertical != 0 {
+			gap.Y = categoryBezel.Vertical
+		}
+	}
+	return image.Pt(max(gap.X, 0), max(gap.Y, 0))
+}
+
+// OverlayForCategory resolves the effective overlay for a category:
+// configuration.behavior.overlay with every field set in categoryOverlay
+// (categories[].behavior.overlay) overriding it. It returns nil when the
+// result is disabled or shows nothing.
+func OverlayForCategory(v *viper.Viper, categoryOverlay *models.Overlay) (*models.Overlay, error) {
+	var o models.Overlay
+	if v.IsSet("configuration.behavior.overlay") {
+		if err := v.UnmarshalKey("configuration.behavior.overlay", &o); err != nil {
+			return nil, fmt.Errorf("unable to decode configuration.behavior.overlay: %w", err)
+		}
+	}
+	if c := categoryOverlay; c != nil {
+		if c.Enabled != nil {
+			o.Enabled = c.Enabled
+		}
+		if len(c.Show) > 0 {
+			o.Show = c.Show
+		}
+		if c.Corner != "" {
+			o.Corner = c.Corner
+		}
+		if c.FontSize != 0 {
+			o.FontSize = c.FontSize
+		}
+		if c.Color != "" {
+			o.Color = c.Color
+		}
+		if c.Shadow != nil {
+			o.Shadow = c.Shadow
+		}
+		if c.DateFormat != "" {
+			o.DateFormat = c.DateFormat
+		}
+		if c.QuoteFile != "" {
+			o.QuoteFile = c.QuoteFile
+		}
+	}
+	if (o.Enabled != nil && !*o.Enabled) || len(o.Show) == 0 {
+		return nil, nil
+	}
+	o.QuoteFile = ExpandTilde(o.QuoteFile)
+	return &o, nil
+}
+
 // LoadWallhavenAPIKey returns configuration.wallhaven.api-key, or "" when unset.
 func LoadWallhavenAPIKey(v *viper.Viper) string {
 	return v.GetString("configuration.wallhaven.api-key")
@@ -227,14 +326,142 @@ func HistoryPath(v *viper.Viper) (string, error) {
 // LoadConditions returns the named conditions declared in
 // configuration.conditions, keyed by name. Returns an empty (non-nil) map
 // when the section is absent.
+//
+// Temperature and wind-speed thresholds are normalized to metric (Celsius,
+// km/h) here, once, so evaluation never has to care about units: bare
+// numbers are read in configuration.weather.units (metric by default), and
+// suffixed strings such as "50F" or "20mph" in the unit they name.
 func LoadConditions(v *viper.Viper) (map[string]models.Condition, error) {
+	units, err := WeatherUnits(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkThresholdKinds(v); err != nil {
+		return nil, err
+	}
+
 	conditions := map[string]models.Condition{}
-	if err := v.UnmarshalKey("configuration.conditions", &conditions); err != nil {
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		thresholdHook(units),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+	if err := v.UnmarshalKey("configuration.conditions", &conditions, hook); err != nil {
 		return nil, fmt.Errorf("unable to decode configuration.conditions: %w", err)
 	}
+
+	if units == weather.UnitsImperial {
+		for name, c := range conditions {
+			conditions[name] = conditionToMetric(c)
+		}
+	}
 	return conditions, nil
 }
 
+// WeatherUnits returns the unit system configured in
+// configuration.weather.units, metric when unset.
+func WeatherUnits(v *viper.Viper) (weather.Units, error) {
+	units, err := weather.ParseUnits(v.GetString("configuration.weather.units"))
+	if err != nil {
+		return "", fmt.Errorf("invalid configuration.weather.units: %w", err)
+	}
+	return units, nil
+}
+
+// thresholdKinds is the kind of quantity each condition threshold measures.
+var thresholdKinds = map[string]weather.QuantityKind{
+	"temperature-min": weather.KindTemperature,
+	"temperature-max": weather.KindTemperature,
+	"wind-speed-min":  weather.KindSpeed,
+	"wind-speed-max":  weather.KindSpeed,
+}
+
+// checkThresholdKinds rejects a threshold whose unit suffix measures
+// another kind of quantity than its field ("50F" as a wind speed), which
+// thresholdHook, converting by the suffix alone, would otherwise accept.
+func checkThresholdKinds(v *viper.Viper) error {
+	for name, raw := range v.GetStringMap("configuration.conditions") {
+		fields, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		for field, want := range thresholdKinds {
+			s, ok := fields[field].(string)
+			if !ok {
+				continue
+			}
+			_, _, kind, err := weather.ParseQuantity(s)
+			if err != nil {
+				return fmt.Errorf("condition %q: %s: %w", name, field, err)
+			}
+			if kind != weather.KindNone && kind != want {
+				return fmt.Errorf("condition %q: %s: %q is not a %s", name, field, s, kindName(want))
+			}
+		}
+	}
+	return nil
+}
+
+func kindName(k weather.QuantityKind) string {
+	if k == weather.KindSpeed {
+		return "speed"
+	}
+	return "temperature"
+}
+
+// thresholdHook decodes a suffixed threshold string ("50F", "20mph") into a
+// number expressed in units, so conditionToMetric can then treat it exactly
+// like a bare number written in the configured system.
+func thresholdHook(units weather.Units) mapstructure.DecodeHookFuncType {
+	return func(from, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String || to.Kind() != reflect.Float64 {
+			return data, nil
+		}
+		value, unit, kind, err := weather.ParseQuantity(data.(string))
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case weather.KindTemperature:
+			return weather.ConvertTemperature(value, unit, units.TemperatureUnit())
+		case weather.KindSpeed:
+			return weather.ConvertSpeed(value, unit, units.SpeedUnit())
+		default:
+			return value, nil
+		}
+	}
+}
+
+// conditionToMetric converts c's imperial thresholds (Fahrenheit, mph) to
+// the metric units weather.Snapshot is reported in.
+func conditionToMetric(c models.Condition) models.Condition {
+	c.TemperatureMin = convertThreshold(c.TemperatureMin, func(f float64) (float64, error) {
+		return weather.ConvertTemperature(f, "F", "C")
+	})
+	c.TemperatureMax = convertThreshold(c.TemperatureMax, func(f float64) (float64, error) {
+		return weather.ConvertTemperature(f, "F", "C")
+	})
+	c.WindSpeedMin = convertThreshold(c.WindSpeedMin, func(f float64) (float64, error) {
+		return weather.ConvertSpeed(f, "mph", "km/h")
+	})
+	c.WindSpeedMax = convertThreshold(c.WindSpeedMax, func(f float64) (float64, error) {
+		return weather.ConvertSpeed(f, "mph", "km/h")
+	})
+	return c
+}
+
+func convertThreshold(v *float64, convert func(float64) (float64, error)) *float64 {
+	if v == nil {
+		return nil
+	}
+	out, err := convert(*v)
+	if err != nil {
+		return v
+	}
+	return &out
+}
+
 // LoadWeatherConfig returns the configuration.weather section, or nil when
 // it is not set.
 func LoadWeatherConfig(v *viper.Viper) (*models.WeatherConfig, error) {
@@ -258,6 +485,314 @@ func WeatherCachePath() (string, error) {
 	return filepath.Join(filepath.Dir(histPath), "weather-cache.json"), nil
 }
 
+// defaultIndexTTL is how old a file index may get before it is rebuilt
+// from scratch when configuration.index.ttl is unset.
+const defaultIndexTTL = 24 * time.Hour
+
+// IndexStore returns the file-index store configured in
+// configuration.index, or nil when the index is not enabled.
+func IndexStore(v *viper.Viper) (*index.Store, error) {
+	if !v.GetBool("configuration.index.enabled") {
+		return nil, nil
+	}
+	store, err := IndexSettings(v)
+	if err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// IndexSettings returns the file-index store described by
+// configuration.index whether or not it is enabled. Indexes live in
+// configuration.index.dir (tilde-expanded), defaulting to an index
+// subdirectory next to the history file.
+func IndexSettings(v *viper.Viper) (index.Store, error) {
+	ttl := defaultIndexTTL
+	if raw := v.GetString("configuration.index.ttl"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return index.Store{}, fmt.Errorf("invalid configuration.index.ttl: %w", err)
+		}
+		ttl = d
+	}
+
+	dir := v.GetString("configuration.index.dir")
+	if dir == "" {
+		histPath, err := HistoryPath(v)
+		if err != nil {
+			return index.Store{}, err
+		}
+		dir = filepath.Join(filepath.Dir(histPath), "index")
+	}
+	return index.Store{Dir: ExpandTilde(dir), TTL: ttl}, nil
+}
+
+// Detection returns configuration.formats.detection, defaulting to
+// imagetype.DetectExtension.
+func Detection(v *viper.Viper) string {
+	if v.GetString("configuration.formats.detection") == imagetype.DetectSniff {
+		return imagetype.DetectSniff
+	}
+	return imagetype.DetectExtension
+}
+
+// ConvertCacheDir returns the directory converted images are written to:
+// configuration.formats.cache, or a converted directory next to the
+// history file.
+func ConvertCacheDir(v *viper.Viper) (string, error) {
+	if dir := v.GetString("configuration.formats.cache"); dir != "" {
+		return ExpandTilde(dir), nil
+	}
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "converted"), nil
+}
+
+// ProcessCacheDir returns the directory the copies rendered by categories'
+// process steps are written to: a processed directory next to the history
+// file.
+func ProcessCacheDir(v *viper.Viper) (string, error) {
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "processed"), nil
+}
+
+// OverlayCacheDir returns the directory wallpapers with their overlay text
+// rendered on are written to: an overlay directory next to the history
+// file.
+func OverlayCacheDir(v *viper.Viper) (string, error) {
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "overlay"), nil
+}
+
+// ComposeCacheDir returns the directory per-monitor picks composed into one
+// spanned canvas, and panorama slices, are written to: a composed directory
+// next to the history file.
+func ComposeCacheDir(v *viper.Viper) (string, error) {
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "composed"), nil
+}
+
+// CollageCacheDir returns the directory collage categories' renders are
+// written to: a collage directory next to the history file.
+func CollageCacheDir(v *viper.Viper) (string, error) {
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "collage"), nil
+}
+
+// GenerateCacheDir returns the directory generated images are written to:
+// a generated directory next to the history file.
+func GenerateCacheDir(v *viper.Viper) (string, error) {
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "generated"), nil
+}
+
+// CalendarCacheDir returns the directory calendar categories' renders are
+// written to: a calendar directory next to the history file.
+func CalendarCacheDir(v *viper.Viper) (string, error) {
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "calendar"), nil
+}
+
+// MetadataCachePath returns the file image metadata read for filters is
+// cached in: metadata.json next to the history file.
+func MetadataCachePath(v *viper.Viper) (string, error) {
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "metadata.json"), nil
+}
+
+// TagDBPath returns the file the tags assigned with gopaper tag are stored
+// in: tags.json next to the history file.
+func TagDBPath(v *viper.Viper) (string, error) {
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "tags.json"), nil
+}
+
+// FavoriteWeight returns configuration.favorites.weight, the factor a
+// favorite's chance of being picked is multiplied by; 1 when unset or
+// below 1.
+func FavoriteWeight(v *viper.Viper) int {
+	if w := v.GetInt("configuration.favorites.weight"); w > 1 {
+		return w
+	}
+	return 1
+}
+
+// defaultDedupeThreshold is the Hamming distance below which two images
+// are the same picture when configuration.dedupe.threshold is unset.
+const defaultDedupeThreshold = 8
+
+// DedupeOptions returns how near-duplicates are recognized, from
+// configuration.dedupe.algorithm and threshold.
+func DedupeOptions(v *viper.Viper) (imghash.Options, error) {
+	alg, err := imghash.ParseAlgorithm(v.GetString("configuration.dedupe.algorithm"))
+	if err != nil {
+		return imghash.Options{}, fmt.Errorf("invalid configuration.dedupe.algorithm: %w", err)
+	}
+	threshold := defaultDedupeThreshold
+	if v.IsSet("configuration.dedupe.threshold") {
+		threshold = v.GetInt("configuration.dedupe.threshold")
+		if threshold < 0 || threshold > 64 {
+			return imghash.Options{}, fmt.Errorf("invalid configuration.dedupe.threshold %d: must be between 0 and 64", threshold)
+		}
+	}
+	return imghash.Options{Algorithm: alg, Threshold: threshold}, nil
+}
+
+// ThemeTemplates returns configuration.theme.templates, with a leading ~
+// expanded in every path, and the number of colors to extract from
+// configuration.theme.colors (palette.DefaultColors when unset).
+func ThemeTemplates(v *viper.Viper) ([]theme.Template, int, error) {
+	colors := palette.DefaultColors
+	if v.IsSet("configuration.theme.colors") {
+		colors = v.GetInt("configuration.theme.colors")
+		if colors < palette.MinColors || colors > palette.MaxColors {
+			return nil, 0, fmt.Errorf("invalid configuration.theme.colors %d: must be between %d and %d", colors, palette.MinColors, palette.MaxColors)
+		}
+	}
+	var raw []models.ThemeTemplate
+	if err := v.UnmarshalKey("configuration.theme.templates", &raw); err != nil {
+		return nil, 0, fmt.Errorf("invalid configuration.theme.templates: %w", err)
+	}
+	templates := make([]theme.Template, 0, len(raw))
+	for i, t := range raw {
+		if t.In == "" || t.Out == "" {
+			return nil, 0, fmt.Errorf("invalid configuration.theme.templates[%d]: in and out are required", i)
+		}
+		templates = append(templates, theme.Template{In: ExpandTilde(t.In), Out: ExpandTilde(t.Out)})
+	}
+	return templates, colors, nil
+}
+
+// Hooks returns the configuration.hooks commands run on event
+// (hooks.PreChange or hooks.PostChange), each with its timeout resolved:
+// its own, else configuration.hooks.timeout, else hooks.DefaultTimeout.
+func Hooks(v *viper.Viper, event string) ([]hooks.Hook, error) {
+	var raw []models.Hook
+	if err := v.UnmarshalKey("configuration.hooks."+event, &raw); err != nil {
+		return nil, fmt.Errorf("invalid configuration.hooks.%s: %w", event, err)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	fallback := hooks.DefaultTimeout
+	if s := v.GetString("configuration.hooks.timeout"); s != "" {
+		d, err := parseTimeout(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration.hooks.timeout: %w", err)
+		}
+		fallback = d
+	}
+	out := make([]hooks.Hook, 0, len(raw))
+	for i, h := range raw {
+		if strings.TrimSpace(h.Command) == "" {
+			return nil, fmt.Errorf("invalid configuration.hooks.%s[%d]: command is required", event, i)
+		}
+		timeout := fallback
+		if h.Timeout != "" {
+			d, err := parseTimeout(h.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid configuration.hooks.%s[%d].timeout: %w", event, i, err)
+			}
+			timeout = d
+		}
+		out = append(out, hooks.Hook{Command: h.Command, Timeout: timeout})
+	}
+	return out, nil
+}
+
+// parseTimeout parses a hook timeout: a positive Go duration.
+func parseTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive, got %s", s)
+	}
+	return d, nil
+}
+
+// FileOutput returns configuration.output for the file backend, with a
+// leading ~ expanded in every path, monitor sizes defaulting to 1920x1080
+// and the manifest to current.json next to the output path.
+func FileOutput(v *viper.Viper) (helper.FileOutput, error) {
+	var raw models.OutputConfig
+	if err := v.UnmarshalKey("configuration.output", &raw); err != nil {
+		return helper.FileOutput{}, fmt.Errorf("invalid configuration.output: %w", err)
+	}
+	if raw.Path == "" {
+		return helper.FileOutput{}, errors.New("invalid configuration.output: path is required")
+	}
+	out := helper.FileOutput{Path: ExpandTilde(raw.Path), Manifest: ExpandTilde(raw.Manifest)}
+	switch raw.Method {
+	case "", "copy":
+	case "symlink":
+		out.Symlink = true
+	default:
+		return helper.FileOutput{}, fmt.Errorf("invalid configuration.output.method %q: use copy or symlink", raw.Method)
+	}
+	if out.Manifest == "" {
+		out.Manifest = filepath.Join(filepath.Dir(out.Path), "current.json")
+	}
+	for i, m := range raw.Monitors {
+		if m.Path == "" {
+			return helper.FileOutput{}, fmt.Errorf("invalid configuration.output.monitors[%d]: path is required", i)
+		}
+		if m.Width < 0 || m.Height < 0 {
+			return helper.FileOutput{}, fmt.Errorf("invalid configuration.output.monitors[%d]: width and height must be positive", i)
+		}
+		fm := helper.FileMonitor{Path: ExpandTilde(m.Path), Width: m.Width, Height: m.Height}
+		if fm.Width == 0 {
+			fm.Width = 1920
+		}
+		if fm.Height == 0 {
+			fm.Height = 1080
+		}
+		out.Monitors = append(out.Monitors, fm)
+	}
+	return out, nil
+}
+
+// QuarantineDir returns where gopaper dedupe moves duplicates to:
+// configuration.dedupe.quarantine, or a quarantine directory next to the
+// history file.
+func QuarantineDir(v *viper.Viper) (string, error) {
+	if dir := v.GetString("configuration.dedupe.quarantine"); dir != "" {
+		return ExpandTilde(dir), nil
+	}
+	histPath, err := HistoryPath(v)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(histPath), "quarantine"), nil
+}
+
 // HistoryLimit returns the configured maximum number of history entries.
 // A non-positive value tells history.Load to keep its own default.
 func HistoryLimit(v *viper.Viper) int {
@@ -1,11 +1,20 @@
 package config
 
 import (
+	"image"
+	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/lucasassuncao/gopaper/internal/helper"
+	"github.com/lucasassuncao/gopaper/internal/hooks"
+	"github.com/lucasassuncao/gopaper/internal/models"
 )
 
 func TestTransitionEnabledForCategory(t *testing.T) {
@@ -69,6 +78,32 @@ func TestMonitorModeForCategory(t *testing.T) {
 	}
 }
 
+func TestOverlayForCategory(t *testing.T) {
+	v := viper.New()
+	if o, err := OverlayForCategory(v, nil); err != nil || o != nil {
+		t.Fatalf("no overlay anywhere: got (%+v, %v), want nil", o, err)
+	}
+
+	v.Set("configuration.behavior.overlay", map[string]any{
+		"show":      []string{"date", "weather"},
+		"corner":    "top-left",
+		"font-size": 24,
+	})
+	o, err := OverlayForCategory(v, &models.Overlay{Show: []string{"hostname"}, Color: "#000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.Overlay{Show: []string{"hostname"}, Corner: "top-left", FontSize: 24, Color: "#000000"}
+	if !reflect.DeepEqual(*o, want) {
+		t.Errorf("merged overlay = %+v, want %+v", *o, want)
+	}
+
+	off := false
+	if o, _ := OverlayForCategory(v, &models.Overlay{Enabled: &off}); o != nil {
+		t.Errorf("enabled: false should turn the inherited overlay off, got %+v", o)
+	}
+}
+
 func TestWallhavenCacheDirOverride(t *testing.T) {
 	v := viper.New()
 	dir, err := WallhavenCacheDir(v, "My Category", `C:\walls\wh-cache`)
@@ -104,3 +139,251 @@ func TestSlugify(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalConfigSourcesShorthandAndWeights(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	raw := `
+categories:
+  - name: "Mixed"
+    sources:
+      - /mnt/nas/walls
+      - path: /home/me/walls
+        weight: 3
+    variants:
+      - sources: [./day]
+        hours: "06:00-17:59"
+`
+	if err := v.ReadConfig(strings.NewReader(raw)); err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+
+	cats, err := UnmarshalConfig(&models.Gopaper{Viper: v})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []models.SourceDir{{Path: "/mnt/nas/walls"}, {Path: "/home/me/walls", Weight: 3}}
+	if !slices.Equal(cats[0].Sources, want) {
+		t.Errorf("sources = %+v, want %+v", cats[0].Sources, want)
+	}
+	if got := cats[0].Variants[0].Sources; len(got) != 1 || got[0].Path != "./day" {
+		t.Errorf("variant sources = %+v, want [./day]", got)
+	}
+}
+
+func TestUnmarshalConfigProcessShorthand(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	raw := `
+categories:
+  - name: "Dimmed"
+    source: /walls
+    process:
+      - resize-to-monitor
+      - {dim: 0.3, condition: night}
+      - grayscale
+`
+	if err := v.ReadConfig(strings.NewReader(raw)); err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	cats, err := UnmarshalConfig(&models.Gopaper{Viper: v})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []models.ProcessStep{{ResizeToMonitor: true}, {Dim: 0.3, Condition: "night"}, {Grayscale: true}}
+	if !slices.Equal(cats[0].Process, want) {
+		t.Errorf("process = %+v, want %+v", cats[0].Process, want)
+	}
+
+	v.Set("categories", []any{map[string]any{"name": "Bad", "process": []any{"sepia"}}})
+	if _, err := UnmarshalConfig(&models.Gopaper{Viper: v}); err == nil {
+		t.Error("expected an error for an unknown process step")
+	}
+}
+
+func TestIndexStore(t *testing.T) {
+	v := viper.New()
+	if store, err := IndexStore(v); err != nil || store != nil {
+		t.Fatalf("disabled index: got (%v, %v), want (nil, nil)", store, err)
+	}
+
+	v.Set("configuration.index.enabled", true)
+	v.Set("configuration.index.dir", "/var/cache/gopaper")
+	store, err := IndexStore(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.Dir != "/var/cache/gopaper" || store.TTL != 24*time.Hour {
+		t.Errorf("got %+v, want the configured dir and the default 24h TTL", store)
+	}
+
+	v.Set("configuration.index.ttl", "soon")
+	if _, err := IndexStore(v); err == nil {
+		t.Error("expected error for an invalid ttl, got nil")
+	}
+}
+
+func TestFormatsSettings(t *testing.T) {
+	v := viper.New()
+	v.Set("configuration.history.file", "/data/gopaper/history.json")
+	if got := Detection(v); got != "extension" {
+		t.Errorf("Detection() = %q, want the extension default", got)
+	}
+	if dir, err := ConvertCacheDir(v); err != nil || dir != filepath.Join("/data/gopaper", "converted") {
+		t.Errorf("ConvertCacheDir() = (%q, %v), want converted next to the history file", dir, err)
+	}
+
+	v.Set("configuration.formats.detection", "sniff")
+	v.Set("configuration.formats.cache", "/tmp/converted")
+	if got := Detection(v); got != "sniff" {
+		t.Errorf("Detection() = %q, want sniff", got)
+	}
+	if dir, _ := ConvertCacheDir(v); dir != "/tmp/converted" {
+		t.Errorf("ConvertCacheDir() = %q, want the configured directory", dir)
+	}
+}
+
+func TestFavoriteWeight(t *testing.T) {
+	v := viper.New()
+	if got := FavoriteWeight(v); got != 1 {
+		t.Errorf("FavoriteWeight() = %d, want 1 when unset", got)
+	}
+	v.Set("configuration.favorites.weight", 0)
+	if got := FavoriteWeight(v); got != 1 {
+		t.Errorf("FavoriteWeight() = %d, want 1 below the minimum", got)
+	}
+	v.Set("configuration.favorites.weight", 4)
+	if got := FavoriteWeight(v); got != 4 {
+		t.Errorf("FavoriteWeight() = %d, want 4", got)
+	}
+}
+
+func TestDedupeOptions(t *testing.T) {
+	v := viper.New()
+	v.Set("configuration.history.file", "/data/gopaper/history.json")
+	opts, err := DedupeOptions(v)
+	if err != nil || opts.Algorithm != "phash" || opts.Threshold != 8 {
+		t.Errorf("DedupeOptions() = (%+v, %v), want the phash/8 defaults", opts, err)
+	}
+	if dir, _ := QuarantineDir(v); dir != filepath.Join("/data/gopaper", "quarantine") {
+		t.Errorf("QuarantineDir() = %q, want quarantine next to the history file", dir)
+	}
+
+	v.Set("configuration.dedupe.algorithm", "dhash")
+	v.Set("configuration.dedupe.threshold", 0)
+	if opts, err := DedupeOptions(v); err != nil || opts.Algorithm != "dhash" || opts.Threshold != 0 {
+		t.Errorf("DedupeOptions() = (%+v, %v), want dhash with an explicit 0", opts, err)
+	}
+	v.Set("configuration.dedupe.threshold", 65)
+	if _, err := DedupeOptions(v); err == nil {
+		t.Error("expected an error for a threshold above 64")
+	}
+}
+
+func TestBezelForCategory(t *testing.T) {
+	v := viper.New()
+	if got := BezelForCategory(v, nil); got != (image.Point{}) {
+		t.Errorf("no bezel anywhere: got %v, want 0,0", got)
+	}
+	v.Set("configuration.behavior.bezel", map[string]any{"horizontal": 60, "vertical": 40})
+	if got := BezelForCategory(v, &models.Bezel{Horizontal: 30}); got != image.Pt(30, 40) {
+		t.Errorf("merged bezel = %v, want 30,40", got)
+	}
+}
+
+func TestThemeTemplates(t *testing.T) {
+	v := viper.New()
+	templates, colors, err := ThemeTemplates(v)
+	if err != nil || len(templates) != 0 || colors != 16 {
+		t.Errorf("ThemeTemplates() = (%v, %d, %v), want none and 16 colors", templates, colors, err)
+	}
+
+	home, _ := os.UserHomeDir()
+	v.Set("configuration.theme", map[string]any{
+		"colors":    8,
+		"templates": []any{map[string]any{"in": "kitty", "out": "~/.config/kitty/gopaper.conf"}},
+	})
+	templates, colors, err = ThemeTemplates(v)
+	if err != nil || colors != 8 || len(templates) != 1 {
+		t.Fatalf("ThemeTemplates() = (%v, %d, %v)", templates, colors, err)
+	}
+	if want := filepath.Join(home, ".config/kitty/gopaper.conf"); templates[0].In != "kitty" || templates[0].Out != want {
+		t.Errorf("template = %+v, want kitty to %s", templates[0], want)
+	}
+
+	v.Set("configuration.theme.colors", 32)
+	if _, _, err := ThemeTemplates(v); err == nil {
+		t.Error("expected an error for 32 colors")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	v := viper.New()
+	if got, err := Hooks(v, hooks.PreChange); err != nil || got != nil {
+		t.Errorf("Hooks() = (%v, %v), want none", got, err)
+	}
+
+	v.Set("configuration.hooks", map[string]any{
+		"timeout": "3s",
+		"post-change": []any{
+			map[string]any{"command": "notify-send gopaper"},
+			map[string]any{"command": "sync-lockscreen", "timeout": "1m"},
+		},
+	})
+	got, err := Hooks(v, hooks.PostChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []hooks.Hook{{Command: "notify-send gopaper", Timeout: 3 * time.Second}, {Command: "sync-lockscreen", Timeout: time.Minute}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Hooks() = %+v, want %+v", got, want)
+	}
+
+	v.Set("configuration.hooks.timeout", "-1s")
+	if _, err := Hooks(v, hooks.PostChange); err == nil {
+		t.Error("expected an error for a negative timeout")
+	}
+	v.Set("configuration.hooks.timeout", "")
+	v.Set("configuration.hooks.pre-change", []any{map[string]any{"command": " "}})
+	if _, err := Hooks(v, hooks.PreChange); err == nil {
+		t.Error("expected an error for an empty command")
+	}
+}
+
+func TestFileOutput(t *testing.T) {
+	v := viper.New()
+	if _, err := FileOutput(v); err == nil {
+		t.Error("expected an error without configuration.output.path")
+	}
+
+	dir := t.TempDir()
+	v.Set("configuration.output", map[string]any{
+		"path":   filepath.Join(dir, "wallpaper.jpg"),
+		"method": "symlink",
+		"monitors": []any{
+			map[string]any{"path": filepath.Join(dir, "left.jpg")},
+			map[string]any{"path": filepath.Join(dir, "right.jpg"), "width": 1080, "height": 1920},
+		},
+	})
+	got, err := FileOutput(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := helper.FileOutput{
+		Path:     filepath.Join(dir, "wallpaper.jpg"),
+		Symlink:  true,
+		Manifest: filepath.Join(dir, "current.json"),
+		Monitors: []helper.FileMonitor{
+			{Path: filepath.Join(dir, "left.jpg"), Width: 1920, Height: 1080},
+			{Path: filepath.Join(dir, "right.jpg"), Width: 1080, Height: 1920},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FileOutput() = %+v, want %+v", got, want)
+	}
+
+	v.Set("configuration.output.method", "hardlink")
+	if _, err := FileOutput(v); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+}
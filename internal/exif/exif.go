@@ -0,0 +1,314 @@
+// Package exif reads the few EXIF fields gopaper filters on — capture time,
+// camera, GPS position and orientation — out of JPEG, TIFF and WebP files,
+// without decoding the image itself.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Data is the EXIF metadata of one image. Zero fields were absent.
+type Data struct {
+	DateTimeOriginal time.Time `json:"taken,omitzero"`
+	Make             string    `json:"make,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	GPS              *GPS      `json:"gps,omitempty"`
+	Orientation      int       `json:"orientation,omitempty"` // 1-8, as in the TIFF spec
+}
+
+// GPS is a position in decimal degrees, negative south of the equator and
+// west of Greenwich.
+type GPS struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+}
+
+// ErrNoExif is returned for a supported file that carries no EXIF block.
+var ErrNoExif = errors.New("no EXIF metadata")
+
+// tag numbers read from the TIFF IFDs.
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagOffsetTimeOrig   = 0x9011
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+// ReadFile returns the EXIF metadata of the JPEG, TIFF or WebP file at
+// path. It returns ErrNoExif when the file has none, and an error for
+// other formats.
+func ReadFile(path string) (*Data, error) {
+	f, err := os.Open(path) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 12)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8}):
+		block, err := jpegExif(f)
+		if err != nil {
+			return nil, err
+		}
+		return parseTIFF(bytes.NewReader(block))
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return parseTIFF(f)
+	case len(header) == 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		block, err := webpExif(f)
+		if err != nil {
+			return nil, err
+		}
+		return parseTIFF(bytes.NewReader(block))
+	}
+	return nil, fmt.Errorf("%s: EXIF is only read from JPEG, TIFF and WebP files", path)
+}
+
+// exifHeader prefixes the TIFF structure inside a JPEG APP1 segment (and,
+// in some writers' output, a WebP EXIF chunk).
+var exifHeader = []byte("Exif\x00\x00")
+
+// jpegExif returns the TIFF structure from the APP1 Exif segment of the
+// JPEG r, walking the segments up to the start of the image data.
+func jpegExif(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(2, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var marker [4]byte
+	for {
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, ErrNoExif
+		}
+		if marker[0] != 0xFF {
+			return nil, fmt.Errorf("corrupt JPEG segment marker")
+		}
+		size := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if marker[1] == 0xDA || marker[1] == 0xD9 || size < 0 { // start of scan / end of image
+			return nil, ErrNoExif
+		}
+		if marker[1] == 0xE1 {
+			seg := make([]byte, size)
+			if _, err := io.ReadFull(r, seg); err != nil {
+				return nil, fmt.Errorf("could not read JPEG APP1 segment: %w", err)
+			}
+			if bytes.HasPrefix(seg, exifHeader) {
+				return seg[len(exifHeader):], nil
+			}
+			continue // XMP or another APP1 payload
+		}
+		if _, err := r.Seek(int64(size), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// webpExif returns the TIFF structure from the EXIF chunk of the WebP r.
+func webpExif(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var chunk [8]byte
+	for {
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, ErrNoExif
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if string(chunk[:4]) == "EXIF" {
+			if size > 1<<24 {
+				return nil, fmt.Errorf("WebP EXIF chunk too large")
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("could not read WebP EXIF chunk: %w", err)
+			}
+			return bytes.TrimPrefix(data, exifHeader), nil
+		}
+		if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil { // chunks are padded to even sizes
+			return nil, err
+		}
+	}
+}
+
+// tiffReader reads IFD entries out of a TIFF structure.
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// ifdEntry is one 12-byte IFD entry.
+type ifdEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    [4]byte // the value itself when it fits, else its offset
+}
+
+// parseTIFF reads Data out of the TIFF structure r.
+func parseTIFF(r io.ReaderAt) (*Data, error) {
+	var head [8]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, fmt.Errorf("corrupt EXIF header: %w", err)
+	}
+	t := tiffReader{r: r}
+	switch string(head[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("corrupt EXIF header: unknown byte order")
+	}
+	if t.order.Uint16(head[2:]) != 42 {
+		return nil, fmt.Errorf("corrupt EXIF header: bad magic number")
+	}
+
+	ifd0, err := t.ifd(int64(t.order.Uint32(head[4:])))
+	if err != nil {
+		return nil, err
+	}
+	d := &Data{
+		Make:        t.ascii(ifd0[tagMake]),
+		Model:       t.ascii(ifd0[tagModel]),
+		Orientation: int(t.uint(ifd0[tagOrientation])),
+	}
+	if e, ok := ifd0[tagExifIFD]; ok {
+		if sub, err := t.ifd(int64(t.uint(e))); err == nil {
+			d.DateTimeOriginal = parseDateTime(t.ascii(sub[tagDateTimeOriginal]), t.ascii(sub[tagOffsetTimeOrig]))
+		}
+	}
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		if sub, err := t.ifd(int64(t.uint(e))); err == nil {
+			d.GPS = t.gps(sub)
+		}
+	}
+	return d, nil
+}
+
+// ifd reads the IFD at offset into a map keyed by tag.
+func (t tiffReader) ifd(offset int64) (map[uint16]ifdEntry, error) {
+	var n [2]byte
+	if _, err := t.r.ReadAt(n[:], offset); err != nil {
+		return nil, fmt.Errorf("corrupt EXIF directory: %w", err)
+	}
+	count := int(t.order.Uint16(n[:]))
+	buf := make([]byte, 12*count)
+	if _, err := t.r.ReadAt(buf, offset+2); err != nil {
+		return nil, fmt.Errorf("corrupt EXIF directory: %w", err)
+	}
+	entries := make(map[uint16]ifdEntry, count)
+	for i := range count {
+		b := buf[12*i:]
+		e := ifdEntry{tag: t.order.Uint16(b), typ: t.order.Uint16(b[2:]), count: t.order.Uint32(b[4:])}
+		copy(e.value[:], b[8:12])
+		entries[e.tag] = e
+	}
+	return entries, nil
+}
+
+// typeSizes is the byte size of each TIFF field type, by type number.
+var typeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// data returns the raw bytes of e's value.
+func (t tiffReader) data(e ifdEntry) []byte {
+	size := typeSizes[e.typ] * e.count
+	if size == 0 || size > 1<<16 {
+		return nil
+	}
+	if size <= 4 {
+		return e.value[:size]
+	}
+	buf := make([]byte, size)
+	if _, err := t.r.ReadAt(buf, int64(t.order.Uint32(e.value[:]))); err != nil {
+		return nil
+	}
+	return buf
+}
+
+func (t tiffReader) ascii(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(t.data(e)), "\x00"))
+}
+
+func (t tiffReader) uint(e ifdEntry) uint32 {
+	switch e.typ {
+	case 3:
+		return uint32(t.order.Uint16(e.value[:]))
+	case 4:
+		return t.order.Uint32(e.value[:])
+	}
+	return 0
+}
+
+// degrees reads a GPS coordinate: three rationals (degrees, minutes,
+// seconds).
+func (t tiffReader) degrees(e ifdEntry) (float64, bool) {
+	b := t.data(e)
+	if e.typ != 5 || e.count != 3 || len(b) != 24 {
+		return 0, false
+	}
+	var parts [3]float64
+	for i := range parts {
+		num, den := t.order.Uint32(b[8*i:]), t.order.Uint32(b[8*i+4:])
+		if den == 0 {
+			return 0, false
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	return parts[0] + parts[1]/60 + parts[2]/3600, true
+}
+
+func (t tiffReader) gps(ifd map[uint16]ifdEntry) *GPS {
+	lat, okLat := t.degrees(ifd[tagGPSLatitude])
+	lon, okLon := t.degrees(ifd[tagGPSLongitude])
+	if !okLat || !okLon || math.IsNaN(lat) || math.IsNaN(lon) {
+		return nil
+	}
+	if t.ascii(ifd[tagGPSLatitudeRef]) == "S" {
+		lat = -lat
+	}
+	if t.ascii(ifd[tagGPSLongitudeRef]) == "W" {
+		lon = -lon
+	}
+	return &GPS{Latitude: lat, Longitude: lon}
+}
+
+// parseDateTime parses an EXIF "2006:01:02 15:04:05" timestamp, in the
+// zone of offset ("+01:00") when one was recorded and local time
+// otherwise. Unparsable values yield the zero time.
+func parseDateTime(value, offset string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value+offset); err == nil {
+			return t
+		}
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
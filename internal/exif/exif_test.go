@@ -0,0 +1,205 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// entry is one IFD entry for buildTIFF: an ASCII string, a SHORT, a LONG
+// (sub-IFD pointers are patched in), or three RATIONALs.
+type entry struct {
+	tag   uint16
+	ascii string
+	short uint16
+	dms   []float64 // degrees, minutes, seconds
+	sub   []entry   // a sub-IFD this entry points to
+}
+
+// buildTIFF encodes IFD0 as a little-endian TIFF structure, laying out
+// out-of-line values and sub-IFDs after each directory.
+func buildTIFF(ifd0 []entry) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("II*\x00")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(8))
+	writeIFD(&buf, ifd0)
+	return buf.Bytes()
+}
+
+func writeIFD(buf *bytes.Buffer, entries []entry) {
+	le := binary.LittleEndian
+	start := buf.Len()
+	extra := start + 2 + 12*len(entries) + 4 // where out-of-line data begins
+	var tail bytes.Buffer
+	var subs []struct {
+		pos     int
+		entries []entry
+	}
+
+	_ = binary.Write(buf, le, uint16(len(entries)))
+	for _, e := range entries {
+		_ = binary.Write(buf, le, e.tag)
+		switch {
+		case e.ascii != "":
+			data := append([]byte(e.ascii), 0)
+			_ = binary.Write(buf, le, uint16(2))
+			_ = binary.Write(buf, le, uint32(len(data)))
+			if len(data) <= 4 {
+				buf.Write(append(data, make([]byte, 4-len(data))...))
+			} else {
+				_ = binary.Write(buf, le, uint32(extra+tail.Len()))
+				tail.Write(data)
+			}
+		case e.dms != nil:
+			_ = binary.Write(buf, le, uint16(5))
+			_ = binary.Write(buf, le, uint32(3))
+			_ = binary.Write(buf, le, uint32(extra+tail.Len()))
+			for _, v := range e.dms {
+				_ = binary.Write(&tail, le, uint32(math.Round(v*1000)))
+				_ = binary.Write(&tail, le, uint32(1000))
+			}
+		case e.sub != nil:
+			_ = binary.Write(buf, le, uint16(4))
+			_ = binary.Write(buf, le, uint32(1))
+			subs = append(subs, struct {
+				pos     int
+				entries []entry
+			}{buf.Len(), e.sub})
+			_ = binary.Write(buf, le, uint32(0)) // patched below
+		default:
+			_ = binary.Write(buf, le, uint16(3))
+			_ = binary.Write(buf, le, uint32(1))
+			_ = binary.Write(buf, le, e.short)
+			_ = binary.Write(buf, le, uint16(0))
+		}
+	}
+	_ = binary.Write(buf, le, uint32(0)) // no next IFD
+	buf.Write(tail.Bytes())
+
+	for _, s := range subs {
+		le.PutUint32(buf.Bytes()[s.pos:], uint32(buf.Len()))
+		writeIFD(buf, s.entries)
+	}
+}
+
+var sample = []entry{
+	{tag: tagMake, ascii: "Google"},
+	{tag: tagModel, ascii: "Pixel 7"},
+	{tag: tagOrientation, short: 6},
+	{tag: tagExifIFD, sub: []entry{
+		{tag: tagDateTimeOriginal, ascii: "2023:06:10 18:30:00"},
+		{tag: tagOffsetTimeOrig, ascii: "+01:00"},
+	}},
+	{tag: tagGPSIFD, sub: []entry{
+		{tag: tagGPSLatitudeRef, ascii: "N"},
+		{tag: tagGPSLatitude, dms: []float64{38, 43, 12}},
+		{tag: tagGPSLongitudeRef, ascii: "W"},
+		{tag: tagGPSLongitude, dms: []float64{9, 8, 24}},
+	}},
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func checkSample(t *testing.T, d *Data) {
+	t.Helper()
+	want := time.Date(2023, time.June, 10, 18, 30, 0, 0, time.FixedZone("", 3600))
+	if !d.DateTimeOriginal.Equal(want) {
+		t.Errorf("DateTimeOriginal = %v, want %v", d.DateTimeOriginal, want)
+	}
+	if d.Make != "Google" || d.Model != "Pixel 7" {
+		t.Errorf("camera = %q %q, want Google Pixel 7", d.Make, d.Model)
+	}
+	if d.Orientation != 6 {
+		t.Errorf("Orientation = %d, want 6", d.Orientation)
+	}
+	if d.GPS == nil || math.Abs(d.GPS.Latitude-38.72) > 1e-6 || math.Abs(d.GPS.Longitude+9.14) > 1e-6 {
+		t.Errorf("GPS = %+v, want 38.72, -9.14", d.GPS)
+	}
+}
+
+func TestReadFileJPEG(t *testing.T) {
+	tiff := buildTIFF(sample)
+	var jpg bytes.Buffer
+	jpg.Write([]byte{0xFF, 0xD8})
+	// A JFIF APP0 segment first, then an XMP APP1, then the Exif APP1.
+	jpg.Write([]byte{0xFF, 0xE0, 0x00, 0x06, 'J', 'F', 'I', 'F'})
+	xmp := []byte("http://ns.adobe.com/xap/1.0/\x00<x/>")
+	jpg.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&jpg, binary.BigEndian, uint16(len(xmp)+2))
+	jpg.Write(xmp)
+	jpg.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&jpg, binary.BigEndian, uint16(len(exifHeader)+len(tiff)+2))
+	jpg.Write(exifHeader)
+	jpg.Write(tiff)
+	jpg.Write([]byte{0xFF, 0xDA, 0x00, 0x02})
+
+	d, err := ReadFile(writeFile(t, "a.jpg", jpg.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSample(t, d)
+}
+
+func TestReadFileTIFF(t *testing.T) {
+	d, err := ReadFile(writeFile(t, "a.tif", buildTIFF(sample)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSample(t, d)
+}
+
+func TestReadFileWebP(t *testing.T) {
+	tiff := buildTIFF(sample)
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	body.WriteString("VP8X")
+	_ = binary.Write(&body, binary.LittleEndian, uint32(3)) // odd size: padded
+	body.Write([]byte{0, 0, 0, 0})
+	body.WriteString("EXIF")
+	_ = binary.Write(&body, binary.LittleEndian, uint32(len(tiff)))
+	body.Write(tiff)
+
+	var webp bytes.Buffer
+	webp.WriteString("RIFF")
+	_ = binary.Write(&webp, binary.LittleEndian, uint32(body.Len()))
+	webp.Write(body.Bytes())
+
+	d, err := ReadFile(writeFile(t, "a.webp", webp.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSample(t, d)
+}
+
+func TestReadFileWithoutExif(t *testing.T) {
+	jpg := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x06, 'J', 'F', 'I', 'F', 0xFF, 0xDA, 0x00, 0x02}
+	if _, err := ReadFile(writeFile(t, "plain.jpg", jpg)); !errors.Is(err, ErrNoExif) {
+		t.Errorf("err = %v, want ErrNoExif", err)
+	}
+	if _, err := ReadFile(writeFile(t, "a.png", []byte("\x89PNG\r\n\x1a\n0000"))); err == nil {
+		t.Error("expected error for a PNG, got nil")
+	}
+}
+
+func TestParseDateTimeWithoutOffsetIsLocal(t *testing.T) {
+	got := parseDateTime("2023:06:10 18:30:00", "")
+	want := time.Date(2023, time.June, 10, 18, 30, 0, 0, time.Local)
+	if !got.Equal(want) {
+		t.Errorf("parseDateTime() = %v, want %v", got, want)
+	}
+	if !parseDateTime("0000:00:00 00:00:00", "").IsZero() {
+		t.Error("expected an unset timestamp to parse to the zero time")
+	}
+}
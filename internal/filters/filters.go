@@ -4,113 +4,477 @@ package filters
 
 import (
 	"fmt"
+	"image/color"
 	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/lucasassuncao/gopaper/internal/exif"
 	"github.com/lucasassuncao/gopaper/internal/models"
 )
 
-// Compiled is a validated, ready-to-evaluate form of models.Filter. Regex is
-// compiled and size bounds are parsed once, so repeated evaluation against
-// many candidate files doesn't repeat that work.
+// Compiled is a validated, ready-to-evaluate form of models.Filter. Regexes
+// are compiled and size bounds are parsed once, so repeated evaluation
+// against many candidate files doesn't repeat that work. It is a tree: each
+// node holds its own constraints plus the compiled any-of, all-of and not
+// filters nested in it.
 type Compiled struct {
-	matchLiteral     string
-	matchGlob        string
-	matchRegex       *regexp.Regexp
-	caseSensitive    bool
-	ageMin, ageMax   time.Duration
-	sizeMin, sizeMax int64
+	matchLiteral         string
+	matchGlobs           []string // any of them must match
+	matchRegexes         []*regexp.Regexp
+	caseSensitive        bool
+	ageMin, ageMax       time.Duration
+	sizeMin, sizeMax     int64
+	minWidth, minHeight  int
+	aspect, aspectTol    float64 // aspect 0 = no aspect constraint
+	orientation          string
+	brightMin, brightMax float64
+	color                *color.RGBA
+	colorTol             float64
+	ageFromExif          bool
+	exifMonths           map[time.Month]bool
+	exifCamera           string // lower-cased glob
+	exifGeotagged        *bool
+	exifArea             *models.GeoArea
+
+	anyOf []*Compiled
+	allOf []*Compiled
+	not   *Compiled
+}
+
+// Metadata supplies what a filter checks about a candidate beyond its name.
+// Matches only calls the methods its constraints need, cheapest first, so
+// implementations can look things up lazily.
+type Metadata interface {
+	Info() (os.FileInfo, error)
+	Dimensions() (width, height int, err error)
+	Colors() (brightness float64, dominant color.RGBA, err error)
+	Exif() (*exif.Data, error) // nil when the image has no EXIF metadata
 }
 
+// defaultAspectTolerance is how far width/height may be from an aspect
+// given without an explicit tolerance.
+const defaultAspectTolerance = 0.01
+
+// defaultColorTolerance is how far (in RGB space) an image's dominant color
+// may be from filter.color.dominant when no tolerance is given.
+const defaultColorTolerance = 30
+
 // Compile validates and compiles f. A nil f compiles to a filter that matches
-// every file.
+// every file. Errors name the offending field by its path from "filter",
+// e.g. "filter.any-of[1].match.glob".
 func Compile(f *models.Filter) (*Compiled, error) {
-	c := &Compiled{}
 	if f == nil {
-		return c, nil
+		return &Compiled{}, nil
 	}
+	return compile(f, "filter", time.Now())
+}
 
-	if err := c.compileMatch(f.Match); err != nil {
+// compile compiles f, found at path, and the filters nested in it.
+func compile(f *models.Filter, path string, now time.Time) (*Compiled, error) {
+	c := &Compiled{}
+	if err := c.compileMatch(f.Match, path+".match"); err != nil {
 		return nil, err
 	}
 	if f.Age != nil {
 		c.ageMin, c.ageMax = f.Age.Min, f.Age.Max
+		switch f.Age.Source {
+		case "", "mtime":
+		case "exif":
+			c.ageFromExif = true
+		default:
+			return nil, fmt.Errorf("invalid %s.age.source %q: must be mtime or exif", path, f.Age.Source)
+		}
+	}
+	if err := c.compileSize(f.Size, path+".size"); err != nil {
+		return nil, err
+	}
+	if err := c.compileDimensions(f.Dimensions, path+".dimensions"); err != nil {
+		return nil, err
 	}
-	if err := c.compileSize(f.Size); err != nil {
+	if err := c.compileColors(f.Brightness, f.Color, path); err != nil {
+		return nil, err
+	}
+	if err := c.compileExif(f.Exif, path+".exif", now); err != nil {
 		return nil, err
 	}
 
+	for i := range f.AnyOf {
+		sub, err := compile(&f.AnyOf[i], fmt.Sprintf("%s.any-of[%d]", path, i), now)
+		if err != nil {
+			return nil, err
+		}
+		c.anyOf = append(c.anyOf, sub)
+	}
+	for i := range f.AllOf {
+		sub, err := compile(&f.AllOf[i], fmt.Sprintf("%s.all-of[%d]", path, i), now)
+		if err != nil {
+			return nil, err
+		}
+		c.allOf = append(c.allOf, sub)
+	}
+	if f.Not != nil {
+		sub, err := compile(f.Not, path+".not", now)
+		if err != nil {
+			return nil, err
+		}
+		c.not = sub
+	}
 	return c, nil
 }
 
-func (c *Compiled) compileMatch(m *models.MatchFilter) error {
+func (c *Compiled) compileMatch(m *models.MatchFilter, path string) error {
 	if m == nil {
 		return nil
 	}
 	c.matchLiteral = m.Literal
-	c.matchGlob = m.Glob
 	c.caseSensitive = m.CaseSensitive
 
 	if m.Regex != "" {
 		re, err := regexp.Compile(m.Regex)
 		if err != nil {
-			return fmt.Errorf("invalid filter.match.regex: %w", err)
+			return fmt.Errorf("invalid %s.regex: %w", path, err)
 		}
-		c.matchRegex = re
+		c.matchRegexes = append(c.matchRegexes, re)
+	}
+	for i, expr := range m.Regexes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid %s.regexes[%d]: %w", path, i, err)
+		}
+		c.matchRegexes = append(c.matchRegexes, re)
 	}
 	if m.Glob != "" {
 		if _, err := filepath.Match(m.Glob, ""); err != nil {
-			return fmt.Errorf("invalid filter.match.glob: %w", err)
+			return fmt.Errorf("invalid %s.glob: %w", path, err)
+		}
+		c.matchGlobs = append(c.matchGlobs, m.Glob)
+	}
+	for i, glob := range m.Globs {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid %s.globs[%d]: %w", path, i, err)
 		}
+		c.matchGlobs = append(c.matchGlobs, glob)
 	}
 	return nil
 }
 
-func (c *Compiled) compileSize(s *models.SizeFilter) error {
+func (c *Compiled) compileSize(s *models.SizeFilter, path string) error {
 	if s == nil {
 		return nil
 	}
 	if s.Min != "" {
 		v, err := ParseSize(s.Min)
 		if err != nil {
-			return fmt.Errorf("invalid filter.size.min: %w", err)
+			return fmt.Errorf("invalid %s.min: %w", path, err)
 		}
 		c.sizeMin = v
 	}
 	if s.Max != "" {
 		v, err := ParseSize(s.Max)
 		if err != nil {
-			return fmt.Errorf("invalid filter.size.max: %w", err)
+			return fmt.Errorf("invalid %s.max: %w", path, err)
 		}
 		c.sizeMax = v
 	}
 	return nil
 }
 
-// NeedsFileInfo reports whether the filter has any age/size constraints, so
-// callers can skip the file-info lookup when it would go unused.
-func (c *Compiled) NeedsFileInfo() bool {
-	return c.ageMin > 0 || c.ageMax > 0 || c.sizeMin > 0 || c.sizeMax > 0
+func (c *Compiled) compileDimensions(d *models.DimensionsFilter, path string) error {
+	if d == nil {
+		return nil
+	}
+	if d.MinWidth < 0 || d.MinHeight < 0 {
+		return fmt.Errorf("invalid %s: min-width and min-height must not be negative", path)
+	}
+	c.minWidth, c.minHeight = d.MinWidth, d.MinHeight
+	if d.Aspect != "" {
+		ratio, tol, err := ParseAspect(d.Aspect)
+		if err != nil {
+			return fmt.Errorf("invalid %s.aspect: %w", path, err)
+		}
+		c.aspect, c.aspectTol = ratio, tol
+	}
+	switch d.Orientation {
+	case "", "landscape", "portrait", "square":
+		c.orientation = d.Orientation
+	default:
+		return fmt.Errorf("invalid %s.orientation %q: must be landscape, portrait or square", path, d.Orientation)
+	}
+	return nil
 }
 
-// Matches reports whether a file with the given name passes the filter. info
-// may be nil when NeedsFileInfo reports false.
-func (c *Compiled) Matches(name string, info os.FileInfo) bool {
-	if !c.matchesName(name) {
-		return false
+// compileColors compiles the brightness and color filters of the filter at
+// path.
+func (c *Compiled) compileColors(b *models.BrightnessFilter, col *models.ColorFilter, path string) error {
+	if b != nil {
+		if b.Min < 0 || b.Min > 1 || b.Max < 0 || b.Max > 1 {
+			return fmt.Errorf("invalid %s.brightness: min and max must be between 0 and 1", path)
+		}
+		c.brightMin, c.brightMax = b.Min, b.Max
+	}
+	if col != nil {
+		want, err := ParseColor(col.Dominant)
+		if err != nil {
+			return fmt.Errorf("invalid %s.color.dominant: %w", path, err)
+		}
+		if col.Tolerance < 0 {
+			return fmt.Errorf("invalid %s.color.tolerance: must not be negative", path)
+		}
+		c.color, c.colorTol = &want, col.Tolerance
+		if c.colorTol == 0 {
+			c.colorTol = defaultColorTolerance
+		}
 	}
-	if info == nil {
+	return nil
+}
+
+// compileExif compiles e, found at path, resolving the "current" month
+// against now.
+func (c *Compiled) compileExif(e *models.ExifFilter, path string, now time.Time) error {
+	if e == nil {
+		return nil
+	}
+	for i, m := range e.Months {
+		month, err := ParseMonth(m, now)
+		if err != nil {
+			return fmt.Errorf("invalid %s.months[%d]: %w", path, i, err)
+		}
+		if c.exifMonths == nil {
+			c.exifMonths = map[time.Month]bool{}
+		}
+		c.exifMonths[month] = true
+	}
+	if e.Camera != "" {
+		if _, err := filepath.Match(e.Camera, ""); err != nil {
+			return fmt.Errorf("invalid %s.camera: %w", path, err)
+		}
+		c.exifCamera = strings.ToLower(e.Camera)
+	}
+	c.exifGeotagged = e.Geotagged
+	if a := e.Area; a != nil {
+		if err := ValidateArea(*a); err != nil {
+			return fmt.Errorf("invalid %s.area: %w", path, err)
+		}
+		c.exifArea = a
+	}
+	return nil
+}
+
+// ParseMonth parses a filter.exif.months entry: a month number 1-12, or
+// "current" for the month of now.
+func ParseMonth(s string, now time.Time) (time.Month, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "current") {
+		return now.Month(), nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 12 {
+		return 0, fmt.Errorf("%q is not a month number (1-12) or \"current\"", s)
+	}
+	return time.Month(n), nil
+}
+
+// ValidateArea checks that a's bounds are valid coordinates and its
+// latitudes ordered (longitudes may wrap across the 180th meridian).
+func ValidateArea(a models.GeoArea) error {
+	for _, lat := range []float64{a.MinLat, a.MaxLat} {
+		if lat < -90 || lat > 90 {
+			return fmt.Errorf("latitude %v is outside -90..90", lat)
+		}
+	}
+	for _, lon := range []float64{a.MinLon, a.MaxLon} {
+		if lon < -180 || lon > 180 {
+			return fmt.Errorf("longitude %v is outside -180..180", lon)
+		}
+	}
+	if a.MinLat > a.MaxLat {
+		return fmt.Errorf("min-lat %v is north of max-lat %v", a.MinLat, a.MaxLat)
+	}
+	return nil
+}
+
+// NeedsMetadata reports whether the filter, or any filter nested in it,
+// checks anything beyond the file name, so callers can skip building
+// Metadata when it would go unused.
+func (c *Compiled) NeedsMetadata() bool {
+	if c.needsInfo() || c.needsDimensions() || c.needsExif() || c.needsColors() {
 		return true
 	}
-	if c.ageMin > 0 && time.Since(info.ModTime()) < c.ageMin {
-		return false
+	for _, sub := range c.children() {
+		if sub.NeedsMetadata() {
+			return true
+		}
 	}
-	if c.ageMax > 0 && time.Since(info.ModTime()) > c.ageMax {
+	return false
+}
+
+// children returns the filters nested in c.
+func (c *Compiled) children() []*Compiled {
+	subs := append(append([]*Compiled{}, c.allOf...), c.anyOf...)
+	if c.not != nil {
+		subs = append(subs, c.not)
+	}
+	return subs
+}
+
+func (c *Compiled) hasAge() bool {
+	return c.ageMin > 0 || c.ageMax > 0
+}
+
+func (c *Compiled) needsInfo() bool {
+	return (c.hasAge() && !c.ageFromExif) || c.sizeMin > 0 || c.sizeMax > 0
+}
+
+func (c *Compiled) needsExif() bool {
+	return (c.hasAge() && c.ageFromExif) || c.exifMonths != nil || c.exifCamera != "" || c.exifGeotagged != nil || c.exifArea != nil
+}
+
+func (c *Compiled) needsDimensions() bool {
+	return c.minWidth > 0 || c.minHeight > 0 || c.aspect > 0 || c.orientation != ""
+}
+
+func (c *Compiled) needsColors() bool {
+	return c.brightMin > 0 || c.brightMax > 0 || c.color != nil
+}
+
+// Matches reports whether a file with the given name passes the filter.
+// meta may be nil when NeedsMetadata reports false. A file whose metadata
+// can't be read doesn't match, even when the unreadable part is under a not.
+func (c *Compiled) Matches(name string, meta Metadata) bool {
+	ok, err := c.eval(name, meta)
+	return ok && err == nil
+}
+
+// eval evaluates the tree rooted at c. A non-nil error means the outcome
+// hinges on metadata that couldn't be read: an any-of branch that matches
+// still makes the file match, and a constraint it fails still rules it
+// out, but otherwise the result is unknown and negating it under not
+// doesn't make it a match.
+func (c *Compiled) eval(name string, meta Metadata) (bool, error) {
+	ok, unknown := c.matchesOwn(name, meta)
+	if !ok && unknown == nil {
+		return false, nil
+	}
+	for _, sub := range c.allOf {
+		ok, err := sub.eval(name, meta)
+		if err != nil {
+			unknown = err
+		} else if !ok {
+			return false, nil
+		}
+	}
+	if len(c.anyOf) > 0 {
+		var anyErr error
+		matched := false
+		for _, sub := range c.anyOf {
+			ok, err := sub.eval(name, meta)
+			if err != nil {
+				anyErr = err
+			} else if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			if anyErr == nil {
+				return false, nil
+			}
+			unknown = anyErr
+		}
+	}
+	if c.not != nil {
+		ok, err := c.not.eval(name, meta)
+		if err != nil {
+			unknown = err
+		} else if ok {
+			return false, nil
+		}
+	}
+	return unknown == nil, unknown
+}
+
+// matchesOwn checks c's own constraints, not those of the filters nested
+// in it, cheapest first. It returns false with a nil error as soon as one
+// fails, and the last metadata error when none failed but some couldn't
+// be checked.
+func (c *Compiled) matchesOwn(name string, meta Metadata) (bool, error) {
+	if !c.matchesName(name) {
+		return false, nil
+	}
+	if meta == nil {
+		return true, nil
+	}
+	var unknown error
+	if c.needsInfo() {
+		info, err := meta.Info()
+		if err != nil {
+			unknown = err
+		} else if !c.matchesInfo(info) {
+			return false, nil
+		}
+	}
+	if c.needsDimensions() {
+		w, h, err := meta.Dimensions()
+		if err != nil {
+			unknown = err
+		} else if !c.matchesDimensions(w, h) {
+			return false, nil
+		}
+	}
+	if c.needsExif() {
+		ok, err := c.matchesExifAndAge(meta)
+		if err != nil {
+			unknown = err
+		} else if !ok {
+			return false, nil
+		}
+	}
+	if c.needsColors() {
+		brightness, dominant, err := meta.Colors()
+		if err != nil {
+			unknown = err
+		} else if !c.matchesColors(brightness, dominant) {
+			return false, nil
+		}
+	}
+	return unknown == nil, unknown
+}
+
+// matchesExifAndAge checks the exif filter and, with age.source exif, the
+// age of the photo, falling back to the file's modification time.
+func (c *Compiled) matchesExifAndAge(meta Metadata) (bool, error) {
+	data, err := meta.Exif()
+	if err != nil {
+		return false, err
+	}
+	if !c.matchesExif(data) {
+		return false, nil
+	}
+	if !c.hasAge() || !c.ageFromExif {
+		return true, nil
+	}
+	var taken time.Time
+	if data != nil {
+		taken = data.DateTimeOriginal
+	}
+	if taken.IsZero() {
+		info, err := meta.Info()
+		if err != nil {
+			return false, err
+		}
+		taken = info.ModTime()
+	}
+	return c.matchesAge(taken), nil
+}
+
+func (c *Compiled) matchesInfo(info os.FileInfo) bool {
+	if !c.ageFromExif && !c.matchesAge(info.ModTime()) {
 		return false
 	}
 	if c.sizeMin > 0 && info.Size() < c.sizeMin {
@@ -122,16 +486,87 @@ func (c *Compiled) Matches(name string, info os.FileInfo) bool {
 	return true
 }
 
-func (c *Compiled) matchesName(name string) bool {
-	if c.matchRegex != nil && !c.matchRegex.MatchString(name) {
+func (c *Compiled) matchesAge(t time.Time) bool {
+	if c.ageMin > 0 && time.Since(t) < c.ageMin {
 		return false
 	}
-	if c.matchGlob != "" {
-		matched, _ := filepath.Match(normalizeCase(c.matchGlob, c.caseSensitive), normalizeCase(name, c.caseSensitive))
-		if !matched {
+	if c.ageMax > 0 && time.Since(t) > c.ageMax {
+		return false
+	}
+	return true
+}
+
+func (c *Compiled) matchesExif(d *exif.Data) bool {
+	if c.exifGeotagged != nil && *c.exifGeotagged != (d != nil && d.GPS != nil) {
+		return false
+	}
+	if c.exifMonths == nil && c.exifCamera == "" && c.exifArea == nil {
+		return true
+	}
+	if d == nil {
+		return false
+	}
+	if c.exifMonths != nil && (d.DateTimeOriginal.IsZero() || !c.exifMonths[d.DateTimeOriginal.Month()]) {
+		return false
+	}
+	if c.exifCamera != "" {
+		model := strings.ToLower(d.Model)
+		full := strings.ToLower(strings.TrimSpace(d.Make + " " + d.Model))
+		okModel, _ := filepath.Match(c.exifCamera, model)
+		okFull, _ := filepath.Match(c.exifCamera, full)
+		if d.Model == "" || (!okModel && !okFull) {
 			return false
 		}
 	}
+	if a := c.exifArea; a != nil {
+		if d.GPS == nil || d.GPS.Latitude < a.MinLat || d.GPS.Latitude > a.MaxLat {
+			return false
+		}
+		lon := d.GPS.Longitude
+		if a.MinLon <= a.MaxLon {
+			if lon < a.MinLon || lon > a.MaxLon {
+				return false
+			}
+		} else if lon < a.MinLon && lon > a.MaxLon { // wraps across the 180th meridian
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Compiled) matchesDimensions(w, h int) bool {
+	if w <= 0 || h <= 0 {
+		return false
+	}
+	if w < c.minWidth || h < c.minHeight {
+		return false
+	}
+	if c.aspect > 0 && math.Abs(float64(w)/float64(h)-c.aspect) > c.aspectTol {
+		return false
+	}
+	switch c.orientation {
+	case "landscape":
+		return w > h
+	case "portrait":
+		return h > w
+	case "square":
+		return w == h
+	}
+	return true
+}
+
+func (c *Compiled) matchesName(name string) bool {
+	if len(c.matchRegexes) > 0 && !slices.ContainsFunc(c.matchRegexes, func(re *regexp.Regexp) bool {
+		return re.MatchString(name)
+	}) {
+		return false
+	}
+	if len(c.matchGlobs) > 0 && !slices.ContainsFunc(c.matchGlobs, func(glob string) bool {
+		matched, _ := filepath.Match(normalizeCase(glob, c.caseSensitive), normalizeCase(name, c.caseSensitive))
+		return matched
+	}) {
+		return false
+	}
 	if c.matchLiteral != "" && normalizeCase(name, c.caseSensitive) != normalizeCase(c.matchLiteral, c.caseSensitive) {
 		return false
 	}
@@ -145,6 +580,73 @@ func normalizeCase(s string, caseSensitive bool) string {
 	return strings.ToLower(s)
 }
 
+func (c *Compiled) matchesColors(brightness float64, dominant color.RGBA) bool {
+	if c.brightMin > 0 && brightness < c.brightMin {
+		return false
+	}
+	if c.brightMax > 0 && brightness > c.brightMax {
+		return false
+	}
+	if c.color != nil {
+		dr := float64(dominant.R) - float64(c.color.R)
+		dg := float64(dominant.G) - float64(c.color.G)
+		db := float64(dominant.B) - float64(c.color.B)
+		if math.Sqrt(dr*dr+dg*dg+db*db) > c.colorTol {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseColor parses a hex RGB color such as "#1e3a8a" or "#28f" (the
+// leading # is optional).
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("could not parse color %q: want #rrggbb or #rgb", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("could not parse color %q: want #rrggbb or #rgb", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// ParseAspect parses an aspect ratio such as "16:9", "2.39" or
+// "16:9±0.05" (also "16:9+-0.05") into the width/height quotient and the
+// allowed deviation from it, defaulting to 0.01.
+func ParseAspect(s string) (ratio, tolerance float64, err error) {
+	s = strings.TrimSpace(s)
+	tolerance = defaultAspectTolerance
+	for _, sep := range []string{"±", "+-"} {
+		if before, after, ok := strings.Cut(s, sep); ok {
+			tolerance, err = strconv.ParseFloat(strings.TrimSpace(after), 64)
+			if err != nil || tolerance < 0 {
+				return 0, 0, fmt.Errorf("could not parse tolerance %q", strings.TrimSpace(after))
+			}
+			s = strings.TrimSpace(before)
+			break
+		}
+	}
+
+	if w, h, ok := strings.Cut(s, ":"); ok {
+		wf, errW := strconv.ParseFloat(strings.TrimSpace(w), 64)
+		hf, errH := strconv.ParseFloat(strings.TrimSpace(h), 64)
+		if errW != nil || errH != nil || wf <= 0 || hf <= 0 {
+			return 0, 0, fmt.Errorf("could not parse ratio %q: want W:H with positive numbers", s)
+		}
+		return wf / hf, tolerance, nil
+	}
+	ratio, err = strconv.ParseFloat(s, 64)
+	if err != nil || ratio <= 0 {
+		return 0, 0, fmt.Errorf("could not parse ratio %q: want W:H or a positive decimal", s)
+	}
+	return ratio, tolerance, nil
+}
+
 // ParseSize parses a human-readable size string (e.g. "10MB", "1.5GB",
 // "256MiB") into bytes. KB/MB/GB/TB are decimal (powers of 1000); KiB/MiB/
 // GiB/TiB are binary (powers of 1024).
@@ -1,10 +1,14 @@
 package filters
 
 import (
+	"errors"
+	"image/color"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/lucasassuncao/gopaper/internal/exif"
 	"github.com/lucasassuncao/gopaper/internal/models"
 )
 
@@ -20,6 +24,13 @@ func (f fakeInfo) ModTime() time.Time { return f.modTime }
 func (f fakeInfo) IsDir() bool        { return false }
 func (f fakeInfo) Sys() any           { return nil }
 
+// Info, Dimensions, Colors and Exif make fakeInfo usable as the Metadata
+// of a file without image-content constraints.
+func (f fakeInfo) Info() (os.FileInfo, error)           { return f, nil }
+func (f fakeInfo) Dimensions() (int, int, error)        { return 0, 0, nil }
+func (f fakeInfo) Colors() (float64, color.RGBA, error) { return 0, color.RGBA{}, nil }
+func (f fakeInfo) Exif() (*exif.Data, error)            { return nil, nil }
+
 func TestCompile_NilFilterMatchesEverything(t *testing.T) {
 	c, err := Compile(nil)
 	if err != nil {
@@ -98,8 +109,8 @@ func TestMatches_Regex(t *testing.T) {
 func TestMatches_SizeMinMax(t *testing.T) {
 	c, _ := Compile(&models.Filter{Size: &models.SizeFilter{Min: "1KB", Max: "1MB"}})
 
-	if !c.NeedsFileInfo() {
-		t.Fatal("expected NeedsFileInfo to be true when size is set")
+	if !c.NeedsMetadata() {
+		t.Fatal("expected NeedsMetadata to be true when size is set")
 	}
 	if !c.Matches("f.jpg", fakeInfo{size: 500_000}) {
 		t.Error("expected in-range size to match")
@@ -115,8 +126,8 @@ func TestMatches_SizeMinMax(t *testing.T) {
 func TestMatches_AgeMinMax(t *testing.T) {
 	c, _ := Compile(&models.Filter{Age: &models.AgeFilter{Min: 24 * time.Hour, Max: 72 * time.Hour}})
 
-	if !c.NeedsFileInfo() {
-		t.Fatal("expected NeedsFileInfo to be true when age is set")
+	if !c.NeedsMetadata() {
+		t.Fatal("expected NeedsMetadata to be true when age is set")
 	}
 	if !c.Matches("f.jpg", fakeInfo{modTime: time.Now().Add(-48 * time.Hour)}) {
 		t.Error("expected in-range age to match")
@@ -159,3 +170,397 @@ func TestParseSize_Invalid(t *testing.T) {
 		}
 	}
 }
+
+// fakeImage is the Metadata of an image of a given size and colors; it fails
+// the test if the filter asks for file info it doesn't need.
+type fakeImage struct {
+	t          *testing.T
+	w, h       int
+	brightness float64
+	dominant   color.RGBA
+	exif       *exif.Data
+	modTime    time.Time // served by Info for an exif age without a capture date
+}
+
+func (f fakeImage) Info() (os.FileInfo, error) {
+	if f.modTime.IsZero() {
+		f.t.Error("unexpected Info() call for a filter on image content")
+	}
+	return fakeInfo{modTime: f.modTime}, nil
+}
+func (f fakeImage) Dimensions() (int, int, error) { return f.w, f.h, nil }
+func (f fakeImage) Colors() (float64, color.RGBA, error) {
+	return f.brightness, f.dominant, nil
+}
+func (f fakeImage) Exif() (*exif.Data, error) { return f.exif, nil }
+
+func TestMatches_Dimensions(t *testing.T) {
+	c, err := Compile(&models.Filter{Dimensions: &models.DimensionsFilter{
+		MinWidth:    2560,
+		Aspect:      "21:9±0.06", // "21:9" ultrawides really span 2.33-2.39
+		Orientation: "landscape",
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.NeedsMetadata() {
+		t.Fatal("expected NeedsMetadata to be true when dimensions are set")
+	}
+
+	cases := []struct {
+		w, h int
+		want bool
+	}{
+		{3440, 1440, true},  // 2.39
+		{2560, 1080, true},  // 2.37
+		{1920, 1080, false}, // too narrow, and 16:9
+		{3840, 2160, false}, // 16:9
+		{1440, 3440, false}, // portrait
+	}
+	for _, tc := range cases {
+		if got := c.Matches("f.jpg", fakeImage{t: t, w: tc.w, h: tc.h}); got != tc.want {
+			t.Errorf("%dx%d: Matches() = %v, want %v", tc.w, tc.h, got, tc.want)
+		}
+	}
+}
+
+func TestMatches_DimensionsUnreadable(t *testing.T) {
+	c, err := Compile(&models.Filter{Dimensions: &models.DimensionsFilter{Orientation: "portrait"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Matches("f.avif", fakeImage{t: t}) {
+		t.Error("expected an image without readable dimensions not to match")
+	}
+}
+
+func TestCompile_InvalidDimensions(t *testing.T) {
+	for _, d := range []*models.DimensionsFilter{
+		{Aspect: "wide"},
+		{Aspect: "16:0"},
+		{Aspect: "16:9±x"},
+		{Orientation: "diagonal"},
+		{MinWidth: -1},
+	} {
+		if _, err := Compile(&models.Filter{Dimensions: d}); err == nil {
+			t.Errorf("Compile(%+v): expected error, got nil", *d)
+		}
+	}
+}
+
+func TestParseAspect(t *testing.T) {
+	cases := []struct {
+		in    string
+		ratio float64
+		tol   float64
+	}{
+		{"16:9", 16.0 / 9, 0.01},
+		{"16:9±0.05", 16.0 / 9, 0.05},
+		{"4:3 +- 0.1", 4.0 / 3, 0.1},
+		{"2.39", 2.39, 0.01},
+	}
+	for _, tc := range cases {
+		ratio, tol, err := ParseAspect(tc.in)
+		if err != nil {
+			t.Errorf("ParseAspect(%q): unexpected error: %v", tc.in, err)
+			continue
+		}
+		if ratio != tc.ratio || tol != tc.tol {
+			t.Errorf("ParseAspect(%q) = (%v, %v), want (%v, %v)", tc.in, ratio, tol, tc.ratio, tc.tol)
+		}
+	}
+}
+
+func TestMatches_BrightnessAndColor(t *testing.T) {
+	c, err := Compile(&models.Filter{
+		Brightness: &models.BrightnessFilter{Max: 0.35},
+		Color:      &models.ColorFilter{Dominant: "#1e3a8a"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.NeedsMetadata() {
+		t.Fatal("expected NeedsMetadata to be true when brightness/color are set")
+	}
+
+	navy := color.RGBA{R: 0x20, G: 0x3c, B: 0x80, A: 0xff}
+	cases := []struct {
+		name       string
+		brightness float64
+		dominant   color.RGBA
+		want       bool
+	}{
+		{"dark navy", 0.2, navy, true},
+		{"bright navy", 0.6, navy, false},
+		{"dark red", 0.2, color.RGBA{R: 0x8a, G: 0x1e, B: 0x1e, A: 0xff}, false},
+	}
+	for _, tc := range cases {
+		if got := c.Matches("f.jpg", fakeImage{t: t, brightness: tc.brightness, dominant: tc.dominant}); got != tc.want {
+			t.Errorf("%s: Matches() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompile_InvalidColors(t *testing.T) {
+	for _, f := range []*models.Filter{
+		{Brightness: &models.BrightnessFilter{Max: 1.5}},
+		{Color: &models.ColorFilter{Dominant: "blue"}},
+		{Color: &models.ColorFilter{Dominant: "#12345"}},
+		{Color: &models.ColorFilter{Dominant: "#1e3a8a", Tolerance: -1}},
+	} {
+		if _, err := Compile(f); err == nil {
+			t.Errorf("Compile(%+v): expected error, got nil", f)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	cases := map[string]color.RGBA{
+		"#1e3a8a": {R: 0x1e, G: 0x3a, B: 0x8a, A: 0xff},
+		"1E3A8A":  {R: 0x1e, G: 0x3a, B: 0x8a, A: 0xff},
+		"#28f":    {R: 0x22, G: 0x88, B: 0xff, A: 0xff},
+	}
+	for in, want := range cases {
+		got, err := ParseColor(in)
+		if err != nil || got != want {
+			t.Errorf("ParseColor(%q) = (%v, %v), want %v", in, got, err, want)
+		}
+	}
+}
+
+func TestMatches_Exif(t *testing.T) {
+	yes := true
+	c, err := Compile(&models.Filter{Exif: &models.ExifFilter{
+		Months:    []string{"6", "7"},
+		Camera:    "pixel*",
+		Geotagged: &yes,
+		Area:      &models.GeoArea{MinLat: 36.9, MaxLat: 42.2, MinLon: -9.6, MaxLon: -6.1},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.NeedsMetadata() {
+		t.Fatal("expected NeedsMetadata to be true when exif is set")
+	}
+
+	lisbon := &exif.GPS{Latitude: 38.72, Longitude: -9.14}
+	june := time.Date(2019, time.June, 10, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name string
+		data *exif.Data
+		want bool
+	}{
+		{"match", &exif.Data{DateTimeOriginal: june, Make: "Google", Model: "Pixel 7", GPS: lisbon}, true},
+		{"wrong month", &exif.Data{DateTimeOriginal: june.AddDate(0, 3, 0), Model: "Pixel 7", GPS: lisbon}, false},
+		{"other camera", &exif.Data{DateTimeOriginal: june, Make: "Canon", Model: "EOS R5", GPS: lisbon}, false},
+		{"madrid", &exif.Data{DateTimeOriginal: june, Model: "Pixel 7", GPS: &exif.GPS{Latitude: 40.42, Longitude: -3.70}}, false},
+		{"no gps", &exif.Data{DateTimeOriginal: june, Model: "Pixel 7"}, false},
+		{"no exif", nil, false},
+	}
+	for _, tc := range cases {
+		if got := c.Matches("f.jpg", fakeImage{t: t, exif: tc.data}); got != tc.want {
+			t.Errorf("%s: Matches() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatches_ExifNotGeotaggedAcceptsMissingExif(t *testing.T) {
+	no := false
+	c, err := Compile(&models.Filter{Exif: &models.ExifFilter{Geotagged: &no}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.Matches("f.png", fakeImage{t: t}) {
+		t.Error("expected an image without EXIF to count as not geotagged")
+	}
+}
+
+func TestMatches_ExifAreaAcrossAntimeridian(t *testing.T) {
+	c, err := Compile(&models.Filter{Exif: &models.ExifFilter{Area: &models.GeoArea{MinLat: -21, MaxLat: -12, MinLon: 176, MaxLon: -178}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for lon, want := range map[float64]bool{178.4: true, -179.5: true, 170: false} {
+		d := &exif.Data{GPS: &exif.GPS{Latitude: -18, Longitude: lon}}
+		if got := c.Matches("f.jpg", fakeImage{t: t, exif: d}); got != want {
+			t.Errorf("longitude %v: Matches() = %v, want %v", lon, got, want)
+		}
+	}
+}
+
+func TestMatches_AgeFromExif(t *testing.T) {
+	c, err := Compile(&models.Filter{Age: &models.AgeFilter{Max: 720 * time.Hour, Source: "exif"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recent := time.Now().Add(-24 * time.Hour)
+	old := time.Now().AddDate(-3, 0, 0)
+
+	if c.Matches("f.jpg", fakeImage{t: t, exif: &exif.Data{DateTimeOriginal: old}}) {
+		t.Error("expected a photo taken years ago not to match, whatever its mtime")
+	}
+	if !c.Matches("f.jpg", fakeImage{t: t, exif: &exif.Data{DateTimeOriginal: recent}}) {
+		t.Error("expected a recently taken photo to match")
+	}
+	if !c.Matches("f.png", fakeImage{t: t, modTime: recent}) {
+		t.Error("expected an image without a capture date to fall back to its mtime")
+	}
+}
+
+func TestCompile_InvalidExif(t *testing.T) {
+	for _, f := range []*models.Filter{
+		{Age: &models.AgeFilter{Source: "ctime"}},
+		{Exif: &models.ExifFilter{Months: []string{"13"}}},
+		{Exif: &models.ExifFilter{Months: []string{"june"}}},
+		{Exif: &models.ExifFilter{Camera: "["}},
+		{Exif: &models.ExifFilter{Area: &models.GeoArea{MinLat: 50, MaxLat: 40}}},
+		{Exif: &models.ExifFilter{Area: &models.GeoArea{MaxLat: 91}}},
+	} {
+		if _, err := Compile(f); err == nil {
+			t.Errorf("Compile(%+v): expected error, got nil", f)
+		}
+	}
+}
+
+func TestParseMonth(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Month{"1": time.January, " 12 ": time.December, "current": time.October, "Current": time.October} {
+		got, err := ParseMonth(in, now)
+		if err != nil || got != want {
+			t.Errorf("ParseMonth(%q) = (%v, %v), want %v", in, got, err, want)
+		}
+	}
+}
+
+func TestMatches_GlobAndRegexLists(t *testing.T) {
+	c, err := Compile(&models.Filter{Match: &models.MatchFilter{Globs: []string{"*.png", "screenshot_*"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, want := range map[string]bool{"a.PNG": true, "Screenshot_1.jpg": true, "photo.jpg": false} {
+		if got := c.Matches(name, nil); got != want {
+			t.Errorf("globs: Matches(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	c, err = Compile(&models.Filter{Match: &models.MatchFilter{Regexes: []string{`^IMG_\d+`, `^PXL_`}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, want := range map[string]bool{"IMG_0001.jpg": true, "PXL_2023.jpg": true, "img_0001.jpg": false} {
+		if got := c.Matches(name, nil); got != want {
+			t.Errorf("regexes: Matches(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMatches_Combinators(t *testing.T) {
+	// Name matches A or B, but not *_draft*.
+	c, err := Compile(&models.Filter{
+		AnyOf: []models.Filter{
+			{Match: &models.MatchFilter{Glob: "city_*"}},
+			{Match: &models.MatchFilter{Regex: `^night-\d+`}},
+		},
+		Not: &models.Filter{Match: &models.MatchFilter{Glob: "*_draft*"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.NeedsMetadata() {
+		t.Error("expected name-only combinators not to need metadata")
+	}
+	cases := map[string]bool{
+		"city_paris.jpg":       true,
+		"night-42.jpg":         true,
+		"city_rome_draft.jpg":  false,
+		"night-7_draft_v2.png": false,
+		"forest.jpg":           false,
+	}
+	for name, want := range cases {
+		if got := c.Matches(name, nil); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMatches_AllOfWithNestedMetadata(t *testing.T) {
+	c, err := Compile(&models.Filter{
+		Match: &models.MatchFilter{Glob: "*.jpg"},
+		AllOf: []models.Filter{
+			{Size: &models.SizeFilter{Min: "1MB"}},
+			{AnyOf: []models.Filter{
+				{Size: &models.SizeFilter{Min: "10MB"}},
+				{Match: &models.MatchFilter{Glob: "fav_*"}},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.NeedsMetadata() {
+		t.Fatal("expected NeedsMetadata to see the nested size filters")
+	}
+	cases := []struct {
+		name string
+		size int64
+		want bool
+	}{
+		{"big.jpg", 20_000_000, true},
+		{"fav_small.jpg", 2_000_000, true},
+		{"medium.jpg", 2_000_000, false},
+		{"fav_tiny.jpg", 500_000, false},
+		{"big.png", 20_000_000, false},
+	}
+	for _, tc := range cases {
+		if got := c.Matches(tc.name, fakeInfo{size: tc.size}); got != tc.want {
+			t.Errorf("Matches(%q, %d bytes) = %v, want %v", tc.name, tc.size, got, tc.want)
+		}
+	}
+}
+
+// unreadable is the Metadata of a file whose image content can't be read.
+type unreadable struct{ fakeInfo }
+
+func (unreadable) Dimensions() (int, int, error) { return 0, 0, errors.New("no decoder") }
+
+func TestMatches_UnreadableMetadataInTree(t *testing.T) {
+	portrait := models.Filter{Dimensions: &models.DimensionsFilter{Orientation: "portrait"}}
+
+	c, _ := Compile(&models.Filter{Not: &portrait})
+	if c.Matches("a.avif", unreadable{}) {
+		t.Error("expected unreadable dimensions not to match under not")
+	}
+
+	c, _ = Compile(&models.Filter{AnyOf: []models.Filter{portrait, {Match: &models.MatchFilter{Glob: "keep_*"}}}})
+	if !c.Matches("keep_a.avif", unreadable{}) {
+		t.Error("expected another any-of branch to match despite unreadable dimensions")
+	}
+	if c.Matches("a.avif", unreadable{}) {
+		t.Error("expected no match when the only other any-of branch fails")
+	}
+
+	c, _ = Compile(&models.Filter{
+		AllOf: []models.Filter{portrait, {Match: &models.MatchFilter{Glob: "keep_*"}}},
+	})
+	notC, _ := Compile(&models.Filter{Not: &models.Filter{
+		AllOf: []models.Filter{portrait, {Match: &models.MatchFilter{Glob: "keep_*"}}},
+	}})
+	if c.Matches("a.avif", unreadable{}) || !notC.Matches("a.avif", unreadable{}) {
+		t.Error("expected a failed all-of branch to decide the result despite unreadable dimensions")
+	}
+}
+
+func TestCompile_NestedErrorPath(t *testing.T) {
+	_, err := Compile(&models.Filter{AnyOf: []models.Filter{
+		{Match: &models.MatchFilter{Glob: "*.png"}},
+		{Not: &models.Filter{Match: &models.MatchFilter{Globs: []string{"["}}}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "filter.any-of[1].not.match.globs[0]") {
+		t.Errorf("err = %v, want it to name filter.any-of[1].not.match.globs[0]", err)
+	}
+
+	_, err = Compile(&models.Filter{AllOf: []models.Filter{{Size: &models.SizeFilter{Max: "lots"}}}})
+	if err == nil || !strings.Contains(err.Error(), "filter.all-of[0].size.max") {
+		t.Errorf("err = %v, want it to name filter.all-of[0].size.max", err)
+	}
+}
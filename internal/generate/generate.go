@@ -0,0 +1,210 @@
+// Package generate renders images from a description instead of a file: a
+// solid color, a linear or radial gradient, noise or stripes. The same
+// description and size always render the same image, which is cached.
+package generate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/overlay"
+)
+
+// Generator types.
+const (
+	Solid          = "solid"
+	LinearGradient = "linear-gradient"
+	RadialGradient = "radial-gradient"
+	Noise          = "noise"
+	Stripes        = "stripes"
+)
+
+// Types returns every generator type, for validation messages.
+func Types() []string {
+	return []string{Solid, LinearGradient, RadialGradient, Noise, Stripes}
+}
+
+// DefaultStripeWidth is the width of each stripe when none is configured.
+const DefaultStripeWidth = 64
+
+// Spec is a validated generator.
+type Spec struct {
+	Type   string
+	Colors []color.NRGBA
+	Angle  float64 // degrees clockwise from left-to-right, for linear-gradient and stripes
+	Seed   uint64  // for noise
+	Width  int     // stripe width in pixels
+}
+
+// String returns the spec in a canonical form, used in cache keys.
+func (s Spec) String() string {
+	parts := []string{s.Type}
+	for _, c := range s.Colors {
+		parts = append(parts, fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A))
+	}
+	switch s.Type {
+	case LinearGradient:
+		parts = append(parts, "angle="+strconv.FormatFloat(s.Angle, 'g', -1, 64))
+	case Noise:
+		parts = append(parts, "seed="+strconv.FormatUint(s.Seed, 10))
+	case Stripes:
+		parts = append(parts, "angle="+strconv.FormatFloat(s.Angle, 'g', -1, 64), "width="+strconv.Itoa(s.Width))
+	}
+	return strings.Join(parts, " ")
+}
+
+// FromConfig validates a configured generator: a known type, one color for
+// solid and at least two for the others, and a non-negative stripe width.
+func FromConfig(c models.Generate) (Spec, error) {
+	if !slices.Contains(Types(), c.Type) {
+		return Spec{}, fmt.Errorf("unknown generator type %q (use %s)", c.Type, strings.Join(Types(), ", "))
+	}
+	switch {
+	case len(c.Colors) == 0:
+		return Spec{}, errors.New("colors: define at least one color")
+	case c.Type == Solid && len(c.Colors) > 1:
+		return Spec{}, errors.New("colors: solid takes exactly one color")
+	case c.Type != Solid && len(c.Colors) < 2:
+		return Spec{}, fmt.Errorf("colors: %s needs at least two colors", c.Type)
+	case c.Width < 0:
+		return Spec{}, fmt.Errorf("width must be positive, got %d", c.Width)
+	}
+	s := Spec{Type: c.Type, Angle: c.Angle, Seed: uint64(c.Seed), Width: c.Width}
+	for _, hex := range c.Colors {
+		col, err := overlay.ParseColor(hex)
+		if err != nil {
+			return Spec{}, fmt.Errorf("colors: %w", err)
+		}
+		s.Colors = append(s.Colors, col)
+	}
+	if s.Type == Stripes && s.Width == 0 {
+		s.Width = DefaultStripeWidth
+	}
+	return s, nil
+}
+
+// Apply renders s at size into cacheDir and returns the image's path,
+// rendering only when that spec and size weren't rendered before.
+func Apply(s Spec, size image.Point, cacheDir string) (string, error) {
+	if size.X <= 0 || size.Y <= 0 {
+		return "", fmt.Errorf("invalid size %v", size)
+	}
+	sum := sha256.Sum256([]byte(s.String() + "\x00" + size.String()))
+	key := hex.EncodeToString(sum[:8])
+	if cached, ok := imagetype.Cached(cacheDir, key); ok {
+		return cached, nil
+	}
+	return imagetype.Store(Render(s, size), cacheDir, key)
+}
+
+// Render draws s on an image of size.
+func Render(s Spec, size image.Point) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	var at func(x, y float64) color.NRGBA
+	switch s.Type {
+	case Solid:
+		at = func(float64, float64) color.NRGBA { return s.Colors[0] }
+	case LinearGradient:
+		project := along(s.Angle, size)
+		at = func(x, y float64) color.NRGBA { return ramp(s.Colors, project(x, y)) }
+	case RadialGradient:
+		cx, cy := float64(size.X)/2, float64(size.Y)/2
+		r := math.Hypot(cx, cy)
+		at = func(x, y float64) color.NRGBA { return ramp(s.Colors, math.Hypot(x-cx, y-cy)/r) }
+	case Noise:
+		n := newValueNoise(s.Seed, size)
+		at = func(x, y float64) color.NRGBA { return ramp(s.Colors, n.at(x, y)) }
+	case Stripes:
+		dx, dy := math.Cos(s.Angle*math.Pi/180), math.Sin(s.Angle*math.Pi/180)
+		at = func(x, y float64) color.NRGBA {
+			i := int(math.Floor((x*dx + y*dy) / float64(s.Width)))
+			return s.Colors[((i%len(s.Colors))+len(s.Colors))%len(s.Colors)]
+		}
+	default:
+		return img
+	}
+	for y := range size.Y {
+		for x := range size.X {
+			img.Set(x, y, at(float64(x)+0.5, float64(y)+0.5))
+		}
+	}
+	return img
+}
+
+// along returns a function mapping a pixel to its position, 0 to 1, along
+// the direction angle (degrees clockwise from left-to-right) across an
+// image of size: the corner the direction starts from is 0, the opposite
+// one 1.
+func along(angle float64, size image.Point) func(x, y float64) float64 {
+	dx, dy := math.Cos(angle*math.Pi/180), math.Sin(angle*math.Pi/180)
+	w, h := float64(size.X), float64(size.Y)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, c := range [][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+		p := c[0]*dx + c[1]*dy
+		lo, hi = min(lo, p), max(hi, p)
+	}
+	return func(x, y float64) float64 {
+		if hi == lo {
+			return 0
+		}
+		return (x*dx + y*dy - lo) / (hi - lo)
+	}
+}
+
+// ramp returns the color at t (0 to 1) of a gradient through colors,
+// evenly spaced.
+func ramp(colors []color.NRGBA, t float64) color.NRGBA {
+	t = min(max(t, 0), 1) * float64(len(colors)-1)
+	i := min(int(t), len(colors)-2)
+	f := t - float64(i)
+	a, b := colors[i], colors[i+1]
+	mix := func(p, q uint8) uint8 { return uint8(math.Round(float64(p) + (float64(q)-float64(p))*f)) }
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
+
+// valueNoise is smooth 2D noise: random values on a coarse lattice,
+// interpolated between the lattice points.
+type valueNoise struct {
+	cell   float64
+	cols   int
+	values []float64
+}
+
+// newValueNoise seeds a lattice with cells an eighth of the shorter side of
+// size, so the noise has the same grain at every resolution.
+func newValueNoise(seed uint64, size image.Point) *valueNoise {
+	cell := math.Max(float64(min(size.X, size.Y))/8, 1)
+	cols := int(float64(size.X)/cell) + 2
+	rows := int(float64(size.Y)/cell) + 2
+	r := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)) // #nosec G404 -- deterministic texture, not security
+	values := make([]float64, cols*rows)
+	for i := range values {
+		values[i] = r.Float64()
+	}
+	return &valueNoise{cell: cell, cols: cols, values: values}
+}
+
+func (n *valueNoise) at(x, y float64) float64 {
+	gx, gy := x/n.cell, y/n.cell
+	x0, y0 := int(gx), int(gy)
+	fx, fy := smoothstep(gx-float64(x0)), smoothstep(gy-float64(y0))
+	v := func(cx, cy int) float64 { return n.values[cy*n.cols+cx] }
+	top := v(x0, y0) + (v(x0+1, y0)-v(x0, y0))*fx
+	bottom := v(x0, y0+1) + (v(x0+1, y0+1)-v(x0, y0+1))*fx
+	return top + (bottom-top)*fy
+}
+
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
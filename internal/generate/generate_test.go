@@ -0,0 +1,110 @@
+package generate
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasassuncao/gopaper/internal/models"
+)
+
+var (
+	black = color.NRGBA{0, 0, 0, 255}
+	white = color.NRGBA{255, 255, 255, 255}
+)
+
+func TestFromConfig(t *testing.T) {
+	s, err := FromConfig(models.Generate{Type: Stripes, Colors: []string{"#000000", "#ffffff"}, Angle: 45})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Width != DefaultStripeWidth || len(s.Colors) != 2 || s.Colors[1] != white {
+		t.Errorf("FromConfig() = %+v", s)
+	}
+	for _, bad := range []models.Generate{
+		{Type: "plasma", Colors: []string{"#000000"}},
+		{Type: Solid},
+		{Type: Solid, Colors: []string{"#000000", "#ffffff"}},
+		{Type: LinearGradient, Colors: []string{"#000000"}},
+		{Type: Noise, Colors: []string{"#000000", "white"}},
+		{Type: Stripes, Colors: []string{"#000000", "#ffffff"}, Width: -4},
+	} {
+		if _, err := FromConfig(bad); err == nil {
+			t.Errorf("FromConfig(%+v): expected an error", bad)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	size := image.Pt(100, 60)
+	bw := []color.NRGBA{black, white}
+	at := func(img *image.RGBA, x, y int) uint8 { return img.RGBAAt(x, y).R }
+
+	if got := Render(Spec{Type: Solid, Colors: []color.NRGBA{{10, 20, 30, 255}}}, size).RGBAAt(50, 30); got != (color.RGBA{10, 20, 30, 255}) {
+		t.Errorf("solid = %v", got)
+	}
+
+	lin := Render(Spec{Type: LinearGradient, Colors: bw}, size)
+	if at(lin, 0, 30) > 5 || at(lin, 99, 30) < 250 || at(lin, 0, 30) != at(lin, 0, 0) {
+		t.Errorf("left-to-right gradient: left %d, right %d", at(lin, 0, 30), at(lin, 99, 30))
+	}
+	down := Render(Spec{Type: LinearGradient, Colors: bw, Angle: 90}, size)
+	if at(down, 50, 0) > 5 || at(down, 50, 59) < 250 {
+		t.Errorf("top-to-bottom gradient: top %d, bottom %d", at(down, 50, 0), at(down, 50, 59))
+	}
+
+	rad := Render(Spec{Type: RadialGradient, Colors: bw}, size)
+	if at(rad, 50, 30) > 5 || at(rad, 0, 0) < 245 {
+		t.Errorf("radial gradient: center %d, corner %d", at(rad, 50, 30), at(rad, 0, 0))
+	}
+
+	st := Render(Spec{Type: Stripes, Colors: bw, Width: 10}, size)
+	if at(st, 5, 0) != 0 || at(st, 15, 0) != 255 || at(st, 25, 59) != 0 {
+		t.Errorf("stripes: %d, %d, %d", at(st, 5, 0), at(st, 15, 0), at(st, 25, 59))
+	}
+}
+
+func TestNoiseIsDeterministic(t *testing.T) {
+	size := image.Pt(64, 48)
+	bw := []color.NRGBA{black, white}
+	a := Render(Spec{Type: Noise, Colors: bw, Seed: 7}, size)
+	b := Render(Spec{Type: Noise, Colors: bw, Seed: 7}, size)
+	c := Render(Spec{Type: Noise, Colors: bw, Seed: 8}, size)
+	if !bytes.Equal(a.Pix, b.Pix) {
+		t.Error("the same seed rendered different noise")
+	}
+	if bytes.Equal(a.Pix, c.Pix) {
+		t.Error("different seeds rendered the same noise")
+	}
+	lo, hi := uint8(255), uint8(0)
+	for i := 0; i < len(a.Pix); i += 4 {
+		lo, hi = min(lo, a.Pix[i]), max(hi, a.Pix[i])
+	}
+	if hi-lo < 64 {
+		t.Errorf("noise spans only %d-%d", lo, hi)
+	}
+}
+
+func TestApplyCachesTheRender(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "generated")
+	s := Spec{Type: LinearGradient, Colors: []color.NRGBA{black, white}}
+
+	first, err := Apply(s, image.Pt(32, 18), cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(first) != cache {
+		t.Fatalf("render %q is not in the cache directory", first)
+	}
+	if again, _ := Apply(s, image.Pt(32, 18), cache); again != first {
+		t.Errorf("same spec rendered to %q, then %q", first, again)
+	}
+	if other, _ := Apply(s, image.Pt(64, 36), cache); other == first {
+		t.Error("another size should render another image")
+	}
+	if _, err := Apply(s, image.Point{}, cache); err == nil {
+		t.Error("expected an error for an empty size")
+	}
+}
@@ -0,0 +1,600 @@
+package helper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The desktop backends UseBackend can select besides DefaultBackend. Each
+// drives its desktop's own command-line tool, so none of them needs more
+// than that tool on PATH. Monitors are the output names ListMonitors
+// returns (e.g. "DP-1"); the backends that number them instead (KDE, feh,
+// nitrogen) take their position in that list.
+
+// fileURI returns path as a file:// URI, as GNOME and KDE store it.
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// uriPath returns the path of a file:// URI, or s itself when it isn't one.
+func uriPath(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "file" {
+		return s
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// --- GNOME ---
+
+const gnomeSchema = "org.gnome.desktop.background"
+
+// gnome sets org.gnome.desktop.background through gsettings, for both the
+// light and the dark style. GNOME has one wallpaper for every monitor.
+type gnome struct{}
+
+func (gnome) Set(path, _ string) error {
+	uri := fileURI(path)
+	if _, err := command("gsettings", "set", gnomeSchema, "picture-uri", uri); err != nil {
+		return err
+	}
+	// picture-uri-dark only exists since GNOME 42.
+	if _, err := command("gsettings", "set", gnomeSchema, "picture-uri-dark", uri); err != nil && !strings.Contains(err.Error(), "No such key") {
+		return err
+	}
+	return nil
+}
+
+func (gnome) SetMonitors([]MonitorTarget, string) error {
+	return errors.New("GNOME can't set a wallpaper per monitor")
+}
+
+func (gnome) PerMonitor() bool { return false }
+
+var gnomeModes = map[string]string{
+	"crop": "zoom", "fit": "scaled", "stretch": "stretched", "span": "spanned", "tile": "wallpaper", "center": "centered",
+}
+
+func (gnome) SetMode(mode string) error {
+	_, err := command("gsettings", "set", gnomeSchema, "picture-options", lookupMode(gnomeModes, mode))
+	return err
+}
+
+func (gnome) Get() (string, error) {
+	out, err := command("gsettings", "get", gnomeSchema, "picture-uri")
+	if err != nil {
+		return "", err
+	}
+	return uriPath(strings.Trim(strings.TrimSpace(out), "'")), nil
+}
+
+// lookupMode returns mode's name in modes, crop's when it has none.
+func lookupMode(modes map[string]string, mode string) string {
+	if name, ok := modes[mode]; ok {
+		return name
+	}
+	return modes["crop"]
+}
+
+// --- KDE Plasma ---
+
+// kde sets the image wallpaper plugin's configuration of every desktop (or
+// of one screen's) through a Plasma script evaluated over D-Bus.
+type kde struct{}
+
+// plasmaScript evaluates script in the running Plasma shell and returns
+// what it printed.
+func plasmaScript(script string) (string, error) {
+	for _, name := range []string{"qdbus6", "qdbus-qt6", "qdbus", "qdbus-qt5"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return command(name, "org.kde.plasmashell", "/PlasmaShell", "org.kde.PlasmaShell.evaluateScript", script)
+		}
+	}
+	return "", errors.New("qdbus not found: install qdbus (qt6-tools or qttools) to use the kde backend")
+}
+
+// kdeScript returns a Plasma script running body for every desktop d on
+// monitor, or on every screen when monitor is nil, with d's image
+// wallpaper configuration selected. Plasma numbers its screens itself (the
+// primary one first), so the monitor's screen is the one at its position.
+func kdeScript(monitor *MonitorDetail, body string) string {
+	filter := ""
+	if monitor != nil {
+		filter = fmt.Sprintf(`
+	var g = screenGeometry(d.screen);
+	if (g.x !== %d || g.y !== %d) return;`, monitor.Left, monitor.Top)
+	}
+	return fmt.Sprintf(`desktops().forEach(function (d) {%s
+	d.wallpaperPlugin = "org.kde.image";
+	d.currentConfigGroup = ["Wallpaper", "org.kde.image", "General"];
+	%s
+});`, filter, body)
+}
+
+// jsString quotes s for a Plasma script: a JSON string is a JavaScript one.
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (kde) Set(path, _ string) error {
+	_, err := plasmaScript(kdeScript(nil, `d.writeConfig("Image", `+jsString(fileURI(path))+`);`))
+	return err
+}
+
+func (kde) SetMonitors(targets []MonitorTarget, _ string) error {
+	for _, t := range targets {
+		monitor, err := monitorDetail(t.DevicePath)
+		if err != nil {
+			return err
+		}
+		if _, err := plasmaScript(kdeScript(&monitor, `d.writeConfig("Image", `+jsString(fileURI(t.Path))+`);`)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (kde) PerMonitor() bool { return true }
+
+// kdeModes are the image plugin's FillMode values.
+var kdeModes = map[string]string{
+	"crop": "2", "fit": "1", "stretch": "0", "span": "2", "tile": "3", "center": "6",
+}
+
+func (kde) SetMode(mode string) error {
+	_, err := plasmaScript(kdeScript(nil, `d.writeConfig("FillMode", `+lookupMode(kdeModes, mode)+`);`))
+	return err
+}
+
+func (kde) Get() (string, error) {
+	out, err := plasmaScript(`var d = desktops()[0];
+d.currentConfigGroup = ["Wallpaper", "org.kde.image", "General"];
+print(d.readConfig("Image"));`)
+	if err != nil {
+		return "", err
+	}
+	return uriPath(strings.TrimSpace(out)), nil
+}
+
+// --- XFCE ---
+
+const xfceChannel = "xfce4-desktop"
+
+// xfce sets the xfce4-desktop channel's backdrop properties through
+// xfconf-query: /backdrop/screen0/monitor<output>/workspace<n>/last-image
+// and image-style, on every workspace.
+type xfce struct{}
+
+// xfceProperties returns the channel's properties, sorted.
+func xfceProperties() ([]string, error) {
+	out, err := command("xfconf-query", "-c", xfceChannel, "-l")
+	if err != nil {
+		return nil, err
+	}
+	props := strings.Fields(out)
+	slices.Sort(props)
+	return props, nil
+}
+
+// xfceImages returns the last-image properties of the monitor named
+// output, or of every monitor when output is empty. A monitor without
+// any gets its first workspace's.
+func xfceImages(props []string, output string) []string {
+	prefix := "/backdrop/screen0/monitor" + output
+	if output != "" {
+		prefix += "/"
+	}
+	var out []string
+	for _, p := range props {
+		if strings.HasPrefix(p, prefix) && strings.HasSuffix(p, "/last-image") {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 && output != "" {
+		out = []string{prefix + "workspace0/last-image"}
+	}
+	return out
+}
+
+// xfceSet sets prop to value, creating it with type typ when it isn't
+// among props yet.
+func xfceSet(props []string, prop, typ, value string) error {
+	args := []string{"-c", xfceChannel, "-p", prop}
+	if _, ok := slices.BinarySearch(props, prop); !ok {
+		args = append(args, "-n", "-t", typ)
+	}
+	_, err := command("xfconf-query", append(args, "-s", value)...)
+	return err
+}
+
+func (xfce) Set(path, _ string) error {
+	props, err := xfceProperties()
+	if err != nil {
+		return err
+	}
+	images := xfceImages(props, "")
+	if len(images) == 0 {
+		monitors, err := monitorDevicePaths()
+		if err != nil {
+			return fmt.Errorf("no XFCE backdrop configured and the monitors are unknown: %w", err)
+		}
+		for _, m := range monitors {
+			images = append(images, xfceImages(props, m)...)
+		}
+	}
+	for _, p := range images {
+		if err := xfceSet(props, p, "string", path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (xfce) SetMonitors(targets []MonitorTarget, _ string) error {
+	props, err := xfceProperties()
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		for _, p := range xfceImages(props, t.DevicePath) {
+			if err := xfceSet(props, p, "string", t.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (xfce) PerMonitor() bool { return true }
+
+// xfceModes are the image-style values.
+var xfceModes = map[string]string{
+	"crop": "5", "fit": "4", "stretch": "3", "span": "6", "tile": "2", "center": "1",
+}
+
+func (xfce) SetMode(mode string) error {
+	props, err := xfceProperties()
+	if err != nil {
+		return err
+	}
+	for _, p := range xfceImages(props, "") {
+		style := strings.TrimSuffix(p, "last-image") + "image-style"
+		if err := xfceSet(props, style, "int", lookupMode(xfceModes, mode)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (xfce) Get() (string, error) {
+	props, err := xfceProperties()
+	if err != nil {
+		return "", err
+	}
+	images := xfceImages(props, "")
+	if len(images) == 0 {
+		return "", errors.New("no XFCE backdrop configured")
+	}
+	out, err := command("xfconf-query", "-c", xfceChannel, "-p", images[0])
+	return strings.TrimSpace(out), err
+}
+
+// --- sway ---
+
+var swayModes = map[string]string{
+	"crop": "fill", "fit": "fit", "stretch": "stretch", "span": "fill", "tile": "tile", "center": "center",
+}
+
+// applySway sets each target as its output's background through swaymsg.
+// Sway can't report the current background, so the backend has no Get.
+func applySway(targets []MonitorTarget, mode string) error {
+	for _, t := range targets {
+		output := t.DevicePath
+		if output == "" {
+			output = "*"
+		}
+		quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(t.Path) + `"`
+		if _, err := command("swaymsg", "output", output, "bg", quoted, lookupMode(swayModes, mode)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Hyprland (hyprpaper) ---
+
+// hyprpaperPrefixes are how hyprpaper fits an image other than by
+// covering the monitor, written before its path.
+var hyprpaperPrefixes = map[string]string{"fit": "contain:", "tile": "tile:"}
+
+// hyprpaper answers "ok" to a request it carried out.
+func hyprpaper(args ...string) (string, error) {
+	out, err := command("hyprctl", append([]string{"hyprpaper"}, args...)...)
+	if err != nil {
+		return "", err
+	}
+	if reply := strings.TrimSpace(out); reply != "ok" && args[0] != "listactive" {
+		return "", fmt.Errorf("hyprpaper %s: %s", args[0], reply)
+	}
+	return out, nil
+}
+
+// applyHyprpaper preloads each target's image in hyprpaper and shows it on
+// its monitor (every monitor for an empty DevicePath), then unloads the
+// images no longer shown.
+func applyHyprpaper(targets []MonitorTarget, mode string) error {
+	for _, t := range targets {
+		if _, err := hyprpaper("preload", t.Path); err != nil {
+			return err
+		}
+		if _, err := hyprpaper("wallpaper", t.DevicePath+","+hyprpaperPrefixes[mode]+t.Path); err != nil {
+			return err
+		}
+	}
+	_, _ = hyprpaper("unload", "unused")
+	return nil
+}
+
+// getHyprpaper returns the first monitor's image from "DP-1 = /path" lines.
+func getHyprpaper() (string, error) {
+	out, err := hyprpaper("listactive")
+	if err != nil {
+		return "", err
+	}
+	for line := range strings.Lines(out) {
+		if _, path, ok := strings.Cut(line, " = "); ok {
+			return strings.TrimSpace(path), nil
+		}
+	}
+	return "", errors.New("hyprpaper shows no wallpaper")
+}
+
+// --- swww ---
+
+// swwwModes are swww's --resize values; it neither spans nor tiles.
+var swwwModes = map[string]string{
+	"crop": "crop", "fit": "fit", "stretch": "stretch", "span": "crop", "tile": "no", "center": "no",
+}
+
+// applySwww shows each target through swww img, on its output or on every
+// output for an empty DevicePath.
+func applySwww(targets []MonitorTarget, mode string) error {
+	for _, t := range targets {
+		args := []string{"img", t.Path, "--resize", lookupMode(swwwModes, mode)}
+		if t.DevicePath != "" {
+			args = append(args, "--outputs", t.DevicePath)
+		}
+		if _, err := command("swww", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSwww returns the first output's image from swww query's
+// "DP-1: 1920x1080, scale: 1, currently displaying: image: /path" lines.
+func getSwww() (string, error) {
+	out, err := command("swww", "query")
+	if err != nil {
+		return "", err
+	}
+	for line := range strings.Lines(out) {
+		if _, path, ok := strings.Cut(line, "image: "); ok {
+			return strings.TrimSpace(path), nil
+		}
+	}
+	return "", errors.New("swww shows no image")
+}
+
+// --- feh ---
+
+var fehModes = map[string]string{
+	"crop": "--bg-fill", "fit": "--bg-max", "stretch": "--bg-scale", "span": "--bg-fill", "tile": "--bg-tile", "center": "--bg-center",
+}
+
+// applyFeh sets the images through feh, which also records them in
+// ~/.fehbg for the session to restore. feh takes one image per monitor,
+// in order, on one command line: monitors without a target keep the image
+// ~/.fehbg has for them.
+func applyFeh(targets []MonitorTarget, mode string) error {
+	args := []string{lookupMode(fehModes, mode)}
+	if mode == "span" {
+		args = append(args, "--no-xinerama")
+	}
+	if len(targets) == 1 && targets[0].DevicePath == "" {
+		_, err := command("feh", append(args, targets[0].Path)...)
+		return err
+	}
+
+	monitors, err := monitorDevicePaths()
+	if err != nil {
+		return err
+	}
+	current, _ := fehImages()
+	for i, m := range monitors {
+		switch j := slices.IndexFunc(targets, func(t MonitorTarget) bool { return t.DevicePath == m }); {
+		case j >= 0:
+			args = append(args, targets[j].Path)
+		case i < len(current):
+			args = append(args, current[i])
+		case len(current) > 0:
+			// feh repeats its last image on the remaining monitors.
+			args = append(args, current[len(current)-1])
+		default:
+			return fmt.Errorf("feh needs an image for every monitor and has none for %s", m)
+		}
+	}
+	_, err = command("feh", args...)
+	return err
+}
+
+// fehImages returns the images ~/.fehbg sets, one per monitor.
+func fehImages() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(home, ".fehbg")) // #nosec G304 -- feh's own state file
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		words := shellWords(scanner.Text())
+		if len(words) == 0 || filepath.Base(words[0]) != "feh" {
+			continue
+		}
+		var images []string
+		for _, w := range words[1:] {
+			if !strings.HasPrefix(w, "-") {
+				images = append(images, w)
+			}
+		}
+		return images, nil
+	}
+	return nil, errors.New("~/.fehbg runs no feh command")
+}
+
+func getFeh() (string, error) {
+	images, err := fehImages()
+	if err != nil {
+		return "", err
+	}
+	if len(images) == 0 {
+		return "", errors.New("~/.fehbg sets no image")
+	}
+	return images[0], nil
+}
+
+// shellWords splits a POSIX shell command line into words, undoing the
+// single and double quotes and backslashes feh writes in ~/.fehbg.
+func shellWords(line string) []string {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '#' && !inWord:
+			return words
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// --- nitrogen ---
+
+var nitrogenModes = map[string]string{
+	"crop": "--set-zoom-fill", "fit": "--set-zoom", "stretch": "--set-scaled", "span": "--set-zoom-fill", "tile": "--set-tiled", "center": "--set-centered",
+}
+
+// applyNitrogen sets and saves each target through nitrogen, on its head
+// (the monitor's position). Every monitor is every head, one at a time,
+// or the whole screen when the monitors are unknown or the mode spans.
+func applyNitrogen(targets []MonitorTarget, mode string) error {
+	set := func(head, path string) error {
+		args := []string{lookupMode(nitrogenModes, mode), "--save"}
+		if head != "" {
+			args = append(args, "--head="+head)
+		}
+		_, err := command("nitrogen", append(args, path)...)
+		return err
+	}
+	for _, t := range targets {
+		if t.DevicePath != "" {
+			head, err := monitorIndex(t.DevicePath)
+			if err != nil {
+				return err
+			}
+			if err := set(strconv.Itoa(head), t.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		monitors, err := monitorDevicePaths()
+		if err != nil || mode == "span" {
+			head := ""
+			if mode == "span" {
+				head = "-1"
+			}
+			if err := set(head, t.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := range monitors {
+			if err := set(strconv.Itoa(i), t.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getNitrogen returns the first image nitrogen saved in bg-saved.cfg.
+func getNitrogen() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filepath.Join(dir, "nitrogen", "bg-saved.cfg")) // #nosec G304 -- nitrogen's own state file
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "file="); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("nitrogen has no saved wallpaper")
+}
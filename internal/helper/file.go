@@ -0,0 +1,287 @@
+package helper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/history"
+)
+
+// FileOutput configures the file backend: where wallpapers are published
+// instead of being put on a desktop.
+type FileOutput struct {
+	// Path receives the wallpaper set on every monitor.
+	Path string
+	// Monitors are the monitors the backend makes up, laid out left to
+	// right in order; each one's wallpaper is published at its Path. With
+	// none, the connected monitors are enumerated as usual and per-monitor
+	// changes are composed into one image at Path.
+	Monitors []FileMonitor
+	// Symlink publishes symbolic links to the images instead of copies.
+	Symlink bool
+	// Manifest is where current.json is written.
+	Manifest string
+}
+
+// FileMonitor is one monitor of the file backend.
+type FileMonitor struct {
+	Path          string
+	Width, Height int
+}
+
+// monitorPaths returns the configured monitors' output paths, in order.
+func (out FileOutput) monitorPaths() []string {
+	paths := make([]string, len(out.Monitors))
+	for i, m := range out.Monitors {
+		paths[i] = m.Path
+	}
+	return paths
+}
+
+// fileOutput is what the file backend publishes to once selected.
+var fileOutput FileOutput
+
+// SetFileOutput configures the file backend for the next UseBackend("file").
+func SetFileOutput(out FileOutput) {
+	fileOutput = out
+}
+
+// Manifest is current.json, what the file backend last published: the
+// change's image, category and mode as history recorded them, and Output,
+// the file the image was published at. Monitors lists what each monitor
+// shows when they were set one by one.
+type Manifest struct {
+	Path      string            `json:"path"`
+	Category  string            `json:"category"`
+	Mode      string            `json:"mode"`
+	Output    string            `json:"output,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Monitors  []ManifestMonitor `json:"monitors,omitempty"`
+}
+
+// ManifestMonitor is one monitor's published wallpaper. Monitor is 1-based.
+type ManifestMonitor struct {
+	Monitor  int    `json:"monitor"`
+	Path     string `json:"path"`
+	Category string `json:"category,omitempty"`
+	Output   string `json:"output"`
+}
+
+// file is the backend publishing wallpapers as files, for kiosks and
+// headless machines where something else displays them. It remembers what
+// it published until RecordChange adds the category and writes the
+// manifest.
+type file struct {
+	out  FileOutput
+	mode string
+	// all is the image published at out.Path, or "" after per-monitor
+	// changes, which published monitors (by output path) instead.
+	all      string
+	monitors map[string]string
+}
+
+func (f *file) Set(path, mode string) error {
+	if f.out.Path == "" {
+		return errors.New("the file backend needs configuration.output.path")
+	}
+	if err := publish(path, f.out.Path, f.out.Symlink); err != nil {
+		return err
+	}
+	f.all, f.monitors, f.mode = path, nil, mode
+	return nil
+}
+
+// SetMonitors publishes each target's image at its monitor's path, the
+// target's DevicePath.
+func (f *file) SetMonitors(targets []MonitorTarget, mode string) error {
+	if !f.PerMonitor() {
+		return errors.New("the file backend needs configuration.output.monitors to set a wallpaper per monitor")
+	}
+	published := map[string]string{}
+	for _, t := range targets {
+		if !slices.ContainsFunc(f.out.Monitors, func(m FileMonitor) bool { return m.Path == t.DevicePath }) {
+			return fmt.Errorf("monitor %q is not in configuration.output.monitors", t.DevicePath)
+		}
+		if err := publish(t.Path, t.DevicePath, f.out.Symlink); err != nil {
+			return err
+		}
+		published[t.DevicePath] = t.Path
+	}
+	f.all, f.monitors, f.mode = "", published, mode
+	return nil
+}
+
+func (f *file) PerMonitor() bool { return len(f.out.Monitors) > 0 }
+
+// SetMode only records the mode in the manifest: whatever displays the
+// image decides how it fills the screen.
+func (f *file) SetMode(mode string) error {
+	f.mode = mode
+	return nil
+}
+
+// Get returns the image of the last change the manifest recorded.
+func (f *file) Get() (string, error) {
+	m, err := readManifest(f.out.Manifest)
+	if err != nil {
+		return "", err
+	}
+	if m.Path == "" {
+		return "", errors.New("the manifest records no wallpaper")
+	}
+	return m.Path, nil
+}
+
+// monitorDetails makes up the configured monitors, side by side.
+func (f *file) monitorDetails() []MonitorDetail {
+	details := make([]MonitorDetail, len(f.out.Monitors))
+	left := 0
+	for i, m := range f.out.Monitors {
+		details[i] = MonitorDetail{Index: i + 1, DevicePath: m.Path, Left: left, Right: left + m.Width, Bottom: m.Height, Name: "file"}
+		left += m.Width
+	}
+	return details
+}
+
+// Record writes the manifest for entry, the change just published. A
+// change to some monitors keeps the others' from the previous manifest.
+func (f *file) Record(entry history.Entry) error {
+	if f.out.Manifest == "" {
+		return nil
+	}
+	m := Manifest{Path: entry.Path, Category: entry.Category, Mode: entry.Mode, Timestamp: entry.Timestamp}
+	if f.all != "" {
+		m.Output = f.out.Path
+	} else {
+		if prev, err := readManifest(f.out.Manifest); err == nil {
+			m.Monitors = prev.Monitors
+		}
+		for i, fm := range f.out.Monitors {
+			image, ok := f.monitors[fm.Path]
+			if !ok {
+				continue
+			}
+			mm := ManifestMonitor{Monitor: i + 1, Path: image, Category: entry.Category, Output: fm.Path}
+			for _, em := range entry.Monitors {
+				if em.Monitor == i+1 {
+					mm.Path, mm.Category = em.Path, em.Category
+				}
+			}
+			m.Monitors = slices.DeleteFunc(m.Monitors, func(o ManifestMonitor) bool { return o.Monitor == mm.Monitor })
+			m.Monitors = append(m.Monitors, mm)
+		}
+		slices.SortFunc(m.Monitors, func(a, b ManifestMonitor) int { return a.Monitor - b.Monitor })
+	}
+	if m.Mode == "" {
+		m.Mode = f.mode
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(f.out.Manifest, append(data, '\n'))
+}
+
+func readManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the user's own config
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// RecordChange has the backend in use record entry, the change it just
+// applied, when it keeps a record of its own (the file backend's
+// manifest).
+func RecordChange(entry history.Entry) error {
+	if r, ok := setter.(interface{ Record(history.Entry) error }); ok {
+		return r.Record(entry)
+	}
+	return nil
+}
+
+// publish atomically replaces dst with a copy of src, or a symbolic link to
+// it: readers see the old file or the new one, never a partial write.
+func publish(src, dst string, symlink bool) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if symlink {
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		tmp := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%s.%d.tmp", filepath.Base(dst), os.Getpid()))
+		_ = os.Remove(tmp)
+		if err := os.Symlink(abs, tmp); err != nil {
+			return fmt.Errorf("could not link %s: %w", dst, err)
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("could not link %s: %w", dst, err)
+		}
+		return nil
+	}
+
+	in, err := os.Open(src) // #nosec G304 -- the wallpaper being applied
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not copy %s: %w", src, err)
+	}
+	return finishAtomic(tmp, dst)
+}
+
+// writeAtomic replaces path with data through a temporary file renamed
+// over it.
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	return finishAtomic(tmp, path)
+}
+
+// finishAtomic closes tmp, makes it readable and renames it to dst,
+// removing it on failure.
+func finishAtomic(tmp *os.File, dst string) error {
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
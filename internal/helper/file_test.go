@@ -0,0 +1,174 @@
+package helper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/history"
+)
+
+// useFileBackend selects the file backend publishing to out for the test.
+func useFileBackend(t *testing.T, out FileOutput) {
+	t.Helper()
+	SetFileOutput(out)
+	t.Cleanup(func() { fileOutput = FileOutput{} })
+	useBackend(t, "file")
+}
+
+func readManifestFile(t *testing.T, path string) Manifest {
+	t.Helper()
+	m, err := readManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", path, data, want)
+	}
+}
+
+func TestFileBackend_Set(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "a.jpg")
+	writeFile(t, src, "image a")
+	out := FileOutput{Path: filepath.Join(dir, "out", "wallpaper.jpg"), Manifest: filepath.Join(dir, "out", "current.json")}
+	useFileBackend(t, out)
+
+	if _, err := GetPreviousWallpaper(); err == nil {
+		t.Error("expected an error before anything was published")
+	}
+	if err := SetWallpaperFromPath(src, "crop", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpaperMode("fit"); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	if err := RecordChange(history.Entry{Path: src, Category: "nature", Mode: "fit", Timestamp: ts}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, out.Path, "image a")
+	if info, err := os.Lstat(out.Path); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("expected a copy at %s, got %v, %v", out.Path, info, err)
+	}
+	want := Manifest{Path: src, Category: "nature", Mode: "fit", Output: out.Path, Timestamp: ts}
+	if got := readManifestFile(t, out.Manifest); !manifestEqual(got, want) {
+		t.Errorf("manifest = %+v, want %+v", got, want)
+	}
+	if got, err := GetPreviousWallpaper(); err != nil || got != src {
+		t.Errorf("GetPreviousWallpaper() = (%q, %v), want %q", got, err, src)
+	}
+	if PerMonitorSupported() {
+		t.Error("PerMonitorSupported() without configured monitors")
+	}
+	entries, err := os.ReadDir(filepath.Dir(out.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestFileBackend_Symlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on Windows")
+	}
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.jpg"), filepath.Join(dir, "b.jpg")
+	writeFile(t, a, "image a")
+	writeFile(t, b, "image b")
+	out := FileOutput{Path: filepath.Join(dir, "wallpaper.jpg"), Symlink: true}
+	useFileBackend(t, out)
+
+	for _, src := range []string{a, b} {
+		if err := SetWallpaperFromPath(src, "crop", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if target, err := os.Readlink(out.Path); err != nil || target != b {
+		t.Errorf("Readlink = (%q, %v), want %q", target, err, b)
+	}
+}
+
+func TestFileBackend_Monitors(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.jpg"), filepath.Join(dir, "b.jpg")
+	writeFile(t, a, "image a")
+	writeFile(t, b, "image b")
+	left, right := filepath.Join(dir, "left.jpg"), filepath.Join(dir, "right.jpg")
+	out := FileOutput{
+		Path:     filepath.Join(dir, "wallpaper.jpg"),
+		Manifest: filepath.Join(dir, "current.json"),
+		Monitors: []FileMonitor{{Path: left, Width: 1920, Height: 1080}, {Path: right, Width: 1080, Height: 1920}},
+	}
+	useFileBackend(t, out)
+
+	monitors, err := ListMonitors()
+	if err != nil || !slices.Equal(monitors, []string{left, right}) {
+		t.Fatalf("ListMonitors() = (%v, %v)", monitors, err)
+	}
+	details, err := ListMonitorDetails()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDetails(t, details, []MonitorDetail{
+		{Index: 1, DevicePath: left, Right: 1920, Bottom: 1080, Name: "file"},
+		{Index: 2, DevicePath: right, Left: 1920, Right: 3000, Bottom: 1920, Name: "file"},
+	})
+	if !PerMonitorSupported() {
+		t.Fatal("PerMonitorSupported() with configured monitors")
+	}
+
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: left, Path: a}, {DevicePath: right, Path: b}}, "crop"); err != nil {
+		t.Fatal(err)
+	}
+	err = RecordChange(history.Entry{Path: a, Category: "nature", Mode: "crop", Monitors: []history.MonitorEntry{
+		{Monitor: 1, Path: a, Category: "nature"},
+		{Monitor: 2, Path: b, Category: "city"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, left, "image a")
+	assertContent(t, right, "image b")
+
+	// Changing monitor 2 alone keeps monitor 1 in the manifest.
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: right, Path: a}}, "crop"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordChange(history.Entry{Path: a, Category: "space", Mode: "fit", Monitors: []history.MonitorEntry{{Monitor: 2, Path: a, Category: "space"}}}); err != nil {
+		t.Fatal(err)
+	}
+	want := Manifest{Path: a, Category: "space", Mode: "fit", Monitors: []ManifestMonitor{
+		{Monitor: 1, Path: a, Category: "nature", Output: left},
+		{Monitor: 2, Path: a, Category: "space", Output: right},
+	}}
+	if got := readManifestFile(t, out.Manifest); !manifestEqual(got, want) {
+		t.Errorf("manifest = %+v, want %+v", got, want)
+	}
+
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: "DP-1", Path: a}}, "crop"); err == nil {
+		t.Error("expected an error for a monitor that isn't configured")
+	}
+}
+
+func manifestEqual(a, b Manifest) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
@@ -1,29 +1,31 @@
 package helper
 
 import (
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
 	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/lucasassuncao/gopaper/internal/exif"
 	"github.com/lucasassuncao/gopaper/internal/filters"
+	"github.com/lucasassuncao/gopaper/internal/ignore"
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/imghash"
+	"github.com/lucasassuncao/gopaper/internal/index"
+	"github.com/lucasassuncao/gopaper/internal/metacache"
 	"github.com/lucasassuncao/gopaper/internal/models"
 	"github.com/lucasassuncao/gopaper/internal/schedule"
+	"github.com/lucasassuncao/gopaper/internal/tags"
 	"github.com/lucasassuncao/gopaper/internal/weather"
-
-	"github.com/reujab/wallpaper"
 )
 
-// imageExtensions holds the supported wallpaper file extensions.
-var imageExtensions = map[string]struct{}{
-	".jpg":  {},
-	".jpeg": {},
-	".png":  {},
-	".webp": {},
-}
-
 // CreateDirectory checks if the specified directory exists, and if not, creates it with full permissions.
 func CreateDirectory(dir string) error {
 	_, err := os.Stat(dir)
@@ -47,6 +49,152 @@ func ReadDirectory(path string) ([]os.DirEntry, error) {
 	return files, nil
 }
 
+// ReadCategoryFiles lists the candidate entries of cat's resolved source
+// directory dir. A plain category gets dir's direct entries; a recursive
+// one gets every file beneath dir, descending at most cat.MaxDepth levels
+// of subdirectories (0 = unlimited), with each entry's Name() being its
+// path relative to dir (e.g. "2024/05/beach.jpg") so it can be joined back
+// onto dir like a flat entry. Either way .gopaperignore files are honored,
+// each one applying to its own directory and everything below it.
+func ReadCategoryFiles(cat *models.Categories, dir string) ([]os.DirEntry, error) {
+	return walkCategory(cat, fsLister{root: dir})
+}
+
+// ReadCategoryFilesIndexed is ReadCategoryFiles served from dir's file
+// index in store, refreshed first, so file sizes and times come without a
+// stat per file. A nil store, or an index that cannot be opened, falls
+// back to reading the directory itself.
+func ReadCategoryFilesIndexed(cat *models.Categories, dir string, store *index.Store) ([]os.DirEntry, error) {
+	if store == nil {
+		return ReadCategoryFiles(cat, dir)
+	}
+	idx, err := store.Open(dir, IndexDepth(cat))
+	if err != nil {
+		return ReadCategoryFiles(cat, dir)
+	}
+	return walkCategory(cat, indexLister{idx})
+}
+
+// IndexDepth is the number of subdirectory levels an index of cat's
+// sources has to cover.
+func IndexDepth(cat *models.Categories) int {
+	switch {
+	case !cat.Recursive:
+		return 0
+	case cat.MaxDepth > 0:
+		return cat.MaxDepth
+	default:
+		return index.Unlimited
+	}
+}
+
+// dirLister reads the directories of a category walk, by path relative to
+// the source root ("" for the root itself, slash-separated below it).
+type dirLister interface {
+	List(rel string) ([]os.DirEntry, error)
+	IgnoreFile(rel string) []byte
+}
+
+// fsLister reads directories straight from disk.
+type fsLister struct{ root string }
+
+func (l fsLister) abs(rel string) string { return filepath.Join(l.root, filepath.FromSlash(rel)) }
+
+func (l fsLister) List(rel string) ([]os.DirEntry, error) { return ReadDirectory(l.abs(rel)) }
+
+func (l fsLister) IgnoreFile(rel string) []byte {
+	data, err := os.ReadFile(filepath.Join(l.abs(rel), ignore.FileName)) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// indexLister reads directories from a source's file index.
+type indexLister struct{ idx *index.Index }
+
+func (l indexLister) List(rel string) ([]os.DirEntry, error) {
+	entries, ok := l.idx.List(rel)
+	if !ok {
+		return nil, fmt.Errorf("directory %q is not indexed", rel)
+	}
+	return entries, nil
+}
+
+func (l indexLister) IgnoreFile(rel string) []byte { return l.idx.IgnoreFile(rel) }
+
+// walkCategory lists cat's candidate entries through l (see
+// ReadCategoryFiles). Only an unreadable root is an error.
+func walkCategory(cat *models.Categories, l dirLister) ([]os.DirEntry, error) {
+	entries, err := l.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	var out []os.DirEntry
+	walkEntries(l, "", entries, 0, cat, ignore.Matcher{}.With("", l.IgnoreFile("")), &out)
+	return out, nil
+}
+
+// walkEntries appends the non-ignored entries among entries (read from the
+// directory rel) to out. Subdirectories are kept as-is for flat categories
+// (GetRandomFile skips them, as with os.ReadDir) and descended into for
+// recursive ones while depth allows. Unreadable subdirectories are skipped
+// rather than failing the whole scan.
+func walkEntries(l dirLister, rel string, entries []os.DirEntry, depth int, cat *models.Categories, m ignore.Matcher, out *[]os.DirEntry) {
+	for _, e := range entries {
+		childRel := path.Join(rel, e.Name())
+		if e.Name() == ignore.FileName || m.Ignored(childRel, e.IsDir()) {
+			continue
+		}
+		switch {
+		case !e.IsDir() && rel == "":
+			*out = append(*out, e)
+		case !e.IsDir():
+			*out = append(*out, relativeEntry{DirEntry: e, rel: filepath.FromSlash(childRel)})
+		case !cat.Recursive:
+			*out = append(*out, e)
+		case cat.MaxDepth > 0 && depth >= cat.MaxDepth:
+			// too deep; leave the directory out entirely
+		default:
+			children, err := l.List(childRel)
+			if err != nil {
+				continue
+			}
+			walkEntries(l, childRel, children, depth+1, cat, m.With(childRel, l.IgnoreFile(childRel)), out)
+		}
+	}
+}
+
+// relativeEntry is a file found below a recursive source's root; Name()
+// returns its path relative to that root instead of its base name.
+type relativeEntry struct {
+	os.DirEntry
+	rel string
+}
+
+func (e relativeEntry) Name() string { return e.rel }
+
+// RelativeToSource returns file's path relative to the source directory
+// dir when file was drawn from it: directly inside dir, or anywhere below
+// it when recursive is true. ok is false otherwise.
+func RelativeToSource(dir, file string, recursive bool) (string, bool) {
+	if file == "" {
+		return "", false
+	}
+	if !recursive {
+		if filepath.Dir(file) != dir {
+			return "", false
+		}
+		return filepath.Base(file), true
+	}
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
 // GetEnabledCategories returns a list of enabled categories from the list of categories.
 func GetEnabledCategories(categories []*models.Categories) []*models.Categories {
 	var enabledCategories []*models.Categories
@@ -70,62 +218,314 @@ func GetRandomCategory(categories []*models.Categories) *models.Categories {
 }
 
 // GetRandomFile returns a random image file from the list of entries.
-// Directories and files with unsupported extensions are excluded. filter may
-// be nil to impose no additional constraint beyond the extension check.
-// exclude, when non-empty, is skipped as long as at least one other
-// candidate remains — used to avoid picking the same file as the current
-// wallpaper again.
+// Directories and files without a supported extension are excluded (there
+// is no directory to sniff the content in). filter may be nil to impose no
+// additional constraint beyond the extension check; it is matched against
+// each file's base name, even for entries from a recursive scan whose
+// Name() is a relative path. exclude, when non-empty, is skipped as long
+// as at least one other candidate remains — used to avoid picking the same
+// file as the current wallpaper again; it is compared against Name(), so
+// pass a relative path for recursive scans.
 func GetRandomFile(files []os.DirEntry, filter *filters.Compiled, exclude string) (string, error) {
+	imageFiles := eligibleFiles(files, "", PickOptions{Filter: filter, Detection: imagetype.DetectExtension})
+	if len(imageFiles) == 0 {
+		return "", errNoImages
+	}
+
+	if exclude != "" && len(imageFiles) > 1 {
+		filtered := imageFiles[:0]
+		for _, f := range imageFiles {
+			if f.Name() != exclude {
+				filtered = append(filtered, f)
+			}
+		}
+		imageFiles = filtered
+	}
+
+	randomIndex := rand.Intn(len(imageFiles)) // #nosec G404 -- non-security random selection
+	return imageFiles[randomIndex].Name(), nil
+}
+
+// errNoImages is returned when no file survives the image-type and filter
+// checks.
+var errNoImages = errors.New("no supported image files found in the directory (" + strings.Join(imagetype.Extensions(), ", ") + ") matching the configured filter")
+
+// eligibleFiles returns the entries of directory dir that may be picked
+// under opts: image files (see imagetype.Eligible for detection) whose tags
+// match opts.Tags and that pass opts.Filter, with image metadata read
+// through opts.Meta.
+func eligibleFiles(files []os.DirEntry, dir string, opts PickOptions) []os.DirEntry {
 	imageFiles := make([]os.DirEntry, 0, len(files))
 	for _, f := range files {
 		if f.IsDir() {
 			continue
 		}
-		ext := strings.ToLower(filepath.Ext(f.Name()))
-		if _, ok := imageExtensions[ext]; !ok {
+		path := filepath.Join(dir, f.Name())
+		if !imagetype.Eligible(path, opts.Detection) {
+			continue
+		}
+		if opts.TagDB.Has(path, tags.Banned) {
 			continue
 		}
-		if filter != nil {
-			var info os.FileInfo
-			if filter.NeedsFileInfo() {
-				fi, err := f.Info()
-				if err != nil {
-					continue
-				}
-				info = fi
+		if opts.Tags != nil && !opts.Tags.Matches(opts.TagDB.Tags(path)) {
+			continue
+		}
+		if filter := opts.Filter; filter != nil {
+			var meta filters.Metadata
+			if filter.NeedsMetadata() {
+				meta = &fileMeta{entry: f, path: path, cache: opts.Meta}
 			}
-			if !filter.Matches(f.Name(), info) {
+			if !filter.Matches(filepath.Base(f.Name()), meta) {
 				continue
 			}
 		}
 		imageFiles = append(imageFiles, f)
 	}
+	return imageFiles
+}
 
-	if len(imageFiles) == 0 {
-		return "", fmt.Errorf("no supported image files found in the directory (.jpg, .jpeg, .png, .webp) matching the configured filter")
+// fileMeta is the filters.Metadata of the candidate entry at path, looked up
+// on first use.
+type fileMeta struct {
+	entry os.DirEntry
+	path  string
+	cache *metacache.Cache
+	info  os.FileInfo
+}
+
+func (m *fileMeta) Info() (os.FileInfo, error) {
+	if m.info == nil {
+		fi, err := m.entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		m.info = fi
 	}
+	return m.info, nil
+}
 
-	if exclude != "" && len(imageFiles) > 1 {
-		filtered := imageFiles[:0]
-		for _, f := range imageFiles {
-			if f.Name() != exclude {
-				filtered = append(filtered, f)
+func (m *fileMeta) Dimensions() (int, int, error) {
+	info, err := m.Info()
+	if err != nil {
+		return 0, 0, err
+	}
+	return m.cache.Dimensions(m.path, info)
+}
+
+func (m *fileMeta) Exif() (*exif.Data, error) {
+	info, err := m.Info()
+	if err != nil {
+		return nil, err
+	}
+	return m.cache.Exif(m.path, info)
+}
+
+func (m *fileMeta) Colors() (float64, color.RGBA, error) {
+	info, err := m.Info()
+	if err != nil {
+		return 0, color.RGBA{}, err
+	}
+	c, err := m.cache.Colors(m.path, info)
+	if err != nil {
+		return 0, color.RGBA{}, err
+	}
+	dominant, err := filters.ParseColor(c.Dominant)
+	if err != nil {
+		return 0, color.RGBA{}, err
+	}
+	return c.Brightness, dominant, nil
+}
+
+// PickOptions tunes GetRandomFileFromSources. The zero value picks among
+// every file with an image extension, reading the directories directly.
+type PickOptions struct {
+	Filter    *filters.Compiled // nil = no constraint beyond the image-type check
+	Previous  string            // current wallpaper's path, skipped while another candidate remains
+	Index     *index.Store      // nil = list the directories directly
+	Detection string            // imagetype.DetectExtension (default) or imagetype.DetectSniff
+	Meta      *metacache.Cache  // caches image metadata for filters; nil = read it every time
+	Tags      *tags.Query       // nil = no tag constraint; else only files of TagDB matching it
+	TagDB     *tags.DB          // nil = no tags; files tagged banned are never eligible
+	FavWeight int               // favorites' weight multiplier; 0 or 1 = no boost
+	Similar   *imghash.Options  // non-nil: also skip near-duplicates of Previous
+}
+
+// GetRandomFileFromSources picks a random eligible image across dirs, the
+// resolved (tilde-expanded) source directories of cat, and returns its full
+// path. Directories are listed through opts.Index's file indexes (see
+// ReadCategoryFilesIndexed). Every eligible file is a candidate, its chance
+// scaled by its directory's weight and, for a favorite, by opts.FavWeight.
+// A directory that cannot be read is left out of the draw; it is only an
+// error when none of them can be read. With no dirs and a tag query in opts
+// (a tags-only category), the candidates are the tagged files matching it
+// instead, wherever they are. With opts.Similar, near-duplicates of
+// opts.Previous are skipped too. Metadata read for the filter or the
+// near-duplicate check is saved to opts.Meta on a best-effort basis.
+func GetRandomFileFromSources(cat *models.Categories, dirs []models.SourceDir, opts PickOptions) (string, error) {
+	type candidate struct {
+		path   string
+		weight int
+	}
+	var (
+		candidates []candidate
+		readErr    error
+		readable   int
+	)
+	for _, d := range dirs {
+		entries, err := ReadCategoryFilesIndexed(cat, d.Path, opts.Index)
+		if err != nil {
+			if readErr == nil {
+				readErr = fmt.Errorf("error reading directory %s: %w", d.Path, err)
 			}
+			continue
+		}
+		readable++
+		for _, f := range eligibleFiles(entries, d.Path, opts) {
+			path := filepath.Join(d.Path, f.Name())
+			candidates = append(candidates, candidate{path: path, weight: d.EffectiveWeight() * favWeight(path, opts)})
+		}
+	}
+	if len(dirs) == 0 && opts.Tags != nil {
+		for _, path := range taggedFiles(opts) {
+			candidates = append(candidates, candidate{path: path, weight: favWeight(path, opts)})
 		}
-		imageFiles = filtered
+	}
+	defer func() { _ = opts.Meta.Save() }()
+	if readable == 0 && readErr != nil {
+		return "", readErr
+	}
+	if len(candidates) == 0 {
+		return "", errNoImages
 	}
 
-	randomIndex := rand.Intn(len(imageFiles)) // #nosec G404 -- non-security random selection
-	return imageFiles[randomIndex].Name(), nil
+	if opts.Previous != "" && len(candidates) > 1 {
+		filtered := candidates[:0]
+		for _, c := range candidates {
+			if c.path != opts.Previous {
+				filtered = append(filtered, c)
+			}
+		}
+		candidates = filtered
+	}
+
+	// Near-duplicates of the current wallpaper count as the same image,
+	// unless nothing else is left. Files that can't be hashed stay in.
+	if prev, ok := hashOf(opts.Previous, opts.Meta); ok && opts.Similar != nil && len(candidates) > 1 {
+		distinct := make([]candidate, 0, len(candidates))
+		for _, c := range candidates {
+			if h, ok := hashOf(c.path, opts.Meta); ok && opts.Similar.Similar(prev, h) {
+				continue
+			}
+			distinct = append(distinct, c)
+		}
+		if len(distinct) > 0 {
+			candidates = distinct
+		}
+	}
+
+	total := 0
+	for _, c := range candidates {
+		total += c.weight
+	}
+	pick := rand.Intn(total) // #nosec G404 -- non-security random selection
+	for _, c := range candidates {
+		if pick < c.weight {
+			return c.path, nil
+		}
+		pick -= c.weight
+	}
+	return candidates[len(candidates)-1].path, nil
+}
+
+// hashOf returns the perceptual hashes of the image at path, through
+// cache; ok is false when path is empty or the image can't be hashed.
+func hashOf(path string, cache *metacache.Cache) (imghash.Hashes, bool) {
+	if path == "" {
+		return imghash.Hashes{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return imghash.Hashes{}, false
+	}
+	h, err := cache.Hashes(path, info)
+	return h, err == nil
+}
+
+// EligibleFiles returns the full paths of the images in dir, a source
+// directory of cat, that are candidates under opts (see
+// GetRandomFileFromSources), without picking one.
+func EligibleFiles(cat *models.Categories, dir string, opts PickOptions) ([]string, error) {
+	entries, err := ReadCategoryFilesIndexed(cat, dir, opts.Index)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory %s: %w", dir, err)
+	}
+	var paths []string
+	for _, f := range eligibleFiles(entries, dir, opts) {
+		paths = append(paths, filepath.Join(dir, f.Name()))
+	}
+	return paths, nil
+}
+
+// favWeight returns the weight multiplier of the file at path: opts.FavWeight
+// for a favorite, else 1.
+func favWeight(path string, opts PickOptions) int {
+	if opts.FavWeight > 1 && opts.TagDB.Has(path, tags.Favorite) {
+		return opts.FavWeight
+	}
+	return 1
+}
+
+// taggedFiles returns the files of opts.TagDB that are eligible under opts,
+// skipping those that no longer exist.
+func taggedFiles(opts PickOptions) []string {
+	var out []string
+	for _, path := range opts.TagDB.Files() {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if len(eligibleFiles([]os.DirEntry{fs.FileInfoToDirEntry(info)}, filepath.Dir(path), opts)) == 1 {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// FromAnySource reports whether file was drawn from one of dirs (see
+// RelativeToSource).
+func FromAnySource(dirs []models.SourceDir, file string, recursive bool) bool {
+	for _, d := range dirs {
+		if _, ok := RelativeToSource(d.Path, file, recursive); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // ResolveSource returns the source directory a category should use at time
-// now, given the current weather snapshot ws (nil when weather is
+// now; for categories with several directories (sources) it returns the
+// first one, and for a tags-only category "" (it has none). See
+// ResolveSources for the parameters and the full list.
+func ResolveSource(cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDir string) (string, bool) {
+	dirs, ok := ResolveSources(cat, now, ws, conditions, wallhavenDir)
+	if !ok || len(dirs) == 0 {
+		return "", ok
+	}
+	return dirs[0].Path, true
+}
+
+// ResolveSources returns the source directories a category should use at
+// time now, given the current weather snapshot ws (nil when weather is
 // unavailable or not configured), the named conditions declared in
 // configuration.conditions, and wallhavenDir, the pre-resolved cache
 // directory for this category when it has a wallhaven source ("" otherwise).
-// Plain categories return their source directly; wallhaven categories
-// return their cache directory.
+// Plain categories return their source (or sources) directly; wallhaven
+// categories return their cache directory. A category defined only by a
+// tag query has no directories: it is always active, and draws from the
+// tag database (see GetRandomFileFromSources). Collage and calendar
+// categories have none either and are always active: their images come
+// from the categories they name. Neither has one where a generator is in
+// effect (see ResolveGenerator).
 //
 // For a category with variants, every variant whose condition currently
 // holds is a candidate; the candidate with the highest priority wins
@@ -133,14 +533,49 @@ func GetRandomFile(files []os.DirEntry, filter *filters.Compiled, exclude string
 // comes from its named condition's priority (0 if unset); a variant using
 // inline hours has priority 0. ok is false when no variant's condition
 // holds, meaning the category is ineligible for this run.
-func ResolveSource(cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDir string) (string, bool) {
+func ResolveSources(cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition, wallhavenDir string) ([]models.SourceDir, bool) {
 	if cat.Wallhaven != nil {
-		return wallhavenDir, wallhavenDir != ""
+		return []models.SourceDir{{Path: wallhavenDir}}, wallhavenDir != ""
+	}
+	if cat.Collage != nil || cat.Calendar != nil || cat.Generate != nil {
+		return nil, true
 	}
 	if len(cat.Variants) == 0 {
-		return cat.Source, true
+		dirs := categoryBases(cat)
+		return dirs, len(dirs) > 0 || cat.Tags != ""
 	}
 
+	v, ok := activeVariant(cat, now, ws, conditions)
+	if !ok {
+		return nil, false
+	}
+	if v.Generate != nil {
+		return nil, true
+	}
+	return resolveVariantSources(cat, v)
+}
+
+// ResolveGenerator returns the generator a category should draw at time
+// now: its own, or the active variant's. ok is false when the category
+// picks files instead (or no variant is active). The parameters are as for
+// ResolveSources.
+func ResolveGenerator(cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition) (*models.Generate, bool) {
+	if cat.Generate != nil {
+		return cat.Generate, true
+	}
+	if len(cat.Variants) == 0 {
+		return nil, false
+	}
+	v, ok := activeVariant(cat, now, ws, conditions)
+	if !ok || v.Generate == nil {
+		return nil, false
+	}
+	return v.Generate, true
+}
+
+// activeVariant returns the variant of cat in effect at time now: among the
+// ones whose condition holds, the highest priority, ties broken by position.
+func activeVariant(cat *models.Categories, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition) (models.Variant, bool) {
 	bestIdx := -1
 	bestPriority := 0
 	for i, v := range cat.Variants {
@@ -154,9 +589,49 @@ func ResolveSource(cat *models.Categories, now time.Time, ws *weather.Snapshot,
 		}
 	}
 	if bestIdx == -1 {
-		return "", false
+		return models.Variant{}, false
+	}
+	return cat.Variants[bestIdx], true
+}
+
+// AllSourceDirs returns every local directory cat can draw from, whatever
+// the time or weather: its source(s) and each variant's, without
+// duplicates. Wallhaven categories have none here; their cache directory
+// is resolved from configuration.
+func AllSourceDirs(cat *models.Categories) []models.SourceDir {
+	if cat.Wallhaven != nil {
+		return nil
+	}
+	candidates := categoryBases(cat)
+	if len(cat.Variants) > 0 {
+		candidates = nil
+		for _, v := range cat.Variants {
+			dirs, _ := resolveVariantSources(cat, v)
+			candidates = append(candidates, dirs...)
+		}
+	}
+
+	var out []models.SourceDir
+	seen := map[string]bool{}
+	for _, d := range candidates {
+		if !seen[d.Path] {
+			seen[d.Path] = true
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// categoryBases returns the category's own directories: its source, or
+// each of its sources.
+func categoryBases(cat *models.Categories) []models.SourceDir {
+	if len(cat.Sources) > 0 {
+		return cat.Sources
+	}
+	if cat.Source == "" {
+		return nil
 	}
-	return resolveVariantSource(cat, cat.Variants[bestIdx])
+	return []models.SourceDir{{Path: cat.Source}}
 }
 
 // variantHolds reports whether v's condition currently holds, and the
@@ -179,6 +654,13 @@ func variantHolds(v models.Variant, now time.Time, ws *weather.Snapshot, conditi
 	return false, 0
 }
 
+// NamedConditionHolds reports whether the condition called name in
+// conditions holds at now; an undefined name never does.
+func NamedConditionHolds(name string, now time.Time, ws *weather.Snapshot, conditions map[string]models.Condition) bool {
+	cond, ok := conditions[name]
+	return ok && conditionHolds(cond, now, ws)
+}
+
 // conditionHolds evaluates a single named condition. A condition holds via
 // exactly one of: hours, date-range, or the weather bucket (validation
 // enforces this is not mixed); weather-bucket conditions never hold when
@@ -209,13 +691,9 @@ func conditionHolds(cond models.Condition, now time.Time, ws *weather.Snapshot)
 		return false
 	}
 	if len(cond.Weather) > 0 {
-		sky, ok := ws.Sky()
-		if !ok {
-			return false
-		}
 		matched := false
 		for _, name := range cond.Weather {
-			if string(sky) == name {
+			if ws.InBucket(name) {
 				matched = true
 				break
 			}
@@ -239,34 +717,55 @@ func conditionHolds(cond models.Condition, now time.Time, ws *weather.Snapshot)
 	return true
 }
 
-// resolveVariantSource returns the directory a variant's images live in.
-// An absolute source is used as-is; a relative one is resolved against the
-// category's source (required in that case — validation enforces this).
-func resolveVariantSource(cat *models.Categories, v models.Variant) (string, bool) {
-	if filepath.IsAbs(v.Source) {
-		return v.Source, true
-	}
-	if cat.Source == "" {
-		return "", false
+// resolveVariantSources returns the directories a variant's images live
+// in. An absolute source is used as-is; a relative one is resolved against
+// the category's source, or against each of the category's sources with
+// the two weights multiplied (a base is required in that case — validation
+// enforces this).
+func resolveVariantSources(cat *models.Categories, v models.Variant) ([]models.SourceDir, bool) {
+	entries := v.Sources
+	if len(entries) == 0 {
+		entries = []models.SourceDir{{Path: v.Source}}
+	}
+	bases := categoryBases(cat)
+
+	var dirs []models.SourceDir
+	for _, e := range entries {
+		if e.Path == "" {
+			continue
+		}
+		if filepath.IsAbs(e.Path) {
+			dirs = append(dirs, e)
+			continue
+		}
+		for _, b := range bases {
+			dirs = append(dirs, models.SourceDir{
+				Path:   filepath.Join(b.Path, e.Path),
+				Weight: b.EffectiveWeight() * e.EffectiveWeight(),
+			})
+		}
 	}
-	return filepath.Join(cat.Source, v.Source), true
+	return dirs, len(dirs) > 0
 }
 
 // SetWallpaperFromFile sets the wallpaper from the specified file.
-func SetWallpaperFromFile(source, file string, fade bool) error {
-	return SetWallpaperFromPath(filepath.Join(source, file), fade)
+func SetWallpaperFromFile(source, file, mode string, fade bool) error {
+	return SetWallpaperFromPath(filepath.Join(source, file), mode, fade)
 }
 
-// SetWallpaperFromPath sets the wallpaper from a pre-built absolute path.
-// When fade is true it tries the native Windows crossfade transition first,
-// falling back to the instant swap if the fade path fails for any reason.
-func SetWallpaperFromPath(fullPath string, fade bool) error {
-	if fade {
+// SetWallpaperFromPath sets the wallpaper from a pre-built absolute path,
+// in mode for the backends that take it along with the image; call
+// SetWallpaperMode(mode) afterwards for the others.
+// When fade is true and no other backend was selected, it tries the native
+// Windows crossfade transition first, falling back to the instant swap if
+// the fade path fails for any reason.
+func SetWallpaperFromPath(fullPath, mode string, fade bool) error {
+	if _, ok := setter.(native); ok && fade {
 		if err := setWallpaperFade(fullPath); err == nil {
 			return nil
 		}
 	}
-	if err := wallpaper.SetFromFile(fullPath); err != nil {
+	if err := setter.Set(fullPath, mode); err != nil {
 		return fmt.Errorf("error setting wallpaper: %v", err)
 	}
 	return nil
@@ -279,20 +778,26 @@ type MonitorTarget struct {
 	Path       string
 }
 
-// ListMonitors returns the connected monitors' device paths in Windows
-// enumeration order (index 0 is "monitor 1" in the configuration). It
-// errors on non-Windows platforms and when the enumeration API is
-// unavailable; callers should fall back to the single-wallpaper flow.
+// ListMonitors returns the connected monitors' device paths in enumeration
+// order (index 0 is "monitor 1" in the configuration): Windows' order, or
+// elsewhere the order swaymsg, wlr-randr or xrandr lists the outputs in,
+// whose names are the device paths. The file backend's configured monitors
+// replace the connected ones, with their output paths as device paths. It
+// errors when no enumeration is available; callers should fall back to the
+// single-wallpaper flow.
 func ListMonitors() ([]string, error) {
+	if f, ok := setter.(*file); ok && f.PerMonitor() {
+		return f.out.monitorPaths(), nil
+	}
 	return monitorDevicePaths()
 }
 
 // MonitorDetail describes one connected monitor for the `gopaper monitors`
 // command: its 1-based index (as used in configuration.behavior.monitor /
 // categories[].monitor), device path, desktop-coordinate bounding rectangle
-// as arranged in Windows Display Settings (left/top is the top-left corner;
-// the primary monitor sits at 0,0), and its EDID-reported name when that
-// could be resolved ("" otherwise).
+// as arranged in the display settings (left/top is the top-left corner; on
+// Wayland, in logical pixels), and its EDID-reported name when that could be
+// resolved ("" otherwise).
 type MonitorDetail struct {
 	Index                    int
 	DevicePath               string
@@ -300,15 +805,32 @@ type MonitorDetail struct {
 	Name                     string
 }
 
+// Rect returns the monitor's bounding rectangle in desktop coordinates.
+func (d MonitorDetail) Rect() image.Rectangle {
+	return image.Rect(d.Left, d.Top, d.Right, d.Bottom)
+}
+
+// PerMonitorSupported reports whether SetWallpapersPerMonitor can give each
+// monitor its own wallpaper with the selected backend. Where it can't,
+// callers compose the per-monitor picks into one image spanned across the
+// desktop instead.
+func PerMonitorSupported() bool {
+	return setter.PerMonitor()
+}
+
 // ListMonitorDetails returns MonitorDetail for every connected monitor, in
-// the same order as ListMonitors. It errors on non-Windows platforms and
-// when the enumeration API is unavailable.
+// the same order as ListMonitors. It errors when no enumeration is
+// available.
 //
-// Name is resolved best-effort from the monitor's EDID data (via WMI) and
+// Name is resolved best-effort from the monitor's EDID data (via WMI on
+// Windows, xrandr --verbose or the compositor's make/model elsewhere) and
 // left empty if that lookup fails or a given monitor can't be matched — a
 // missing name never fails the call, since position/device path alone are
 // already useful.
 func ListMonitorDetails() ([]MonitorDetail, error) {
+	if f, ok := setter.(*file); ok && f.PerMonitor() {
+		return f.monitorDetails(), nil
+	}
 	details, err := monitorDetails()
 	if err != nil {
 		return nil, err
@@ -346,48 +868,22 @@ func matchMonitorName(devicePath string, names map[string]string) string {
 	return ""
 }
 
-// SetWallpapersPerMonitor applies each target's Path to its DevicePath.
+// SetWallpapersPerMonitor applies each target's Path to its DevicePath, in
+// mode as SetWallpaperFromPath does.
 // This is always an instant swap — the native crossfade cannot target
-// monitors individually. Every target is attempted even if one fails; the
-// first error is returned.
-func SetWallpapersPerMonitor(targets []MonitorTarget) error {
-	var firstErr error
-	for _, t := range targets {
-		if err := setWallpaperOnMonitor(t.DevicePath, t.Path); err != nil && firstErr == nil {
-			firstErr = fmt.Errorf("could not set wallpaper on monitor %q: %w", t.DevicePath, err)
-		}
-	}
-	return firstErr
+// monitors individually. With the native backend every target is attempted
+// even if one fails; the first error is returned.
+func SetWallpapersPerMonitor(targets []MonitorTarget, mode string) error {
+	return setter.SetMonitors(targets, mode)
 }
 
 // GetPreviousWallpaper returns the path of the previous wallpaper.
 func GetPreviousWallpaper() (string, error) {
-	return wallpaper.Get()
+	return setter.Get()
 }
 
-// SetWallpaperMode sets the wallpaper mode based on the user's preference.
-// On Windows this applies the mode via IDesktopWallpaper directly, which
-// avoids the legacy registry write + reapply that would otherwise stomp on
-// SetWallpaperFromPath's fade transition; other platforms fall back to the
-// standard behavior.
+// SetWallpaperMode sets the wallpaper mode based on the user's preference,
+// through the selected backend.
 func SetWallpaperMode(mode string) error {
-	if err := setWallpaperPosition(mode); err == nil {
-		return nil
-	}
-	switch mode {
-	case "center":
-		return wallpaper.SetMode(wallpaper.Center)
-	case "fit":
-		return wallpaper.SetMode(wallpaper.Fit)
-	case "span":
-		return wallpaper.SetMode(wallpaper.Span)
-	case "stretch":
-		return wallpaper.SetMode(wallpaper.Stretch)
-	case "tile":
-		return wallpaper.SetMode(wallpaper.Tile)
-	case "crop":
-		fallthrough
-	default:
-		return wallpaper.SetMode(wallpaper.Crop)
-	}
+	return setter.SetMode(mode)
 }
@@ -1,12 +1,23 @@
 package helper
 
 import (
+	"bytes"
+	"image"
+	"image/png"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/lucasassuncao/gopaper/internal/filters"
+	"github.com/lucasassuncao/gopaper/internal/imghash"
+	"github.com/lucasassuncao/gopaper/internal/index"
+	"github.com/lucasassuncao/gopaper/internal/metacache"
 	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/tags"
 )
 
 // mockDirEntry implements os.DirEntry for testing purposes.
@@ -151,7 +162,7 @@ func TestGetRandomFile_OnlyUnsupportedExtensions(t *testing.T) {
 	entries := []os.DirEntry{
 		mockDirEntry{name: "readme.txt", isDir: false},
 		mockDirEntry{name: "data.json", isDir: false},
-		mockDirEntry{name: "image.bmp", isDir: false},
+		mockDirEntry{name: "image.psd", isDir: false},
 	}
 	_, err := GetRandomFile(entries, nil, "")
 	if err == nil {
@@ -275,3 +286,408 @@ func TestGetRandomFile_FilterExcludingEverythingReturnsError(t *testing.T) {
 		t.Error("expected error when the filter excludes every candidate, got nil")
 	}
 }
+
+func TestGetRandomFile_FilterMatchesBaseNameOfRelativeEntries(t *testing.T) {
+	entries := []os.DirEntry{
+		mockDirEntry{name: filepath.Join("2024", "screenshot_01.png")},
+		mockDirEntry{name: filepath.Join("screenshot_dir", "wallpaper.png")},
+	}
+	filter, err := filters.Compile(&models.Filter{Match: &models.MatchFilter{Glob: "screenshot_*"}})
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+
+	for range 20 {
+		name, err := GetRandomFile(entries, filter, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if name != filepath.Join("2024", "screenshot_01.png") {
+			t.Errorf("expected the filter to match base names only, got %s", name)
+		}
+	}
+}
+
+// --- ReadCategoryFiles ---
+
+// writeTree creates each slash-separated path under root, with parent
+// directories as needed.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func entryNames(entries []os.DirEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, filepath.ToSlash(e.Name()))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestReadCategoryFiles_FlatKeepsDirectEntries(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.jpg":          "",
+		"skip.png":       "",
+		"sub/b.jpg":      "",
+		".gopaperignore": "skip.png\n",
+	})
+
+	entries, err := ReadCategoryFiles(&models.Categories{}, root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := entryNames(entries), []string{"a.jpg", "sub"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadCategoryFiles_RecursiveHonorsIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.jpg":                    "",
+		"2024/05/beach.jpg":        "",
+		"2024/05/beach.tmp.jpg":    "",
+		"drafts/wip.jpg":           "",
+		"keep/x.png":               "",
+		"keep/.gopaperignore":      "*.png\n",
+		".gopaperignore":           "drafts/\n*.tmp.jpg\n",
+		"keep/nested/y.png":        "",
+		"keep/nested/z.jpg":        "",
+		"keep/nested/deeper/w.jpg": "",
+	})
+
+	entries, err := ReadCategoryFiles(&models.Categories{Recursive: true}, root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"2024/05/beach.jpg", "a.jpg", "keep/nested/deeper/w.jpg", "keep/nested/z.jpg"}
+	if got := entryNames(entries); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadCategoryFiles_MaxDepth(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.jpg":     "",
+		"1/b.jpg":   "",
+		"1/2/c.jpg": "",
+	})
+
+	entries, err := ReadCategoryFiles(&models.Categories{Recursive: true, MaxDepth: 1}, root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := entryNames(entries), []string{"1/b.jpg", "a.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadCategoryFiles_MissingRootIsAnError(t *testing.T) {
+	if _, err := ReadCategoryFiles(&models.Categories{Recursive: true}, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for a missing source directory, got nil")
+	}
+}
+
+// --- RelativeToSource ---
+
+func TestRelativeToSource(t *testing.T) {
+	dir := filepath.FromSlash("/walls/nature")
+	tests := []struct {
+		file      string
+		recursive bool
+		want      string
+		ok        bool
+	}{
+		{"", false, "", false},
+		{filepath.FromSlash("/walls/nature/a.jpg"), false, "a.jpg", true},
+		{filepath.FromSlash("/walls/nature/2024/a.jpg"), false, "", false},
+		{filepath.FromSlash("/walls/nature/2024/a.jpg"), true, filepath.FromSlash("2024/a.jpg"), true},
+		{filepath.FromSlash("/walls/nature-old/a.jpg"), true, "", false},
+		{filepath.FromSlash("/walls/city/a.jpg"), true, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := RelativeToSource(dir, tt.file, tt.recursive)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("RelativeToSource(%q, %q, %v) = %q, %v; want %q, %v", dir, tt.file, tt.recursive, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// --- GetRandomFileFromSources ---
+
+func TestGetRandomFileFromSources_UnionOfDirectories(t *testing.T) {
+	nas, local := t.TempDir(), t.TempDir()
+	writeTree(t, nas, map[string]string{"a.jpg": ""})
+	writeTree(t, local, map[string]string{"b.jpg": "", "notes.txt": ""})
+	dirs := []models.SourceDir{{Path: nas}, {Path: local}}
+
+	seen := map[string]bool{}
+	for range 100 {
+		p, err := GetRandomFileFromSources(&models.Categories{}, dirs, PickOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		seen[p] = true
+	}
+	want := map[string]bool{filepath.Join(nas, "a.jpg"): true, filepath.Join(local, "b.jpg"): true}
+	if len(seen) != len(want) {
+		t.Fatalf("picked %v, want both %v", seen, want)
+	}
+	for p := range seen {
+		if !want[p] {
+			t.Errorf("unexpected pick %s", p)
+		}
+	}
+}
+
+func TestGetRandomFileFromSources_ExcludesPrevious(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	writeTree(t, a, map[string]string{"x.jpg": ""})
+	writeTree(t, b, map[string]string{"x.jpg": ""})
+	previous := filepath.Join(a, "x.jpg")
+
+	for range 20 {
+		p, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: a}, {Path: b}}, PickOptions{Previous: previous})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p == previous {
+			t.Fatalf("picked the previous wallpaper %s", p)
+		}
+	}
+}
+
+func TestGetRandomFileFromSources_TagQuery(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	writeTree(t, a, map[string]string{"leaves.jpg": "", "family.jpg": "", "plain.jpg": ""})
+	writeTree(t, b, map[string]string{"snow.jpg": ""})
+	db, err := tags.Open(filepath.Join(t.TempDir(), "tags.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Add(filepath.Join(a, "leaves.jpg"), "autumn")
+	_, _ = db.Add(filepath.Join(a, "family.jpg"), "autumn", "people")
+	_, _ = db.Add(filepath.Join(b, "snow.jpg"), "winter")
+	_, _ = db.Add(filepath.Join(b, "deleted.jpg"), "autumn")
+	q, err := tags.ParseQuery("(autumn or winter) and not people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := PickOptions{TagDB: db, Tags: q}
+
+	// Without directories the tag database is the source.
+	seen := map[string]bool{}
+	for range 50 {
+		p, err := GetRandomFileFromSources(&models.Categories{}, nil, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		seen[p] = true
+	}
+	want := map[string]bool{filepath.Join(a, "leaves.jpg"): true, filepath.Join(b, "snow.jpg"): true}
+	if !maps.Equal(seen, want) {
+		t.Errorf("tags-only picks = %v, want %v", seen, want)
+	}
+
+	// With directories the query narrows their files.
+	for range 20 {
+		p, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: a}}, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p != filepath.Join(a, "leaves.jpg") {
+			t.Fatalf("picked %s, want only the matching file of the source", p)
+		}
+	}
+}
+
+func TestGetRandomFileFromSources_BansAndFavorites(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"fav.jpg": "", "plain.jpg": "", "banned.jpg": ""})
+	db, err := tags.Open(filepath.Join(t.TempDir(), "tags.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = db.Add(filepath.Join(dir, "fav.jpg"), tags.Favorite)
+	_, _ = db.Add(filepath.Join(dir, "banned.jpg"), tags.Banned)
+	opts := PickOptions{TagDB: db, FavWeight: 9}
+
+	counts := map[string]int{}
+	for range 1000 {
+		p, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: dir}}, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		counts[filepath.Base(p)]++
+	}
+	if counts["banned.jpg"] != 0 {
+		t.Errorf("picked the banned image %d times", counts["banned.jpg"])
+	}
+	// 9:1 odds; anything below 4:1 over 1000 draws means no boost.
+	if counts["fav.jpg"] < 4*counts["plain.jpg"] {
+		t.Errorf("favorite picked %d times vs %d, want it about 9 times as often", counts["fav.jpg"], counts["plain.jpg"])
+	}
+}
+
+func TestGetRandomFileFromSources_WeightSkewsDraw(t *testing.T) {
+	light, heavy := t.TempDir(), t.TempDir()
+	writeTree(t, light, map[string]string{"l.jpg": ""})
+	writeTree(t, heavy, map[string]string{"h.jpg": ""})
+	dirs := []models.SourceDir{{Path: light}, {Path: heavy, Weight: 9}}
+
+	heavyPicks := 0
+	for range 1000 {
+		p, err := GetRandomFileFromSources(&models.Categories{}, dirs, PickOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if filepath.Dir(p) == heavy {
+			heavyPicks++
+		}
+	}
+	// Expected ~900; the bound is loose enough never to flake.
+	if heavyPicks < 750 {
+		t.Errorf("weight 9 source picked %d/1000 times, want roughly 900", heavyPicks)
+	}
+}
+
+func TestGetRandomFileFromSources_UnreadableDirectory(t *testing.T) {
+	ok := t.TempDir()
+	writeTree(t, ok, map[string]string{"a.jpg": ""})
+	missing := filepath.Join(t.TempDir(), "offline-nas")
+
+	p, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: missing}, {Path: ok}}, PickOptions{})
+	if err != nil || p != filepath.Join(ok, "a.jpg") {
+		t.Errorf("got (%q, %v), want the readable directory's file", p, err)
+	}
+
+	if _, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: missing}}, PickOptions{}); err == nil {
+		t.Error("expected an error when no source can be read, got nil")
+	}
+}
+
+func TestReadCategoryFilesIndexed_MatchesDirectRead(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.jpg":               "",
+		"2024/b.jpg":          "",
+		"2024/skip.jpg":       "",
+		"2024/.gopaperignore": "skip.jpg\n",
+		"2024/05/c.jpg":       "",
+	})
+	store := &index.Store{Dir: t.TempDir()}
+
+	for _, cat := range []*models.Categories{{}, {Recursive: true}, {Recursive: true, MaxDepth: 1}} {
+		direct, err := ReadCategoryFiles(cat, root)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		indexed, err := ReadCategoryFilesIndexed(cat, root, store)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := entryNames(indexed), entryNames(direct); !slices.Equal(got, want) {
+			t.Errorf("recursive=%v max-depth=%d: indexed %v, direct %v", cat.Recursive, cat.MaxDepth, got, want)
+		}
+	}
+}
+
+func TestGetRandomFileFromSources_SniffDetection(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"IMG_0001": "\x89PNG\r\n\x1a\n0000000",
+		"notes":    "just some text",
+	})
+
+	if _, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: dir}}, PickOptions{}); err == nil {
+		t.Error("expected no candidates when detecting by extension only")
+	}
+	p, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: dir}}, PickOptions{Detection: "sniff"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := filepath.Join(dir, "IMG_0001"); p != want {
+		t.Errorf("got %q, want %q (the only file whose content is an image)", p, want)
+	}
+}
+
+func TestGetRandomFileFromSources_DimensionsFilter(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string][2]int{"wide.png": {64, 27}, "tall.png": {27, 64}} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, size[0], size[1]))); err != nil {
+			t.Fatal(err)
+		}
+		writeTree(t, dir, map[string]string{name: buf.String()})
+	}
+	filter, err := filters.Compile(&models.Filter{Dimensions: &models.DimensionsFilter{Orientation: "landscape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := metacache.Open(filepath.Join(t.TempDir(), "metadata.json"))
+
+	for range 10 {
+		p, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: dir}}, PickOptions{Filter: filter, Meta: cache})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if filepath.Base(p) != "wide.png" {
+			t.Fatalf("picked %q, want only the landscape image", p)
+		}
+	}
+}
+
+func TestGetRandomFileFromSources_SkipsNearDuplicatesOfPrevious(t *testing.T) {
+	// A horizontal gradient at two resolutions is one picture; a vertical
+	// one is another.
+	gradient := func(w, h int, vertical bool) string {
+		img := image.NewGray(image.Rect(0, 0, w, h))
+		for y := range h {
+			for x := range w {
+				v := x * 255 / w
+				if vertical {
+					v = y * 255 / h
+				}
+				img.Pix[y*img.Stride+x] = uint8(v)
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"big.png":   gradient(320, 200, false),
+		"small.png": gradient(160, 100, false),
+		"other.png": gradient(320, 200, true),
+	})
+	opts := PickOptions{
+		Previous: filepath.Join(dir, "big.png"),
+		Meta:     metacache.Open(filepath.Join(t.TempDir(), "metadata.json")),
+		Similar:  &imghash.Options{Algorithm: imghash.PHash, Threshold: 8},
+	}
+
+	for range 10 {
+		p, err := GetRandomFileFromSources(&models.Categories{}, []models.SourceDir{{Path: dir}}, opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if filepath.Base(p) != "other.png" {
+			t.Fatalf("picked %q, want the near-duplicate of the previous wallpaper skipped", p)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+//go:build !windows
+
+package helper
+
+import (
+	"errors"
+	"os"
+)
+
+// monitorSource enumerates the monitors with one tool, when the session it
+// talks to is running.
+type monitorSource struct {
+	env     string
+	details func() ([]MonitorDetail, error)
+}
+
+// monitorSources are tried in order: the Wayland ones first, since an
+// XWayland DISPLAY only sees the outputs through a compatibility layer.
+var monitorSources = []monitorSource{
+	{"SWAYSOCK", swayMonitors},
+	{"WAYLAND_DISPLAY", wlrMonitors},
+	{"DISPLAY", xrandrMonitors},
+}
+
+// monitorDetails returns the monitors of the first source whose session is
+// running and that can list them, in that tool's order.
+func monitorDetails() ([]MonitorDetail, error) {
+	var errs []error
+	for _, source := range monitorSources {
+		if os.Getenv(source.env) == "" {
+			continue
+		}
+		details, err := source.details()
+		if err == nil {
+			return details, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no graphical session to enumerate monitors in (none of SWAYSOCK, WAYLAND_DISPLAY or DISPLAY is set)")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// monitorDevicePaths returns the output names of monitorDetails, in order.
+func monitorDevicePaths() ([]string, error) {
+	details, err := monitorDetails()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(details))
+	for i, d := range details {
+		paths[i] = d.DevicePath
+	}
+	return paths, nil
+}
+
+// monitorNames has nothing to add: monitorDetails already names the
+// monitors from the same tool's output.
+func monitorNames() (map[string]string, error) {
+	return nil, errors.New("monitor names come with the monitor details")
+}
+
+func swayMonitors() ([]MonitorDetail, error) {
+	out, err := command("swaymsg", "-r", "-t", "get_outputs")
+	if err != nil {
+		return nil, err
+	}
+	return parseSwayOutputs([]byte(out))
+}
+
+func wlrMonitors() ([]MonitorDetail, error) {
+	out, err := command("wlr-randr", "--json")
+	if err != nil {
+		return nil, err
+	}
+	return parseWlrRandr([]byte(out))
+}
+
+// xrandrMonitors lists the monitors with xrandr --listmonitors and names
+// them from the EDID in xrandr --verbose, best-effort.
+func xrandrMonitors() ([]MonitorDetail, error) {
+	out, err := command("xrandr", "--listmonitors")
+	if err != nil {
+		return nil, err
+	}
+	details, err := parseXrandrMonitors(out)
+	if err != nil {
+		return nil, err
+	}
+	if verbose, err := command("xrandr", "--verbose"); err == nil {
+		names := parseXrandrNames(verbose)
+		for i := range details {
+			details[i].Name = names[details[i].DevicePath]
+		}
+	}
+	return details, nil
+}
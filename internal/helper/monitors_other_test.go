@@ -0,0 +1,75 @@
+//go:build !windows
+
+package helper
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMonitorDetails_Sway(t *testing.T) {
+	dir, calls := fakeTools(t, "swaymsg", "xrandr")
+	writeFile(t, filepath.Join(dir, "swaymsg.out"), string(readFixture(t, "swaymsg-outputs.json")))
+	t.Setenv("SWAYSOCK", "/run/user/1000/sway-ipc.sock")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+
+	got, err := ListMonitors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "eDP-1" || got[1] != "DP-1" {
+		t.Errorf("ListMonitors = %v", got)
+	}
+	assertCalls(t, calls(), []string{"swaymsg [-r] [-t] [get_outputs]"})
+}
+
+func TestMonitorDetails_Xrandr(t *testing.T) {
+	dir, calls := fakeTools(t, "xrandr")
+	// The fake prints the same file whatever the arguments: name the
+	// monitors from a verbose listing that fails.
+	writeFile(t, filepath.Join(dir, "xrandr.out"), string(readFixture(t, "xrandr-listmonitors.txt")))
+	writeFile(t, filepath.Join(dir, "xrandr.fail"), "--verbose")
+	writeFile(t, filepath.Join(dir, "xrandr.err"), "xrandr: RandR 1.2 required")
+	t.Setenv("SWAYSOCK", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+
+	got, err := ListMonitorDetails()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDetails(t, got, []MonitorDetail{
+		{Index: 1, DevicePath: "eDP-1", Left: 0, Top: 360, Right: 1920, Bottom: 1440},
+		{Index: 2, DevicePath: "DP-1", Left: 1920, Top: 0, Right: 4480, Bottom: 1440},
+	})
+	assertCalls(t, calls(), []string{"xrandr [--listmonitors]", "xrandr [--verbose]"})
+}
+
+func TestMonitorDetails_NoSession(t *testing.T) {
+	fakeTools(t)
+	t.Setenv("SWAYSOCK", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", "")
+	if _, err := ListMonitorDetails(); err == nil {
+		t.Error("expected an error without a graphical session")
+	}
+}
+
+func TestMonitorDetails_FallsBack(t *testing.T) {
+	dir, _ := fakeTools(t, "wlr-randr", "xrandr")
+	writeFile(t, filepath.Join(dir, "wlr-randr.fail"), "--json")
+	writeFile(t, filepath.Join(dir, "wlr-randr.err"), "compositor doesn't support wlr-output-management")
+	writeFile(t, filepath.Join(dir, "xrandr.out"), string(readFixture(t, "xrandr-listmonitors.txt")))
+	t.Setenv("SWAYSOCK", "")
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	t.Setenv("DISPLAY", ":0")
+
+	got, err := monitorDevicePaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1] != "DP-1" {
+		t.Errorf("monitorDevicePaths = %v", got)
+	}
+}
@@ -0,0 +1,238 @@
+package helper
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Parsers for the tools monitors are enumerated with outside Windows. Each
+// returns the enabled monitors in the tool's own order, indexed from 1, with
+// DevicePath the output's name (e.g. "DP-1") — what the desktop backends
+// address monitors by. Positions and sizes are in desktop coordinates: on
+// Wayland those are logical pixels, the mode's size divided by the output's
+// scale, so that monitors of different scales still line up.
+
+// xrandrMonitor matches a line of xrandr --listmonitors:
+//
+//	0: +*eDP-1 1920/344x1080/194+0+360  eDP-1
+var xrandrMonitor = regexp.MustCompile(`^\s*(\d+):\s+\+?\*?(\S+)\s+(\d+)/\d+x(\d+)/\d+\+(-?\d+)\+(-?\d+)(?:\s+(.*))?$`)
+
+// parseXrandrMonitors parses xrandr --listmonitors.
+func parseXrandrMonitors(out string) ([]MonitorDetail, error) {
+	var details []MonitorDetail
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		m := xrandrMonitor.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		w, _ := strconv.Atoi(m[3])
+		h, _ := strconv.Atoi(m[4])
+		x, _ := strconv.Atoi(m[5])
+		y, _ := strconv.Atoi(m[6])
+		// A monitor is named after its output, unless it was defined by
+		// hand (xrandr --setmonitor) over one or several outputs.
+		name := m[2]
+		if outputs := strings.Fields(m[7]); len(outputs) == 1 {
+			name = outputs[0]
+		}
+		details = append(details, MonitorDetail{Index: len(details) + 1, DevicePath: name, Left: x, Top: y, Right: x + w, Bottom: y + h})
+	}
+	if len(details) == 0 {
+		return nil, errors.New("xrandr lists no monitors")
+	}
+	return details, nil
+}
+
+// parseXrandrNames returns the EDID name of every connected output in
+// xrandr --verbose, by output name. Outputs without EDID are left out.
+func parseXrandrNames(out string) map[string]string {
+	names := map[string]string{}
+	var (
+		output string
+		edid   strings.Builder
+		inEDID bool
+	)
+	flush := func() {
+		if output != "" && edid.Len() > 0 {
+			if data, err := hex.DecodeString(edid.String()); err == nil {
+				if name := edidName(data); name != "" {
+					names[output] = name
+				}
+			}
+		}
+		edid.Reset()
+		inEDID = false
+	}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line != "" && line[0] != ' ' && line[0] != '\t':
+			flush()
+			output = ""
+			if fields := strings.Fields(line); len(fields) > 1 && fields[1] == "connected" {
+				output = fields[0]
+			}
+		case strings.TrimSpace(line) == "EDID:":
+			flush()
+			inEDID = true
+		case inEDID:
+			chunk := strings.TrimSpace(line)
+			if _, err := hex.DecodeString(chunk); err != nil || !strings.HasPrefix(line, "\t\t") {
+				flush()
+				continue
+			}
+			edid.WriteString(chunk)
+		}
+	}
+	flush()
+	return names
+}
+
+// edidName returns the monitor name an EDID block sets in its display
+// descriptors, or its three-letter manufacturer code when it sets none
+// (common for laptop panels), as Windows reports them.
+func edidName(edid []byte) string {
+	if len(edid) < 128 {
+		return ""
+	}
+	for off := 54; off+18 <= 126; off += 18 {
+		d := edid[off : off+18]
+		if d[0] == 0 && d[1] == 0 && d[2] == 0 && d[3] == 0xFC {
+			name, _, _ := strings.Cut(string(d[5:18]), "\n")
+			if name = strings.TrimSpace(name); name != "" {
+				return name
+			}
+		}
+	}
+	id := int(edid[8])<<8 | int(edid[9])
+	code := []byte{byte('@' + id>>10&0x1F), byte('@' + id>>5&0x1F), byte('@' + id&0x1F)}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+	}
+	return string(code)
+}
+
+// waylandName returns the name to show for an output from its make and
+// model, as the compositor read them from EDID: the model is the EDID name,
+// unless the EDID sets none and the model is the product code (0x07B6).
+func waylandName(make, model string) string {
+	if model != "" && model != "Unknown" && !strings.HasPrefix(model, "0x") {
+		return model
+	}
+	if make == "Unknown" {
+		return ""
+	}
+	return make
+}
+
+// logicalSize returns the size of a w×h mode at scale and transform, in
+// logical pixels.
+func logicalSize(w, h int, scale float64, transform string) (int, int) {
+	if scale <= 0 {
+		scale = 1
+	}
+	if strings.HasSuffix(transform, "90") || strings.HasSuffix(transform, "270") {
+		w, h = h, w
+	}
+	return int(math.Round(float64(w) / scale)), int(math.Round(float64(h) / scale))
+}
+
+// parseWlrRandr parses wlr-randr --json.
+func parseWlrRandr(data []byte) ([]MonitorDetail, error) {
+	var outputs []struct {
+		Name    string `json:"name"`
+		Make    string `json:"make"`
+		Model   string `json:"model"`
+		Enabled bool   `json:"enabled"`
+		Modes   []struct {
+			Width   int  `json:"width"`
+			Height  int  `json:"height"`
+			Current bool `json:"current"`
+		} `json:"modes"`
+		Position struct {
+			X int `json:"x"`
+			Y int `json:"y"`
+		} `json:"position"`
+		Transform string  `json:"transform"`
+		Scale     float64 `json:"scale"`
+	}
+	if err := json.Unmarshal(data, &outputs); err != nil {
+		return nil, fmt.Errorf("invalid wlr-randr output: %w", err)
+	}
+	var details []MonitorDetail
+	for _, o := range outputs {
+		if !o.Enabled {
+			continue
+		}
+		for _, m := range o.Modes {
+			if !m.Current {
+				continue
+			}
+			w, h := logicalSize(m.Width, m.Height, o.Scale, o.Transform)
+			details = append(details, MonitorDetail{
+				Index:      len(details) + 1,
+				DevicePath: o.Name,
+				Left:       o.Position.X,
+				Top:        o.Position.Y,
+				Right:      o.Position.X + w,
+				Bottom:     o.Position.Y + h,
+				Name:       waylandName(o.Make, o.Model),
+			})
+			break
+		}
+	}
+	if len(details) == 0 {
+		return nil, errors.New("wlr-randr lists no enabled outputs")
+	}
+	return details, nil
+}
+
+// parseSwayOutputs parses swaymsg -t get_outputs (sway's rect is already
+// in logical pixels).
+func parseSwayOutputs(data []byte) ([]MonitorDetail, error) {
+	var outputs []struct {
+		Name   string `json:"name"`
+		Make   string `json:"make"`
+		Model  string `json:"model"`
+		Active bool   `json:"active"`
+		Rect   struct {
+			X      int `json:"x"`
+			Y      int `json:"y"`
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"rect"`
+	}
+	if err := json.Unmarshal(data, &outputs); err != nil {
+		return nil, fmt.Errorf("invalid swaymsg output: %w", err)
+	}
+	var details []MonitorDetail
+	for _, o := range outputs {
+		if !o.Active {
+			continue
+		}
+		details = append(details, MonitorDetail{
+			Index:      len(details) + 1,
+			DevicePath: o.Name,
+			Left:       o.Rect.X,
+			Top:        o.Rect.Y,
+			Right:      o.Rect.X + o.Rect.Width,
+			Bottom:     o.Rect.Y + o.Rect.Height,
+			Name:       waylandName(o.Make, o.Model),
+		})
+	}
+	if len(details) == 0 {
+		return nil, errors.New("sway lists no active outputs")
+	}
+	return details, nil
+}
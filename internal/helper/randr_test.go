@@ -0,0 +1,100 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func assertDetails(t *testing.T, got, want []MonitorDetail) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("details:\n  %+v\nwant:\n  %+v", got, want)
+	}
+}
+
+func TestParseXrandrMonitors(t *testing.T) {
+	got, err := parseXrandrMonitors(string(readFixture(t, "xrandr-listmonitors.txt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDetails(t, got, []MonitorDetail{
+		{Index: 1, DevicePath: "eDP-1", Left: 0, Top: 360, Right: 1920, Bottom: 1440},
+		{Index: 2, DevicePath: "DP-1", Left: 1920, Top: 0, Right: 4480, Bottom: 1440},
+	})
+}
+
+func TestParseXrandrMonitors_SetMonitor(t *testing.T) {
+	got, err := parseXrandrMonitors("Monitors: 1\n 0: +*Wall 3840/1x1080/1+-1920+0  HDMI-1 DP-1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDetails(t, got, []MonitorDetail{{Index: 1, DevicePath: "Wall", Left: -1920, Top: 0, Right: 1920, Bottom: 1080}})
+}
+
+func TestParseXrandrMonitors_Empty(t *testing.T) {
+	if _, err := parseXrandrMonitors("Monitors: 0\n"); err == nil {
+		t.Error("expected an error without monitors")
+	}
+}
+
+func TestParseXrandrNames(t *testing.T) {
+	got := parseXrandrNames(string(readFixture(t, "xrandr-verbose.txt")))
+	want := map[string]string{"eDP-1": "BOE", "DP-1": "DELL U2720Q"}
+	if len(got) != len(want) {
+		t.Fatalf("names = %v, want %v", got, want)
+	}
+	for output, name := range want {
+		if got[output] != name {
+			t.Errorf("names[%q] = %q, want %q", output, got[output], name)
+		}
+	}
+}
+
+func TestEdidName_Short(t *testing.T) {
+	if got := edidName(make([]byte, 64)); got != "" {
+		t.Errorf("edidName = %q, want \"\"", got)
+	}
+}
+
+func TestParseWlrRandr(t *testing.T) {
+	got, err := parseWlrRandr(readFixture(t, "wlr-randr.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// eDP-1 is 2880x1620 at scale 1.5; DP-1 is rotated.
+	assertDetails(t, got, []MonitorDetail{
+		{Index: 1, DevicePath: "eDP-1", Left: 0, Top: 360, Right: 1920, Bottom: 1440, Name: "BOE"},
+		{Index: 2, DevicePath: "DP-1", Left: 1920, Top: 0, Right: 3360, Bottom: 2560, Name: "DELL U2720Q"},
+	})
+}
+
+func TestParseSwayOutputs(t *testing.T) {
+	got, err := parseSwayOutputs(readFixture(t, "swaymsg-outputs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDetails(t, got, []MonitorDetail{
+		{Index: 1, DevicePath: "eDP-1", Left: 0, Top: 360, Right: 1920, Bottom: 1440, Name: "BOE"},
+		{Index: 2, DevicePath: "DP-1", Left: 1920, Top: 0, Right: 3360, Bottom: 2560, Name: "DELL U2720Q"},
+	})
+}
+
+func TestParseWaylandOutputs_Invalid(t *testing.T) {
+	if _, err := parseWlrRandr([]byte("not json")); err == nil {
+		t.Error("parseWlrRandr: expected an error")
+	}
+	if _, err := parseSwayOutputs([]byte("[]")); err == nil {
+		t.Error("parseSwayOutputs: expected an error without outputs")
+	}
+}
@@ -1,6 +1,8 @@
 package helper
 
 import (
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
@@ -259,3 +261,102 @@ func TestResolveSourcePriorityChainWeatherCombinations(t *testing.T) {
 		t.Errorf("got (%q, %v), want stormy (priority 15) to win", src, ok)
 	}
 }
+
+func TestResolveSourceCustomWeatherBucket(t *testing.T) {
+	conditions := map[string]models.Condition{
+		"rainy":      {Weather: []string{"rain"}, Priority: 10},
+		"heavy-rain": {Weather: []string{"heavy-rain"}, Priority: 20},
+	}
+	cat := &models.Categories{Variants: []models.Variant{
+		{Source: "/walls/rainy", Condition: "rainy"},
+		{Source: "/walls/heavy-rain", Condition: "heavy-rain"},
+	}}
+	buckets := weather.NewBuckets(map[string][]int{"heavy-rain": {65, 67, 82}})
+
+	heavy := &weather.Snapshot{Code: 65, Buckets: buckets}
+	if src, ok := ResolveSource(cat, time.Now(), heavy, conditions, ""); !ok || src != "/walls/heavy-rain" {
+		t.Errorf("got (%q, %v), want heavy-rain variant to win on priority", src, ok)
+	}
+
+	light := &weather.Snapshot{Code: 61, Buckets: buckets}
+	if src, ok := ResolveSource(cat, time.Now(), light, conditions, ""); !ok || src != "/walls/rainy" {
+		t.Errorf("got (%q, %v), want rainy variant for light rain", src, ok)
+	}
+}
+
+func TestResolveSourcesPlainCategory(t *testing.T) {
+	cat := &models.Categories{Sources: []models.SourceDir{{Path: "/mnt/nas/walls"}, {Path: "/home/me/walls", Weight: 2}}}
+	dirs, ok := ResolveSources(cat, time.Now(), nil, nil, "")
+	if !ok || !slices.Equal(dirs, cat.Sources) {
+		t.Fatalf("got (%+v, %v), want the category's sources", dirs, ok)
+	}
+
+	if src, _ := ResolveSource(cat, time.Now(), nil, nil, ""); src != "/mnt/nas/walls" {
+		t.Errorf("ResolveSource = %q, want the first source", src)
+	}
+}
+
+func TestResolveSourcesTagsOnlyCategory(t *testing.T) {
+	cat := &models.Categories{Tags: "autumn"}
+	dirs, ok := ResolveSources(cat, time.Now(), nil, nil, "")
+	if !ok || len(dirs) != 0 {
+		t.Errorf("got (%+v, %v), want an active category without directories", dirs, ok)
+	}
+}
+
+func TestResolveSourcesCollageCategory(t *testing.T) {
+	for _, cat := range []*models.Categories{
+		{Collage: &models.Collage{From: []string{"nature"}}},
+		{Calendar: &models.Calendar{Background: "nature"}},
+	} {
+		dirs, ok := ResolveSources(cat, time.Now(), nil, nil, "")
+		if !ok || len(dirs) != 0 {
+			t.Errorf("got (%+v, %v), want an active category without directories", dirs, ok)
+		}
+	}
+}
+
+func TestResolveGenerator(t *testing.T) {
+	dusk := &models.Generate{Type: "linear-gradient", Colors: []string{"#ff7e5f", "#2c3e50"}}
+	cat := &models.Categories{Source: "/walls", Variants: []models.Variant{
+		{Source: "./day", Hours: "06:00-17:59"},
+		{Generate: dusk, Hours: "18:00-05:59"},
+	}}
+
+	night := time.Date(2026, 7, 10, 23, 0, 0, 0, time.Local)
+	if g, ok := ResolveGenerator(cat, night, nil, nil); !ok || g != dusk {
+		t.Errorf("23:00: got (%+v, %v), want the dusk generator", g, ok)
+	}
+	if dirs, ok := ResolveSources(cat, night, nil, nil, ""); !ok || len(dirs) != 0 {
+		t.Errorf("23:00: got (%+v, %v), want an active category without directories", dirs, ok)
+	}
+
+	noon := time.Date(2026, 7, 10, 12, 0, 0, 0, time.Local)
+	if g, ok := ResolveGenerator(cat, noon, nil, nil); ok {
+		t.Errorf("noon: got (%+v, %v), want no generator", g, ok)
+	}
+
+	solid := &models.Categories{Generate: &models.Generate{Type: "solid", Colors: []string{"#000000"}}}
+	if g, ok := ResolveGenerator(solid, noon, nil, nil); !ok || g != solid.Generate {
+		t.Errorf("category-level generator: got (%+v, %v)", g, ok)
+	}
+}
+
+func TestResolveSourcesRelativeVariantAgainstEveryBase(t *testing.T) {
+	cat := &models.Categories{
+		Sources: []models.SourceDir{{Path: "/mnt/nas"}, {Path: "/home/me", Weight: 2}},
+		Variants: []models.Variant{
+			{Sources: []models.SourceDir{{Path: "day", Weight: 3}, {Path: "/shared/day"}}, Hours: "00:00-23:59"},
+		},
+	}
+
+	dirs, ok := ResolveSources(cat, time.Now(), nil, nil, "")
+	want := []models.SourceDir{
+		{Path: filepath.Join("/mnt/nas", "day"), Weight: 3},
+		{Path: filepath.Join("/home/me", "day"), Weight: 6},
+		{Path: "/shared/day"},
+	}
+	if !ok || !slices.Equal(dirs, want) {
+		t.Errorf("got (%+v, %v), want %+v", dirs, ok, want)
+	}
+}
@@ -0,0 +1,228 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/reujab/wallpaper"
+)
+
+// Setter puts wallpapers on the desktop through one backend. SetWallpaper*,
+// SetWallpaperMode, PerMonitorSupported and GetPreviousWallpaper all go
+// through the one selected with UseBackend.
+type Setter interface {
+	// Set puts the image at path on every monitor. mode is the one
+	// SetMode is called with next: backends taking the mode along with
+	// the image apply it here, so the image is only set once.
+	Set(path, mode string) error
+	// SetMonitors puts each target's image on its monitor, named by the
+	// device path ListMonitors returns, leaving the other monitors as
+	// they are. mode is as for Set.
+	SetMonitors(targets []MonitorTarget, mode string) error
+	// PerMonitor reports whether SetMonitors is supported.
+	PerMonitor() bool
+	// SetMode sets how the wallpaper fills the screen: crop, fit,
+	// stretch, span, tile or center.
+	SetMode(mode string) error
+	// Get returns the path of the current wallpaper (the first monitor's
+	// when they differ).
+	Get() (string, error)
+}
+
+// DefaultBackend leaves the desktop to be detected: IDesktopWallpaper on
+// Windows, reujab/wallpaper's detection elsewhere.
+const DefaultBackend = "auto"
+
+// backends are the setters UseBackend can select, by name.
+var backends = map[string]func() Setter{
+	DefaultBackend: func() Setter { return native{} },
+	"gnome":        func() Setter { return gnome{} },
+	"kde":          func() Setter { return kde{} },
+	"xfce":         func() Setter { return xfce{} },
+	"sway":         func() Setter { return newModal(applySway, nil, true) },
+	"hyprland":     func() Setter { return newModal(applyHyprpaper, getHyprpaper, true) },
+	"swww":         func() Setter { return newModal(applySwww, getSwww, true) },
+	"feh":          func() Setter { return newModal(applyFeh, getFeh, true) },
+	"nitrogen":     func() Setter { return newModal(applyNitrogen, getNitrogen, true) },
+	"file":         func() Setter { return &file{out: fileOutput} },
+}
+
+// setter is the backend in use.
+var setter Setter = native{}
+
+// Backends returns the names UseBackend accepts.
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// UseBackend selects the backend every later change goes through; "" is
+// DefaultBackend.
+func UseBackend(name string) error {
+	if name == "" {
+		name = DefaultBackend
+	}
+	newSetter, ok := backends[name]
+	if !ok {
+		return fmt.Errorf("unknown wallpaper backend %q: use one of %s", name, strings.Join(Backends(), ", "))
+	}
+	setter = newSetter()
+	return nil
+}
+
+// native is DefaultBackend: IDesktopWallpaper on Windows, for every monitor
+// or one at a time, and reujab/wallpaper's desktop detection elsewhere.
+type native struct{}
+
+func (native) Set(path, _ string) error { return wallpaper.SetFromFile(path) }
+
+func (native) SetMonitors(targets []MonitorTarget, _ string) error {
+	var firstErr error
+	for _, t := range targets {
+		if err := setWallpaperOnMonitor(t.DevicePath, t.Path); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("could not set wallpaper on monitor %q: %w", t.DevicePath, err)
+		}
+	}
+	return firstErr
+}
+
+func (native) PerMonitor() bool { return perMonitorSupported }
+
+// SetMode applies the mode via IDesktopWallpaper directly on Windows, which
+// avoids the legacy registry write + reapply that would otherwise stomp on
+// SetWallpaperFromPath's fade transition; other platforms fall back to the
+// standard behavior.
+func (native) SetMode(mode string) error {
+	if err := setWallpaperPosition(mode); err == nil {
+		return nil
+	}
+	switch mode {
+	case "center":
+		return wallpaper.SetMode(wallpaper.Center)
+	case "fit":
+		return wallpaper.SetMode(wallpaper.Fit)
+	case "span":
+		return wallpaper.SetMode(wallpaper.Span)
+	case "stretch":
+		return wallpaper.SetMode(wallpaper.Stretch)
+	case "tile":
+		return wallpaper.SetMode(wallpaper.Tile)
+	case "crop":
+		fallthrough
+	default:
+		return wallpaper.SetMode(wallpaper.Crop)
+	}
+}
+
+func (native) Get() (string, error) { return wallpaper.Get() }
+
+// modal is a backend that takes the mode along with the images, on one
+// command line: it sets them in the mode Set is given, and remembers them
+// so that SetMode can set them again should the mode change afterwards.
+type modal struct {
+	apply      func(targets []MonitorTarget, mode string) error
+	get        func() (string, error)
+	perMonitor bool
+	mode       string
+	last       []MonitorTarget
+}
+
+// newModal returns a modal backend running apply, where a single target
+// without a DevicePath stands for every monitor. get may be nil when the
+// backend can't tell the current wallpaper.
+func newModal(apply func([]MonitorTarget, string) error, get func() (string, error), perMonitor bool) *modal {
+	return &modal{apply: apply, get: get, perMonitor: perMonitor, mode: "crop"}
+}
+
+func (m *modal) Set(path, mode string) error {
+	return m.set([]MonitorTarget{{Path: path}}, mode)
+}
+
+func (m *modal) SetMonitors(targets []MonitorTarget, mode string) error {
+	if !m.perMonitor {
+		return errors.New("this backend can't set a wallpaper per monitor")
+	}
+	return m.set(targets, mode)
+}
+
+func (m *modal) set(targets []MonitorTarget, mode string) error {
+	if mode == "" {
+		mode = m.mode
+	}
+	if err := m.apply(targets, mode); err != nil {
+		return err
+	}
+	m.mode, m.last = mode, targets
+	return nil
+}
+
+func (m *modal) PerMonitor() bool { return m.perMonitor }
+
+func (m *modal) SetMode(mode string) error {
+	if mode == m.mode {
+		return nil
+	}
+	m.mode = mode
+	if m.last == nil {
+		return nil
+	}
+	return m.apply(m.last, mode)
+}
+
+func (m *modal) Get() (string, error) {
+	if m.get == nil {
+		return "", errors.New("this backend can't tell the current wallpaper")
+	}
+	return m.get()
+}
+
+// command runs name with args and returns its standard output, or an error
+// with what it printed on standard error.
+func command(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...) // #nosec G204 -- fixed desktop tools, arguments built here
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return string(out), nil
+}
+
+// monitorIndex returns the 0-based position of devicePath among the
+// connected monitors, for backends that address monitors by number.
+func monitorIndex(devicePath string) (int, error) {
+	monitors, err := monitorDevicePaths()
+	if err != nil {
+		return 0, err
+	}
+	i := slices.Index(monitors, devicePath)
+	if i < 0 {
+		return 0, fmt.Errorf("monitor %q is not connected", devicePath)
+	}
+	return i, nil
+}
+
+// monitorDetail returns the connected monitor devicePath, for backends that
+// address monitors by where they are.
+func monitorDetail(devicePath string) (MonitorDetail, error) {
+	details, err := monitorDetails()
+	if err != nil {
+		return MonitorDetail{}, err
+	}
+	i := slices.IndexFunc(details, func(d MonitorDetail) bool { return d.DevicePath == devicePath })
+	if i < 0 {
+		return MonitorDetail{}, fmt.Errorf("monitor %q is not connected", devicePath)
+	}
+	return details[i], nil
+}
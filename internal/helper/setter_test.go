@@ -0,0 +1,419 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lucasassuncao/gopaper/internal/models"
+)
+
+// fakeTools puts executables named tools on PATH (and nothing else) that
+// record their arguments, one call per line with each argument in
+// brackets, and print the file <name>.out next to them when there is one.
+// Given the argument in <name>.fail, they print <name>.err and fail.
+// It returns the directory they live in and a function reading the calls
+// made so far.
+func fakeTools(t *testing.T, tools ...string) (string, func() []string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls.log")
+	// Shell builtins only: PATH holds nothing but the fake tools.
+	script := `#!/bin/sh
+name=${0##*/}
+{ printf '%s' "$name"; for a in "$@"; do printf ' [%s]' "$a"; done; echo; } >> "` + log + `"
+show() { while IFS= read -r l || [ -n "$l" ]; do printf '%s\n' "$l"; done < "$1"; }
+[ -f "` + dir + `/$name.out" ] && show "` + dir + `/$name.out"
+if [ -f "` + dir + `/$name.fail" ]; then
+	read -r arg < "` + dir + `/$name.fail"
+	for a in "$@"; do [ "$a" = "$arg" ] && { show "` + dir + `/$name.err" >&2; exit 1; }; done
+fi
+exit 0
+`
+	for _, tool := range tools {
+		if err := os.WriteFile(filepath.Join(dir, tool), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	return dir, func() []string {
+		data, err := os.ReadFile(log)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+// useBackend selects name for the test, restoring the default after it.
+func useBackend(t *testing.T, name string) {
+	t.Helper()
+	if err := UseBackend(name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { setter = native{} })
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertCalls(t *testing.T, got, want []string) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Errorf("calls:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestUseBackend(t *testing.T) {
+	if err := UseBackend("wayland"); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+	t.Cleanup(func() { setter = native{} })
+	for _, name := range Backends() {
+		if err := UseBackend(name); err != nil {
+			t.Errorf("UseBackend(%q): %v", name, err)
+		}
+	}
+	if err := UseBackend(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := setter.(native); !ok {
+		t.Errorf("UseBackend(\"\") selected %T, want the native backend", setter)
+	}
+}
+
+func TestBackendsMatchMetadata(t *testing.T) {
+	oneOf := slices.Clone(models.Behavior{}.Metadata()["backend"].OneOf)
+	slices.Sort(oneOf)
+	if !slices.Equal(oneOf, Backends()) {
+		t.Errorf("behavior.backend allows %v, but the backends are %v", oneOf, Backends())
+	}
+}
+
+func TestGnomeBackend(t *testing.T) {
+	dir, calls := fakeTools(t, "gsettings")
+	useBackend(t, "gnome")
+
+	if err := SetWallpaperFromPath("/walls/a b.jpg", "crop", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpaperMode("fit"); err != nil {
+		t.Fatal(err)
+	}
+	assertCalls(t, calls(), []string{
+		"gsettings [set] [org.gnome.desktop.background] [picture-uri] [file:///walls/a%20b.jpg]",
+		"gsettings [set] [org.gnome.desktop.background] [picture-uri-dark] [file:///walls/a%20b.jpg]",
+		"gsettings [set] [org.gnome.desktop.background] [picture-options] [scaled]",
+	})
+	if PerMonitorSupported() {
+		t.Error("GNOME has no per-monitor wallpapers")
+	}
+
+	writeFile(t, filepath.Join(dir, "gsettings.out"), "'file:///walls/a%20b.jpg'\n")
+	if got, err := GetPreviousWallpaper(); err != nil || got != "/walls/a b.jpg" {
+		t.Errorf("GetPreviousWallpaper() = (%q, %v)", got, err)
+	}
+}
+
+func TestGnomeBackendWithoutDarkKey(t *testing.T) {
+	dir, _ := fakeTools(t, "gsettings")
+	useBackend(t, "gnome")
+	writeFile(t, filepath.Join(dir, "gsettings.fail"), "picture-uri-dark\n")
+	writeFile(t, filepath.Join(dir, "gsettings.err"), "No such key “picture-uri-dark”\n")
+	if err := SetWallpaperFromPath("/walls/a.jpg", "crop", false); err != nil {
+		t.Errorf("GNOME before 42 has no dark wallpaper, which isn't an error: %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "gsettings.fail"), "picture-uri\n")
+	writeFile(t, filepath.Join(dir, "gsettings.err"), "Schema “org.gnome.desktop.background” is not installed\n")
+	if err := SetWallpaperFromPath("/walls/a.jpg", "crop", false); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("expected gsettings' error, got %v", err)
+	}
+}
+
+func TestKDEBackend(t *testing.T) {
+	dir, calls := fakeTools(t, "qdbus")
+	useBackend(t, "kde")
+
+	if err := SetWallpaperFromPath(`/walls/"quoted".jpg`, "crop", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpaperMode("tile"); err != nil {
+		t.Fatal(err)
+	}
+	// Plasma scripts span several lines: split the log on the calls.
+	got := strings.SplitAfter(strings.Join(calls(), "\n"), "});]")
+	if len(got) != 3 || got[2] != "" {
+		t.Fatalf("expected 2 calls, got %q", got)
+	}
+	for _, want := range []string{"qdbus [org.kde.plasmashell] [/PlasmaShell] [org.kde.PlasmaShell.evaluateScript]", `d.writeConfig("Image", "file:///walls/%22quoted%22.jpg");`} {
+		if !strings.Contains(got[0], want) {
+			t.Errorf("set call %q lacks %q", got[0], want)
+		}
+	}
+	if strings.Contains(got[0], "screenGeometry") {
+		t.Errorf("set call %q is limited to one screen", got[0])
+	}
+	if !strings.Contains(got[1], `d.writeConfig("FillMode", 3);`) {
+		t.Errorf("mode call %q sets no tiled FillMode", got[1])
+	}
+	if !PerMonitorSupported() {
+		t.Error("KDE sets wallpapers per monitor")
+	}
+
+	writeFile(t, filepath.Join(dir, "qdbus.out"), "file:///walls/a.jpg\n")
+	if got, err := GetPreviousWallpaper(); err != nil || got != "/walls/a.jpg" {
+		t.Errorf("GetPreviousWallpaper() = (%q, %v)", got, err)
+	}
+}
+
+func TestKDEBackendPerMonitor(t *testing.T) {
+	dir, calls := fakeTools(t, "qdbus", "xrandr")
+	useBackend(t, "kde")
+	// The primary output, Plasma's screen 0, is listed second.
+	writeFile(t, filepath.Join(dir, "xrandr.out"), `Monitors: 2
+ 0: +DP-1 2560/597x1440/336+1920+0  DP-1
+ 1: +*eDP-1 1920/344x1080/194+0+360  eDP-1
+`)
+	t.Setenv("SWAYSOCK", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: "eDP-1", Path: "/walls/a.jpg"}}, "crop"); err != nil {
+		t.Fatal(err)
+	}
+	var script string
+	for _, c := range strings.SplitAfter(strings.Join(calls(), "\n"), "});]") {
+		if strings.Contains(c, "qdbus ") {
+			script = c
+		}
+	}
+	for _, want := range []string{"if (g.x !== 0 || g.y !== 360) return;", `d.writeConfig("Image", "file:///walls/a.jpg");`} {
+		if !strings.Contains(script, want) {
+			t.Errorf("set call %q lacks %q", script, want)
+		}
+	}
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: "HDMI-1", Path: "/walls/a.jpg"}}, "crop"); err == nil {
+		t.Error("expected an error for a monitor that isn't connected")
+	}
+}
+
+func TestKDEBackendWithoutQdbus(t *testing.T) {
+	fakeTools(t)
+	useBackend(t, "kde")
+	if err := SetWallpaperFromPath("/walls/a.jpg", "crop", false); err == nil || !strings.Contains(err.Error(), "qdbus not found") {
+		t.Errorf("expected a missing qdbus error, got %v", err)
+	}
+}
+
+func TestXfceBackend(t *testing.T) {
+	dir, calls := fakeTools(t, "xfconf-query")
+	useBackend(t, "xfce")
+	writeFile(t, filepath.Join(dir, "xfconf-query.out"), `/backdrop/screen0/monitorHDMI-1/workspace0/last-image
+/backdrop/screen0/monitoreDP-1/workspace0/image-style
+/backdrop/screen0/monitoreDP-1/workspace0/last-image
+/backdrop/screen0/monitoreDP-1/workspace1/last-image
+/desktop-icons/style
+`)
+
+	if err := SetWallpaperFromPath("/walls/a.jpg", "crop", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: "eDP-1", Path: "/walls/b.jpg"}, {DevicePath: "DP-2", Path: "/walls/c.jpg"}}, "crop"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpaperMode("center"); err != nil {
+		t.Fatal(err)
+	}
+	assertCalls(t, calls(), []string{
+		"xfconf-query [-c] [xfce4-desktop] [-l]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitorHDMI-1/workspace0/last-image] [-s] [/walls/a.jpg]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitoreDP-1/workspace0/last-image] [-s] [/walls/a.jpg]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitoreDP-1/workspace1/last-image] [-s] [/walls/a.jpg]",
+		"xfconf-query [-c] [xfce4-desktop] [-l]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitoreDP-1/workspace0/last-image] [-s] [/walls/b.jpg]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitoreDP-1/workspace1/last-image] [-s] [/walls/b.jpg]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitorDP-2/workspace0/last-image] [-n] [-t] [string] [-s] [/walls/c.jpg]",
+		"xfconf-query [-c] [xfce4-desktop] [-l]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitorHDMI-1/workspace0/image-style] [-n] [-t] [int] [-s] [1]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitoreDP-1/workspace0/image-style] [-s] [1]",
+		"xfconf-query [-c] [xfce4-desktop] [-p] [/backdrop/screen0/monitoreDP-1/workspace1/image-style] [-n] [-t] [int] [-s] [1]",
+	})
+}
+
+func TestModalAppliesOnce(t *testing.T) {
+	var applied []string
+	m := newModal(func(targets []MonitorTarget, mode string) error {
+		applied = append(applied, targets[0].Path+" "+mode)
+		return nil
+	}, nil, true)
+
+	// A change sets the image in its mode, then the mode: one run.
+	if err := m.Set("/walls/a.jpg", "fit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetMode("fit"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(applied, []string{"/walls/a.jpg fit"}) {
+		t.Errorf("applied = %q, want one run in fit mode", applied)
+	}
+
+	// Changing the mode alone sets the image again.
+	if err := m.SetMode("tile"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(applied, []string{"/walls/a.jpg fit", "/walls/a.jpg tile"}) {
+		t.Errorf("applied = %q", applied)
+	}
+}
+
+func TestSwayBackend(t *testing.T) {
+	_, calls := fakeTools(t, "swaymsg")
+	useBackend(t, "sway")
+
+	if err := SetWallpaperFromPath(`/walls/a "b".jpg`, "crop", false); err != nil {
+		t.Fatal(err)
+	}
+	// The mode goes with the image, which is set again in the new mode.
+	if err := SetWallpaperMode("fit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpaperMode("fit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: "DP-1", Path: "/walls/c.jpg"}}, "fit"); err != nil {
+		t.Fatal(err)
+	}
+	assertCalls(t, calls(), []string{
+		`swaymsg [output] [*] [bg] ["/walls/a \"b\".jpg"] [fill]`,
+		`swaymsg [output] [*] [bg] ["/walls/a \"b\".jpg"] [fit]`,
+		`swaymsg [output] [DP-1] [bg] ["/walls/c.jpg"] [fit]`,
+	})
+	if _, err := GetPreviousWallpaper(); err == nil {
+		t.Error("sway can't tell its background: expected an error")
+	}
+}
+
+func TestHyprlandBackend(t *testing.T) {
+	dir, calls := fakeTools(t, "hyprctl")
+	useBackend(t, "hyprland")
+	writeFile(t, filepath.Join(dir, "hyprctl.out"), "ok\n")
+
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: "DP-1", Path: "/walls/a.jpg"}}, "crop"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpaperMode("fit"); err != nil {
+		t.Fatal(err)
+	}
+	assertCalls(t, calls(), []string{
+		"hyprctl [hyprpaper] [preload] [/walls/a.jpg]",
+		"hyprctl [hyprpaper] [wallpaper] [DP-1,/walls/a.jpg]",
+		"hyprctl [hyprpaper] [unload] [unused]",
+		"hyprctl [hyprpaper] [preload] [/walls/a.jpg]",
+		"hyprctl [hyprpaper] [wallpaper] [DP-1,contain:/walls/a.jpg]",
+		"hyprctl [hyprpaper] [unload] [unused]",
+	})
+
+	writeFile(t, filepath.Join(dir, "hyprctl.out"), "DP-1 = /walls/a.jpg\nHDMI-A-1 = /walls/b.jpg\n")
+	if got, err := GetPreviousWallpaper(); err != nil || got != "/walls/a.jpg" {
+		t.Errorf("GetPreviousWallpaper() = (%q, %v)", got, err)
+	}
+
+	writeFile(t, filepath.Join(dir, "hyprctl.out"), "wallpaper failed (not preloaded)\n")
+	if err := SetWallpaperFromPath("/walls/b.jpg", "crop", false); err == nil {
+		t.Error("expected hyprpaper's refusal to be an error")
+	}
+}
+
+func TestSwwwBackend(t *testing.T) {
+	dir, calls := fakeTools(t, "swww")
+	useBackend(t, "swww")
+
+	if err := SetWallpaperFromPath("/walls/a.jpg", "crop", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpapersPerMonitor([]MonitorTarget{{DevicePath: "HDMI-A-1", Path: "/walls/b.jpg"}}, "crop"); err != nil {
+		t.Fatal(err)
+	}
+	assertCalls(t, calls(), []string{
+		"swww [img] [/walls/a.jpg] [--resize] [crop]",
+		"swww [img] [/walls/b.jpg] [--resize] [crop] [--outputs] [HDMI-A-1]",
+	})
+
+	writeFile(t, filepath.Join(dir, "swww.out"), "DP-1: 2560x1440, scale: 1, currently displaying: image: /walls/a.jpg\n")
+	if got, err := GetPreviousWallpaper(); err != nil || got != "/walls/a.jpg" {
+		t.Errorf("GetPreviousWallpaper() = (%q, %v)", got, err)
+	}
+}
+
+func TestFehBackend(t *testing.T) {
+	_, calls := fakeTools(t, "feh")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	useBackend(t, "feh")
+
+	if err := SetWallpaperFromPath("/walls/a.jpg", "crop", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpaperMode("span"); err != nil {
+		t.Fatal(err)
+	}
+	assertCalls(t, calls(), []string{
+		"feh [--bg-fill] [/walls/a.jpg]",
+		"feh [--bg-fill] [--no-xinerama] [/walls/a.jpg]",
+	})
+
+	writeFile(t, filepath.Join(home, ".fehbg"), "#!/bin/sh\nfeh --no-fehbg --bg-fill '/walls/it'\\''s.jpg' '/walls/b.jpg' \n")
+	if got, err := GetPreviousWallpaper(); err != nil || got != "/walls/it's.jpg" {
+		t.Errorf("GetPreviousWallpaper() = (%q, %v)", got, err)
+	}
+}
+
+func TestNitrogenBackend(t *testing.T) {
+	_, calls := fakeTools(t, "nitrogen")
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	useBackend(t, "nitrogen")
+
+	if err := SetWallpaperFromPath("/walls/a.jpg", "crop", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetWallpaperMode("span"); err != nil {
+		t.Fatal(err)
+	}
+	got := calls()
+	want := "nitrogen [--set-zoom-fill] [--save] [--head=-1] [/walls/a.jpg]"
+	if len(got) < 2 || got[len(got)-1] != want {
+		t.Errorf("calls %q, want the last to be %q", got, want)
+	}
+
+	writeFile(t, filepath.Join(config, "nitrogen", "bg-saved.cfg"), "[xin_0]\nfile=/walls/a.jpg\nmode=5\nbgcolor=#000000\n")
+	if got, err := GetPreviousWallpaper(); err != nil || got != "/walls/a.jpg" {
+		t.Errorf("GetPreviousWallpaper() = (%q, %v)", got, err)
+	}
+}
+
+func TestShellWords(t *testing.T) {
+	got := shellWords(`feh --bg-fill '/a b.jpg' "/c \"d\".jpg" /e\ f.jpg # comment`)
+	want := []string{"feh", "--bg-fill", "/a b.jpg", `/c "d".jpg`, "/e f.jpg"}
+	if !slices.Equal(got, want) {
+		t.Errorf("shellWords() = %q, want %q", got, want)
+	}
+}
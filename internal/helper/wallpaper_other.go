@@ -16,21 +16,12 @@ func setWallpaperPosition(mode string) error {
 	return errors.New("wallpaper fade transition is only supported on Windows")
 }
 
-// monitorDevicePaths and setWallpaperOnMonitor back the per-monitor mode,
-// which relies on IDesktopWallpaper and is therefore Windows-only. The
-// caller treats an error here as "fall back to the single-wallpaper flow".
-func monitorDevicePaths() ([]string, error) {
-	return nil, errors.New("per-monitor wallpapers are only supported on Windows")
-}
+// Without IDesktopWallpaper every monitor shows the same wallpaper.
+const perMonitorSupported = false
 
+// setWallpaperOnMonitor backs the native per-monitor mode, which relies on
+// IDesktopWallpaper and is therefore Windows-only; the desktop backends set
+// per-monitor wallpapers themselves.
 func setWallpaperOnMonitor(devicePath, fullPath string) error {
 	return errors.New("per-monitor wallpapers are only supported on Windows")
 }
-
-func monitorDetails() ([]MonitorDetail, error) {
-	return nil, errors.New("monitor enumeration is only supported on Windows")
-}
-
-func monitorNames() (map[string]string, error) {
-	return nil, errors.New("monitor enumeration is only supported on Windows")
-}
@@ -310,6 +310,9 @@ func utf16PtrToStringAndFree(p *uint16) string {
 	return windows.UTF16PtrToString(p)
 }
 
+// IDesktopWallpaper sets a wallpaper per monitor.
+const perMonitorSupported = true
+
 // monitorDevicePaths returns the device path of every monitor Explorer
 // knows about, in IDesktopWallpaper enumeration order (index 0 is
 // "monitor 1" in the configuration).
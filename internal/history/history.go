@@ -20,20 +20,28 @@ var (
 // Entry represents a single wallpaper that was applied. For a per-monitor
 // change, Path/Category mirror the primary monitor's selection and Monitors
 // holds every monitor's wallpaper; for a regular change Monitors is empty.
+// Panorama marks Path as spread across every monitor rather than mirrored.
+// For a collage, Path is the rendered collage and Parts the images laid out
+// on it; Calendar marks Path as a rendered calendar and Parts as its
+// background, if it has one.
 type Entry struct {
 	Path      string         `json:"path"`
 	Category  string         `json:"category"`
 	Mode      string         `json:"mode"`
 	Timestamp time.Time      `json:"timestamp"`
 	Monitors  []MonitorEntry `json:"monitors,omitempty"`
+	Panorama  bool           `json:"panorama,omitempty"`
+	Calendar  bool           `json:"calendar,omitempty"`
+	Parts     []string       `json:"parts,omitempty"`
 }
 
 // MonitorEntry is one monitor's wallpaper within a per-monitor change.
 // Monitor is 1-based, matching the categories[].monitor config field.
 type MonitorEntry struct {
-	Monitor  int    `json:"monitor"`
-	Path     string `json:"path"`
-	Category string `json:"category"`
+	Monitor  int      `json:"monitor"`
+	Path     string   `json:"path"`
+	Category string   `json:"category"`
+	Parts    []string `json:"parts,omitempty"`
 }
 
 // History is the persistent navigation state for wallpaper history.
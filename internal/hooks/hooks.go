@@ -0,0 +1,188 @@
+// Package hooks runs the user's commands around a wallpaper change: the
+// pre-change ones before it is applied, any of which can veto it by
+// failing, and the post-change ones after it. Every command is handed the
+// change through its environment and, as the JSON history entry, its
+// standard input.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/history"
+)
+
+// The events hooks run on.
+const (
+	PreChange  = "pre-change"
+	PostChange = "post-change"
+)
+
+// DefaultTimeout bounds a hook without a timeout of its own.
+const DefaultTimeout = 10 * time.Second
+
+// maxOutput bounds how much of a failed hook's output is kept for its
+// error.
+const maxOutput = 512
+
+// ErrVetoed is returned when a pre-change hook rejects the change.
+var ErrVetoed = errors.New("wallpaper change vetoed by a pre-change hook")
+
+// Hook is one command, run by the shell (sh, or PowerShell on Windows),
+// killed when it runs longer than Timeout.
+type Hook struct {
+	Command string
+	Timeout time.Duration
+}
+
+// Applied is what a change handed to the desktop, which differs from the
+// images its history entry records once they were converted, processed,
+// overlaid, sliced or composed.
+type Applied struct {
+	// Path is the file set as the wallpaper: the one every monitor shows,
+	// the canvas the monitors' images were composed into, or else the
+	// first monitor's.
+	Path string
+	// Monitors is each 1-based monitor's file, when each got its own.
+	Monitors map[int]string
+}
+
+// Env returns the environment describing the change recorded by entry, on
+// event, once applied (post-change only; zero on pre-change):
+//
+//	GOPAPER_EVENT                         pre-change or post-change
+//	GOPAPER_PATH                          the wallpaper (the first monitor's for a per-monitor change)
+//	GOPAPER_APPLIED_PATH                  the file set on the desktop
+//	GOPAPER_CATEGORY, GOPAPER_MODE
+//	GOPAPER_MONITORS                      the 1-based monitors changed, space-separated (per-monitor changes and panoramas only)
+//	GOPAPER_MONITOR_<n>_PATH              monitor n's wallpaper (the whole image for a panorama)
+//	GOPAPER_MONITOR_<n>_APPLIED_PATH      the file set on monitor n
+//	GOPAPER_MONITOR_<n>_CATEGORY          monitor n's category
+//	GOPAPER_ENTRY                         the history entry as JSON
+func Env(event string, entry history.Entry, applied Applied) ([]string, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	env := []string{
+		"GOPAPER_EVENT=" + event,
+		"GOPAPER_PATH=" + entry.Path,
+		"GOPAPER_CATEGORY=" + entry.Category,
+		"GOPAPER_MODE=" + entry.Mode,
+		"GOPAPER_ENTRY=" + string(data),
+	}
+	if applied.Path != "" {
+		env = append(env, "GOPAPER_APPLIED_PATH="+applied.Path)
+	}
+	monitors := entry.Monitors
+	if len(monitors) == 0 && entry.Panorama {
+		// Every monitor shows a slice of the one image.
+		for _, n := range slices.Sorted(maps.Keys(applied.Monitors)) {
+			monitors = append(monitors, history.MonitorEntry{Monitor: n, Path: entry.Path, Category: entry.Category})
+		}
+	}
+	if len(monitors) > 0 {
+		indices := make([]string, len(monitors))
+		for i, m := range monitors {
+			n := strconv.Itoa(m.Monitor)
+			indices[i] = n
+			env = append(env,
+				"GOPAPER_MONITOR_"+n+"_PATH="+m.Path,
+				"GOPAPER_MONITOR_"+n+"_CATEGORY="+m.Category,
+			)
+			if path, ok := applied.Monitors[m.Monitor]; ok {
+				env = append(env, "GOPAPER_MONITOR_"+n+"_APPLIED_PATH="+path)
+			}
+		}
+		env = append(env, "GOPAPER_MONITORS="+strings.Join(indices, " "))
+	}
+	return env, nil
+}
+
+// Run runs hooks in order for event. On pre-change, the first hook that
+// fails (exits non-zero, can't be started or times out) stops the others
+// and vetoes the change: the error wraps ErrVetoed. On post-change, every
+// hook runs and the failures are joined. applied is what the change handed
+// to the desktop, on post-change.
+func Run(ctx context.Context, event string, hooks []Hook, entry history.Entry, applied Applied) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	env, err := Env(event, entry, applied)
+	if err != nil {
+		return err
+	}
+	input, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, h := range hooks {
+		if err := run(ctx, h, env, input); err != nil {
+			if event == PreChange {
+				return fmt.Errorf("%w: %s: %w", ErrVetoed, h.Command, err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", h.Command, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func run(ctx context.Context, h Hook, env []string, input []byte) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := shell(ctx, h.Command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(input)
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	// A command that leaves a child holding its output open must not keep
+	// the change waiting past the timeout.
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		if msg := lastLines(out.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// shell returns the command running command through the platform's shell.
+func shell(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command) // #nosec G204 -- configured hook
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command) // #nosec G204 -- configured hook
+}
+
+// lastLines returns the end of a hook's output, at most maxOutput bytes,
+// on one line.
+func lastLines(out string) string {
+	out = strings.TrimSpace(out)
+	if len(out) > maxOutput {
+		out = "…" + out[len(out)-maxOutput:]
+	}
+	return strings.Join(strings.Fields(out), " ")
+}
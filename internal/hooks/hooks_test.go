@@ -0,0 +1,154 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/history"
+)
+
+func skipWithoutSh(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are PowerShell on Windows")
+	}
+}
+
+func perMonitorEntry() history.Entry {
+	return history.Entry{
+		Path:      "/walls/a.jpg",
+		Category:  "Nature",
+		Mode:      "crop",
+		Timestamp: time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC),
+		Monitors: []history.MonitorEntry{
+			{Monitor: 1, Path: "/walls/a.jpg", Category: "Nature"},
+			{Monitor: 3, Path: "/walls/b.jpg", Category: "City"},
+		},
+	}
+}
+
+func TestEnv(t *testing.T) {
+	env, err := Env(PostChange, perMonitorEntry(), Applied{Path: "/cache/a.jpg", Monitors: map[int]string{1: "/cache/a.jpg", 3: "/cache/b.jpg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"GOPAPER_EVENT=post-change",
+		"GOPAPER_PATH=/walls/a.jpg",
+		"GOPAPER_CATEGORY=Nature",
+		"GOPAPER_MODE=crop",
+		"GOPAPER_MONITORS=1 3",
+		"GOPAPER_MONITOR_3_PATH=/walls/b.jpg",
+		"GOPAPER_MONITOR_3_CATEGORY=City",
+		"GOPAPER_APPLIED_PATH=/cache/a.jpg",
+		"GOPAPER_MONITOR_3_APPLIED_PATH=/cache/b.jpg",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("missing %s in %v", want, env)
+		}
+	}
+
+	single, err := Env(PreChange, history.Entry{Path: "/walls/a.jpg"}, Applied{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kv := range single {
+		if strings.HasPrefix(kv, "GOPAPER_MONITOR") || strings.HasPrefix(kv, "GOPAPER_APPLIED") {
+			t.Errorf("unexpected %s for a single-wallpaper change not applied yet", kv)
+		}
+	}
+
+	panorama, err := Env(PostChange, history.Entry{Path: "/walls/wide.jpg", Category: "Nature", Panorama: true},
+		Applied{Path: "/cache/1.jpg", Monitors: map[int]string{2: "/cache/2.jpg", 1: "/cache/1.jpg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"GOPAPER_MONITORS=1 2",
+		"GOPAPER_MONITOR_2_PATH=/walls/wide.jpg",
+		"GOPAPER_MONITOR_2_CATEGORY=Nature",
+		"GOPAPER_MONITOR_2_APPLIED_PATH=/cache/2.jpg",
+	} {
+		if !slices.Contains(panorama, want) {
+			t.Errorf("missing %s in %v", want, panorama)
+		}
+	}
+}
+
+func TestRunPassesTheEntry(t *testing.T) {
+	skipWithoutSh(t)
+	dir := t.TempDir()
+	stdin, env := filepath.Join(dir, "stdin.json"), filepath.Join(dir, "env.json")
+	hooks := []Hook{{Command: `cat > "` + stdin + `"; printf '%s' "$GOPAPER_ENTRY" > "` + env + `"`}}
+	if err := Run(context.Background(), PostChange, hooks, perMonitorEntry(), Applied{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{stdin, env} {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got history.Entry
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(p), err)
+		}
+		if got.Path != "/walls/a.jpg" || len(got.Monitors) != 2 || got.Monitors[1].Category != "City" {
+			t.Errorf("%s: got %+v", filepath.Base(p), got)
+		}
+	}
+}
+
+func TestRunPreChangeVetoes(t *testing.T) {
+	skipWithoutSh(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	hooks := []Hook{
+		{Command: `echo "presenting"; exit 3`},
+		{Command: `touch "` + marker + `"`},
+	}
+	err := Run(context.Background(), PreChange, hooks, perMonitorEntry(), Applied{})
+	if !errors.Is(err, ErrVetoed) {
+		t.Fatalf("expected a veto, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "presenting") {
+		t.Errorf("expected the hook's output in %q", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("a vetoing hook must stop the ones after it")
+	}
+}
+
+func TestRunPostChangeRunsEveryHook(t *testing.T) {
+	skipWithoutSh(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	hooks := []Hook{
+		{Command: `exit 1`},
+		{Command: `touch "` + marker + `"`},
+	}
+	err := Run(context.Background(), PostChange, hooks, perMonitorEntry(), Applied{})
+	if err == nil || errors.Is(err, ErrVetoed) {
+		t.Fatalf("expected a plain failure, got %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("a failing post-change hook must not stop the others")
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	skipWithoutSh(t)
+	start := time.Now()
+	err := Run(context.Background(), PreChange, []Hook{{Command: "sleep 5", Timeout: 100 * time.Millisecond}}, perMonitorEntry(), Applied{})
+	if !errors.Is(err, ErrVetoed) || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout veto, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("the hook ran for %s despite its timeout", elapsed)
+	}
+}
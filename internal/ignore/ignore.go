@@ -0,0 +1,192 @@
+// Package ignore implements the gitignore-style rules read from
+// .gopaperignore files while walking a recursive category source.
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// FileName is the name of the per-directory ignore file.
+const FileName = ".gopaperignore"
+
+// rule is one compiled line of an ignore file.
+type rule struct {
+	re      *regexp.Regexp
+	base    string // slash-separated directory of the ignore file, relative to the walk root ("" at the root)
+	negate  bool
+	dirOnly bool
+	// anchored rules (a "/" anywhere but at the end) match the whole path
+	// below base; unanchored ones match the last path element at any depth.
+	anchored bool
+}
+
+// Matcher holds the rules collected so far while walking down from the
+// source root. It is immutable: With returns an extended copy, so sibling
+// directories never see each other's rules.
+type Matcher struct {
+	rules []rule
+}
+
+// With returns a copy of m extended by the rules in data, an ignore file
+// found in base (slash-separated, relative to the walk root).
+func (m Matcher) With(base string, data []byte) Matcher {
+	parsed := Parse(base, data)
+	if len(parsed.rules) == 0 {
+		return m
+	}
+	rules := make([]rule, 0, len(m.rules)+len(parsed.rules))
+	rules = append(rules, m.rules...)
+	rules = append(rules, parsed.rules...)
+	return Matcher{rules: rules}
+}
+
+// Parse compiles the lines of an ignore file located in base. Blank lines
+// and "#" comments are skipped; malformed patterns are ignored rather than
+// failing the whole file.
+func Parse(base string, data []byte) Matcher {
+	var m Matcher
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if r, ok := parseLine(base, scanner.Text()); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+	return m
+}
+
+func parseLine(base, line string) (rule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	r := rule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp translates a gitignore glob into a regular expression:
+// "*" and "?" never cross a "/", "**" does, and "[...]" classes pass
+// through (with "[!" as negation).
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				switch {
+				case i+1 < len(glob) && glob[i+1] == '/':
+					i++
+					b.WriteString("(?:.*/)?")
+				default:
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Escape returns a pattern matching exactly the file name name: glob
+// characters and backslashes are backslash-escaped, as are a leading "!"
+// or "#" and a trailing space.
+func Escape(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		switch {
+		case strings.ContainsRune(`\*?[`, c),
+			i == 0 && (c == '!' || c == '#'),
+			c == ' ' && i == len(name)-1:
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Ignored reports whether rel (slash-separated, relative to the walk root)
+// is excluded. As in gitignore, the last matching rule wins, so a later
+// "!pattern" re-includes what an earlier rule excluded.
+func (m Matcher) Ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub, ok := below(r.base, rel)
+		if !ok {
+			continue
+		}
+		target := sub
+		if !r.anchored {
+			target = path.Base(sub)
+		}
+		if r.re.MatchString(target) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// below returns rel relative to base when rel lies inside base.
+func below(base, rel string) (string, bool) {
+	if base == "" {
+		return rel, true
+	}
+	sub, ok := strings.CutPrefix(rel, base+"/")
+	return sub, ok
+}
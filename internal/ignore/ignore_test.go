@@ -0,0 +1,91 @@
+package ignore
+
+import "testing"
+
+func TestIgnored(t *testing.T) {
+	m := Parse("", []byte(`# comment
+
+*.tmp
+drafts/
+/top.jpg
+raw/**/*.png
+!keep.tmp
+`))
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"a.tmp", false, true},
+		{"nested/deep/b.tmp", false, true},
+		{"keep.tmp", false, false},
+		{"drafts", true, true},
+		{"nested/drafts", true, true},
+		{"drafts", false, false},
+		{"top.jpg", false, true},
+		{"nested/top.jpg", false, false},
+		{"raw/x.png", false, true},
+		{"raw/a/b/x.png", false, true},
+		{"raw/x.jpg", false, false},
+		{"beach.jpg", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestWithScopesRulesToTheirDirectory(t *testing.T) {
+	m := Parse("", []byte("*.png\n")).With("sub", []byte("!*.png\n/only-here.jpg\n"))
+
+	if !m.Ignored("a.png", false) {
+		t.Error("expected root rule to ignore a.png")
+	}
+	if m.Ignored("sub/a.png", false) {
+		t.Error("expected sub/.gopaperignore to re-include sub/a.png")
+	}
+	if !m.Ignored("sub/only-here.jpg", false) {
+		t.Error("expected anchored rule to match relative to its own directory")
+	}
+	if m.Ignored("only-here.jpg", false) || m.Ignored("other/only-here.jpg", false) {
+		t.Error("expected sub rules not to apply outside sub")
+	}
+}
+
+func TestWithLeavesOriginalUnchanged(t *testing.T) {
+	base := Parse("", []byte("*.tmp\n"))
+	_ = base.With("a", []byte("*.jpg\n"))
+
+	if base.Ignored("a/x.jpg", false) {
+		t.Error("expected With to return a copy, not extend the receiver")
+	}
+}
+
+func TestParseCharacterClassAndEscapes(t *testing.T) {
+	m := Parse("", []byte("img[0-9].jpg\n\\#hash.jpg\nfile[!a].png\n"))
+
+	if !m.Ignored("img7.jpg", false) || m.Ignored("imgx.jpg", false) {
+		t.Error("expected [0-9] class to match digits only")
+	}
+	if !m.Ignored("#hash.jpg", false) {
+		t.Error("expected escaped # to be a literal pattern, not a comment")
+	}
+	if !m.Ignored("fileb.png", false) || m.Ignored("filea.png", false) {
+		t.Error("expected [!a] to negate the class")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	names := []string{"a*b?.jpg", "[draft].png", `back\slash.jpg`, "!bang.jpg", "#hash.jpg", "trailing .jpg ", "plain.jpg"}
+	for _, name := range names {
+		m := Parse("", []byte("/"+Escape(name)))
+		if !m.Ignored(name, false) {
+			t.Errorf("the escaped pattern %q does not match %q", Escape(name), name)
+		}
+		if m.Ignored("other.jpg", false) || m.Ignored("x"+name, false) {
+			t.Errorf("the escaped pattern %q matches more than %q", Escape(name), name)
+		}
+	}
+}
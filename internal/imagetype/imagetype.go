@@ -0,0 +1,342 @@
+// Package imagetype recognizes wallpaper image formats, by file extension
+// or by the file's leading magic bytes, and converts the formats a desktop
+// setter can't display into a cached PNG or JPEG it can.
+package imagetype
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/bmp"  // registers the BMP decoder with image.Decode
+	_ "golang.org/x/image/tiff" // registers the TIFF decoder with image.Decode
+	_ "golang.org/x/image/webp" // registers the WebP decoder with image.Decode
+)
+
+// Format is a recognized image format.
+type Format string
+
+const (
+	Unknown Format = ""
+	JPEG    Format = "jpeg"
+	PNG     Format = "png"
+	WebP    Format = "webp"
+	BMP     Format = "bmp"
+	GIF     Format = "gif"
+	TIFF    Format = "tiff"
+	AVIF    Format = "avif"
+)
+
+// Detection modes for configuration.formats.detection.
+const (
+	DetectExtension = "extension" // trust the file extension only
+	DetectSniff     = "sniff"     // fall back to the magic bytes for unknown extensions
+)
+
+// extensions maps every accepted (lower-case) extension to its format.
+var extensions = map[string]Format{
+	".jpg":  JPEG,
+	".jpeg": JPEG,
+	".jfif": JPEG,
+	".png":  PNG,
+	".webp": WebP,
+	".bmp":  BMP,
+	".gif":  GIF,
+	".tif":  TIFF,
+	".tiff": TIFF,
+	".avif": AVIF,
+}
+
+// Extensions returns the accepted extensions, sorted, for messages and
+// docs.
+func Extensions() []string {
+	out := make([]string, 0, len(extensions))
+	for ext := range extensions {
+		out = append(out, ext)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// FromExtension returns the format name's extension stands for.
+func FromExtension(name string) (Format, bool) {
+	f, ok := extensions[strings.ToLower(filepath.Ext(name))]
+	return f, ok
+}
+
+// headerSize is how many leading bytes Detect needs to tell every format
+// apart.
+const headerSize = 16
+
+// Detect returns the format whose signature header starts with.
+func Detect(header []byte) Format {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return GIF
+	case bytes.HasPrefix(header, []byte("BM")) && len(header) >= 14:
+		return BMP
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return TIFF
+	case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return WebP
+	case len(header) >= 12 && string(header[4:8]) == "ftyp" && (string(header[8:12]) == "avif" || string(header[8:12]) == "avis"):
+		return AVIF
+	}
+	return Unknown
+}
+
+// Sniff reads the leading bytes of the file at path and returns its
+// format.
+func Sniff(path string) (Format, error) {
+	f, err := os.Open(path) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return Unknown, err
+	}
+	defer f.Close()
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Unknown, err
+	}
+	return Detect(header[:n]), nil
+}
+
+// Eligible reports whether the file at path is a wallpaper candidate under
+// mode: a known extension always is (the fast path, no I/O); under
+// DetectSniff a file with any other extension is when its content is a
+// recognized format.
+func Eligible(path, mode string) bool {
+	if _, ok := FromExtension(path); ok {
+		return true
+	}
+	if mode != DetectSniff {
+		return false
+	}
+	f, err := Sniff(path)
+	return err == nil && f != Unknown
+}
+
+// Native reports whether desktop setters display f as-is. GIF and TIFF
+// support varies too much between desktops to rely on; AVIF has no pure-Go
+// decoder, so it is always handed over as-is and needs OS support (e.g.
+// the AV1 extension on Windows).
+func (f Format) Native() bool {
+	switch f {
+	case JPEG, PNG, WebP, BMP, AVIF:
+		return true
+	}
+	return false
+}
+
+// maxCached is how many images a cache directory keeps; older ones are
+// removed after each conversion.
+const maxCached = 20
+
+// Displayable returns a path the desktop setter can display for the image
+// at path: path itself when its extension names a native format, otherwise
+// a PNG (for images with transparency) or JPEG converted into cacheDir.
+// Conversions are keyed by path, size and modification time, so an
+// unchanged image is converted only once. A file without a recognized
+// extension (accepted by sniffing) is converted too, since setters go by
+// the extension.
+func Displayable(path, cacheDir string) (string, error) {
+	if f, ok := FromExtension(path); ok && f.Native() {
+		return path, nil
+	}
+	format, err := Sniff(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+	if format == Unknown {
+		return "", fmt.Errorf("%s is not a recognized image", path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+	key := Key(path, info)
+	if cached, ok := Cached(cacheDir, key); ok {
+		return cached, nil
+	}
+
+	img, err := decode(path, format)
+	if err != nil {
+		return "", err
+	}
+	return Store(img, cacheDir, key)
+}
+
+// Key returns a cache key for the image at path with the given file info,
+// which changes whenever the file is modified; extra strings (e.g. the
+// processing applied) are folded into it.
+func Key(path string, info os.FileInfo, extra ...string) string {
+	parts := append([]string{path, strconv.FormatInt(info.Size(), 10), strconv.FormatInt(info.ModTime().UnixNano(), 10)}, extra...)
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Cached returns the image stored in cacheDir under key, if any, marking it
+// recently used.
+func Cached(cacheDir, key string) (string, bool) {
+	for _, ext := range []string{".png", ".jpg"} {
+		cached := filepath.Join(cacheDir, key+ext)
+		if _, err := os.Stat(cached); err == nil {
+			now := time.Now()
+			_ = os.Chtimes(cached, now, now) // keep recently used entries out of the prune
+			return cached, true
+		}
+	}
+	return "", false
+}
+
+// Store writes img into cacheDir under key (see Cached) and prunes the
+// directory down to its most recently used entries.
+func Store(img image.Image, cacheDir, key string) (string, error) {
+	out, err := write(img, cacheDir, key)
+	if err != nil {
+		return "", err
+	}
+	prune(cacheDir, out)
+	return out, nil
+}
+
+// Decode reads the image at path, whatever its extension, taking the first
+// frame of an animated GIF.
+func Decode(path string) (image.Image, error) {
+	format, err := Sniff(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	if format == Unknown {
+		return nil, fmt.Errorf("%s is not a recognized image", path)
+	}
+	return decode(path, format)
+}
+
+// decode reads the image at path, taking the first frame of an animated
+// GIF.
+func decode(path string, format Format) (image.Image, error) {
+	if format == AVIF {
+		return nil, fmt.Errorf("%s: AVIF images can't be converted, give the file an .avif extension to hand it to the desktop as-is", path)
+	}
+	f, err := os.Open(path) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	defer f.Close()
+	var img image.Image
+	if format == GIF {
+		img, err = gif.Decode(f)
+	} else {
+		img, _, err = image.Decode(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// write encodes img into cacheDir as key.png when it has transparency and
+// key.jpg otherwise, atomically (temp file + rename).
+func write(img image.Image, cacheDir, key string) (string, error) {
+	if err := os.MkdirAll(cacheDir, 0o750); err != nil {
+		return "", fmt.Errorf("could not create conversion cache directory: %w", err)
+	}
+	var buf bytes.Buffer
+	ext := ".jpg"
+	if opaque(img) {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+			return "", fmt.Errorf("could not encode converted image: %w", err)
+		}
+	} else {
+		ext = ".png"
+		if err := png.Encode(&buf, img); err != nil {
+			return "", fmt.Errorf("could not encode converted image: %w", err)
+		}
+	}
+
+	out := filepath.Join(cacheDir, key+ext)
+	tmp, err := os.CreateTemp(cacheDir, ".convert-*")
+	if err != nil {
+		return "", fmt.Errorf("could not write converted image: %w", err)
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("could not write converted image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("could not write converted image: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), out); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("could not write converted image: %w", err)
+	}
+	return out, nil
+}
+
+// opaque reports whether img has no transparent pixels.
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// prune removes the oldest conversions beyond maxCached, never keep.
+func prune(cacheDir, keep string) {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		path string
+		mod  int64
+	}
+	var files []cached
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cached{filepath.Join(cacheDir, e.Name()), info.ModTime().UnixNano()})
+	}
+	if len(files) <= maxCached {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod > files[j].mod })
+	for _, f := range files[maxCached:] {
+		if f.path != keep {
+			_ = os.Remove(f.path)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package imagetype
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   Format
+	}{
+		{"jpeg", "\xFF\xD8\xFF\xE0\x00\x10JFIF", JPEG},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00", PNG},
+		{"gif", "GIF89a\x01\x00", GIF},
+		{"bmp", "BM\x36\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00", BMP},
+		{"tiff little-endian", "II*\x00\x08\x00", TIFF},
+		{"tiff big-endian", "MM\x00*\x00\x00", TIFF},
+		{"webp", "RIFF\x24\x00\x00\x00WEBPVP8 ", WebP},
+		{"avif", "\x00\x00\x00\x1cftypavif\x00\x00", AVIF},
+		{"text", "hello, world", Unknown},
+		{"short bmp-like", "BM", Unknown},
+		{"empty", "", Unknown},
+	}
+	for _, tt := range tests {
+		if got := Detect([]byte(tt.header)); got != tt.want {
+			t.Errorf("%s: Detect() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEligible(t *testing.T) {
+	dir := t.TempDir()
+	noExt := filepath.Join(dir, "IMG_0001")
+	text := filepath.Join(dir, "notes.txt")
+	writeFile(t, noExt, []byte("GIF89a\x01\x00\x01\x00"))
+	writeFile(t, text, []byte("not an image"))
+
+	if !Eligible(filepath.Join(dir, "missing.TIFF"), DetectExtension) {
+		t.Error("expected a known extension to be eligible without reading the file")
+	}
+	if Eligible(noExt, DetectExtension) {
+		t.Error("expected a file without an image extension to be rejected under extension detection")
+	}
+	if !Eligible(noExt, DetectSniff) {
+		t.Error("expected sniffing to accept a GIF without an extension")
+	}
+	if Eligible(text, DetectSniff) {
+		t.Error("expected sniffing to reject a text file")
+	}
+}
+
+func TestDisplayableKeepsNativeFormats(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a.webp")
+	got, err := Displayable(p, t.TempDir())
+	if err != nil || got != p {
+		t.Errorf("Displayable() = (%q, %v), want the path unchanged", got, err)
+	}
+}
+
+func TestDisplayableConvertsAndCaches(t *testing.T) {
+	dir := t.TempDir()
+	cache := filepath.Join(t.TempDir(), "converted")
+
+	// A GIF under a wrong-for-the-setter name converts to JPEG: it's opaque.
+	src := filepath.Join(dir, "frame")
+	var buf bytes.Buffer
+	pal := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White})
+	if err := gif.Encode(&buf, pal, nil); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, src, buf.Bytes())
+
+	out, err := Displayable(src, cache)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Dir(out) != cache || !strings.HasSuffix(out, ".jpg") {
+		t.Errorf("got %q, want a .jpg inside %s", out, cache)
+	}
+	again, err := Displayable(src, cache)
+	if err != nil || again != out {
+		t.Errorf("second call = (%q, %v), want the cached %q", again, err, out)
+	}
+
+	// Transparency survives as PNG.
+	clear := filepath.Join(dir, "clear.tiff")
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	buf.Reset()
+	if err := png.Encode(&buf, img); err != nil { // the decoder goes by content, not extension
+		t.Fatal(err)
+	}
+	writeFile(t, clear, buf.Bytes())
+	out, err = Displayable(clear, cache)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out, ".png") {
+		t.Errorf("got %q, want a .png for a transparent image", out)
+	}
+}
+
+func TestDisplayableRejectsNonImages(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "notes")
+	writeFile(t, src, []byte("not an image"))
+	if _, err := Displayable(src, t.TempDir()); err == nil {
+		t.Error("expected error for a file that isn't an image, got nil")
+	}
+}
+
+func writeFile(t *testing.T, p string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(p, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
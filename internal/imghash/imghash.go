@@ -0,0 +1,221 @@
+// Package imghash computes perceptual hashes of images, which stay close
+// for the same picture at another resolution, compression or slight crop,
+// and groups near-duplicates by the Hamming distance between them.
+package imghash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// Hash algorithms.
+const (
+	// PHash is a DCT-based hash: robust to scaling, compression and small
+	// color changes.
+	PHash = "phash"
+	// DHash is a gradient hash: cheaper, a little more sensitive to edits.
+	DHash = "dhash"
+)
+
+// Hashes holds both 64-bit hashes of an image.
+type Hashes struct {
+	P uint64 `json:"phash"`
+	D uint64 `json:"dhash"`
+}
+
+// Of returns the hash of the given algorithm; anything but DHash is PHash.
+func (h Hashes) Of(algorithm string) uint64 {
+	if algorithm == DHash {
+		return h.D
+	}
+	return h.P
+}
+
+// Options decide when two images count as the same picture.
+type Options struct {
+	Algorithm string // PHash (default) or DHash
+	Threshold int    // maximum Hamming distance, 0-64
+}
+
+// Distance returns how many bits of the options' hash differ between a
+// and b.
+func (o Options) Distance(a, b Hashes) int {
+	return Distance(a.Of(o.Algorithm), b.Of(o.Algorithm))
+}
+
+// Similar reports whether a and b are within the options' threshold.
+func (o Options) Similar(a, b Hashes) bool {
+	return o.Distance(a, b) <= o.Threshold
+}
+
+// ParseAlgorithm validates a configured algorithm name; "" is PHash.
+func ParseAlgorithm(s string) (string, error) {
+	switch s {
+	case "", PHash:
+		return PHash, nil
+	case DHash:
+		return DHash, nil
+	}
+	return "", fmt.Errorf("unknown hash algorithm %q: use phash or dhash", s)
+}
+
+// Distance returns the Hamming distance between two hashes: 0 for
+// identical ones, up to 64.
+func Distance(a, b uint64) int { return bits.OnesCount64(a ^ b) }
+
+// Compute returns both hashes of img.
+func Compute(img image.Image) Hashes {
+	return Hashes{P: pHash(img), D: dHash(img)}
+}
+
+// dHash compares each of the 8×8 cells of a 9×8 grayscale thumbnail with
+// its right neighbor.
+func dHash(img image.Image) uint64 {
+	g := grayGrid(img, 9, 8)
+	var h uint64
+	for y := range 8 {
+		for x := range 8 {
+			h <<= 1
+			if g[y*9+x] < g[y*9+x+1] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// pHash takes the 2D DCT of a 32×32 grayscale thumbnail and sets one bit
+// per coefficient of its lowest 8×8 frequencies, by whether it is above
+// their median (the DC term, the mean brightness, is left out of the
+// median).
+func pHash(img image.Image) uint64 {
+	const n = 32
+	g := grayGrid(img, n, n)
+
+	// Separable DCT-II, rows then columns, keeping only the 8 lowest
+	// frequencies in each direction.
+	var cos [8][n]float64
+	for u := range 8 {
+		for x := range n {
+			cos[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n))
+		}
+	}
+	var rows [n][8]float64
+	for y := range n {
+		for u := range 8 {
+			var s float64
+			for x := range n {
+				s += g[y*n+x] * cos[u][x]
+			}
+			rows[y][u] = s
+		}
+	}
+	coeffs := make([]float64, 0, 64)
+	for v := range 8 {
+		for u := range 8 {
+			var s float64
+			for y := range n {
+				s += rows[y][u] * cos[v][y]
+			}
+			coeffs = append(coeffs, s)
+		}
+	}
+
+	sorted := slices.Clone(coeffs[1:])
+	slices.Sort(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	var h uint64
+	for _, c := range coeffs {
+		h <<= 1
+		if c > median {
+			h |= 1
+		}
+	}
+	return h
+}
+
+// cellSamples bounds how many pixels per axis are averaged into one cell of
+// a grayscale grid, so a large photo costs the same as a small one.
+const cellSamples = 16
+
+// grayGrid shrinks img to a w×h grid of mean luma values (0-255), each
+// cell averaging an evenly spaced sample of the pixels it covers.
+func grayGrid(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	grid := make([]float64, w*h)
+	if b.Empty() {
+		return grid
+	}
+	ycc, _ := img.(*image.YCbCr)
+	luma := func(x, y int) float64 {
+		if ycc != nil {
+			return float64(ycc.Y[ycc.YOffset(x, y)])
+		}
+		return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+	}
+	for cy := range h {
+		y0, y1 := b.Min.Y+cy*b.Dy()/h, b.Min.Y+(cy+1)*b.Dy()/h
+		for cx := range w {
+			x0, x1 := b.Min.X+cx*b.Dx()/w, b.Min.X+(cx+1)*b.Dx()/w
+			grid[cy*w+cx] = cellMean(luma, x0, max(x1, x0+1), y0, max(y1, y0+1))
+		}
+	}
+	return grid
+}
+
+// cellMean averages luma over up to cellSamples×cellSamples evenly spaced
+// pixels of [x0,x1)×[y0,y1).
+func cellMean(luma func(x, y int) float64, x0, x1, y0, y1 int) float64 {
+	sx, sy := min(x1-x0, cellSamples), min(y1-y0, cellSamples)
+	var sum float64
+	for j := range sy {
+		y := y0 + (2*j+1)*(y1-y0)/(2*sy)
+		for i := range sx {
+			sum += luma(x0+(2*i+1)*(x1-x0)/(2*sx), y)
+		}
+	}
+	return sum / float64(sx*sy)
+}
+
+// Group returns the groups of near-duplicates among hashes: the indexes of
+// hashes linked by chains of pairs within opts' threshold, for groups of
+// two or more. Groups and their members are in index order.
+func Group(hashes []Hashes, opts Options) [][]int {
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if opts.Similar(hashes[i], hashes[j]) {
+				if ri, rj := find(i), find(j); ri != rj {
+					parent[max(ri, rj)] = min(ri, rj)
+				}
+			}
+		}
+	}
+
+	members := map[int][]int{}
+	for i := range hashes {
+		r := find(i)
+		members[r] = append(members[r], i)
+	}
+	var groups [][]int
+	for i := range hashes {
+		if g := members[i]; len(g) > 1 {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
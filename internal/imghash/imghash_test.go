@@ -0,0 +1,104 @@
+package imghash
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// scene draws a w×h picture of a diagonal gradient with a bright disc and
+// a dark bar, at positions relative to the size so every resolution shows
+// the same picture.
+func scene(w, h int, discX float64) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			fx, fy := float64(x)/float64(w), float64(y)/float64(h)
+			v := 40 + 120*(fx+fy)/2
+			if math.Hypot(fx-discX, fy-0.4) < 0.2 {
+				v = 240
+			}
+			if fy > 0.75 && fy < 0.85 {
+				v = 10
+			}
+			img.Set(x, y, color.RGBA{uint8(v), uint8(v * 0.8), uint8(v * 0.6), 255})
+		}
+	}
+	return img
+}
+
+// reencode round-trips img through a low-quality JPEG.
+func reencode(t *testing.T, img image.Image) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSamePictureAtAnotherResolution(t *testing.T) {
+	big := Compute(scene(1600, 1000, 0.3))
+	small := Compute(reencode(t, scene(400, 250, 0.3)))
+	other := Compute(scene(1600, 1000, 0.75))
+
+	for _, alg := range []string{PHash, DHash} {
+		opts := Options{Algorithm: alg}
+		if d := opts.Distance(big, small); d > 6 {
+			t.Errorf("%s: distance between resolutions = %d, want at most 6", alg, d)
+		}
+		if d := opts.Distance(big, other); d < 12 {
+			t.Errorf("%s: distance between different pictures = %d, want at least 12", alg, d)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d := Distance(0, 0); d != 0 {
+		t.Errorf("Distance(0, 0) = %d", d)
+	}
+	if d := Distance(0b1011, 0b0010); d != 2 {
+		t.Errorf("Distance = %d, want 2", d)
+	}
+	if d := Distance(0, math.MaxUint64); d != 64 {
+		t.Errorf("Distance = %d, want 64", d)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	hashes := []Hashes{
+		{P: 0b0000},
+		{P: 0xFFFF_0000},
+		{P: 0b0001},      // near 0
+		{P: 0xFFFF_0001}, // near 1
+		{P: 0b0011},      // near 2, so chained to 0
+		{P: 0xF0F0_F0F0},
+	}
+	got := Group(hashes, Options{Algorithm: PHash, Threshold: 1})
+	want := [][]int{{0, 2, 4}, {1, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Group() = %v, want %v", got, want)
+	}
+	if got := Group(hashes, Options{Algorithm: DHash, Threshold: 1}); len(got) != 1 || len(got[0]) != 6 {
+		t.Errorf("all-zero dhashes should form one group, got %v", got)
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for in, want := range map[string]string{"": PHash, "phash": PHash, "dhash": DHash} {
+		if got, err := ParseAlgorithm(in); err != nil || got != want {
+			t.Errorf("ParseAlgorithm(%q) = (%q, %v), want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseAlgorithm("ahash"); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
@@ -0,0 +1,354 @@
+// Package index keeps an on-disk listing of a category source directory so
+// large libraries (tens of thousands of files, often on a network share)
+// don't have to be re-listed and stat'ed on every run. A refresh only
+// re-reads the directories whose modification time changed since the last
+// scan; a TTL forces a full rescan now and then to pick up changes a
+// directory's mtime doesn't reflect (a file rewritten in place).
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/ignore"
+)
+
+// Unlimited is the depth of an index that descends into every
+// subdirectory.
+const Unlimited = -1
+
+// Index is the listing of one source directory, scanned Depth levels of
+// subdirectories deep (0 = the root only, Unlimited = all of them).
+// ScannedAt is the time of the last full scan; refreshes don't move it.
+type Index struct {
+	Root      string          `json:"root"`
+	Depth     int             `json:"depth"`
+	ScannedAt time.Time       `json:"scanned_at"`
+	Dirs      map[string]*Dir `json:"dirs"` // keyed by slash-separated path relative to Root ("" = Root)
+}
+
+// Dir is one scanned directory.
+type Dir struct {
+	ModTime       time.Time `json:"mtime"`
+	Ignore        string    `json:"ignore,omitempty"` // contents of its .gopaperignore
+	IgnoreModTime time.Time `json:"ignore_mtime,omitzero"`
+	Subdirs       []string  `json:"subdirs,omitempty"`
+	Files         []File    `json:"files,omitempty"`
+}
+
+// File is one regular file as of the last scan.
+type File struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// Scan lists root from scratch, descending depth levels of subdirectories.
+// Only an unreadable root is an error; unreadable subdirectories are left
+// out.
+func Scan(root string, depth int) (*Index, error) {
+	idx := &Index{Root: root, Depth: depth, ScannedAt: time.Now(), Dirs: map[string]*Dir{}}
+	if err := idx.scanDir("", 0); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Refresh brings idx up to date by re-reading only the directories whose
+// own mtime (or .gopaperignore mtime) changed, picking up new and removed
+// subdirectories along the way. It reports whether anything changed.
+func (idx *Index) Refresh() (bool, error) {
+	changed := false
+	for _, rel := range idx.dirNames() {
+		d, ok := idx.Dirs[rel]
+		if !ok {
+			continue // dropped with a removed parent earlier in this pass
+		}
+		abs := idx.abs(rel)
+		info, err := os.Stat(abs)
+		if err != nil || !info.IsDir() {
+			if rel == "" {
+				return changed, fmt.Errorf("could not read source directory %s: %w", idx.Root, err)
+			}
+			idx.drop(rel)
+			changed = true
+			continue
+		}
+		if info.ModTime().Equal(d.ModTime) && ignoreModTime(abs).Equal(d.IgnoreModTime) {
+			continue
+		}
+		if err := idx.scanDir(rel, depthOf(rel)); err != nil {
+			if rel == "" {
+				return changed, err
+			}
+			idx.drop(rel)
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// scanDir (re)reads the directory rel, found depth levels below Root, and
+// recurses into subdirectories that are new or whose contents are unknown.
+// The subtrees of subdirectories that disappeared are dropped.
+func (idx *Index) scanDir(rel string, depth int) error {
+	abs := idx.abs(rel)
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("could not read source directory %s: %w", abs, err)
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return fmt.Errorf("could not read source directory %s: %w", abs, err)
+	}
+
+	d := &Dir{ModTime: info.ModTime()}
+	if data, err := os.ReadFile(filepath.Join(abs, ignore.FileName)); err == nil { // #nosec G304 -- inside a configured category source
+		d.Ignore = string(data)
+		d.IgnoreModTime = ignoreModTime(abs)
+	}
+
+	previous := idx.Dirs[rel]
+
+	for _, e := range entries {
+		if e.IsDir() {
+			d.Subdirs = append(d.Subdirs, e.Name())
+			continue
+		}
+		if e.Name() == ignore.FileName {
+			continue
+		}
+		fi, err := e.Info()
+		if e.Type()&fs.ModeSymlink != 0 {
+			fi, err = os.Stat(filepath.Join(abs, e.Name())) // size and mtime of the link's target
+		}
+		if err != nil {
+			continue
+		}
+		d.Files = append(d.Files, File{Name: e.Name(), Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+	idx.Dirs[rel] = d
+
+	if previous != nil {
+		for _, name := range previous.Subdirs {
+			if !slices.Contains(d.Subdirs, name) {
+				idx.drop(path.Join(rel, name))
+			}
+		}
+	}
+	if idx.Depth != Unlimited && depth >= idx.Depth {
+		return nil
+	}
+	for _, name := range d.Subdirs {
+		child := path.Join(rel, name)
+		if _, ok := idx.Dirs[child]; ok {
+			continue // already indexed; Refresh checks it on its own
+		}
+		_ = idx.scanDir(child, depth+1)
+	}
+	return nil
+}
+
+// drop removes rel and everything below it.
+func (idx *Index) drop(rel string) {
+	for k := range idx.Dirs {
+		if k == rel || strings.HasPrefix(k, rel+"/") {
+			delete(idx.Dirs, k)
+		}
+	}
+}
+
+// dirNames returns the indexed directories, parents before children.
+func (idx *Index) dirNames() []string {
+	names := make([]string, 0, len(idx.Dirs))
+	for k := range idx.Dirs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (idx *Index) abs(rel string) string {
+	return filepath.Join(idx.Root, filepath.FromSlash(rel))
+}
+
+// List returns the entries of the indexed directory rel (slash-separated,
+// relative to Root): its files, whose Info comes from the index instead of
+// a stat, followed by its subdirectories. The second result is false when
+// rel was not indexed (unreadable, or deeper than Depth).
+func (idx *Index) List(rel string) ([]os.DirEntry, bool) {
+	d, ok := idx.Dirs[rel]
+	if !ok {
+		return nil, false
+	}
+	entries := make([]os.DirEntry, 0, len(d.Files)+len(d.Subdirs))
+	for _, f := range d.Files {
+		entries = append(entries, fileEntry{f})
+	}
+	for _, name := range d.Subdirs {
+		entries = append(entries, dirEntry(name))
+	}
+	return entries, true
+}
+
+// IgnoreFile returns the contents of the .gopaperignore in directory rel,
+// or nil when it has none.
+func (idx *Index) IgnoreFile(rel string) []byte {
+	if d, ok := idx.Dirs[rel]; ok && d.Ignore != "" {
+		return []byte(d.Ignore)
+	}
+	return nil
+}
+
+// FileCount returns the number of files in the index.
+func (idx *Index) FileCount() int {
+	n := 0
+	for _, d := range idx.Dirs {
+		n += len(d.Files)
+	}
+	return n
+}
+
+func ignoreModTime(dir string) time.Time {
+	info, err := os.Stat(filepath.Join(dir, ignore.FileName))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func depthOf(rel string) int {
+	if rel == "" {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+// Store keeps indexes as JSON files in Dir, one per source directory and
+// depth. TTL is how old an index may get before it is rescanned from
+// scratch instead of refreshed; 0 disables the full rescan.
+type Store struct {
+	Dir string
+	TTL time.Duration
+}
+
+// Open returns the up-to-date index of root at depth: the stored one,
+// refreshed (or rescanned once older than TTL), or a fresh scan when none
+// is stored. The result is written back when it changed; failing to write
+// it is not an error, since the listing itself is still valid.
+func (s Store) Open(root string, depth int) (*Index, error) {
+	p := s.path(root, depth)
+	idx, err := load(p)
+	if err != nil || idx.Root != root || idx.Depth != depth || (s.TTL > 0 && time.Since(idx.ScannedAt) > s.TTL) {
+		return s.Rebuild(root, depth)
+	}
+	changed, err := idx.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		_ = s.save(p, idx)
+	}
+	return idx, nil
+}
+
+// Rebuild rescans root from scratch and stores the result.
+func (s Store) Rebuild(root string, depth int) (*Index, error) {
+	idx, err := Scan(root, depth)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(s.path(root, depth), idx); err != nil {
+		return idx, err
+	}
+	return idx, nil
+}
+
+// path names the index file of root at depth after a hash of both, so any
+// directory maps to a safe, fixed-length file name.
+func (s Store) path(root string, depth int) string {
+	sum := sha256.Sum256([]byte(root + "\x00" + strconv.Itoa(depth)))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func load(p string) (*Index, error) {
+	data, err := os.ReadFile(p) // #nosec G304 -- path is built from the configured index directory
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	if idx.Dirs == nil {
+		return nil, fmt.Errorf("index %s has no directories", p)
+	}
+	return &idx, nil
+}
+
+// save writes idx atomically (temp file + rename) so a concurrent run
+// never reads a half-written index.
+func (s Store) save(p string, idx *Index) error {
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		return fmt.Errorf("could not create index directory: %w", err)
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("could not encode index: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".index-*")
+	if err != nil {
+		return fmt.Errorf("could not write index: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write index: %w", err)
+	}
+	return nil
+}
+
+// fileEntry is an indexed file as an os.DirEntry.
+type fileEntry struct{ f File }
+
+func (e fileEntry) Name() string               { return e.f.Name }
+func (e fileEntry) IsDir() bool                { return false }
+func (e fileEntry) Type() fs.FileMode          { return 0 }
+func (e fileEntry) Info() (os.FileInfo, error) { return fileInfo(e), nil }
+
+// fileInfo answers Size/ModTime from the index, without touching the disk.
+type fileInfo struct{ f File }
+
+func (i fileInfo) Name() string       { return i.f.Name }
+func (i fileInfo) Size() int64        { return i.f.Size }
+func (i fileInfo) Mode() fs.FileMode  { return 0o444 }
+func (i fileInfo) ModTime() time.Time { return i.f.ModTime }
+func (i fileInfo) IsDir() bool        { return false }
+func (i fileInfo) Sys() any           { return nil }
+
+// dirEntry is an indexed subdirectory as an os.DirEntry.
+type dirEntry string
+
+func (e dirEntry) Name() string               { return string(e) }
+func (e dirEntry) IsDir() bool                { return true }
+func (e dirEntry) Type() fs.FileMode          { return fs.ModeDir }
+func (e dirEntry) Info() (os.FileInfo, error) { return nil, fs.ErrInvalid }
@@ -0,0 +1,174 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, root, rel string, size int) {
+	t.Helper()
+	p := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, make([]byte, size), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// touchDir moves dir's mtime forward so a refresh sees it as changed even
+// on file systems with coarse timestamps.
+func touchDir(t *testing.T, dir string) {
+	t.Helper()
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(dir, future, future); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fileNames(idx *Index, rel string) []string {
+	entries, _ := idx.List(rel)
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestScanRecordsFilesAndDepth(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "a.jpg", 10)
+	writeFile(t, root, "1/b.jpg", 20)
+	writeFile(t, root, "1/2/c.jpg", 30)
+	writeFile(t, root, ".gopaperignore", 0)
+
+	idx, err := Scan(root, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fileNames(idx, ""); !slices.Equal(got, []string{"a.jpg"}) {
+		t.Errorf("root files = %v, want [a.jpg] (the ignore file is not a candidate)", got)
+	}
+	if got := fileNames(idx, "1"); !slices.Equal(got, []string{"b.jpg"}) {
+		t.Errorf("1 files = %v, want [b.jpg]", got)
+	}
+	if _, ok := idx.List("1/2"); ok {
+		t.Error("expected 1/2 to be beyond depth 1")
+	}
+
+	entries, _ := idx.List("1")
+	info, err := entries[0].Info()
+	if err != nil || info.Size() != 20 {
+		t.Errorf("Info() = (%v, %v), want size 20 from the index", info, err)
+	}
+}
+
+func TestScanMissingRootIsAnError(t *testing.T) {
+	if _, err := Scan(filepath.Join(t.TempDir(), "missing"), Unlimited); err == nil {
+		t.Error("expected error for a missing root, got nil")
+	}
+}
+
+func TestRefreshRereadsOnlyChangedDirectories(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "a.jpg", 10)
+	writeFile(t, root, "keep/b.jpg", 10)
+	writeFile(t, root, "gone/c.jpg", 10)
+
+	idx, err := Scan(root, Unlimited)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keep := idx.Dirs["keep"]
+
+	if changed, err := idx.Refresh(); err != nil || changed {
+		t.Fatalf("Refresh() on an untouched tree = (%v, %v), want (false, nil)", changed, err)
+	}
+
+	writeFile(t, root, "new/d.jpg", 10)
+	if err := os.RemoveAll(filepath.Join(root, "gone")); err != nil {
+		t.Fatal(err)
+	}
+	touchDir(t, root)
+
+	changed, err := idx.Refresh()
+	if err != nil || !changed {
+		t.Fatalf("Refresh() = (%v, %v), want (true, nil)", changed, err)
+	}
+	if _, ok := idx.List("gone"); ok {
+		t.Error("expected the removed directory to be dropped")
+	}
+	if got := fileNames(idx, "new"); !slices.Equal(got, []string{"d.jpg"}) {
+		t.Errorf("new files = %v, want [d.jpg]", got)
+	}
+	if idx.Dirs["keep"] != keep {
+		t.Error("expected the unchanged directory not to be re-read")
+	}
+}
+
+func TestRefreshPicksUpIgnoreFileChanges(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "a.jpg", 10)
+
+	idx, err := Scan(root, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if idx.IgnoreFile("") != nil {
+		t.Fatal("expected no ignore file yet")
+	}
+
+	if err := os.WriteFile(filepath.Join(root, ".gopaperignore"), []byte("a.jpg\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touchDir(t, root)
+	if _, err := idx.Refresh(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(idx.IgnoreFile("")); got != "a.jpg\n" {
+		t.Errorf("IgnoreFile = %q, want the new rules", got)
+	}
+}
+
+func TestStoreOpenReusesAndRebuilds(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "a.jpg", 10)
+	store := Store{Dir: t.TempDir()}
+
+	first, err := store.Open(root, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := store.Open(root, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !second.ScannedAt.Equal(first.ScannedAt) {
+		t.Error("expected the stored index to be reused")
+	}
+
+	expired := Store{Dir: store.Dir, TTL: time.Nanosecond}
+	time.Sleep(time.Millisecond)
+	third, err := expired.Open(root, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !third.ScannedAt.After(first.ScannedAt) {
+		t.Error("expected an index older than the TTL to be rescanned")
+	}
+
+	other, err := store.Open(root, Unlimited)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if other.Depth != Unlimited {
+		t.Errorf("Depth = %d, want a separate unlimited index", other.Depth)
+	}
+}
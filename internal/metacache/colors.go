@@ -0,0 +1,97 @@
+package metacache
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+)
+
+// Colors summarizes an image's pixels: its mean brightness (relative
+// luminance, 0 = black, 1 = white) and its dominant color as "#rrggbb".
+type Colors struct {
+	Brightness float64 `json:"brightness"`
+	Dominant   string  `json:"dominant"`
+}
+
+// sampleSize is the edge of the grid the image is downscaled to before its
+// colors are measured: plenty to tell dark from bright and blue from red.
+const sampleSize = 64
+
+// Colors returns the color summary of the image at path, whose file info is
+// info, from the cache or by decoding the image and sampling it down to a
+// sampleSize×sampleSize grid.
+func (c *Cache) Colors(path string, info os.FileInfo) (Colors, error) {
+	e, ok := c.Get(path, info)
+	if ok && e.Colors != nil {
+		return *e.Colors, nil
+	}
+	f, err := os.Open(path) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return Colors{}, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return Colors{}, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+	colors := Analyze(img)
+	if !ok {
+		e = Entry{Size: info.Size(), ModTime: info.ModTime()}
+	}
+	b := img.Bounds()
+	e.Width, e.Height = b.Dx(), b.Dy()
+	e.Colors = &colors
+	c.Put(path, e)
+	return colors, nil
+}
+
+// Analyze computes the Colors of img from an evenly spaced grid of pixels.
+// The dominant color is the mean of the most populated cell of a 16-level
+// per-channel quantization.
+func Analyze(img image.Image) Colors {
+	b := img.Bounds()
+	if b.Empty() {
+		return Colors{}
+	}
+	type bucket struct {
+		n       int
+		r, g, b uint64
+	}
+	buckets := map[uint16]*bucket{}
+	var luminance float64
+	samples := 0
+	for sy := range sampleSize {
+		y := b.Min.Y + (sy*b.Dy()+b.Dy()/2)/sampleSize
+		for sx := range sampleSize {
+			x := b.Min.X + (sx*b.Dx()+b.Dx()/2)/sampleSize
+			px := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			luminance += (0.2126*float64(px.R) + 0.7152*float64(px.G) + 0.0722*float64(px.B)) / 255
+			samples++
+
+			key := uint16(px.R>>4)<<8 | uint16(px.G>>4)<<4 | uint16(px.B>>4)
+			bk := buckets[key]
+			if bk == nil {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.n++
+			bk.r += uint64(px.R)
+			bk.g += uint64(px.G)
+			bk.b += uint64(px.B)
+		}
+	}
+
+	var top *bucket
+	var topKey uint16
+	for k, bk := range buckets {
+		if top == nil || bk.n > top.n || (bk.n == top.n && k < topKey) {
+			top, topKey = bk, k
+		}
+	}
+	n := uint64(top.n)
+	return Colors{
+		Brightness: luminance / float64(samples),
+		Dominant:   fmt.Sprintf("#%02x%02x%02x", top.r/n, top.g/n, top.b/n),
+	}
+}
@@ -0,0 +1,35 @@
+package metacache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/lucasassuncao/gopaper/internal/exif"
+)
+
+// Exif returns the EXIF metadata of the image at path, whose file info is
+// info, from the cache or by reading the file's EXIF block. An image
+// without readable EXIF metadata (none recorded, a corrupt block, or a
+// format that has none) yields nil, and that is cached too; only failing
+// to open the file is an error.
+func (c *Cache) Exif(path string, info os.FileInfo) (*exif.Data, error) {
+	e, ok := c.Get(path, info)
+	if ok && e.ExifRead {
+		return e.Exif, nil
+	}
+	data, err := exif.ReadFile(path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return nil, err
+		}
+		data = nil
+	}
+	if !ok {
+		e = Entry{Size: info.Size(), ModTime: info.ModTime()}
+	}
+	e.Exif, e.ExifRead = data, true
+	c.Put(path, e)
+	return data, nil
+}
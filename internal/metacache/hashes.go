@@ -0,0 +1,36 @@
+package metacache
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/lucasassuncao/gopaper/internal/imghash"
+)
+
+// Hashes returns the perceptual hashes of the image at path, whose file
+// info is info, from the cache or by decoding the image.
+func (c *Cache) Hashes(path string, info os.FileInfo) (imghash.Hashes, error) {
+	e, ok := c.Get(path, info)
+	if ok && e.Hashes != nil {
+		return *e.Hashes, nil
+	}
+	f, err := os.Open(path) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return imghash.Hashes{}, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return imghash.Hashes{}, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+	hashes := imghash.Compute(img)
+	if !ok {
+		e = Entry{Size: info.Size(), ModTime: info.ModTime()}
+	}
+	b := img.Bounds()
+	e.Width, e.Height = b.Dx(), b.Dy()
+	e.Hashes = &hashes
+	c.Put(path, e)
+	return hashes, nil
+}
@@ -0,0 +1,154 @@
+// Package metacache remembers what was read out of image files (pixel
+// dimensions, brightness and colors, EXIF metadata, perceptual hashes)
+// between runs, so filters and gopaper dedupe only pay for decoding a file
+// once. An entry stays valid while the file's size and
+// modification time are unchanged.
+package metacache
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"  // registers the GIF decoder with image.DecodeConfig
+	_ "image/jpeg" // registers the JPEG decoder with image.DecodeConfig
+	_ "image/png"  // registers the PNG decoder with image.DecodeConfig
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/exif"
+	"github.com/lucasassuncao/gopaper/internal/imghash"
+
+	_ "golang.org/x/image/bmp"  // registers the BMP decoder with image.DecodeConfig
+	_ "golang.org/x/image/tiff" // registers the TIFF decoder with image.DecodeConfig
+	_ "golang.org/x/image/webp" // registers the WebP decoder with image.DecodeConfig
+)
+
+// Entry is what is known about one image file.
+type Entry struct {
+	Size    int64           `json:"size"`
+	ModTime time.Time       `json:"mtime"`
+	Width   int             `json:"width,omitempty"`
+	Height  int             `json:"height,omitempty"`
+	Colors  *Colors         `json:"colors,omitempty"`
+	Hashes  *imghash.Hashes `json:"hashes,omitempty"`
+
+	Exif     *exif.Data `json:"exif,omitempty"`
+	ExifRead bool       `json:"exif_read,omitempty"` // Exif was looked for (nil = the image has none)
+}
+
+// Cache is a set of entries keyed by absolute path, stored as one JSON
+// file. A nil *Cache is valid and caches nothing.
+type Cache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]Entry
+	dirty   bool
+}
+
+// Open loads the cache stored at path. A missing or unreadable file yields
+// an empty cache: it is only an optimization.
+func Open(path string) *Cache {
+	c := &Cache{path: path, entries: map[string]Entry{}}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is built from the configured history directory
+	if err != nil {
+		return c
+	}
+	var entries map[string]Entry
+	if json.Unmarshal(data, &entries) == nil && entries != nil {
+		c.entries = entries
+	}
+	return c
+}
+
+// Get returns the entry for path if it was recorded for a file of info's
+// size and modification time.
+func (c *Cache) Get(path string, info os.FileInfo) (Entry, bool) {
+	if c == nil {
+		return Entry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[path]
+	if !ok || e.Size != info.Size() || !e.ModTime.Equal(info.ModTime()) {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Put records e for path.
+func (c *Cache) Put(path string, e Entry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = e
+	c.dirty = true
+}
+
+// Save writes the cache back when something was added, atomically (temp
+// file + rename) so a concurrent run never reads a half-written file.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("could not create metadata cache directory: %w", err)
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("could not encode metadata cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".metadata-*")
+	if err != nil {
+		return fmt.Errorf("could not write metadata cache: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write metadata cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write metadata cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write metadata cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// Dimensions returns the pixel size of the image at path, whose file info
+// is info, from the cache or by decoding only the image's header. Formats
+// without a decoder (AVIF) are an error.
+func (c *Cache) Dimensions(path string, info os.FileInfo) (width, height int, err error) {
+	e, ok := c.Get(path, info)
+	if ok && e.Width > 0 {
+		return e.Width, e.Height, nil
+	}
+	f, err := os.Open(path) // #nosec G304 -- inside a configured category source
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not read image dimensions of %s: %w", path, err)
+	}
+	if !ok {
+		e = Entry{Size: info.Size(), ModTime: info.ModTime()}
+	}
+	e.Width, e.Height = cfg.Width, cfg.Height
+	c.Put(path, e)
+	return cfg.Width, cfg.Height, nil
+}
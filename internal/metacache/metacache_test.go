@@ -0,0 +1,146 @@
+package metacache
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePNG(t *testing.T, p string, w, h int) os.FileInfo {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestDimensionsAreCachedAndPersisted(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.png")
+	info := writePNG(t, img, 40, 30)
+	cachePath := filepath.Join(dir, "cache", "metadata.json")
+
+	c := Open(cachePath)
+	w, h, err := c.Dimensions(img, info)
+	if err != nil || w != 40 || h != 30 {
+		t.Fatalf("Dimensions() = (%d, %d, %v), want (40, 30, nil)", w, h, err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Served from the cache: the file itself is no longer readable.
+	if err := os.WriteFile(img, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(img, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := Open(cachePath).Get(img, info)
+	if !ok || e.Width != 40 || e.Height != 30 {
+		t.Errorf("Get() after reopening = (%+v, %v), want the saved dimensions", e, ok)
+	}
+}
+
+func TestGetIgnoresChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.png")
+	info := writePNG(t, img, 40, 30)
+
+	c := Open(filepath.Join(dir, "metadata.json"))
+	if _, _, err := c.Dimensions(img, info); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(img, later, later); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := os.Stat(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(img, changed); ok {
+		t.Error("expected an entry recorded for another modification time to be ignored")
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	img := filepath.Join(t.TempDir(), "a.png")
+	info := writePNG(t, img, 8, 16)
+
+	var c *Cache
+	if w, h, err := c.Dimensions(img, info); err != nil || w != 8 || h != 16 {
+		t.Errorf("Dimensions() on a nil cache = (%d, %d, %v), want (8, 16, nil)", w, h, err)
+	}
+	if err := c.Save(); err != nil {
+		t.Errorf("Save() on a nil cache = %v, want nil", err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	navy := color.RGBA{R: 0x1e, G: 0x3a, B: 0x8a, A: 0xff}
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: navy}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 100, 20), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+
+	got := Analyze(img)
+	if got.Dominant != "#1e3a8a" {
+		t.Errorf("Dominant = %q, want the navy that covers most of the image", got.Dominant)
+	}
+	if got.Brightness < 0.35 || got.Brightness > 0.42 {
+		t.Errorf("Brightness = %v, want 80%% navy + 20%% white (~0.38)", got.Brightness)
+	}
+}
+
+func TestColorsAreCached(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.png")
+	info := writePNG(t, img, 16, 16)
+
+	c := Open(filepath.Join(dir, "metadata.json"))
+	colors, err := c.Colors(img, info)
+	if err != nil || colors.Dominant != "#000000" || colors.Brightness != 0 {
+		t.Fatalf("Colors() = (%+v, %v), want a black image", colors, err)
+	}
+	e, ok := c.Get(img, info)
+	if !ok || e.Colors == nil || e.Width != 16 {
+		t.Errorf("Get() = (%+v, %v), want the colors and dimensions recorded", e, ok)
+	}
+}
+
+func TestHashesAreCachedAndPersisted(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.png")
+	info := writePNG(t, img, 32, 24)
+	cachePath := filepath.Join(dir, "metadata.json")
+
+	c := Open(cachePath)
+	hashes, err := c.Hashes(img, info)
+	if err != nil {
+		t.Fatalf("Hashes() error: %v", err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := Open(cachePath).Get(img, info)
+	if !ok || e.Hashes == nil || *e.Hashes != hashes || e.Width != 32 {
+		t.Errorf("reloaded entry = (%+v, %v), want the hashes and dimensions recorded", e, ok)
+	}
+	if _, err := c.Hashes(filepath.Join(dir, "missing.png"), info); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -1,10 +1,12 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 
 	"github.com/lucasassuncao/yedit/editor"
 	"github.com/lucasassuncao/yedit/metadata"
@@ -24,6 +26,13 @@ type Configuration struct {
 	Weather    *WeatherConfig       `yaml:"weather,omitempty" mapstructure:"weather"`
 	Wallhaven  *WallhavenConfig     `yaml:"wallhaven,omitempty" mapstructure:"wallhaven"`
 	Conditions map[string]Condition `yaml:"conditions,omitempty" mapstructure:"conditions"`
+	Index      *IndexConfig         `yaml:"index,omitempty" mapstructure:"index"`
+	Formats    *FormatsConfig       `yaml:"formats,omitempty" mapstructure:"formats"`
+	Favorites  *FavoritesConfig     `yaml:"favorites,omitempty" mapstructure:"favorites"`
+	Dedupe     *DedupeConfig        `yaml:"dedupe,omitempty" mapstructure:"dedupe"`
+	Theme      *ThemeConfig         `yaml:"theme,omitempty" mapstructure:"theme"`
+	Hooks      *HooksConfig         `yaml:"hooks,omitempty" mapstructure:"hooks"`
+	Output     *OutputConfig        `yaml:"output,omitempty" mapstructure:"output"`
 }
 
 // Behavior groups how a wallpaper change is applied. At configuration level
@@ -31,9 +40,12 @@ type Configuration struct {
 // whose non-empty fields override the defaults when that category is
 // selected.
 type Behavior struct {
-	Transition string `yaml:"transition,omitempty" mapstructure:"transition"`
-	Monitor    string `yaml:"monitor,omitempty" mapstructure:"monitor"`
-	Mode       string `yaml:"mode,omitempty" mapstructure:"mode"`
+	Transition string   `yaml:"transition,omitempty" mapstructure:"transition"`
+	Monitor    string   `yaml:"monitor,omitempty" mapstructure:"monitor"`
+	Mode       string   `yaml:"mode,omitempty" mapstructure:"mode"`
+	Overlay    *Overlay `yaml:"overlay,omitempty" mapstructure:"overlay"`
+	Bezel      *Bezel   `yaml:"bezel,omitempty" mapstructure:"bezel"`
+	Backend    string   `yaml:"backend,omitempty" mapstructure:"backend"`
 }
 
 func (Behavior) Metadata() map[string]*metadata.Node {
@@ -46,10 +58,11 @@ func (Behavior) Metadata() map[string]*metadata.Node {
 		"monitor": {FieldMeta: editor.FieldMeta{
 			Description: "How wallpapers are applied on multi-monitor setups. \"all\" mirrors one image " +
 				"on every monitor (fade works); \"per-monitor\" gives each monitor its own image (always " +
-				"instant); \"monitor1\", \"monitor2\", etc. pin this category to one specific monitor, " +
-				"leaving the others untouched (always instant). On a category, this decides what happens " +
-				"when that category wins the draw.",
-			Pattern: `^(all|per-monitor|monitor[1-9][0-9]*)$`,
+				"instant); \"panorama\" spreads one image across every monitor as laid out in the display " +
+				"settings, skipping the bezel gaps (always instant); \"monitor1\", \"monitor2\", etc. pin " +
+				"this category to one specific monitor, leaving the others untouched (always instant). On a " +
+				"category, this decides what happens when that category wins the draw.",
+			Pattern: `^(all|per-monitor|panorama|monitor[1-9][0-9]*)$`,
 			Default: "all",
 		}},
 		"mode": {FieldMeta: editor.FieldMeta{
@@ -57,6 +70,94 @@ func (Behavior) Metadata() map[string]*metadata.Node {
 			OneOf:       []string{"crop", "tile", "stretch", "span", "fit", "center"},
 			Default:     "crop",
 		}},
+		"overlay": {FieldMeta: editor.FieldMeta{
+			Description: "Text rendered onto the wallpaper (date, weather, quote, hostname). On a category, each field set overrides the configuration-level overlay's.",
+		}},
+		"bezel": {FieldMeta: editor.FieldMeta{
+			Description: "Width of the monitor frames between adjacent screens, skipped by monitor: panorama so the image lines up across them. On a category, each field set overrides the configuration-level bezel's.",
+		}},
+		"backend": {FieldMeta: editor.FieldMeta{
+			Description: "How wallpapers are set. \"auto\" uses IDesktopWallpaper on Windows and detects the desktop elsewhere; the others drive a Linux desktop's own tool: gnome (gsettings), kde (qdbus), xfce (xfconf-query), sway (swaymsg), hyprland (hyprctl hyprpaper), swww, feh or nitrogen. \"file\" publishes the images as files instead, as configured in configuration.output. Configuration level only.",
+			OneOf:       []string{"auto", "gnome", "kde", "xfce", "sway", "hyprland", "swww", "feh", "nitrogen", "file"},
+			Default:     "auto",
+		}},
+	}
+}
+
+// Bezel is the gap, in pixels of the image, the monitor frames leave between
+// adjacent screens. A panorama skips that much of the image at every seam so
+// straight lines stay straight across monitors.
+type Bezel struct {
+	Horizontal int `yaml:"horizontal,omitempty" mapstructure:"horizontal"`
+	Vertical   int `yaml:"vertical,omitempty" mapstructure:"vertical"`
+}
+
+func (Bezel) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"horizontal": {FieldMeta: editor.FieldMeta{
+			Description: "Pixels skipped between monitors side by side: both frames' width, measured at the monitors' pixel density.",
+			Min:         "0",
+			Default:     "0",
+		}},
+		"vertical": {FieldMeta: editor.FieldMeta{
+			Description: "Pixels skipped between monitors stacked on top of each other.",
+			Min:         "0",
+			Default:     "0",
+		}},
+	}
+}
+
+// Overlay renders lines of text (the date, the weather, a quote, the
+// hostname) onto a copy of the wallpaper. A category's overlay overrides
+// the configuration-level one field by field.
+type Overlay struct {
+	Enabled    *bool    `yaml:"enabled,omitempty" mapstructure:"enabled"`
+	Show       []string `yaml:"show,omitempty" mapstructure:"show"`
+	Corner     string   `yaml:"corner,omitempty" mapstructure:"corner"`
+	FontSize   float64  `yaml:"font-size,omitempty" mapstructure:"font-size"`
+	Color      string   `yaml:"color,omitempty" mapstructure:"color"`
+	Shadow     *bool    `yaml:"shadow,omitempty" mapstructure:"shadow"`
+	DateFormat string   `yaml:"date-format,omitempty" mapstructure:"date-format"`
+	QuoteFile  string   `yaml:"quote-file,omitempty" mapstructure:"quote-file"`
+}
+
+func (Overlay) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"enabled": {FieldMeta: editor.FieldMeta{
+			Description: "Set to false on a category to turn off the overlay it would inherit.",
+			Default:     "true",
+		}},
+		"show": {FieldMeta: editor.FieldMeta{
+			Description: "The lines to render, top to bottom: date, weather, quote, hostname. weather needs configuration.weather; quote needs quote-file.",
+			Example:     "show: [date, weather]",
+		}},
+		"corner": {FieldMeta: editor.FieldMeta{
+			Description: "Corner of the wallpaper the text is placed in.",
+			OneOf:       []string{"top-left", "top-right", "bottom-left", "bottom-right"},
+			Default:     "bottom-right",
+		}},
+		"font-size": {FieldMeta: editor.FieldMeta{
+			Description: "Text height in pixels of the image (after process steps; use resize-to-monitor for screen pixels). Defaults to 1/36 of the image height.",
+			Example:     "font-size: 28",
+		}},
+		"color": {FieldMeta: editor.FieldMeta{
+			Description: "Text color, as #rrggbb or #rrggbbaa.",
+			Pattern:     `^#([0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`,
+			Default:     "#ffffff",
+		}},
+		"shadow": {FieldMeta: editor.FieldMeta{
+			Description: "Draws a dark drop shadow behind the text, for legibility on light images.",
+			Default:     "true",
+		}},
+		"date-format": {FieldMeta: editor.FieldMeta{
+			Description: "Go time layout of the date line, written with the reference date Mon Jan 2 15:04:05 2006.",
+			Default:     "Monday, January 2",
+			Example:     `date-format: "Mon 02 Jan 2006"`,
+		}},
+		"quote-file": {FieldMeta: editor.FieldMeta{
+			Description: "Text file of quotes, one per line (blank lines and lines starting with # are skipped). The quote changes once a day.",
+			Example:     "quote-file: ~/quotes.txt",
+		}},
 	}
 }
 
@@ -67,13 +168,257 @@ type WallhavenConfig struct {
 	Cache  string `yaml:"cache,omitempty" mapstructure:"cache"`
 }
 
+// IndexConfig enables the persistent per-source file index, which spares
+// large libraries a full directory listing and a stat per file on every
+// run.
+type IndexConfig struct {
+	Enabled bool   `yaml:"enabled,omitempty" mapstructure:"enabled"`
+	Dir     string `yaml:"dir,omitempty" mapstructure:"dir"`
+	TTL     string `yaml:"ttl,omitempty" mapstructure:"ttl"`
+}
+
+func (IndexConfig) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"enabled": {FieldMeta: editor.FieldMeta{
+			Description: "Keep an on-disk index of every source directory and pick files from it. Directories are re-read only when their modification time changes.",
+			Default:     "false",
+		}},
+		"dir": {FieldMeta: editor.FieldMeta{
+			Description: "Directory where index files are stored. Defaults to an index subdirectory next to the history file.",
+		}},
+		"ttl": {FieldMeta: editor.FieldMeta{
+			Description: `How old an index may get before it is rebuilt from scratch, as a Go duration (e.g. "24h"). Catches files rewritten in place, which don't change their directory's modification time. "0" never forces a rebuild.`,
+			Default:     "24h",
+		}},
+	}
+}
+
+// FormatsConfig controls how image files are recognized and where images
+// in formats the desktop can't display are converted to.
+type FormatsConfig struct {
+	Detection string `yaml:"detection,omitempty" mapstructure:"detection"`
+	Cache     string `yaml:"cache,omitempty" mapstructure:"cache"`
+}
+
+func (FormatsConfig) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"detection": {FieldMeta: editor.FieldMeta{
+			Description: "How image files are recognized. \"extension\" only accepts known image extensions (.jpg, .png, .webp, .bmp, .gif, .tif/.tiff, .avif, ...); \"sniff\" also reads the first bytes of every other file and accepts it when they identify an image.",
+			OneOf:       []string{"extension", "sniff"},
+			Default:     "extension",
+		}},
+		"cache": {FieldMeta: editor.FieldMeta{
+			Description: "Directory for the PNG/JPEG copies of images the desktop can't display as-is (GIF, TIFF, files without an image extension). Defaults to a converted subdirectory next to the history file.",
+		}},
+	}
+}
+
+// FavoritesConfig tunes how images marked with gopaper fav are drawn.
+type FavoritesConfig struct {
+	Weight int `yaml:"weight,omitempty" mapstructure:"weight"`
+}
+
+func (FavoritesConfig) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"weight": {FieldMeta: editor.FieldMeta{
+			Description: "How many times more likely a favorite is to be picked than any other image of the same category. 1 leaves favorites unboosted.",
+			Default:     "1",
+			Min:         "1",
+			Example:     "3",
+		}},
+	}
+}
+
+// DedupeConfig sets how near-duplicate images are recognized, by gopaper
+// dedupe and, with ExcludeSimilar, when picking a wallpaper.
+type DedupeConfig struct {
+	Algorithm      string `yaml:"algorithm,omitempty" mapstructure:"algorithm"`
+	Threshold      *int   `yaml:"threshold,omitempty" mapstructure:"threshold"`
+	ExcludeSimilar bool   `yaml:"exclude-similar,omitempty" mapstructure:"exclude-similar"`
+	Quarantine     string `yaml:"quarantine,omitempty" mapstructure:"quarantine"`
+}
+
+func (DedupeConfig) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"algorithm": {FieldMeta: editor.FieldMeta{
+			Description: "Perceptual hash compared between images. \"phash\" (DCT-based) best survives resizing and recompression; \"dhash\" (gradient-based) is cheaper to compute.",
+			OneOf:       []string{"phash", "dhash"},
+			Default:     "phash",
+		}},
+		"threshold": {FieldMeta: editor.FieldMeta{
+			Description: "Largest number of differing hash bits (out of 64) for two images to count as the same picture. 0 only matches identical hashes; above about 12, different pictures start to match.",
+			Default:     "8",
+			Min:         "0",
+			Max:         "64",
+		}},
+		"exclude-similar": {FieldMeta: editor.FieldMeta{
+			Description: "When picking a wallpaper, also skip near-duplicates of the current one (the same picture in another folder or at another resolution). Every candidate is hashed once, then served from the metadata cache.",
+			Default:     "false",
+		}},
+		"quarantine": {FieldMeta: editor.FieldMeta{
+			Description: "Directory gopaper dedupe --action quarantine moves duplicates to. Defaults to a quarantine subdirectory next to the history file.",
+		}},
+	}
+}
+
+// ThemeConfig exports the wallpaper's color palette after every change,
+// rendered through text/template files.
+type ThemeConfig struct {
+	Colors    int             `yaml:"colors,omitempty" mapstructure:"colors"`
+	Templates []ThemeTemplate `yaml:"templates,omitempty" mapstructure:"templates"`
+}
+
+func (ThemeConfig) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"colors": {FieldMeta: editor.FieldMeta{
+			Description: "Number of colors extracted from the wallpaper. The 16 terminal colors are derived from them.",
+			Default:     "16",
+			Min:         "8",
+			Max:         "16",
+		}},
+		"templates": {FieldMeta: editor.FieldMeta{
+			Description: "Templates rendered after every wallpaper change (and by gopaper theme), each from in to out.",
+			Example:     "templates: [{in: kitty, out: ~/.config/kitty/gopaper.conf}]",
+		}},
+	}
+}
+
+// ThemeTemplate is one file written from the wallpaper's colors: In is a
+// Go text/template file, or the name of a built-in template.
+type ThemeTemplate struct {
+	In  string `yaml:"in" mapstructure:"in"`
+	Out string `yaml:"out" mapstructure:"out"`
+}
+
+func (ThemeTemplate) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"in": {FieldMeta: editor.FieldMeta{
+			Description: "A Go text/template file, or one of the built-in templates: json, sh, xresources, css, kitty, alacritty.",
+			Required:    true,
+			Example:     "in: ~/.config/gopaper/colors.tmpl",
+		}},
+		"out": {FieldMeta: editor.FieldMeta{
+			Description: "File the rendered template is written to, replaced whole on every change. Its directory is created when missing.",
+			Required:    true,
+			Example:     "out: ~/.cache/gopaper/colors.sh",
+		}},
+	}
+}
+
+// HooksConfig lists commands run around every wallpaper change.
+type HooksConfig struct {
+	Timeout    string `yaml:"timeout,omitempty" mapstructure:"timeout"`
+	PreChange  []Hook `yaml:"pre-change,omitempty" mapstructure:"pre-change"`
+	PostChange []Hook `yaml:"post-change,omitempty" mapstructure:"post-change"`
+}
+
+func (HooksConfig) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"timeout": {FieldMeta: editor.FieldMeta{
+			Description: `How long a hook may run before it is killed, as a Go duration (e.g. "5s"), unless it sets its own.`,
+			Default:     "10s",
+		}},
+		"pre-change": {FieldMeta: editor.FieldMeta{
+			Description: "Commands run, in order, before a wallpaper change is applied. One that exits non-zero (or times out) vetoes the change and skips the rest.",
+			Example:     `pre-change: [{command: "! pgrep -x obs"}]`,
+		}},
+		"post-change": {FieldMeta: editor.FieldMeta{
+			Description: "Commands run, in order, after a wallpaper change. Failures are logged and never undo the change.",
+			Example:     `post-change: [{command: "pkill -USR1 kitty"}]`,
+		}},
+	}
+}
+
+// OutputConfig is where the file backend (behavior.backend: file) publishes
+// wallpapers, for kiosks and headless machines where something else
+// displays them.
+type OutputConfig struct {
+	Path     string          `yaml:"path" mapstructure:"path"`
+	Monitors []OutputMonitor `yaml:"monitors,omitempty" mapstructure:"monitors"`
+	Method   string          `yaml:"method,omitempty" mapstructure:"method"`
+	Manifest string          `yaml:"manifest,omitempty" mapstructure:"manifest"`
+}
+
+func (OutputConfig) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"path": {FieldMeta: editor.FieldMeta{
+			Description: "File the wallpaper is published at when it is set on every monitor, replaced atomically on every change.",
+			Required:    true,
+			Example:     "path: /srv/kiosk/wallpaper.jpg",
+		}},
+		"monitors": {FieldMeta: editor.FieldMeta{
+			Description: "Monitors to publish one wallpaper each for, laid out left to right in order: monitor1 is the first. They replace the connected monitors, so per-monitor and panorama changes work without a display. Without them, per-monitor changes are composed into one image at path.",
+			Example:     "monitors: [{path: /srv/kiosk/left.jpg}, {path: /srv/kiosk/right.jpg, width: 1080, height: 1920}]",
+		}},
+		"method": {FieldMeta: editor.FieldMeta{
+			Description: "How the images are published: copied, or as symbolic links to them. Links are cheaper but break when the image is moved or, for processed copies, evicted from the cache.",
+			OneOf:       []string{"copy", "symlink"},
+			Default:     "copy",
+		}},
+		"manifest": {FieldMeta: editor.FieldMeta{
+			Description: "Where to write the JSON manifest of the current wallpaper (path, category, mode, monitors). Defaults to current.json next to path.",
+			Example:     "manifest: /srv/kiosk/current.json",
+		}},
+	}
+}
+
+// OutputMonitor is one monitor the file backend publishes a wallpaper for.
+type OutputMonitor struct {
+	Path   string `yaml:"path" mapstructure:"path"`
+	Width  int    `yaml:"width,omitempty" mapstructure:"width"`
+	Height int    `yaml:"height,omitempty" mapstructure:"height"`
+}
+
+func (OutputMonitor) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"path": {FieldMeta: editor.FieldMeta{
+			Description: "File this monitor's wallpaper is published at.",
+			Required:    true,
+			Example:     "path: /srv/kiosk/left.jpg",
+		}},
+		"width": {FieldMeta: editor.FieldMeta{
+			Description: "Monitor width in pixels, used to lay out panoramas and by resize-to-monitor.",
+			Min:         "1",
+			Default:     "1920",
+		}},
+		"height": {FieldMeta: editor.FieldMeta{
+			Description: "Monitor height in pixels.",
+			Min:         "1",
+			Default:     "1080",
+		}},
+	}
+}
+
+// Hook is one command run around a wallpaper change by the shell (sh, or
+// PowerShell on Windows), with the change in its environment and the JSON
+// history entry on its standard input.
+type Hook struct {
+	Command string `yaml:"command" mapstructure:"command"`
+	Timeout string `yaml:"timeout,omitempty" mapstructure:"timeout"`
+}
+
+func (Hook) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"command": {FieldMeta: editor.FieldMeta{
+			Description: "Shell command run with GOPAPER_PATH, GOPAPER_CATEGORY, GOPAPER_MODE, GOPAPER_MONITORS, GOPAPER_MONITOR_<n>_PATH, GOPAPER_MONITOR_<n>_CATEGORY and GOPAPER_ENTRY (the JSON history entry, also on standard input) set.",
+			Required:    true,
+			Example:     `command: "cp \"$GOPAPER_PATH\" ~/.cache/lockscreen.jpg"`,
+		}},
+		"timeout": {FieldMeta: editor.FieldMeta{
+			Description: `How long this command may run before it is killed, as a Go duration (e.g. "30s"). Defaults to hooks.timeout.`,
+		}},
+	}
+}
+
 // WeatherConfig configures the weather data source used by
 // weather-based conditions.
 type WeatherConfig struct {
-	Provider  string  `yaml:"provider" mapstructure:"provider"`
-	Latitude  float64 `yaml:"latitude" mapstructure:"latitude"`
-	Longitude float64 `yaml:"longitude" mapstructure:"longitude"`
-	CacheTTL  string  `yaml:"cache-ttl,omitempty" mapstructure:"cache-ttl"`
+	Provider  string           `yaml:"provider" mapstructure:"provider"`
+	Latitude  float64          `yaml:"latitude" mapstructure:"latitude"`
+	Longitude float64          `yaml:"longitude" mapstructure:"longitude"`
+	CacheTTL  string           `yaml:"cache-ttl,omitempty" mapstructure:"cache-ttl"`
+	Units     string           `yaml:"units,omitempty" mapstructure:"units"`
+	Buckets   map[string][]int `yaml:"buckets,omitempty" mapstructure:"buckets"`
 }
 
 // Condition is a named, reusable rule a variant can reference by name
@@ -81,6 +426,8 @@ type WeatherConfig struct {
 // date-range, or the weather bucket (weather/wind-speed-*/temperature-*,
 // which combine with AND). Priority breaks ties when multiple variants'
 // conditions hold at the same time (higher wins); it defaults to 0.
+// Once loaded through config.LoadConditions, wind speeds are in km/h and
+// temperatures in Celsius whatever units the file was written in.
 type Condition struct {
 	Hours          string     `yaml:"hours,omitempty" mapstructure:"hours"`
 	DateRange      *DateRange `yaml:"date-range,omitempty" mapstructure:"date-range"`
@@ -152,6 +499,21 @@ func (Configuration) Metadata() map[string]*metadata.Node {
 		"conditions": {FieldMeta: editor.FieldMeta{
 			Description: "Named, reusable conditions (time-of-day or weather) referenced by categories[].variants[].condition.",
 		}},
+		"index": {FieldMeta: editor.FieldMeta{
+			Description: "Persistent file index for large libraries (e.g. on a network share); rebuild it by hand with gopaper index rebuild.",
+		}},
+		"formats": {FieldMeta: editor.FieldMeta{
+			Description: "Image type detection (by extension or by content) and the cache for images converted to a format the desktop can display.",
+		}},
+		"favorites": {FieldMeta: editor.FieldMeta{
+			Description: "Weight boost for images marked with gopaper fav.",
+		}},
+		"dedupe": {FieldMeta: editor.FieldMeta{
+			Description: "How near-duplicate images are recognized by gopaper dedupe, and whether the current wallpaper's near-duplicates are skipped when picking.",
+		}},
+		"output": {FieldMeta: editor.FieldMeta{
+			Description: "Where the file backend (behavior.backend: file) publishes wallpapers and the current.json manifest, instead of setting them on a desktop.",
+		}},
 	}
 }
 
@@ -179,6 +541,15 @@ func (WeatherConfig) Metadata() map[string]*metadata.Node {
 			Description: `How long a fetched weather snapshot is reused before refetching, as a Go duration (e.g. "15m").`,
 			Default:     "15m",
 		}},
+		"units": {FieldMeta: editor.FieldMeta{
+			Description: "Unit system for conditions' temperature-* and wind-speed-* values written as bare numbers: metric (Celsius, km/h) or imperial (Fahrenheit, mph). A value with a unit suffix (e.g. \"50F\", \"20mph\") is read in that unit regardless.",
+			OneOf:       []string{"metric", "imperial"},
+			Default:     "metric",
+		}},
+		"buckets": {FieldMeta: editor.FieldMeta{
+			Description: "Custom sky buckets, each a name mapped to the WMO weather codes it covers, usable in conditions[].weather alongside the built-in ones. A bucket reusing a built-in name (e.g. rain) replaces that bucket's codes; a code may belong to several buckets.",
+			Example:     `buckets: {heavy-rain: [65, 67, 82]}`,
+		}},
 	}
 }
 
@@ -192,19 +563,21 @@ func (Condition) Metadata() map[string]*metadata.Node {
 			Description: "Calendar date span (month/day only, no year), both ends inclusive; wraps into the next year when start orders after end. Mutually exclusive with hours and weather/wind-speed-*/temperature-*.",
 		}},
 		"weather": {FieldMeta: editor.FieldMeta{
-			Description: "Sky conditions that satisfy this condition: one or more of clear, cloudy, fog, drizzle, rain, snow, thunderstorm. Combinable with wind-speed-*/temperature-* (AND); mutually exclusive with hours/date-range.",
+			Description: "Sky conditions that satisfy this condition: one or more of clear, cloudy, fog, drizzle, rain, snow, thunderstorm, or a custom bucket from configuration.weather.buckets. Combinable with wind-speed-*/temperature-* (AND); mutually exclusive with hours/date-range.",
 		}},
 		"wind-speed-min": {FieldMeta: editor.FieldMeta{
-			Description: "Minimum current wind speed, in km/h, for this condition to hold.",
+			Description: "Minimum current wind speed for this condition to hold, in configuration.weather.units (km/h or mph), or with an explicit suffix (km/h, mph, m/s, kn).",
+			Example:     `wind-speed-min: "20mph"`,
 		}},
 		"wind-speed-max": {FieldMeta: editor.FieldMeta{
-			Description: "Maximum current wind speed, in km/h, for this condition to hold.",
+			Description: "Maximum current wind speed for this condition to hold, in configuration.weather.units (km/h or mph), or with an explicit suffix (km/h, mph, m/s, kn).",
 		}},
 		"temperature-min": {FieldMeta: editor.FieldMeta{
-			Description: "Minimum current temperature, in Celsius, for this condition to hold.",
+			Description: "Minimum current temperature for this condition to hold, in configuration.weather.units (Celsius or Fahrenheit), or with an explicit C/F suffix.",
+			Example:     `temperature-min: "50F"`,
 		}},
 		"temperature-max": {FieldMeta: editor.FieldMeta{
-			Description: "Maximum current temperature, in Celsius, for this condition to hold.",
+			Description: "Maximum current temperature for this condition to hold, in configuration.weather.units (Celsius or Fahrenheit), or with an explicit C/F suffix.",
 		}},
 		"priority": {FieldMeta: editor.FieldMeta{
 			Description: "Tie-breaker when multiple variants' conditions hold at once; the highest priority wins. Default 0.",
@@ -278,7 +651,20 @@ func (Categories) Metadata() map[string]*metadata.Node {
 			Unique:      true,
 		}},
 		"source": {FieldMeta: editor.FieldMeta{
-			Description: "Directory containing the wallpaper images for this category, used directly when there are no variants, or as the base directory for variants with relative source paths.",
+			Description: "Directory containing the wallpaper images for this category, used directly when there are no variants, or as the base directory for variants with relative source paths. Mutually exclusive with sources.",
+		}},
+		"sources": {FieldMeta: editor.FieldMeta{
+			Description: "Several directories this category draws from, as the union of their files (e.g. a NAS share plus a local folder). Entries are plain paths or {path, weight} mappings. With variants, each entry is a base directory for relative variant sources. Mutually exclusive with source.",
+			Example:     "sources: [/mnt/nas/walls, ~/Pictures/walls]",
+		}},
+		"recursive": {FieldMeta: editor.FieldMeta{
+			Description: "Also picks images from subdirectories of the source (or of the active variant's source). Files and directories matched by a .gopaperignore file are skipped; each ignore file applies to its own directory and everything below it.",
+			Default:     "false",
+		}},
+		"max-depth": {FieldMeta: editor.FieldMeta{
+			Description: "With recursive, the number of subdirectory levels below source to descend into (1 = direct subdirectories only). 0 or unset means unlimited.",
+			Min:         "0",
+			Example:     "max-depth: 2",
 		}},
 		"variants": {FieldMeta: editor.FieldMeta{
 			Description: "Time-conditioned renditions of this category (e.g. day/night). The first variant whose hours window contains the current time provides the source; if none matches, the category is skipped for that run. Mutually exclusive with source.",
@@ -298,7 +684,24 @@ func (Categories) Metadata() map[string]*metadata.Node {
 			Description: "Optional constraints narrowing which files in source are eligible, beyond the fixed image-extension check.",
 		}},
 		"wallhaven": {FieldMeta: editor.FieldMeta{
-			Description: "Sources this category's images from the Wallhaven API instead of a local directory (downloads are cached locally). Mutually exclusive with source and variants.",
+			Description: "Sources this category's images from the Wallhaven API instead of a local directory (downloads are cached locally). Mutually exclusive with source, sources, variants, and tags.",
+		}},
+		"generate": {FieldMeta: editor.FieldMeta{
+			Description: "Draws a generated image (solid color, gradient, noise or stripes) at the monitor's resolution instead of picking a file. Mutually exclusive with source, sources, variants, wallhaven, tags, and collage; put generate in each variant for a palette that follows the time of day.",
+		}},
+		"collage": {FieldMeta: editor.FieldMeta{
+			Description: "Makes this category lay several images, picked from the categories named in from, out on one wallpaper sized to the primary monitor. Mutually exclusive with source, sources, variants, wallhaven, and tags.",
+		}},
+		"calendar": {FieldMeta: editor.FieldMeta{
+			Description: "Makes this category draw the current month, today highlighted and optional events from an .ics file, over an image picked from another category. Redrawn when the date changes. Mutually exclusive with source, sources, variants, wallhaven, tags, collage, and generate.",
+		}},
+		"tags": {FieldMeta: editor.FieldMeta{
+			Description: "A tag query (tags combined with and, or, not and parentheses) over the tags assigned with gopaper tag. Without source, sources or variants, the category draws from every tagged file that matches; with them, it narrows their files to the matching ones.",
+			Example:     `tags: "autumn and not people"`,
+		}},
+		"process": {FieldMeta: editor.FieldMeta{
+			Description: "Steps rendered over the picked image, in order, before it is applied: resize-to-monitor, blur: <radius>, dim: <0-1>, grayscale. The processed copy is cached, and a step with a condition only runs while that condition holds.",
+			Example:     "process: [resize-to-monitor, {dim: 0.3, condition: night}]",
 		}},
 	}
 }
@@ -312,12 +715,20 @@ type Gopaper struct {
 type Categories struct {
 	Name      string           `yaml:"name" mapstructure:"name"`
 	Source    string           `yaml:"source,omitempty" mapstructure:"source"`
+	Sources   []SourceDir      `yaml:"sources,omitempty" mapstructure:"sources"`
+	Recursive bool             `yaml:"recursive,omitempty" mapstructure:"recursive"`
+	MaxDepth  int              `yaml:"max-depth,omitempty" mapstructure:"max-depth"`
 	Enabled   bool             `yaml:"enabled" mapstructure:"enabled"`
 	Behavior  *Behavior        `yaml:"behavior,omitempty" mapstructure:"behavior"`
 	Monitor   int              `yaml:"monitor,omitempty" mapstructure:"monitor"`
 	Filter    *Filter          `yaml:"filter,omitempty" mapstructure:"filter"`
 	Variants  []Variant        `yaml:"variants,omitempty" mapstructure:"variants"`
 	Wallhaven *WallhavenSource `yaml:"wallhaven,omitempty" mapstructure:"wallhaven"`
+	Tags      string           `yaml:"tags,omitempty" mapstructure:"tags"`
+	Process   []ProcessStep    `yaml:"process,omitempty" mapstructure:"process"`
+	Collage   *Collage         `yaml:"collage,omitempty" mapstructure:"collage"`
+	Generate  *Generate        `yaml:"generate,omitempty" mapstructure:"generate"`
+	Calendar  *Calendar        `yaml:"calendar,omitempty" mapstructure:"calendar"`
 }
 
 // TransitionOverride returns this category's transition override, or ""
@@ -338,6 +749,24 @@ func (c *Categories) MonitorOverride() string {
 	return c.Behavior.Monitor
 }
 
+// OverlayOverride returns this category's overlay block, or nil when it has
+// none (the configuration-level overlay then applies as-is).
+func (c *Categories) OverlayOverride() *Overlay {
+	if c == nil || c.Behavior == nil {
+		return nil
+	}
+	return c.Behavior.Overlay
+}
+
+// BezelOverride returns this category's bezel block, or nil when it has
+// none (the configuration-level bezel then applies as-is).
+func (c *Categories) BezelOverride() *Bezel {
+	if c == nil || c.Behavior == nil {
+		return nil
+	}
+	return c.Behavior.Bezel
+}
+
 // ModeOverride returns this category's wallpaper mode override, or "" when
 // it has none (the configuration-level behavior then applies).
 func (c *Categories) ModeOverride() string {
@@ -392,20 +821,250 @@ func (WallhavenSource) Metadata() map[string]*metadata.Node {
 	}
 }
 
+// Generate makes a category (or a variant) draw a generated image - a
+// solid color, a gradient, noise or stripes - instead of picking a file.
+// Mutually exclusive with Source/Sources.
+type Generate struct {
+	Type   string   `yaml:"type" mapstructure:"type"`
+	Colors []string `yaml:"colors" mapstructure:"colors"`
+	Angle  float64  `yaml:"angle,omitempty" mapstructure:"angle"`
+	Seed   uint64   `yaml:"seed,omitempty" mapstructure:"seed"`
+	Width  int      `yaml:"width,omitempty" mapstructure:"width"`
+}
+
+func (Generate) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"type": {FieldMeta: editor.FieldMeta{
+			Description: "What to draw: one solid color, a linear gradient across the screen, a radial gradient from its center, smooth noise shading between the colors, or stripes cycling through them.",
+			OneOf:       []string{"solid", "linear-gradient", "radial-gradient", "noise", "stripes"},
+			Required:    true,
+		}},
+		"colors": {FieldMeta: editor.FieldMeta{
+			Description: "Colors as #rrggbb or #rrggbbaa: exactly one for solid, at least two for the other types (gradients run through them in order).",
+			Required:    true,
+			Example:     `colors: ["#0f2027", "#2c5364"]`,
+		}},
+		"angle": {FieldMeta: editor.FieldMeta{
+			Description: "Direction of a linear-gradient or of stripes, in degrees clockwise: 0 runs left to right, 90 top to bottom.",
+			Default:     "0",
+		}},
+		"seed": {FieldMeta: editor.FieldMeta{
+			Description: "Seed of the noise pattern: the same seed always draws the same pattern.",
+			Min:         "0",
+			Default:     "0",
+		}},
+		"width": {FieldMeta: editor.FieldMeta{
+			Description: "Width of each stripe, in pixels.",
+			Min:         "1",
+			Default:     "64",
+		}},
+	}
+}
+
+// Calendar makes a category draw the current month's calendar over an
+// image picked from another category. Mutually exclusive with
+// Source/Sources/Variants/Wallhaven/Tags/Collage/Generate.
+type Calendar struct {
+	Background string `yaml:"background,omitempty" mapstructure:"background"`
+	Events     string `yaml:"events,omitempty" mapstructure:"events"`
+	WeekStart  string `yaml:"week-start,omitempty" mapstructure:"week-start"`
+	Color      string `yaml:"color,omitempty" mapstructure:"color"`
+	Accent     string `yaml:"accent,omitempty" mapstructure:"accent"`
+}
+
+func (Calendar) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"background": {FieldMeta: editor.FieldMeta{
+			Description: "Name of the category the background image is picked from. It doesn't need to be enabled. Without it the calendar is drawn on a plain dark background.",
+			Example:     "background: nature",
+		}},
+		"events": {FieldMeta: editor.FieldMeta{
+			Description: "An .ics file whose events are marked on their days, the next few listed under the month. Read on every draw, so an exported or synced file stays current.",
+			Example:     "events: ~/calendar.ics",
+		}},
+		"week-start": {FieldMeta: editor.FieldMeta{
+			Description: "First day of each week row.",
+			OneOf:       []string{"monday", "sunday"},
+			Default:     "monday",
+		}},
+		"color": {FieldMeta: editor.FieldMeta{
+			Description: "Text color as #rrggbb or #rrggbbaa.",
+			Default:     "#ffffff",
+		}},
+		"accent": {FieldMeta: editor.FieldMeta{
+			Description: "Color of today's circle and of the event markers, as #rrggbb or #rrggbbaa.",
+			Default:     "#e76f51",
+		}},
+	}
+}
+
+// Collage makes a category lay several images, picked from other
+// categories, out on one wallpaper. Mutually exclusive with
+// Source/Sources/Variants/Wallhaven/Tags.
+type Collage struct {
+	Count  int      `yaml:"count,omitempty" mapstructure:"count"`
+	Layout string   `yaml:"layout,omitempty" mapstructure:"layout"`
+	Gap    int      `yaml:"gap,omitempty" mapstructure:"gap"`
+	From   []string `yaml:"from" mapstructure:"from"`
+}
+
+func (Collage) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"count": {FieldMeta: editor.FieldMeta{
+			Description: "Number of images in the collage. Fewer are used when the categories in from don't have that many.",
+			Min:         "1",
+			Default:     "4",
+		}},
+		"layout": {FieldMeta: editor.FieldMeta{
+			Description: "How the images are arranged: grid gives every image an equal cell; mosaic sizes rows and cells to keep each image close to its own shape.",
+			OneOf:       []string{"grid", "mosaic"},
+			Default:     "grid",
+		}},
+		"gap": {FieldMeta: editor.FieldMeta{
+			Description: "Pixels of black between the images and around the edges.",
+			Min:         "0",
+			Default:     "0",
+		}},
+		"from": {FieldMeta: editor.FieldMeta{
+			Description: "Names of the categories the images are picked from, each draw from a random one of them. They don't need to be enabled.",
+			Required:    true,
+			Example:     `from: [nature, city]`,
+		}},
+	}
+}
+
 // Variant is one conditioned rendition of a category's image collection
 // (macOS dynamic wallpaper style). Among variants whose condition
 // currently holds, the one with the highest priority provides the
 // category's source.
 type Variant struct {
-	Source    string `yaml:"source" mapstructure:"source"`
-	Hours     string `yaml:"hours,omitempty" mapstructure:"hours"`
-	Condition string `yaml:"condition,omitempty" mapstructure:"condition"`
+	Source    string      `yaml:"source,omitempty" mapstructure:"source"`
+	Sources   []SourceDir `yaml:"sources,omitempty" mapstructure:"sources"`
+	Generate  *Generate   `yaml:"generate,omitempty" mapstructure:"generate"`
+	Hours     string      `yaml:"hours,omitempty" mapstructure:"hours"`
+	Condition string      `yaml:"condition,omitempty" mapstructure:"condition"`
+}
+
+// SourceDir is one entry of a sources list. In YAML it is either a plain
+// path or a {path, weight} mapping; Weight scales the chance of each of the
+// directory's files relative to files from the other entries (0 means 1).
+type SourceDir struct {
+	Path   string `yaml:"path" mapstructure:"path"`
+	Weight int    `yaml:"weight,omitempty" mapstructure:"weight"`
+}
+
+// UnmarshalYAML accepts the plain-path shorthand alongside the mapping form.
+func (s *SourceDir) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = SourceDir{Path: node.Value}
+		return nil
+	}
+	type plain SourceDir
+	return node.Decode((*plain)(s))
+}
+
+// EffectiveWeight returns Weight, treating an unset (or non-positive)
+// weight as 1.
+func (s SourceDir) EffectiveWeight() int {
+	if s.Weight <= 0 {
+		return 1
+	}
+	return s.Weight
+}
+
+// ProcessStep is one step of a category's process pipeline: exactly one of
+// the operations, optionally limited to the times a named condition from
+// configuration.conditions holds. In YAML it is either a bare operation
+// name (resize-to-monitor, grayscale) or a mapping such as {blur: 8} or
+// {dim: 0.3, condition: night}.
+type ProcessStep struct {
+	ResizeToMonitor bool    `yaml:"resize-to-monitor,omitempty" mapstructure:"resize-to-monitor"`
+	Blur            float64 `yaml:"blur,omitempty" mapstructure:"blur"`
+	Dim             float64 `yaml:"dim,omitempty" mapstructure:"dim"`
+	Grayscale       bool    `yaml:"grayscale,omitempty" mapstructure:"grayscale"`
+	Condition       string  `yaml:"condition,omitempty" mapstructure:"condition"`
+}
+
+// UnmarshalYAML accepts the bare-name shorthand alongside the mapping form.
+func (s *ProcessStep) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		step, err := ParseProcessStep(node.Value)
+		if err != nil {
+			return err
+		}
+		*s = step
+		return nil
+	}
+	type plain ProcessStep
+	return node.Decode((*plain)(s))
+}
+
+// ParseProcessStep returns the step a bare operation name stands for. Only
+// the operations without an amount have a bare form.
+func ParseProcessStep(name string) (ProcessStep, error) {
+	switch name {
+	case "resize-to-monitor":
+		return ProcessStep{ResizeToMonitor: true}, nil
+	case "grayscale":
+		return ProcessStep{Grayscale: true}, nil
+	case "blur":
+		return ProcessStep{}, fmt.Errorf("process step %q needs a radius, e.g. {blur: 8}", name)
+	case "dim":
+		return ProcessStep{}, fmt.Errorf("process step %q needs an amount, e.g. {dim: 0.3}", name)
+	}
+	return ProcessStep{}, fmt.Errorf("unknown process step %q: use resize-to-monitor, blur, dim or grayscale", name)
+}
+
+func (ProcessStep) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"resize-to-monitor": {FieldMeta: editor.FieldMeta{
+			Description: "Scales and center-crops the image to exactly cover the monitor it is shown on (the largest monitor for a mirrored wallpaper). Skipped when monitors can't be enumerated.",
+			Default:     "false",
+		}},
+		"blur": {FieldMeta: editor.FieldMeta{
+			Description: "Blurs the image with this radius, in pixels of the image as it is at this step.",
+			Example:     "blur: 8",
+		}},
+		"dim": {FieldMeta: editor.FieldMeta{
+			Description: "Darkens the image by this fraction, from 0 (unchanged) to 1 (black).",
+			Example:     "dim: 0.3",
+		}},
+		"grayscale": {FieldMeta: editor.FieldMeta{
+			Description: "Turns the image into shades of gray.",
+			Default:     "false",
+		}},
+		"condition": {FieldMeta: editor.FieldMeta{
+			Description: "Name of a condition from configuration.conditions: the step only runs while it holds.",
+			Example:     "condition: night",
+		}},
+	}
+}
+
+func (SourceDir) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"path": {FieldMeta: editor.FieldMeta{
+			Description: "Directory containing wallpaper images. A plain string entry is shorthand for {path: <dir>}.",
+			Required:    true,
+		}},
+		"weight": {FieldMeta: editor.FieldMeta{
+			Description: "Relative weight of each file in this directory against files from the other sources (2 = twice as likely).",
+			Min:         "1",
+			Default:     "1",
+		}},
+	}
 }
 
 func (Variant) Metadata() map[string]*metadata.Node {
 	return map[string]*metadata.Node{
 		"source": {FieldMeta: editor.FieldMeta{
-			Description: `Directory containing this variant's wallpaper images. Absolute paths are used as-is; relative paths (e.g. "./day") are resolved against the category's source.`,
+			Description: `Directory containing this variant's wallpaper images. Absolute paths are used as-is; relative paths (e.g. "./day") are resolved against the category's source, or against each of its sources. Mutually exclusive with sources.`,
+		}},
+		"sources": {FieldMeta: editor.FieldMeta{
+			Description: "Several directories this variant draws from, as the union of their files. Entries resolve like source and may carry a weight. Mutually exclusive with source.",
+			Example:     "sources: [./day, /mnt/nas/day]",
+		}},
+		"generate": {FieldMeta: editor.FieldMeta{
+			Description: "Draws a generated image (solid color, gradient, noise or stripes) while this variant is active, instead of picking a file. Mutually exclusive with source and sources.",
 		}},
 		"hours": {FieldMeta: editor.FieldMeta{
 			Description: "Daily time window in which this variant is active, in 24h HH:MM-HH:MM format, both ends inclusive. May cross midnight. Mutually exclusive with condition.",
@@ -418,27 +1077,43 @@ func (Variant) Metadata() map[string]*metadata.Node {
 }
 
 // Filter narrows which files in a category's source directory are eligible
-// for selection, beyond the fixed image-extension check. Match, Age, and Size
-// combine with AND semantics; a nil sub-filter imposes no constraint.
+// for selection, beyond the fixed image-type check. Match, Age, Size,
+// Dimensions, Brightness, Color and Exif combine with AND semantics; a nil
+// sub-filter imposes no constraint. AnyOf, AllOf and Not nest further
+// filters, so a file must also match at least one of AnyOf, every one of
+// AllOf, and not Not.
 type Filter struct {
-	Match *MatchFilter `yaml:"match,omitempty" mapstructure:"match"`
-	Age   *AgeFilter   `yaml:"age,omitempty" mapstructure:"age"`
-	Size  *SizeFilter  `yaml:"size,omitempty" mapstructure:"size"`
+	Match      *MatchFilter      `yaml:"match,omitempty" mapstructure:"match"`
+	Age        *AgeFilter        `yaml:"age,omitempty" mapstructure:"age"`
+	Size       *SizeFilter       `yaml:"size,omitempty" mapstructure:"size"`
+	Dimensions *DimensionsFilter `yaml:"dimensions,omitempty" mapstructure:"dimensions"`
+	Brightness *BrightnessFilter `yaml:"brightness,omitempty" mapstructure:"brightness"`
+	Color      *ColorFilter      `yaml:"color,omitempty" mapstructure:"color"`
+	Exif       *ExifFilter       `yaml:"exif,omitempty" mapstructure:"exif"`
+	AnyOf      []Filter          `yaml:"any-of,omitempty" mapstructure:"any-of"`
+	AllOf      []Filter          `yaml:"all-of,omitempty" mapstructure:"all-of"`
+	Not        *Filter           `yaml:"not,omitempty" mapstructure:"not"`
 }
 
-// MatchFilter matches a file by its name. Literal, Regex, and Glob are
-// mutually exclusive.
+// MatchFilter matches a file by its name. Literal, Regex, Regexes, Glob and
+// Globs are mutually exclusive; the list forms match a name that matches
+// any of their patterns.
 type MatchFilter struct {
-	Literal       string `yaml:"literal,omitempty" mapstructure:"literal"`
-	Regex         string `yaml:"regex,omitempty" mapstructure:"regex"`
-	Glob          string `yaml:"glob,omitempty" mapstructure:"glob"`
-	CaseSensitive bool   `yaml:"case-sensitive,omitempty" mapstructure:"case-sensitive"`
+	Literal       string   `yaml:"literal,omitempty" mapstructure:"literal"`
+	Regex         string   `yaml:"regex,omitempty" mapstructure:"regex"`
+	Regexes       []string `yaml:"regexes,omitempty" mapstructure:"regexes"`
+	Glob          string   `yaml:"glob,omitempty" mapstructure:"glob"`
+	Globs         []string `yaml:"globs,omitempty" mapstructure:"globs"`
+	CaseSensitive bool     `yaml:"case-sensitive,omitempty" mapstructure:"case-sensitive"`
 }
 
-// AgeFilter matches a file by how long ago it was last modified.
+// AgeFilter matches a file by how long ago it was last modified, or, with
+// Source "exif", how long ago the photo was taken (falling back to the
+// modification time for images without an EXIF capture date).
 type AgeFilter struct {
-	Min time.Duration `yaml:"min,omitempty" mapstructure:"min"`
-	Max time.Duration `yaml:"max,omitempty" mapstructure:"max"`
+	Min    time.Duration `yaml:"min,omitempty" mapstructure:"min"`
+	Max    time.Duration `yaml:"max,omitempty" mapstructure:"max"`
+	Source string        `yaml:"source,omitempty" mapstructure:"source"`
 }
 
 // SizeFilter matches a file by its size in bytes. Min/Max accept
@@ -459,22 +1134,53 @@ func (Filter) Metadata() map[string]*metadata.Node {
 		"size": {FieldMeta: editor.FieldMeta{
 			Description: "Match files by size.",
 		}},
+		"dimensions": {FieldMeta: editor.FieldMeta{
+			Description: "Match images by pixel dimensions, aspect ratio or orientation. Only the image header is read, and the result is cached.",
+		}},
+		"brightness": {FieldMeta: editor.FieldMeta{
+			Description: "Match images by mean brightness, from 0 (black) to 1 (white). Measured once per image and cached.",
+		}},
+		"color": {FieldMeta: editor.FieldMeta{
+			Description: "Match images by dominant color. Measured once per image and cached.",
+		}},
+		"exif": {FieldMeta: editor.FieldMeta{
+			Description: "Match photos by their EXIF metadata (capture month, camera, GPS position), read from JPEG, TIFF and WebP files and cached.",
+		}},
+		"any-of": {FieldMeta: editor.FieldMeta{
+			Description: "Nested filters of which a file must match at least one, in addition to this filter's other constraints.",
+			Example:     `any-of: [{match: {glob: "*.png"}}, {size: {min: 5MB}}]`,
+		}},
+		"all-of": {FieldMeta: editor.FieldMeta{
+			Description: "Nested filters a file must all match, in addition to this filter's other constraints.",
+		}},
+		"not": {FieldMeta: editor.FieldMeta{
+			Description: "A nested filter a file must not match.",
+			Example:     `not: {match: {glob: "*_draft*"}}`,
+		}},
 	}
 }
 
 func (MatchFilter) Metadata() map[string]*metadata.Node {
 	return map[string]*metadata.Node{
 		"literal": {FieldMeta: editor.FieldMeta{
-			Description: "Exact filename match (whole name including extension). Mutually exclusive with regex/glob.",
+			Description: "Exact filename match (whole name including extension). Mutually exclusive with regex/regexes/glob/globs.",
 		}},
 		"regex": {FieldMeta: editor.FieldMeta{
-			Description: "RE2 regular expression matched against the filename. Mutually exclusive with literal/glob.",
+			Description: "RE2 regular expression matched against the filename. Mutually exclusive with literal/regexes/glob/globs.",
 			Example:     `regex: '^\d{4}-\d{2}-\d{2}_'`,
 		}},
+		"regexes": {FieldMeta: editor.FieldMeta{
+			Description: "RE2 regular expressions; the filename must match at least one. Mutually exclusive with literal/regex/glob/globs.",
+			Example:     `regexes: ['^IMG_', '^PXL_']`,
+		}},
 		"glob": {FieldMeta: editor.FieldMeta{
-			Description: "Wildcard pattern matched against the filename. Mutually exclusive with literal/regex.",
+			Description: "Wildcard pattern matched against the filename. Mutually exclusive with literal/regex/regexes/globs.",
 			Example:     `glob: "screenshot_*"`,
 		}},
+		"globs": {FieldMeta: editor.FieldMeta{
+			Description: "Wildcard patterns; the filename must match at least one. Mutually exclusive with literal/regex/regexes/glob.",
+			Example:     `globs: ["*.png", "screenshot_*"]`,
+		}},
 		"case-sensitive": {FieldMeta: editor.FieldMeta{
 			Description: "Whether literal/glob/regex matching is case-sensitive.",
 			Default:     "false",
@@ -492,6 +1198,163 @@ func (AgeFilter) Metadata() map[string]*metadata.Node {
 			Description: "Maximum time since the file was last modified.",
 			Example:     "max: 720h",
 		}},
+		"source": {FieldMeta: editor.FieldMeta{
+			Description: "Which time the age is measured from: the file's modification time, or the photo's EXIF capture date (DateTimeOriginal; images without one fall back to the modification time).",
+			OneOf:       []string{"mtime", "exif"},
+			Default:     "mtime",
+		}},
+	}
+}
+
+// DimensionsFilter matches an image by its pixel size. Aspect is a
+// "W:H" ratio (or a decimal such as "2.39") with an optional "±tolerance"
+// on the width/height quotient, e.g. "16:9±0.05".
+type DimensionsFilter struct {
+	MinWidth    int    `yaml:"min-width,omitempty" mapstructure:"min-width"`
+	MinHeight   int    `yaml:"min-height,omitempty" mapstructure:"min-height"`
+	Aspect      string `yaml:"aspect,omitempty" mapstructure:"aspect"`
+	Orientation string `yaml:"orientation,omitempty" mapstructure:"orientation"`
+}
+
+func (DimensionsFilter) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"min-width": {FieldMeta: editor.FieldMeta{
+			Description: "Minimum image width in pixels.",
+			Example:     "min-width: 3840",
+			Min:         "0",
+		}},
+		"min-height": {FieldMeta: editor.FieldMeta{
+			Description: "Minimum image height in pixels.",
+			Example:     "min-height: 1600",
+			Min:         "0",
+		}},
+		"aspect": {FieldMeta: editor.FieldMeta{
+			Description: "Aspect ratio as \"W:H\" or a decimal, with an optional tolerance on width/height after \"±\" (or \"+-\"); without one the ratio must match within 0.01.",
+			Example:     `aspect: "21:9±0.05"`,
+		}},
+		"orientation": {FieldMeta: editor.FieldMeta{
+			Description: "Required orientation: wider than tall, taller than wide, or exactly square.",
+			OneOf:       []string{"landscape", "portrait", "square"},
+		}},
+	}
+}
+
+// BrightnessFilter matches an image by its mean brightness (relative
+// luminance), 0 = black to 1 = white. A zero bound is unset.
+type BrightnessFilter struct {
+	Min float64 `yaml:"min,omitempty" mapstructure:"min"`
+	Max float64 `yaml:"max,omitempty" mapstructure:"max"`
+}
+
+func (BrightnessFilter) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"min": {FieldMeta: editor.FieldMeta{
+			Description: "Minimum mean brightness, between 0 and 1.",
+			Example:     "min: 0.6",
+			Min:         "0",
+			Max:         "1",
+		}},
+		"max": {FieldMeta: editor.FieldMeta{
+			Description: "Maximum mean brightness, between 0 and 1.",
+			Example:     "max: 0.35",
+			Min:         "0",
+			Max:         "1",
+		}},
+	}
+}
+
+// ColorFilter matches an image whose dominant color lies within Tolerance
+// (Euclidean distance in RGB, 0-441) of Dominant.
+type ColorFilter struct {
+	Dominant  string  `yaml:"dominant" mapstructure:"dominant"`
+	Tolerance float64 `yaml:"tolerance,omitempty" mapstructure:"tolerance"`
+}
+
+func (ColorFilter) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"dominant": {FieldMeta: editor.FieldMeta{
+			Description: "Wanted dominant color as a hex RGB value.",
+			Example:     `dominant: "#1e3a8a"`,
+			Required:    true,
+		}},
+		"tolerance": {FieldMeta: editor.FieldMeta{
+			Description: "How far the image's dominant color may be from dominant, as a distance in RGB space (0 = exact, 441 = anything).",
+			Default:     "30",
+			Min:         "0",
+			Max:         "441",
+		}},
+	}
+}
+
+// ExifFilter matches a photo by its EXIF metadata. Months holds month
+// numbers (1-12) or "current" for the month of the run; Camera is a
+// case-insensitive glob on the camera model (or "make model"). Images
+// without EXIF metadata only pass a Geotagged: false constraint.
+type ExifFilter struct {
+	Months    []string `yaml:"months,omitempty" mapstructure:"months"`
+	Camera    string   `yaml:"camera,omitempty" mapstructure:"camera"`
+	Geotagged *bool    `yaml:"geotagged,omitempty" mapstructure:"geotagged"`
+	Area      *GeoArea `yaml:"area,omitempty" mapstructure:"area"`
+}
+
+func (ExifFilter) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"months": {FieldMeta: editor.FieldMeta{
+			Description: `Months the photo was taken in (EXIF DateTimeOriginal), any year: numbers 1-12, or "current" for the month gopaper runs in.`,
+			Example:     `months: ["current"]`,
+		}},
+		"camera": {FieldMeta: editor.FieldMeta{
+			Description: `Case-insensitive wildcard pattern matched against the camera model and against "make model".`,
+			Example:     `camera: "Pixel *"`,
+		}},
+		"geotagged": {FieldMeta: editor.FieldMeta{
+			Description: "true keeps only photos with a GPS position, false only photos without one.",
+		}},
+		"area": {FieldMeta: editor.FieldMeta{
+			Description: "Keep only photos whose GPS position lies inside this latitude/longitude box.",
+		}},
+	}
+}
+
+// GeoArea is a latitude/longitude box in decimal degrees. MinLon greater
+// than MaxLon denotes a box crossing the 180th meridian.
+type GeoArea struct {
+	MinLat float64 `yaml:"min-lat" mapstructure:"min-lat"`
+	MaxLat float64 `yaml:"max-lat" mapstructure:"max-lat"`
+	MinLon float64 `yaml:"min-lon" mapstructure:"min-lon"`
+	MaxLon float64 `yaml:"max-lon" mapstructure:"max-lon"`
+}
+
+func (GeoArea) Metadata() map[string]*metadata.Node {
+	return map[string]*metadata.Node{
+		"min-lat": {FieldMeta: editor.FieldMeta{
+			Description: "Southern edge, in decimal degrees (negative = south).",
+			Example:     "min-lat: 36.9",
+			Required:    true,
+			Min:         "-90",
+			Max:         "90",
+		}},
+		"max-lat": {FieldMeta: editor.FieldMeta{
+			Description: "Northern edge, in decimal degrees.",
+			Example:     "max-lat: 42.2",
+			Required:    true,
+			Min:         "-90",
+			Max:         "90",
+		}},
+		"min-lon": {FieldMeta: editor.FieldMeta{
+			Description: "Western edge, in decimal degrees (negative = west). Greater than max-lon for a box crossing the 180th meridian.",
+			Example:     "min-lon: -9.6",
+			Required:    true,
+			Min:         "-180",
+			Max:         "180",
+		}},
+		"max-lon": {FieldMeta: editor.FieldMeta{
+			Description: "Eastern edge, in decimal degrees.",
+			Example:     "max-lon: -6.1",
+			Required:    true,
+			Min:         "-180",
+			Max:         "180",
+		}},
 	}
 }
 
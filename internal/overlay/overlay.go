@@ -0,0 +1,283 @@
+// Package overlay renders lines of text — the date, the weather, a quote,
+// the hostname — onto a copy of a wallpaper, in pure Go with the embedded
+// Go fonts, caching the result so unchanged text is rendered once.
+package overlay
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/weather"
+)
+
+// Overlay items.
+const (
+	Date     = "date"
+	Weather  = "weather"
+	Quote    = "quote"
+	Hostname = "hostname"
+)
+
+// Corners.
+const (
+	TopLeft     = "top-left"
+	TopRight    = "top-right"
+	BottomLeft  = "bottom-left"
+	BottomRight = "bottom-right"
+)
+
+// DefaultDateFormat is the date line's layout when none is configured.
+const DefaultDateFormat = "Monday, January 2"
+
+// Items returns the known overlay items, for messages and docs.
+func Items() []string { return []string{Date, Weather, Quote, Hostname} }
+
+// Inputs are the values the overlay items are rendered from.
+type Inputs struct {
+	Now        time.Time
+	DateFormat string            // Go layout; DefaultDateFormat when empty
+	Weather    *weather.Snapshot // nil leaves the weather line out
+	Units      weather.Units
+	QuoteFile  string
+	Hostname   string
+}
+
+// Lines returns the text of items, one line each, in order. An item with
+// nothing to show (no weather snapshot, an empty quote file) is left out;
+// the errors met (unknown items, an unreadable quote file) are joined and
+// returned alongside the lines that could be rendered.
+func Lines(items []string, in Inputs) ([]string, error) {
+	var (
+		lines []string
+		errs  []error
+	)
+	for _, item := range items {
+		switch item {
+		case Date:
+			layout := in.DateFormat
+			if layout == "" {
+				layout = DefaultDateFormat
+			}
+			lines = append(lines, in.Now.Format(layout))
+		case Weather:
+			if in.Weather != nil {
+				lines = append(lines, weatherLine(*in.Weather, in.Units))
+			}
+		case Quote:
+			q, err := quoteOfTheDay(in.QuoteFile, in.Now)
+			if err != nil {
+				errs = append(errs, err)
+			} else if q != "" {
+				lines = append(lines, q)
+			}
+		case Hostname:
+			if in.Hostname != "" {
+				lines = append(lines, in.Hostname)
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown overlay item %q: use one of %s", item, strings.Join(Items(), ", ")))
+		}
+	}
+	return lines, errors.Join(errs...)
+}
+
+// weatherLine renders a snapshot as e.g. "cloudy, 18.5°C".
+func weatherLine(s weather.Snapshot, units weather.Units) string {
+	sky := "weather code " + strconv.Itoa(s.Code)
+	if k, ok := s.Sky(); ok {
+		sky = string(k)
+	}
+	return sky + ", " + units.FormatTemperature(s.Temperature)
+}
+
+// quoteOfTheDay returns one quote of the file at path (one per line, blank
+// lines and # comments skipped), the same one all day long.
+func quoteOfTheDay(path string, now time.Time) (string, error) {
+	if path == "" {
+		return "", errors.New("the quote overlay needs a quote-file")
+	}
+	f, err := os.Open(path) // #nosec G304 -- configured quote file
+	if err != nil {
+		return "", fmt.Errorf("could not read quote file: %w", err)
+	}
+	defer f.Close()
+	var quotes []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			quotes = append(quotes, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", fmt.Errorf("could not read quote file: %w", err)
+	}
+	if len(quotes) == 0 {
+		return "", nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(now.Format(time.DateOnly)))
+	return quotes[h.Sum32()%uint32(len(quotes))], nil
+}
+
+// Style is how the lines are drawn.
+type Style struct {
+	Corner string  // one of the corner constants; BottomRight when empty
+	Size   float64 // text height in pixels; 0 is 1/36 of the image height
+	Color  color.NRGBA
+	Shadow bool
+}
+
+// String returns the style in a canonical form, used in cache keys.
+func (s Style) String() string {
+	return fmt.Sprintf("%s/%g/%02x%02x%02x%02x/%t", s.Corner, s.Size, s.Color.R, s.Color.G, s.Color.B, s.Color.A, s.Shadow)
+}
+
+// FromConfig returns the style an overlay configures: the defaults are the
+// bottom-right corner, white text and a shadow.
+func FromConfig(o models.Overlay) (Style, error) {
+	st := Style{Corner: BottomRight, Size: o.FontSize, Color: color.NRGBA{255, 255, 255, 255}, Shadow: o.Shadow == nil || *o.Shadow}
+	switch o.Corner {
+	case "":
+	case TopLeft, TopRight, BottomLeft, BottomRight:
+		st.Corner = o.Corner
+	default:
+		return Style{}, fmt.Errorf("unknown corner %q: use top-left, top-right, bottom-left or bottom-right", o.Corner)
+	}
+	if o.FontSize < 0 {
+		return Style{}, fmt.Errorf("font-size must be positive, got %v", o.FontSize)
+	}
+	if o.Color != "" {
+		c, err := ParseColor(o.Color)
+		if err != nil {
+			return Style{}, err
+		}
+		st.Color = c
+	}
+	return st, nil
+}
+
+// ParseColor parses a #rrggbb or #rrggbbaa color.
+func ParseColor(s string) (color.NRGBA, error) {
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok || (len(hex) != 6 && len(hex) != 8) {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: use #rrggbb or #rrggbbaa", s)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: use #rrggbb or #rrggbbaa", s)
+	}
+	return color.NRGBA{uint8(n >> 24), uint8(n >> 16), uint8(n >> 8), uint8(n)}, nil
+}
+
+// Apply renders lines onto a copy of the image at path in cacheDir and
+// returns the copy's path, or path itself when there are no lines. The copy
+// is keyed by path, size, modification time, lines and style, so it is
+// rendered again only when one of them changes (e.g. the date).
+func Apply(path string, lines []string, st Style, cacheDir string) (string, error) {
+	if len(lines) == 0 {
+		return path, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+	key := imagetype.Key(path, info, append([]string{st.String()}, lines...)...)
+	if cached, ok := imagetype.Cached(cacheDir, key); ok {
+		return cached, nil
+	}
+
+	img, err := imagetype.Decode(path)
+	if err != nil {
+		return "", err
+	}
+	b := img.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), img, b.Min, draw.Src)
+	if err := Draw(canvas, lines, st); err != nil {
+		return "", err
+	}
+	return imagetype.Store(canvas, cacheDir, key)
+}
+
+// regular is the embedded Go Regular font, parsed once.
+var regular = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(goregular.TTF)
+})
+
+// Face returns the embedded font at size pixels; the caller closes it.
+func Face(size float64) (font.Face, error) {
+	f, err := regular()
+	if err != nil {
+		return nil, fmt.Errorf("could not load the overlay font: %w", err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("could not load the overlay font: %w", err)
+	}
+	return face, nil
+}
+
+// Draw renders lines onto img in st's corner, with a margin of one text
+// height from the edges.
+func Draw(img draw.Image, lines []string, st Style) error {
+	b := img.Bounds()
+	size := st.Size
+	if size <= 0 {
+		size = max(float64(b.Dy())/36, 8)
+	}
+	face, err := Face(size)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	corner := st.Corner
+	if corner == "" {
+		corner = BottomRight
+	}
+	m := face.Metrics()
+	lineHeight := m.Height.Ceil()
+	margin := int(size)
+	right := corner == TopRight || corner == BottomRight
+	top := b.Min.Y + margin
+	if corner == BottomLeft || corner == BottomRight {
+		top = b.Max.Y - margin - lineHeight*len(lines)
+	}
+
+	shadowOffset := max(1, int(size/16))
+	for i, line := range lines {
+		x := b.Min.X + margin
+		if right {
+			x = b.Max.X - margin - font.MeasureString(face, line).Ceil()
+		}
+		dot := fixed.P(x, top+i*lineHeight+m.Ascent.Ceil())
+		if st.Shadow {
+			shadow := image.NewUniform(color.NRGBA{0, 0, 0, uint8(int(st.Color.A) * 160 / 255)})
+			d := font.Drawer{Dst: img, Src: shadow, Face: face, Dot: dot.Add(fixed.P(shadowOffset, shadowOffset))}
+			d.DrawString(line)
+		}
+		d := font.Drawer{Dst: img, Src: image.NewUniform(st.Color), Face: face, Dot: dot}
+		d.DrawString(line)
+	}
+	return nil
+}
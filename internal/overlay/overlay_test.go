@@ -0,0 +1,145 @@
+package overlay
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasassuncao/gopaper/internal/models"
+	"github.com/lucasassuncao/gopaper/internal/weather"
+)
+
+func TestLines(t *testing.T) {
+	dir := t.TempDir()
+	quotes := filepath.Join(dir, "quotes.txt")
+	if err := os.WriteFile(quotes, []byte("# mine\n\nStay hungry.\nLess is more.\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	in := Inputs{
+		Now:       time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC),
+		Weather:   &weather.Snapshot{Code: 3, Temperature: 18.5},
+		Units:     weather.UnitsMetric,
+		QuoteFile: quotes,
+		Hostname:  "kiosk-1",
+	}
+
+	got, err := Lines([]string{Date, Weather, Hostname}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Monday, October 19", "cloudy, 18.5°C", "kiosk-1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+
+	q, err := Lines([]string{Quote}, in)
+	if err != nil || len(q) != 1 || (q[0] != "Stay hungry." && q[0] != "Less is more.") {
+		t.Fatalf("quote line = (%q, %v)", q, err)
+	}
+	later := in
+	later.Now = in.Now.Add(10 * time.Hour)
+	if again, _ := Lines([]string{Quote}, later); !reflect.DeepEqual(again, q) {
+		t.Errorf("the quote changed within the day: %q, then %q", q, again)
+	}
+
+	in.Weather = nil
+	in.DateFormat = "02/01"
+	got, err = Lines([]string{Date, Weather, "moon"}, in)
+	if err == nil || !strings.Contains(err.Error(), `"moon"`) {
+		t.Errorf("expected an unknown item error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"19/10"}) {
+		t.Errorf("without weather, Lines() = %q, want just the date", got)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	off := false
+	st, err := FromConfig(models.Overlay{Corner: "top-left", FontSize: 20, Color: "#ff000080", Shadow: &off})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Style{Corner: TopLeft, Size: 20, Color: color.NRGBA{255, 0, 0, 128}}
+	if st != want {
+		t.Errorf("FromConfig() = %+v, want %+v", st, want)
+	}
+	if st, _ := FromConfig(models.Overlay{}); st.Corner != BottomRight || !st.Shadow || st.Color != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("defaults = %+v", st)
+	}
+	for _, bad := range []models.Overlay{{Corner: "middle"}, {Color: "red"}, {Color: "#12345"}, {FontSize: -2}} {
+		if _, err := FromConfig(bad); err == nil {
+			t.Errorf("FromConfig(%+v): expected an error", bad)
+		}
+	}
+}
+
+// inked reports whether any pixel of r differs from the black background.
+func inked(img *image.RGBA, r image.Rectangle) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.RGBAAt(x, y) != (color.RGBA{0, 0, 0, 255}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestDrawPlacesTextInTheCorner(t *testing.T) {
+	topLeft, bottomRight := image.Rect(0, 0, 200, 100), image.Rect(200, 100, 400, 200)
+	for corner, at := range map[string][2]image.Rectangle{
+		TopLeft:     {topLeft, bottomRight},
+		BottomRight: {bottomRight, topLeft},
+	} {
+		img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+		if err := Draw(img, []string{"Monday"}, Style{Corner: corner, Size: 16, Color: color.NRGBA{255, 255, 255, 255}}); err != nil {
+			t.Fatal(err)
+		}
+		if !inked(img, at[0]) {
+			t.Errorf("%s: no text in %v", corner, at[0])
+		}
+		if inked(img, at[1]) {
+			t.Errorf("%s: text in the opposite corner %v", corner, at[1])
+		}
+	}
+}
+
+func TestApplyRendersOncePerText(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "wall.png")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 120, 80))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	cache := filepath.Join(dir, "overlay")
+	st := Style{Corner: BottomRight, Color: color.NRGBA{255, 255, 255, 255}}
+
+	if got, err := Apply(src, nil, st, cache); err != nil || got != src {
+		t.Fatalf("Apply without lines = (%q, %v), want the source", got, err)
+	}
+	first, err := Apply(src, []string{"Monday"}, st, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(first) != cache {
+		t.Fatalf("overlaid copy %q is not in the cache directory", first)
+	}
+	if again, _ := Apply(src, []string{"Monday"}, st, cache); again != first {
+		t.Errorf("same text rendered to %q, then %q", first, again)
+	}
+	if next, _ := Apply(src, []string{"Tuesday"}, st, cache); next == first {
+		t.Error("new text should render a new copy")
+	}
+}
@@ -0,0 +1,140 @@
+// Package palette extracts an image's dominant colors by median cut:
+// the image's pixels are split, box by box, along the channel they vary
+// most in, and each final box's average is one color of the palette.
+package palette
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"slices"
+)
+
+// Limits on the number of colors extracted.
+const (
+	MinColors     = 8
+	MaxColors     = 16
+	DefaultColors = 16
+)
+
+// maxSamples bounds the pixels looked at: larger images are sampled on an
+// even grid, which keeps extraction fast and hardly moves the averages.
+const maxSamples = 256 * 256
+
+// Extract returns up to n of img's dominant colors, the most common first.
+// Fully transparent pixels are skipped. The result is deterministic: the
+// same image always gives the same palette.
+func Extract(img image.Image, n int) []color.NRGBA {
+	pixels := sample(img)
+	if len(pixels) == 0 || n <= 0 {
+		return nil
+	}
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		i, ch := widest(boxes)
+		if i < 0 {
+			break
+		}
+		b := boxes[i]
+		slices.SortStableFunc(b, func(p, q [3]uint8) int { return cmp.Compare(p[ch], q[ch]) })
+		mid := split(b, ch)
+		boxes[i] = b[:mid]
+		boxes = append(boxes, b[mid:])
+	}
+
+	type weighted struct {
+		c     color.NRGBA
+		count int
+	}
+	colors := make([]weighted, len(boxes))
+	for i, b := range boxes {
+		var sum [3]int
+		for _, p := range b {
+			sum[0] += int(p[0])
+			sum[1] += int(p[1])
+			sum[2] += int(p[2])
+		}
+		colors[i] = weighted{color.NRGBA{uint8(sum[0] / len(b)), uint8(sum[1] / len(b)), uint8(sum[2] / len(b)), 255}, len(b)}
+	}
+	slices.SortStableFunc(colors, func(a, b weighted) int {
+		if c := cmp.Compare(b.count, a.count); c != 0 {
+			return c
+		}
+		return cmp.Compare(Luminance(a.c), Luminance(b.c))
+	})
+	out := make([]color.NRGBA, len(colors))
+	for i, w := range colors {
+		out[i] = w.c
+	}
+	return out
+}
+
+// split returns where to cut b, sorted along channel ch: at the median,
+// moved to the nearest change of value so that equal values stay together
+// (b's range along ch is not zero, so there is one).
+func split(b [][3]uint8, ch int) int {
+	mid := len(b) / 2
+	for up, down := mid, mid; ; up, down = up+1, down-1 {
+		if up < len(b) && b[up][ch] != b[up-1][ch] {
+			return up
+		}
+		if down > 0 && b[down][ch] != b[down-1][ch] {
+			return down
+		}
+	}
+}
+
+// sample returns the pixels of img as RGB triples, on an even grid of at
+// most maxSamples of them.
+func sample(img image.Image) [][3]uint8 {
+	b := img.Bounds()
+	step := 1
+	for (b.Dx()/step)*(b.Dy()/step) > maxSamples {
+		step++
+	}
+	pixels := make([][3]uint8, 0, (b.Dx()/step+1)*(b.Dy()/step+1))
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			pixels = append(pixels, [3]uint8{c.R, c.G, c.B})
+		}
+	}
+	return pixels
+}
+
+// widest returns the box with the largest range along one channel, and
+// that channel, or -1 when every box holds a single color.
+func widest(boxes [][][3]uint8) (box, channel int) {
+	box, best := -1, 0
+	for i, b := range boxes {
+		if len(b) < 2 {
+			continue
+		}
+		lo, hi := [3]uint8{255, 255, 255}, [3]uint8{}
+		for _, p := range b {
+			for c := range 3 {
+				lo[c], hi[c] = min(lo[c], p[c]), max(hi[c], p[c])
+			}
+		}
+		for c := range 3 {
+			if r := int(hi[c]) - int(lo[c]); r > best {
+				box, channel, best = i, c, r
+			}
+		}
+	}
+	return box, channel
+}
+
+// Luminance returns c's relative luminance, 0 (black) to 1 (white).
+func Luminance(c color.NRGBA) float64 {
+	return (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / 255
+}
+
+// Mix returns a blended t of the way from a to b.
+func Mix(a, b color.NRGBA, t float64) color.NRGBA {
+	m := func(p, q uint8) uint8 { return uint8(float64(p) + (float64(q)-float64(p))*t + 0.5) }
+	return color.NRGBA{m(a.R, b.R), m(a.G, b.G), m(a.B, b.B), 255}
+}
@@ -0,0 +1,45 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"testing"
+)
+
+func TestExtractFindsTheDominantColors(t *testing.T) {
+	red, blue, white := color.NRGBA{200, 30, 30, 255}, color.NRGBA{20, 40, 160, 255}, color.NRGBA{250, 250, 250, 255}
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, image.Rect(0, 0, 60, 100), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(60, 0, 90, 100), image.NewUniform(blue), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(90, 0, 100, 100), image.NewUniform(white), image.Point{}, draw.Src)
+
+	got := Extract(img, 8)
+	if len(got) != 3 {
+		t.Fatalf("Extract() = %v, want the 3 colors of the image", got)
+	}
+	if want := []color.NRGBA{red, blue, white}; !slices.Equal(got, want) {
+		t.Errorf("Extract() = %v, want %v (most common first)", got, want)
+	}
+}
+
+func TestExtractIsDeterministic(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for y := range 200 {
+		for x := range 300 {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+		}
+	}
+	a, b := Extract(img, 16), Extract(img, 16)
+	if len(a) != 16 || !slices.Equal(a, b) {
+		t.Errorf("Extract() gave %d colors, then %v vs %v", len(a), a, b)
+	}
+}
+
+func TestExtractSkipsTransparentPixels(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	if got := Extract(img, 8); got != nil {
+		t.Errorf("Extract() of a transparent image = %v, want nil", got)
+	}
+}
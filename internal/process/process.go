@@ -0,0 +1,238 @@
+// Package process renders a category's process steps (resize to the
+// monitor, blur, dim, grayscale) over a wallpaper image, caching the
+// rendered copy so an unchanged image and pipeline are rendered once.
+package process
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/models"
+)
+
+// Step operations.
+const (
+	ResizeToMonitor = "resize-to-monitor"
+	Blur            = "blur"
+	Dim             = "dim"
+	Grayscale       = "grayscale"
+)
+
+// Step is one validated process step.
+type Step struct {
+	Op     string
+	Amount float64 // blur radius in pixels, or dim fraction (0-1)
+}
+
+// String returns the step in a canonical form, "blur=8" or "grayscale",
+// used in cache keys and log messages.
+func (s Step) String() string {
+	switch s.Op {
+	case Blur, Dim:
+		return s.Op + "=" + strconv.FormatFloat(s.Amount, 'g', -1, 64)
+	}
+	return s.Op
+}
+
+// FromConfig validates a configured step: exactly one operation, blur with
+// a positive radius, dim with an amount between 0 and 1.
+func FromConfig(c models.ProcessStep) (Step, error) {
+	var ops []Step
+	if c.ResizeToMonitor {
+		ops = append(ops, Step{Op: ResizeToMonitor})
+	}
+	if c.Blur != 0 {
+		ops = append(ops, Step{Op: Blur, Amount: c.Blur})
+	}
+	if c.Dim != 0 {
+		ops = append(ops, Step{Op: Dim, Amount: c.Dim})
+	}
+	if c.Grayscale {
+		ops = append(ops, Step{Op: Grayscale})
+	}
+	switch len(ops) {
+	case 0:
+		return Step{}, errors.New("define one of resize-to-monitor, blur or dim (with a positive amount), or grayscale")
+	case 1:
+	default:
+		names := make([]string, len(ops))
+		for i, s := range ops {
+			names[i] = s.Op
+		}
+		return Step{}, fmt.Errorf("one operation per step, got %s - split them into separate steps", strings.Join(names, ", "))
+	}
+
+	s := ops[0]
+	switch {
+	case s.Op == Blur && s.Amount < 0:
+		return Step{}, fmt.Errorf("blur radius must be positive, got %v", s.Amount)
+	case s.Op == Dim && (s.Amount < 0 || s.Amount > 1):
+		return Step{}, fmt.Errorf("dim must be between 0 and 1, got %v", s.Amount)
+	}
+	return s, nil
+}
+
+// Active returns the steps of cfg to run now, in order: the unconditioned
+// ones and those whose condition holds. It errors on the first invalid
+// step.
+func Active(cfg []models.ProcessStep, holds func(condition string) bool) ([]Step, error) {
+	var steps []Step
+	for i, c := range cfg {
+		s, err := FromConfig(c)
+		if err != nil {
+			return nil, fmt.Errorf("process step %d: %w", i+1, err)
+		}
+		if c.Condition != "" && !holds(c.Condition) {
+			continue
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// NeedsScreen reports whether any of steps depends on the monitor size.
+func NeedsScreen(steps []Step) bool {
+	for _, s := range steps {
+		if s.Op == ResizeToMonitor {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply renders steps over the image at path into cacheDir and returns the
+// rendered copy's path, or path itself when there are no steps. The copy is
+// keyed by path, size, modification time, steps and (for
+// resize-to-monitor) screen, so it is rendered only once.
+func Apply(path string, steps []Step, screen image.Point, cacheDir string) (string, error) {
+	if len(steps) == 0 {
+		return path, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+	extra := make([]string, 0, len(steps)+1)
+	for _, s := range steps {
+		extra = append(extra, s.String())
+	}
+	if NeedsScreen(steps) {
+		extra = append(extra, fmt.Sprintf("%dx%d", screen.X, screen.Y))
+	}
+	key := imagetype.Key(path, info, extra...)
+	if cached, ok := imagetype.Cached(cacheDir, key); ok {
+		return cached, nil
+	}
+
+	img, err := imagetype.Decode(path)
+	if err != nil {
+		return "", err
+	}
+	return imagetype.Store(Render(img, steps, screen), cacheDir, key)
+}
+
+// Render applies steps to img in order. screen is the size of the monitor
+// the result is shown on; resize-to-monitor is skipped when it is empty.
+func Render(img image.Image, steps []Step, screen image.Point) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Src)
+	for _, s := range steps {
+		switch s.Op {
+		case ResizeToMonitor:
+			if screen.X > 0 && screen.Y > 0 && out.Bounds().Size() != screen {
+				out = Cover(out, screen)
+			}
+		case Blur:
+			boxBlur(out, int(math.Round(s.Amount)))
+		case Dim:
+			dim(out, s.Amount)
+		case Grayscale:
+			grayscale(out)
+		}
+	}
+	return out
+}
+
+// Cover returns img scaled to exactly fill size, with the overflowing edges
+// cropped evenly (the "crop" wallpaper mode, done ahead of time).
+func Cover(img image.Image, size image.Point) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 || size.X <= 0 || size.Y <= 0 {
+		return out
+	}
+	scale := max(float64(size.X)/float64(w), float64(size.Y)/float64(h))
+	cw := min(w, int(math.Round(float64(size.X)/scale)))
+	ch := min(h, int(math.Round(float64(size.Y)/scale)))
+	x0, y0 := b.Min.X+(w-cw)/2, b.Min.Y+(h-ch)/2
+
+	xdraw.CatmullRom.Scale(out, out.Bounds(), img, image.Rect(x0, y0, x0+cw, y0+ch), xdraw.Src, nil)
+	return out
+}
+
+// boxBlur approximates a Gaussian blur of the given radius with three
+// passes of a horizontal and a vertical box blur, in place.
+func boxBlur(img *image.RGBA, radius int) {
+	if radius < 1 {
+		return
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	tmp := make([]uint8, len(img.Pix))
+	for range 3 {
+		// Rows: pixels are 4 bytes apart; columns: a stride apart.
+		for y := range h {
+			blurLine(tmp, img.Pix, y*img.Stride, 4, w, radius)
+		}
+		for x := range w {
+			blurLine(img.Pix, tmp, x*4, img.Stride, h, radius)
+		}
+	}
+}
+
+// blurLine box-blurs the n pixels of one row or column of src into dst,
+// starting at offset start with step bytes between pixels, using a sliding
+// sum over the window of radius pixels on each side (edges are clamped).
+func blurLine(dst, src []uint8, start, step, n, radius int) {
+	window := 2*radius + 1
+	at := func(i int) int { return start + min(max(i, 0), n-1)*step }
+	for c := range 4 {
+		sum := 0
+		for i := -radius; i <= radius; i++ {
+			sum += int(src[at(i)+c])
+		}
+		for i := range n {
+			dst[start+i*step+c] = uint8(sum / window)
+			sum += int(src[at(i+radius+1)+c]) - int(src[at(i-radius)+c])
+		}
+	}
+}
+
+// dim darkens img by amount (0-1), in place.
+func dim(img *image.RGBA, amount float64) {
+	keep := 1 - amount
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := range 3 {
+			img.Pix[i+c] = uint8(float64(img.Pix[i+c])*keep + 0.5)
+		}
+	}
+}
+
+// grayscale replaces every pixel with its luma, in place.
+func grayscale(img *image.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		p := img.Pix[i : i+3 : i+3]
+		y := uint8((299*int(p[0]) + 587*int(p[1]) + 114*int(p[2]) + 500) / 1000)
+		p[0], p[1], p[2] = y, y, y
+	}
+}
@@ -0,0 +1,144 @@
+package process
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lucasassuncao/gopaper/internal/models"
+)
+
+// checker draws a w×h black-and-white checkerboard of 1-pixel squares.
+func checker(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := uint8(0)
+			if (x+y)%2 == 0 {
+				v = 255
+			}
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestFromConfig(t *testing.T) {
+	valid := map[models.ProcessStep]Step{
+		{ResizeToMonitor: true}:           {Op: ResizeToMonitor},
+		{Blur: 8}:                         {Op: Blur, Amount: 8},
+		{Dim: 0.3, Condition: "night"}:    {Op: Dim, Amount: 0.3},
+		{Grayscale: true}:                 {Op: Grayscale},
+		{Grayscale: true, Condition: "x"}: {Op: Grayscale},
+	}
+	for in, want := range valid {
+		if got, err := FromConfig(in); err != nil || got != want {
+			t.Errorf("FromConfig(%+v) = (%+v, %v), want %+v", in, got, err, want)
+		}
+	}
+
+	invalid := map[models.ProcessStep]string{
+		{}:                         "define one of",
+		{Blur: 4, Grayscale: true}: "one operation per step",
+		{Blur: -1}:                 "must be positive",
+		{Dim: 1.5}:                 "between 0 and 1",
+		{Condition: "night"}:       "define one of",
+	}
+	for in, msg := range invalid {
+		if _, err := FromConfig(in); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("FromConfig(%+v) error = %v, want one containing %q", in, err, msg)
+		}
+	}
+}
+
+func TestActiveSkipsStepsWhoseConditionDoesNotHold(t *testing.T) {
+	cfg := []models.ProcessStep{
+		{ResizeToMonitor: true},
+		{Dim: 0.3, Condition: "night"},
+		{Blur: 2, Condition: "rain"},
+	}
+	holds := func(name string) bool { return name == "night" }
+	got, err := Active(cfg, holds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Step{{Op: ResizeToMonitor}, {Op: Dim, Amount: 0.3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Active() = %v, want %v", got, want)
+	}
+
+	if _, err := Active([]models.ProcessStep{{Grayscale: true}, {}}, holds); err == nil || !strings.Contains(err.Error(), "step 2") {
+		t.Errorf("expected an error naming step 2, got %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	src := checker(40, 20)
+
+	if got := Render(src, []Step{{Op: ResizeToMonitor}}, image.Pt(30, 30)).Bounds(); got != image.Rect(0, 0, 30, 30) {
+		t.Errorf("resize-to-monitor bounds = %v, want 30x30", got)
+	}
+	if got := Render(src, []Step{{Op: ResizeToMonitor}}, image.Point{}).Bounds(); got != src.Bounds() {
+		t.Errorf("resize-to-monitor without a screen size should be skipped, got %v", got)
+	}
+
+	blurred := Render(src, []Step{{Op: Blur, Amount: 3}}, image.Point{})
+	if r := blurred.RGBAAt(20, 10).R; r < 100 || r > 155 {
+		t.Errorf("a blurred checkerboard should be mid-gray, got %d", r)
+	}
+
+	dimmed := Render(src, []Step{{Op: Dim, Amount: 0.25}}, image.Point{})
+	if got := dimmed.RGBAAt(0, 0); got != (color.RGBA{191, 191, 191, 255}) {
+		t.Errorf("dim 0.25 of white = %v", got)
+	}
+
+	red := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < len(red.Pix); i += 4 {
+		red.Pix[i], red.Pix[i+3] = 255, 255
+	}
+	if got := Render(red, []Step{{Op: Grayscale}}, image.Point{}).RGBAAt(1, 1); got != (color.RGBA{76, 76, 76, 255}) {
+		t.Errorf("grayscale of red = %v", got)
+	}
+}
+
+func TestApplyCachesByStepsAndFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "wall.png")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, checker(16, 16)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	cache := filepath.Join(dir, "processed")
+
+	if got, err := Apply(src, nil, image.Point{}, cache); err != nil || got != src {
+		t.Fatalf("Apply without steps = (%q, %v), want the source", got, err)
+	}
+
+	steps := []Step{{Op: Dim, Amount: 0.5}}
+	first, err := Apply(src, steps, image.Point{}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(first) != cache {
+		t.Fatalf("processed copy %q is not in the cache directory", first)
+	}
+	if again, _ := Apply(src, steps, image.Point{}, cache); again != first {
+		t.Errorf("same image and steps rendered to %q, then %q", first, again)
+	}
+	if other, _ := Apply(src, []Step{{Op: Dim, Amount: 0.2}}, image.Point{}, cache); other == first {
+		t.Error("different steps should render a different copy")
+	}
+	resized, _ := Apply(src, []Step{{Op: ResizeToMonitor}}, image.Pt(8, 8), cache)
+	if other, _ := Apply(src, []Step{{Op: ResizeToMonitor}}, image.Pt(4, 4), cache); other == resized {
+		t.Error("resize-to-monitor for another screen size should render a different copy")
+	}
+}
@@ -0,0 +1,191 @@
+package tags
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed tag query: tags combined with and, or, not and
+// parentheses, e.g. "autumn and not (people or city)". not binds tightest,
+// then and, then or; keywords are case-insensitive.
+type Query struct {
+	source string
+	root   node
+}
+
+// node is one operator or tag of a parsed query.
+type node interface {
+	eval(tags []string) bool
+}
+
+type tagNode string
+
+func (n tagNode) eval(tags []string) bool { return slices.Contains(tags, string(n)) }
+
+type notNode struct{ x node }
+
+func (n notNode) eval(tags []string) bool { return !n.x.eval(tags) }
+
+type andNode struct{ l, r node }
+
+func (n andNode) eval(tags []string) bool { return n.l.eval(tags) && n.r.eval(tags) }
+
+type orNode struct{ l, r node }
+
+func (n orNode) eval(tags []string) bool { return n.l.eval(tags) || n.r.eval(tags) }
+
+func isKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "and", "or", "not":
+		return true
+	}
+	return false
+}
+
+// token is a word or parenthesis of a query, with its byte offset for
+// error messages.
+type token struct {
+	text string
+	pos  int
+}
+
+func tokenize(s string) []token {
+	var toks []token
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			toks = append(toks, token{s[start:end], start})
+			start = -1
+		}
+	}
+	for i, r := range s {
+		switch {
+		case r == '(' || r == ')':
+			flush(i)
+			toks = append(toks, token{string(r), i})
+		case unicode.IsSpace(r):
+			flush(i)
+		case start < 0:
+			start = i
+		}
+	}
+	flush(len(s))
+	return toks
+}
+
+// ParseQuery parses a tag query. Tags are matched case-insensitively.
+func ParseQuery(s string) (*Query, error) {
+	p := &parser{toks: tokenize(s)}
+	if len(p.toks) == 0 {
+		return nil, fmt.Errorf("empty tag query")
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+	}
+	return &Query{source: s, root: root}, nil
+}
+
+// Matches reports whether a file with the given (normalized) tags matches
+// the query.
+func (q *Query) Matches(tags []string) bool {
+	return q.root.eval(tags)
+}
+
+// String returns the query as written.
+func (q *Query) String() string { return q.source }
+
+// parser is a recursive-descent parser over the tokens of a query.
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.i >= len(p.toks) {
+		return token{}, false
+	}
+	return p.toks[p.i], true
+}
+
+// keyword consumes the next token when it is the keyword kw.
+func (p *parser) keyword(kw string) bool {
+	if t, ok := p.peek(); ok && strings.EqualFold(t.text, kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (node, error) {
+	if p.keyword("not") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of tag query")
+	}
+	p.i++
+	switch {
+	case t.text == "(":
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := p.peek(); !ok || c.text != ")" {
+			return nil, fmt.Errorf("missing ')' for '(' at position %d", t.pos+1)
+		}
+		p.i++
+		return x, nil
+	case t.text == ")" || isKeyword(t.text):
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+	}
+	tag, err := Normalize(t.text)
+	if err != nil {
+		return nil, fmt.Errorf("at position %d: %w", t.pos+1, err)
+	}
+	return tagNode(tag), nil
+}
@@ -0,0 +1,258 @@
+// Package tags keeps the tags a user assigns to image files, in one JSON
+// file next to the history file, and evaluates the tag queries
+// ("autumn and not people") that define tag-based categories.
+package tags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DB maps absolute image paths to their tags. Tags are stored lower-cased,
+// sorted and without duplicates. A nil *DB is valid and holds no tags.
+type DB struct {
+	path  string
+	mu    sync.Mutex
+	files map[string][]string
+	dirty bool
+}
+
+// Open loads the tag database stored at path. A missing file yields an
+// empty database; an unreadable or corrupt one is an error, since unlike a
+// cache its contents can't be rebuilt.
+func Open(path string) (*DB, error) {
+	db := &DB{path: path, files: map[string][]string{}}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is built from the configured history directory
+	if errors.Is(err, fs.ErrNotExist) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read tag database: %w", err)
+	}
+	if err := json.Unmarshal(data, &db.files); err != nil {
+		return nil, fmt.Errorf("could not parse tag database %s: %w", path, err)
+	}
+	if db.files == nil {
+		db.files = map[string][]string{}
+	}
+	return db, nil
+}
+
+// Normalize returns tag in its stored form: trimmed and lower-cased. A tag
+// is made of letters, digits and - _ . : / and can't be one of the query
+// keywords and, or, not.
+func Normalize(tag string) (string, error) {
+	t := strings.ToLower(strings.TrimSpace(tag))
+	if t == "" {
+		return "", errors.New("empty tag")
+	}
+	for _, r := range t {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.:/", r) {
+			return "", fmt.Errorf("invalid tag %q: only letters, digits and - _ . : / are allowed", tag)
+		}
+	}
+	if isKeyword(t) {
+		return "", fmt.Errorf("invalid tag %q: and, or and not are query keywords", tag)
+	}
+	return t, nil
+}
+
+// Sanitize turns a free-form keyword, such as an XMP subject, into a tag:
+// lower-cased, with runs of spaces replaced by "-" and other disallowed
+// characters dropped. It returns "" when nothing usable is left.
+func Sanitize(keyword string) string {
+	var b strings.Builder
+	for _, word := range strings.Fields(strings.ToLower(keyword)) {
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_.:/", r) {
+				b.WriteRune(r)
+			}
+		}
+	}
+	t, err := Normalize(strings.Trim(b.String(), "-"))
+	if err != nil {
+		return ""
+	}
+	return t
+}
+
+// Reserved tags set by gopaper fav and gopaper ban. A banned file is never
+// picked; a favorite can be drawn more often (configuration.favorites) or
+// gathered in a category with tags: favorite.
+const (
+	Favorite = "favorite"
+	Banned   = "banned"
+)
+
+// key is the form paths are stored under: absolute and cleaned.
+func key(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// Add tags the file at path with tags, which are normalized first. It
+// returns how many of them the file didn't have yet.
+func (db *DB) Add(path string, tags ...string) (int, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		n, err := Normalize(t)
+		if err != nil {
+			return 0, err
+		}
+		normalized = append(normalized, n)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	k := key(path)
+	current := db.files[k]
+	added := 0
+	for _, t := range normalized {
+		if !slices.Contains(current, t) {
+			current = append(current, t)
+			added++
+		}
+	}
+	if added > 0 {
+		slices.Sort(current)
+		db.files[k] = current
+		db.dirty = true
+	}
+	return added, nil
+}
+
+// Remove removes tags from the file at path, or every tag when none are
+// given, and returns how many were removed.
+func (db *DB) Remove(path string, tags ...string) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	k := key(path)
+	current, ok := db.files[k]
+	if !ok {
+		return 0
+	}
+	if len(tags) == 0 {
+		delete(db.files, k)
+		db.dirty = true
+		return len(current)
+	}
+	kept := current[:0]
+	for _, t := range current {
+		if !slices.ContainsFunc(tags, func(rm string) bool { return strings.EqualFold(strings.TrimSpace(rm), t) }) {
+			kept = append(kept, t)
+		}
+	}
+	removed := len(current) - len(kept)
+	if removed == 0 {
+		return 0
+	}
+	if len(kept) == 0 {
+		delete(db.files, k)
+	} else {
+		db.files[k] = kept
+	}
+	db.dirty = true
+	return removed
+}
+
+// Tags returns the tags of the file at path, sorted.
+func (db *DB) Tags(path string) []string {
+	if db == nil {
+		return nil
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return slices.Clone(db.files[key(path)])
+}
+
+// Has reports whether the file at path carries tag, which must be in its
+// normalized form.
+func (db *DB) Has(path, tag string) bool {
+	if db == nil {
+		return false
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return slices.Contains(db.files[key(path)], tag)
+}
+
+// Files returns every tagged file, sorted.
+func (db *DB) Files() []string {
+	if db == nil {
+		return nil
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	files := make([]string, 0, len(db.files))
+	for f := range db.files {
+		files = append(files, f)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// Counts returns how many files carry each tag.
+func (db *DB) Counts() map[string]int {
+	counts := map[string]int{}
+	if db == nil {
+		return counts
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, tags := range db.files {
+		for _, t := range tags {
+			counts[t]++
+		}
+	}
+	return counts
+}
+
+// Save writes the database back when it changed, atomically (temp file +
+// rename) so a concurrent run never reads a half-written file.
+func (db *DB) Save() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if !db.dirty {
+		return nil
+	}
+	dir := filepath.Dir(db.path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("could not create tag database directory: %w", err)
+	}
+	data, err := json.MarshalIndent(db.files, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode tag database: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tags-*")
+	if err != nil {
+		return fmt.Errorf("could not write tag database: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write tag database: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write tag database: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), db.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write tag database: %w", err)
+	}
+	db.dirty = false
+	return nil
+}
@@ -0,0 +1,187 @@
+package tags
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddRemoveAndPersist(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tags.json")
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("unexpected error opening a missing database: %v", err)
+	}
+
+	img := filepath.Join(t.TempDir(), "leaves.jpg")
+	if n, err := db.Add(img, "Autumn", "cozy", "autumn"); err != nil || n != 2 {
+		t.Fatalf("Add() = (%d, %v), want 2 new tags", n, err)
+	}
+	if _, err := db.Add(img, "two words"); err == nil {
+		t.Error("expected an error for a tag with a space")
+	}
+	if _, err := db.Add(img, "NOT"); err == nil {
+		t.Error("expected an error for a query keyword used as a tag")
+	}
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	db, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got := db.Tags(img); !reflect.DeepEqual(got, []string{"autumn", "cozy"}) {
+		t.Errorf("Tags() = %v, want [autumn cozy]", got)
+	}
+	if n := db.Remove(img, "COZY", "missing"); n != 1 {
+		t.Errorf("Remove() = %d, want 1", n)
+	}
+	if n := db.Remove(img); n != 1 || len(db.Files()) != 0 {
+		t.Errorf("Remove(all) = %d leaving %v, want 1 and no files", n, db.Files())
+	}
+}
+
+func TestHas(t *testing.T) {
+	var nilDB *DB
+	if nilDB.Has("/a.jpg", Favorite) {
+		t.Error("a nil database has no tags")
+	}
+	db, _ := Open(filepath.Join(t.TempDir(), "tags.json"))
+	_, _ = db.Add("/a.jpg", Favorite)
+	if !db.Has("/a.jpg", Favorite) || db.Has("/a.jpg", Banned) || db.Has("/b.jpg", Favorite) {
+		t.Errorf("Has() disagrees with Tags() = %v", db.Tags("/a.jpg"))
+	}
+}
+
+func TestOpenCorruptDatabaseIsAnError(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tags.json")
+	if err := os.WriteFile(dbPath, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dbPath); err == nil {
+		t.Error("expected an error for a corrupt database")
+	}
+}
+
+func TestCounts(t *testing.T) {
+	db, _ := Open(filepath.Join(t.TempDir(), "tags.json"))
+	_, _ = db.Add("/a.jpg", "autumn", "forest")
+	_, _ = db.Add("/b.jpg", "autumn")
+	want := map[string]int{"autumn": 2, "forest": 1}
+	if got := db.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %v, want %v", got, want)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	cases := map[string]string{
+		"Autumn Leaves":    "autumn-leaves",
+		"  Café  ":         "café",
+		"People (family)":  "people-family",
+		"!!!":              "",
+		"and":              "",
+		"Places/Lisbon":    "places/lisbon",
+		"rating: 5 stars ": "rating:-5-stars",
+	}
+	for in, want := range cases {
+		if got := Sanitize(in); got != want {
+			t.Errorf("Sanitize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	cases := []struct {
+		query string
+		tags  []string
+		want  bool
+	}{
+		{"autumn", []string{"autumn", "cozy"}, true},
+		{"Autumn and not people", []string{"autumn"}, true},
+		{"autumn and not people", []string{"autumn", "people"}, false},
+		{"autumn or winter and snow", []string{"autumn"}, true}, // and binds tighter
+		{"(autumn or winter) and snow", []string{"autumn"}, false},
+		{"(autumn or winter) and snow", []string{"winter", "snow"}, true},
+		{"not not cozy", []string{"cozy"}, true},
+		{"not cozy", nil, true},
+	}
+	for _, tc := range cases {
+		q, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tc.query, err)
+		}
+		if got := q.Matches(tc.tags); got != tc.want {
+			t.Errorf("%q.Matches(%v) = %v, want %v", tc.query, tc.tags, got, tc.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	cases := map[string]string{
+		"":                "empty",
+		"autumn and":      "unexpected end",
+		"(autumn or cozy": "missing ')'",
+		"autumn cozy":     `unexpected "cozy" at position 8`,
+		"autumn)":         `unexpected ")"`,
+		"and autumn":      `unexpected "and" at position 1`,
+		"autumn or f*x":   "position 11",
+	}
+	for query, want := range cases {
+		_, err := ParseQuery(query)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseQuery(%q) error = %v, want it to mention %q", query, err, want)
+		}
+	}
+}
+
+const xmpPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Not a keyword</rdf:li></rdf:Alt></dc:title>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>Autumn Leaves</rdf:li>
+     <rdf:li>forest</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+func TestReadXMPSubjects(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "leaves.jpg")
+	// An embedded packet, as in a JPEG APP1 segment, surrounded by binary data.
+	data := append([]byte("\xFF\xD8\xFF\xE1\x10\x00http://ns.adobe.com/xap/1.0/\x00"), xmpPacket...)
+	data = append(data, "\xFF\xDA\x00\x02binary"...)
+	if err := os.WriteFile(img, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := strings.ReplaceAll(xmpPacket, "forest", "cozy")
+	if err := os.WriteFile(filepath.Join(dir, "leaves.xmp"), []byte(sidecar), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadXMPSubjects(img)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"Autumn Leaves", "forest", "cozy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadXMPSubjects() = %v, want %v", got, want)
+	}
+}
+
+func TestReadXMPSubjectsWithoutXMP(t *testing.T) {
+	img := filepath.Join(t.TempDir(), "plain.png")
+	if err := os.WriteFile(img, []byte("\x89PNG\r\n\x1a\nno metadata"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadXMPSubjects(img)
+	if err != nil || len(got) != 0 {
+		t.Errorf("ReadXMPSubjects() = (%v, %v), want no subjects", got, err)
+	}
+}
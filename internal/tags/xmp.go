@@ -0,0 +1,97 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Namespaces of the XMP elements ReadXMPSubjects looks for.
+const (
+	nsDC  = "http://purl.org/dc/elements/1.1/"
+	nsRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+// ReadXMPSubjects returns the XMP dc:subject keywords of the image at path:
+// those of the XMP packet embedded in the file (as JPEG, PNG, TIFF and WebP
+// files carry it) and of a sidecar file next to it ("photo.jpg.xmp" or
+// "photo.xmp"), without duplicates. Keywords are returned as written; see
+// Sanitize for turning them into tags.
+func ReadXMPSubjects(path string) ([]string, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- a file named on the command line
+	if err != nil {
+		return nil, err
+	}
+	subjects := packetSubjects(data)
+
+	for _, sidecar := range []string{path + ".xmp", strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp"} {
+		data, err := os.ReadFile(sidecar) // #nosec G304 -- next to a file named on the command line
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range packetSubjects(data) {
+			if !slices.Contains(subjects, s) {
+				subjects = append(subjects, s)
+			}
+		}
+	}
+	return subjects, nil
+}
+
+// packetSubjects finds the XMP packet in data (an image file or a sidecar)
+// and returns its dc:subject keywords. A missing or malformed packet has
+// none.
+func packetSubjects(data []byte) []string {
+	start := bytes.Index(data, []byte("<x:xmpmeta"))
+	if start < 0 {
+		return nil
+	}
+	end := bytes.Index(data[start:], []byte("</x:xmpmeta>"))
+	if end < 0 {
+		return nil
+	}
+	packet := data[start : start+end+len("</x:xmpmeta>")]
+
+	var subjects []string
+	dec := xml.NewDecoder(bytes.NewReader(packet))
+	inSubject, inItem := 0, false
+	var item strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return subjects // the end, or damage: keep what was read before it
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == nsDC && t.Name.Local == "subject":
+				inSubject++
+			case inSubject > 0 && t.Name.Space == nsRDF && t.Name.Local == "li":
+				inItem = true
+				item.Reset()
+			}
+		case xml.CharData:
+			if inItem {
+				item.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == nsDC && t.Name.Local == "subject":
+				inSubject--
+			case inItem && t.Name.Space == nsRDF && t.Name.Local == "li":
+				inItem = false
+				if s := strings.TrimSpace(item.String()); s != "" && !slices.Contains(subjects, s) {
+					subjects = append(subjects, s)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package theme
+
+// builtins are the templates an in of just their name renders.
+var builtins = map[string]string{
+	"json": `{
+  "wallpaper": {{json .Wallpaper}},
+  "special": {
+    "background": "{{.Background}}",
+    "foreground": "{{.Foreground}}",
+    "cursor": "{{.Cursor}}"
+  },
+  "colors": {
+{{- range $i, $c := .Colors}}{{if $i}},{{end}}
+    "color{{$i}}": "{{$c}}"
+{{- end}}
+  },
+  "palette": [{{range $i, $c := .Palette}}{{if $i}}, {{end}}"{{$c}}"{{end}}]
+}
+`,
+	"sh": `# Generated by gopaper from {{.Wallpaper}}
+wallpaper={{shquote .Wallpaper}}
+background='{{.Background}}'
+foreground='{{.Foreground}}'
+cursor='{{.Cursor}}'
+{{range $i, $c := .Colors}}color{{$i}}='{{$c}}'
+{{end}}export wallpaper background foreground cursor{{range $i, $c := .Colors}} color{{$i}}{{end}}
+`,
+	"xresources": `! Generated by gopaper from {{.Wallpaper}}
+*background: {{.Background}}
+*foreground: {{.Foreground}}
+*cursorColor: {{.Cursor}}
+{{range $i, $c := .Colors}}*color{{$i}}: {{$c}}
+{{end}}`,
+	"css": `/* Generated by gopaper from {{.Wallpaper}} */
+:root {
+  --wallpaper: url({{json .Wallpaper}});
+  --background: {{.Background}};
+  --foreground: {{.Foreground}};
+  --cursor: {{.Cursor}};
+{{- range $i, $c := .Colors}}
+  --color{{$i}}: {{$c}};
+{{- end}}
+}
+`,
+	"kitty": `# Generated by gopaper from {{.Wallpaper}}
+background {{.Background}}
+foreground {{.Foreground}}
+cursor {{.Cursor}}
+selection_background {{.Foreground}}
+selection_foreground {{.Background}}
+{{range $i, $c := .Colors}}color{{$i}} {{$c}}
+{{end}}`,
+	"alacritty": `# Generated by gopaper from {{.Wallpaper}}
+[colors.primary]
+background = "{{.Background}}"
+foreground = "{{.Foreground}}"
+
+[colors.cursor]
+text = "{{.Background}}"
+cursor = "{{.Cursor}}"
+
+[colors.normal]
+black = "{{index .Colors 0}}"
+red = "{{index .Colors 1}}"
+green = "{{index .Colors 2}}"
+yellow = "{{index .Colors 3}}"
+blue = "{{index .Colors 4}}"
+magenta = "{{index .Colors 5}}"
+cyan = "{{index .Colors 6}}"
+white = "{{index .Colors 7}}"
+
+[colors.bright]
+black = "{{index .Colors 8}}"
+red = "{{index .Colors 9}}"
+green = "{{index .Colors 10}}"
+yellow = "{{index .Colors 11}}"
+blue = "{{index .Colors 12}}"
+magenta = "{{index .Colors 13}}"
+cyan = "{{index .Colors 14}}"
+white = "{{index .Colors 15}}"
+`,
+}
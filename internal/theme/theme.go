@@ -0,0 +1,206 @@
+// Package theme turns a wallpaper's palette into a terminal-style color
+// scheme (a background, a foreground and the 16 ANSI colors) and writes it
+// out through text/template files, either the user's or built-in ones for
+// JSON, shell, Xresources, CSS, kitty and alacritty.
+package theme
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/lucasassuncao/gopaper/internal/imagetype"
+	"github.com/lucasassuncao/gopaper/internal/palette"
+)
+
+// Color is one color of a theme. It prints as #rrggbb in templates.
+type Color struct{ R, G, B uint8 }
+
+func fromNRGBA(c color.NRGBA) Color { return Color{c.R, c.G, c.B} }
+
+// String returns c as #rrggbb.
+func (c Color) String() string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+
+// Strip returns c as rrggbb, without the #.
+func (c Color) Strip() string { return c.String()[1:] }
+
+// RGB returns c as r,g,b in decimal, e.g. for rgba() in CSS.
+func (c Color) RGB() string { return fmt.Sprintf("%d,%d,%d", c.R, c.G, c.B) }
+
+// Theme is what templates are rendered with.
+type Theme struct {
+	Wallpaper  string  // path of the image the colors come from
+	Background Color   // Colors[0]
+	Foreground Color   // Colors[15]
+	Cursor     Color   // the foreground
+	Colors     []Color // the 16 terminal colors, color0 to color15
+	Palette    []Color // every extracted color, the most common first
+}
+
+// New builds a theme from a palette (the most common color first): the
+// darkest color, darkened further, is the background (color 0), the
+// lightest, lightened, the foreground (color 15, color 7 a dimmer one), and
+// the others, brightened enough to read on the background, are colors 1 to
+// 6, the most common first; 8 to 14 are lighter versions of 0 to 6.
+func New(colors []color.NRGBA, wallpaper string) Theme {
+	black, white := color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}
+	if len(colors) == 0 {
+		colors = []color.NRGBA{black, white}
+	}
+	t := Theme{Wallpaper: wallpaper}
+	for _, c := range colors {
+		t.Palette = append(t.Palette, fromNRGBA(c))
+	}
+
+	byLuminance := slices.Clone(colors)
+	slices.SortStableFunc(byLuminance, func(a, b color.NRGBA) int {
+		return cmp.Compare(palette.Luminance(a), palette.Luminance(b))
+	})
+	darkest, lightest := byLuminance[0], byLuminance[len(byLuminance)-1]
+	bg := darkest
+	for palette.Luminance(bg) > 0.08 {
+		bg = palette.Mix(bg, black, 0.2)
+	}
+	fg := palette.Mix(lightest, white, 0.75)
+
+	var accents []color.NRGBA
+	for _, c := range colors {
+		if c != darkest && c != lightest {
+			accents = append(accents, c)
+		}
+	}
+	if len(accents) == 0 {
+		accents = []color.NRGBA{palette.Mix(darkest, lightest, 0.5)}
+	}
+
+	t.Colors = make([]Color, 16)
+	t.Colors[0], t.Colors[7], t.Colors[15] = fromNRGBA(bg), fromNRGBA(palette.Mix(fg, bg, 0.15)), fromNRGBA(fg)
+	t.Colors[8] = fromNRGBA(palette.Mix(bg, white, 0.25))
+	for i := range 6 {
+		c := accents[i%len(accents)]
+		for palette.Luminance(c) < 0.35 {
+			c = palette.Mix(c, white, 0.1)
+		}
+		t.Colors[1+i] = fromNRGBA(c)
+		t.Colors[9+i] = fromNRGBA(palette.Mix(c, white, 0.25))
+	}
+	t.Background, t.Foreground, t.Cursor = t.Colors[0], t.Colors[15], t.Colors[15]
+	return t
+}
+
+// FromImage extracts n colors from the image at path and builds its theme.
+func FromImage(path string, n int) (Theme, error) {
+	img, err := imagetype.Decode(path)
+	if err != nil {
+		return Theme{}, err
+	}
+	colors := palette.Extract(img, n)
+	if len(colors) == 0 {
+		return Theme{}, fmt.Errorf("%s has no visible pixels", path)
+	}
+	return New(colors, path), nil
+}
+
+// Template is one template to render: In is a template file or the name of
+// a built-in one (see Builtins), Out the file written.
+type Template struct {
+	In  string
+	Out string
+}
+
+// Builtins returns the names of the built-in templates.
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// funcs are the functions templates can call besides text/template's own.
+var funcs = template.FuncMap{
+	// json quotes a string for JSON (or CSS).
+	"json": func(s string) (string, error) {
+		b, err := json.Marshal(s)
+		return string(b), err
+	},
+	// shquote quotes a string for POSIX shells.
+	"shquote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+}
+
+// Parse returns the template in names: a built-in one, or else the file at
+// that path.
+func Parse(in string) (*template.Template, error) {
+	text, ok := builtins[in]
+	if !ok {
+		data, err := os.ReadFile(in) // #nosec G304 -- configured template file
+		if err != nil {
+			return nil, fmt.Errorf("could not read template: %w", err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New(filepath.Base(in)).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", in, err)
+	}
+	return tmpl, nil
+}
+
+// Write renders every template with t, replacing each output file whole
+// (written next to it, then renamed over it) so a program reading it never
+// sees half a theme. A template that fails doesn't stop the others; the
+// errors are joined.
+func Write(templates []Template, t Theme) error {
+	var errs []error
+	for _, tpl := range templates {
+		if err := write(tpl, t); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tpl.Out, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func write(tpl Template, t Theme) error {
+	tmpl, err := Parse(tpl.In)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, t); err != nil {
+		return fmt.Errorf("could not render %s: %w", tpl.In, err)
+	}
+	dir := filepath.Dir(tpl.Out)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("could not create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".theme-*")
+	if err != nil {
+		return fmt.Errorf("could not write theme: %w", err)
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write theme: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write theme: %w", err)
+	}
+	_ = os.Chmod(tmp.Name(), 0o644) // #nosec G302 -- read by terminals and shells, like any dotfile
+	if err := os.Rename(tmp.Name(), tpl.Out); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write theme: %w", err)
+	}
+	return nil
+}
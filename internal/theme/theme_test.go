@@ -0,0 +1,109 @@
+package theme
+
+import (
+	"encoding/json"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasassuncao/gopaper/internal/palette"
+)
+
+var sample = []color.NRGBA{
+	{40, 60, 90, 255},
+	{200, 120, 40, 255},
+	{10, 12, 20, 255},
+	{230, 220, 200, 255},
+	{90, 150, 80, 255},
+}
+
+func lum(c Color) float64 { return palette.Luminance(color.NRGBA{c.R, c.G, c.B, 255}) }
+
+func TestNew(t *testing.T) {
+	th := New(sample, "/walls/a.jpg")
+	if len(th.Colors) != 16 || len(th.Palette) != len(sample) {
+		t.Fatalf("got %d colors and a palette of %d", len(th.Colors), len(th.Palette))
+	}
+	if th.Background != th.Colors[0] || th.Foreground != th.Colors[15] {
+		t.Errorf("background %v / foreground %v are not color0 / color15", th.Background, th.Foreground)
+	}
+	if lum(th.Background) > 0.08 || lum(th.Foreground) < 0.75 {
+		t.Errorf("background %v is not dark or foreground %v not light", th.Background, th.Foreground)
+	}
+	for i := 1; i <= 6; i++ {
+		if lum(th.Colors[i]) < 0.35 {
+			t.Errorf("color%d %v is too dark to read on the background", i, th.Colors[i])
+		}
+	}
+	// The most common color that is neither the darkest nor the lightest
+	// comes first.
+	if th.Colors[1] != (Color{40, 60, 90}) && lum(Color{40, 60, 90}) >= 0.35 {
+		t.Errorf("color1 = %v", th.Colors[1])
+	}
+}
+
+func TestWriteBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	th := New(sample, `/walls/it's "a".jpg`)
+	var templates []Template
+	for _, name := range Builtins() {
+		templates = append(templates, Template{In: name, Out: filepath.Join(dir, "out", name)})
+	}
+	if err := Write(templates, th); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "out", "json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Wallpaper string            `json:"wallpaper"`
+		Colors    map[string]string `json:"colors"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("json template is not valid JSON: %v\n%s", err, data)
+	}
+	if parsed.Wallpaper != th.Wallpaper || parsed.Colors["color15"] != th.Foreground.String() {
+		t.Errorf("json = %+v", parsed)
+	}
+
+	sh, _ := os.ReadFile(filepath.Join(dir, "out", "sh"))
+	if !strings.Contains(string(sh), `wallpaper='/walls/it'\''s "a".jpg'`) || !strings.Contains(string(sh), "color4='"+th.Colors[4].String()+"'") {
+		t.Errorf("sh =\n%s", sh)
+	}
+	kitty, _ := os.ReadFile(filepath.Join(dir, "out", "kitty"))
+	if !strings.Contains(string(kitty), "background "+th.Background.String()) {
+		t.Errorf("kitty =\n%s", kitty)
+	}
+}
+
+func TestWriteUserTemplates(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "colors.tmpl")
+	os.WriteFile(good, []byte("bg={{.Background.Strip}} rgb={{(index .Colors 1).RGB}}\n"), 0o600)
+	bad := filepath.Join(dir, "bad.tmpl")
+	os.WriteFile(bad, []byte("{{.Nope}}"), 0o600)
+
+	th := New(sample, "/walls/a.jpg")
+	err := Write([]Template{
+		{In: bad, Out: filepath.Join(dir, "bad.out")},
+		{In: good, Out: filepath.Join(dir, "colors.out")},
+	}, th)
+	if err == nil || !strings.Contains(err.Error(), "bad.out") {
+		t.Errorf("expected an error naming bad.out, got %v", err)
+	}
+	got, readErr := os.ReadFile(filepath.Join(dir, "colors.out"))
+	if readErr != nil {
+		t.Fatalf("the good template should still be written: %v", readErr)
+	}
+	c := th.Colors[1]
+	if want := "bg=" + th.Background.Strip() + " rgb=" + c.RGB() + "\n"; string(got) != want {
+		t.Errorf("colors.out = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad.out")); err == nil {
+		t.Error("a template that fails to render should leave no output")
+	}
+}
@@ -18,6 +18,7 @@ type Snapshot struct {
 	Code        int     // WMO weather code
 	WindSpeed   float64 // km/h
 	Temperature float64 // Celsius
+	Buckets     Buckets // sky-name mapping used by InBucket; nil means the built-in one
 }
 
 // Sky maps the snapshot's code to a Sky category. ok is false for an
@@ -26,6 +27,16 @@ func (s Snapshot) Sky() (Sky, bool) {
 	return CodeToSky(s.Code)
 }
 
+// InBucket reports whether the snapshot's code belongs to the sky bucket
+// called name, using s.Buckets when set and the built-in mapping otherwise.
+func (s Snapshot) InBucket(name string) bool {
+	b := s.Buckets
+	if b == nil {
+		b = defaultBuckets
+	}
+	return b.Contains(name, s.Code)
+}
+
 // Config is the location and cache policy used to fetch a Snapshot.
 type Config struct {
 	Latitude  float64
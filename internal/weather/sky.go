@@ -2,7 +2,10 @@
 // by category variants that switch on sky condition or wind speed.
 package weather
 
-import "sort"
+import (
+	"slices"
+	"sort"
+)
 
 // Sky is one of the weather categories a condition can match against.
 type Sky string
@@ -80,3 +83,52 @@ func CodeToSky(code int) (sky Sky, ok bool) {
 	sky, ok = codeToSky[code]
 	return
 }
+
+// Buckets maps sky names to the WMO codes each one covers: the seven
+// built-in categories plus any declared in configuration.weather.buckets.
+// A code may sit in several buckets at once, so a custom "heavy-rain"
+// bucket can coexist with the built-in "rain" one.
+type Buckets map[string][]int
+
+// defaultBuckets is the built-in mapping, used when a Snapshot carries no
+// Buckets of its own.
+var defaultBuckets = NewBuckets(nil)
+
+// NewBuckets returns the built-in mapping extended by custom. A custom
+// bucket with a new name is added alongside the built-ins; one reusing a
+// built-in name replaces that bucket's codes entirely.
+func NewBuckets(custom map[string][]int) Buckets {
+	b := Buckets{}
+	for code, sky := range codeToSky {
+		b[string(sky)] = append(b[string(sky)], code)
+	}
+	for name := range b {
+		sort.Ints(b[name])
+	}
+	for name, codes := range custom {
+		b[name] = slices.Clone(codes)
+	}
+	return b
+}
+
+// Has reports whether name is a known bucket, built-in or custom.
+func (b Buckets) Has(name string) bool {
+	_, ok := b[name]
+	return ok
+}
+
+// Names returns every bucket name, sorted.
+func (b Buckets) Names() []string {
+	names := make([]string, 0, len(b))
+	for n := range b {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Contains reports whether code belongs to the bucket called name. An
+// unknown name contains no codes.
+func (b Buckets) Contains(name string, code int) bool {
+	return slices.Contains(b[name], code)
+}
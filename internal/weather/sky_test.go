@@ -48,3 +48,41 @@ func TestSkyNames(t *testing.T) {
 		}
 	}
 }
+
+func TestNewBucketsExtendsBuiltins(t *testing.T) {
+	b := NewBuckets(map[string][]int{"heavy-rain": {65, 67, 82}})
+
+	if !b.Contains("heavy-rain", 65) {
+		t.Error("expected custom heavy-rain bucket to contain 65")
+	}
+	if !b.Contains("rain", 65) {
+		t.Error("expected built-in rain bucket to still contain 65")
+	}
+	if b.Contains("heavy-rain", 61) {
+		t.Error("expected heavy-rain to not contain light rain (61)")
+	}
+	if len(b.Names()) != 8 {
+		t.Errorf("got %d names, want 8 (7 built-in + heavy-rain): %v", len(b.Names()), b.Names())
+	}
+}
+
+func TestNewBucketsOverridesBuiltinName(t *testing.T) {
+	b := NewBuckets(map[string][]int{"cloudy": {3}})
+
+	if b.Contains("cloudy", 1) {
+		t.Error("expected overridden cloudy bucket to drop code 1")
+	}
+	if !b.Contains("cloudy", 3) {
+		t.Error("expected overridden cloudy bucket to contain code 3")
+	}
+}
+
+func TestSnapshotInBucketDefaultsToBuiltins(t *testing.T) {
+	s := Snapshot{Code: 95}
+	if !s.InBucket("thunderstorm") {
+		t.Error("expected code 95 to be in thunderstorm with no custom buckets")
+	}
+	if s.InBucket("heavy-rain") {
+		t.Error("expected an undeclared bucket to never match")
+	}
+}
@@ -0,0 +1,145 @@
+package weather
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Units is the unit system thresholds are written in when they carry no
+// explicit suffix. Snapshots are always metric (km/h, Celsius); thresholds
+// are converted to metric once, when conditions are loaded.
+type Units string
+
+const (
+	UnitsMetric   Units = "metric"
+	UnitsImperial Units = "imperial"
+)
+
+// ParseUnits returns the unit system named by s, defaulting to metric when
+// s is empty. Any other value is an error.
+func ParseUnits(s string) (Units, error) {
+	switch Units(strings.ToLower(strings.TrimSpace(s))) {
+	case "", UnitsMetric:
+		return UnitsMetric, nil
+	case UnitsImperial:
+		return UnitsImperial, nil
+	default:
+		return "", fmt.Errorf("unknown units %q - use metric or imperial", s)
+	}
+}
+
+// TemperatureUnit is the temperature unit suffix of u: "C" or "F".
+func (u Units) TemperatureUnit() string {
+	if u == UnitsImperial {
+		return "F"
+	}
+	return "C"
+}
+
+// SpeedUnit is the wind-speed unit suffix of u: "km/h" or "mph".
+func (u Units) SpeedUnit() string {
+	if u == UnitsImperial {
+		return "mph"
+	}
+	return "km/h"
+}
+
+// FormatTemperature renders a Celsius reading in u, e.g. "64.4°F".
+func (u Units) FormatTemperature(celsius float64) string {
+	v, _ := ConvertTemperature(celsius, "C", u.TemperatureUnit())
+	return fmt.Sprintf("%.1f°%s", v, u.TemperatureUnit())
+}
+
+// FormatSpeed renders a km/h reading in u, e.g. "12.4 mph".
+func (u Units) FormatSpeed(kmh float64) string {
+	v, _ := ConvertSpeed(kmh, "km/h", u.SpeedUnit())
+	return fmt.Sprintf("%.1f %s", v, u.SpeedUnit())
+}
+
+// QuantityKind tells which kind of quantity a unit suffix measures.
+type QuantityKind int
+
+const (
+	KindNone QuantityKind = iota // bare number, no suffix
+	KindTemperature
+	KindSpeed
+)
+
+// unitSuffixes maps every accepted suffix (lowercased) to its canonical
+// unit, longest first so "km/h" is never read as a bare "h".
+var unitSuffixes = []struct {
+	suffix    string
+	canonical string
+	kind      QuantityKind
+}{
+	{"km/h", "km/h", KindSpeed},
+	{"kmh", "km/h", KindSpeed},
+	{"kph", "km/h", KindSpeed},
+	{"mph", "mph", KindSpeed},
+	{"m/s", "m/s", KindSpeed},
+	{"kn", "kn", KindSpeed},
+	{"°c", "C", KindTemperature},
+	{"°f", "F", KindTemperature},
+	{"c", "C", KindTemperature},
+	{"f", "F", KindTemperature},
+}
+
+// ParseQuantity parses a threshold written as a number with an optional
+// unit suffix ("50F", "20mph", "10 km/h", "12.5"). unit is the canonical
+// suffix ("C", "F", "km/h", "mph", "m/s", "kn"), or "" for a bare number.
+func ParseQuantity(s string) (value float64, unit string, kind QuantityKind, err error) {
+	trimmed := strings.TrimSpace(s)
+	lower := strings.ToLower(trimmed)
+	num := trimmed
+	for _, u := range unitSuffixes {
+		if strings.HasSuffix(lower, u.suffix) {
+			num = strings.TrimSpace(trimmed[:len(trimmed)-len(u.suffix)])
+			unit, kind = u.canonical, u.kind
+			break
+		}
+	}
+	value, err = strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, "", KindNone, fmt.Errorf("could not parse %q - use a number with an optional unit suffix (C, F, km/h, mph, m/s, kn)", s)
+	}
+	return value, unit, kind, nil
+}
+
+// ConvertTemperature converts v from one temperature unit ("C"/"F") to
+// another.
+func ConvertTemperature(v float64, from, to string) (float64, error) {
+	if from == to {
+		return v, nil
+	}
+	switch {
+	case from == "F" && to == "C":
+		return (v - 32) * 5 / 9, nil
+	case from == "C" && to == "F":
+		return v*9/5 + 32, nil
+	default:
+		return 0, fmt.Errorf("cannot convert temperature from %q to %q", from, to)
+	}
+}
+
+// speedToKmh holds each speed unit's factor to km/h.
+var speedToKmh = map[string]float64{
+	"km/h": 1,
+	"mph":  1.609344,
+	"m/s":  3.6,
+	"kn":   1.852,
+}
+
+// ConvertSpeed converts v from one speed unit ("km/h", "mph", "m/s", "kn")
+// to another.
+func ConvertSpeed(v float64, from, to string) (float64, error) {
+	fromFactor, ok := speedToKmh[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown speed unit %q", from)
+	}
+	toFactor, ok := speedToKmh[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown speed unit %q", to)
+	}
+	return v * fromFactor / toFactor, nil
+}
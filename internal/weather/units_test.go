@@ -0,0 +1,87 @@
+package weather
+
+import (
+	"math"
+	"testing"
+)
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 0.01 }
+
+func TestParseQuantity(t *testing.T) {
+	cases := []struct {
+		in   string
+		val  float64
+		unit string
+		kind QuantityKind
+	}{
+		{"12.5", 12.5, "", KindNone},
+		{"50F", 50, "F", KindTemperature},
+		{"10 °C", 10, "C", KindTemperature},
+		{"-4f", -4, "F", KindTemperature},
+		{"20mph", 20, "mph", KindSpeed},
+		{"30 km/h", 30, "km/h", KindSpeed},
+		{"30kmh", 30, "km/h", KindSpeed},
+		{"8m/s", 8, "m/s", KindSpeed},
+		{"15kn", 15, "kn", KindSpeed},
+	}
+	for _, c := range cases {
+		val, unit, kind, err := ParseQuantity(c.in)
+		if err != nil {
+			t.Errorf("ParseQuantity(%q): unexpected error: %v", c.in, err)
+			continue
+		}
+		if val != c.val || unit != c.unit || kind != c.kind {
+			t.Errorf("ParseQuantity(%q) = (%v, %q, %v), want (%v, %q, %v)", c.in, val, unit, kind, c.val, c.unit, c.kind)
+		}
+	}
+}
+
+func TestParseQuantityInvalid(t *testing.T) {
+	for _, in := range []string{"", "warm", "20 furlongs"} {
+		if _, _, _, err := ParseQuantity(in); err == nil {
+			t.Errorf("ParseQuantity(%q): expected error, got nil", in)
+		}
+	}
+}
+
+func TestConvertTemperature(t *testing.T) {
+	if got, _ := ConvertTemperature(50, "F", "C"); !approx(got, 10) {
+		t.Errorf("50F = %v C, want 10", got)
+	}
+	if got, _ := ConvertTemperature(100, "C", "F"); !approx(got, 212) {
+		t.Errorf("100C = %v F, want 212", got)
+	}
+}
+
+func TestConvertSpeed(t *testing.T) {
+	if got, _ := ConvertSpeed(10, "mph", "km/h"); !approx(got, 16.09) {
+		t.Errorf("10mph = %v km/h, want 16.09", got)
+	}
+	if got, _ := ConvertSpeed(10, "m/s", "km/h"); !approx(got, 36) {
+		t.Errorf("10m/s = %v km/h, want 36", got)
+	}
+	if _, err := ConvertSpeed(1, "furlongs", "km/h"); err == nil {
+		t.Error("expected error for an unknown speed unit")
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	if u, err := ParseUnits(""); err != nil || u != UnitsMetric {
+		t.Errorf("empty: got (%q, %v), want metric", u, err)
+	}
+	if u, err := ParseUnits("Imperial"); err != nil || u != UnitsImperial {
+		t.Errorf("Imperial: got (%q, %v), want imperial", u, err)
+	}
+	if _, err := ParseUnits("kelvin"); err == nil {
+		t.Error("expected error for unknown units")
+	}
+}
+
+func TestUnitsFormat(t *testing.T) {
+	if got := UnitsImperial.FormatTemperature(18); got != "64.4°F" {
+		t.Errorf("imperial 18C = %q, want 64.4°F", got)
+	}
+	if got := UnitsMetric.FormatSpeed(12); got != "12.0 km/h" {
+		t.Errorf("metric 12km/h = %q, want 12.0 km/h", got)
+	}
+}